@@ -17,12 +17,15 @@ package cmd
 import (
 	"flag"
 	"fmt"
+	"os"
 	"reflect"
 	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
+	"k8s.io/api/admissionregistration/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"istio.io/istio/galley/pkg/server"
 	"istio.io/istio/galley/pkg/server/settings"
@@ -40,6 +43,18 @@ func serverCmd() *cobra.Command {
 
 	var (
 		serverArgs = settings.DefaultArgs()
+
+		// validationObjectSelector is parsed into the ObjectSelector of the validation args.
+		validationObjectSelector string
+
+		// validationDecisionsToStdout sets the DecisionOutput of the validation args to stdout.
+		validationDecisionsToStdout bool
+
+		// validationDisabledRules are set in the DisabledRules of the validation args.
+		validationDisabledRules []string
+
+		// validationRuleOperations are parsed into the RuleOperations of the validation args.
+		validationRuleOperations map[string]string
 	)
 
 	svr := &cobra.Command{
@@ -77,6 +92,32 @@ func serverCmd() *cobra.Command {
 				serverArgs.ValidationArgs.KeyFile = serverArgs.CredentialOptions.KeyFile
 			}
 
+			if validationObjectSelector != "" {
+				selector, err := metav1.ParseToLabelSelector(validationObjectSelector)
+				if err != nil {
+					log.Fatalf("Invalid validation object selector %q: %v", validationObjectSelector, err)
+				}
+				serverArgs.ValidationArgs.ObjectSelector = selector
+			}
+			if validationDecisionsToStdout {
+				serverArgs.ValidationArgs.DecisionOutput = os.Stdout
+			}
+			if len(validationDisabledRules) > 0 {
+				serverArgs.ValidationArgs.DisabledRules = make(map[string]bool, len(validationDisabledRules))
+				for _, rule := range validationDisabledRules {
+					serverArgs.ValidationArgs.DisabledRules[rule] = true
+				}
+			}
+			if len(validationRuleOperations) > 0 {
+				serverArgs.ValidationArgs.RuleOperations = make(map[string][]v1beta1.OperationType, len(validationRuleOperations))
+				for resource, operations := range validationRuleOperations {
+					for _, operation := range strings.Split(operations, "+") {
+						serverArgs.ValidationArgs.RuleOperations[resource] = append(
+							serverArgs.ValidationArgs.RuleOperations[resource], v1beta1.OperationType(operation))
+					}
+				}
+			}
+
 			if !serverArgs.EnableServer && !serverArgs.ValidationArgs.EnableValidation {
 				log.Fatala("Galley must be running under at least one mode: server or validation")
 			}
@@ -166,11 +207,238 @@ func serverCmd() *cobra.Command {
 		"File that contains k8s validatingwebhookconfiguration yaml. Required if enable-validation is true.")
 	svr.PersistentFlags().UintVar(&serverArgs.ValidationArgs.Port, "validation-port",
 		serverArgs.ValidationArgs.Port, "HTTPS port of the validation service.")
+	svr.PersistentFlags().UintVar(&serverArgs.ValidationArgs.StatusPort, "validation-status-port",
+		serverArgs.ValidationArgs.StatusPort, "Plain HTTP port serving the validation status endpoints. Zero serves them on the validation port only.")
 	svr.PersistentFlags().BoolVar(&serverArgs.ValidationArgs.EnableValidation, "enable-validation", serverArgs.ValidationArgs.EnableValidation,
 		"Run galley validation mode")
 	svr.PersistentFlags().BoolVar(&serverArgs.ValidationArgs.EnableReconcileWebhookConfiguration,
 		"enable-reconcileWebhookConfiguration", serverArgs.ValidationArgs.EnableReconcileWebhookConfiguration,
 		"Enable reconciliation for webhook configuration.")
+	svr.PersistentFlags().BoolVar(&serverArgs.ValidationArgs.EnableMixerValidation,
+		"enable-mixer-validation", serverArgs.ValidationArgs.EnableMixerValidation,
+		"Validate mixer configuration. When disabled only pilot configuration is validated.")
+	svr.PersistentFlags().BoolVar(&serverArgs.ValidationArgs.DisableKeepAlives,
+		"validation-disable-keepalives", serverArgs.ValidationArgs.DisableKeepAlives,
+		"Disable HTTP keep-alives on the validation server.")
+	svr.PersistentFlags().IntVar(&serverArgs.ValidationArgs.ListenBacklog,
+		"validation-listen-backlog", serverArgs.ValidationArgs.ListenBacklog,
+		"Accept queue length of the validation server listener (Linux only). Zero uses the system default.")
+	svr.PersistentFlags().BoolVar(&serverArgs.ValidationArgs.AllowSkipAnnotation,
+		"validation-allow-skip-annotation", serverArgs.ValidationArgs.AllowSkipAnnotation,
+		"Allow resources to opt out of validation with the annotation specified by --validation-skip-annotation.")
+	svr.PersistentFlags().StringVar(&serverArgs.ValidationArgs.SkipAnnotation,
+		"validation-skip-annotation", serverArgs.ValidationArgs.SkipAnnotation,
+		"Annotation that, when set to true on a resource, skips its validation.")
+	svr.PersistentFlags().StringVar(&serverArgs.ValidationArgs.AcceptMessage,
+		"validation-accept-message", serverArgs.ValidationArgs.AcceptMessage,
+		"Status message returned in admission responses that allow a resource.")
+	svr.PersistentFlags().StringToStringVar(&serverArgs.ValidationArgs.GroupAliases,
+		"validation-group-aliases", serverArgs.ValidationArgs.GroupAliases,
+		"Comma-separated list of alias=group API group mappings applied before selecting the validator. Ex: 'old.istio.io=networking.istio.io'")
+	svr.PersistentFlags().DurationVar(&serverArgs.ValidationArgs.CertClockSkew,
+		"validation-cert-clock-skew", serverArgs.ValidationArgs.CertClockSkew,
+		"Clock skew tolerated when checking the validity period of the validation server certificate.")
+	svr.PersistentFlags().BoolVar(&serverArgs.ValidationArgs.EnableDebugEndpoints,
+		"validation-enable-debug", serverArgs.ValidationArgs.EnableDebugEndpoints,
+		"Serve the validation /debug endpoints alongside the validation readiness endpoint. Requires --validation-debug-token.")
+	svr.PersistentFlags().StringVar(&serverArgs.ValidationArgs.DebugToken,
+		"validation-debug-token", serverArgs.ValidationArgs.DebugToken,
+		"Bearer token required to access the validation /debug endpoints.")
+	svr.PersistentFlags().BoolVar(&serverArgs.ValidationArgs.SkipUnchangedSpecOnUpdate,
+		"validation-skip-unchanged-spec", serverArgs.ValidationArgs.SkipUnchangedSpecOnUpdate,
+		"Admit updates that only change resource metadata without validating them again.")
+	svr.PersistentFlags().BoolVar(&serverArgs.ValidationArgs.ProtectReferencedObjects,
+		"validation-protect-referenced-objects", serverArgs.ValidationArgs.ProtectReferencedObjects,
+		"Reject the deletion of gateways that are still referenced by virtual services.")
+	svr.PersistentFlags().StringVar(&serverArgs.ValidationArgs.RegoPolicyDir,
+		"validation-rego-policy-dir", serverArgs.ValidationArgs.RegoPolicyDir,
+		"Directory of Rego policies to evaluate against admitted resources.")
+	svr.PersistentFlags().DurationVar(&serverArgs.ValidationArgs.ReadyTimeout,
+		"validation-ready-timeout", serverArgs.ValidationArgs.ReadyTimeout,
+		"Maximum time the validation readiness handler takes to respond, or 0 for no bound.")
+	svr.PersistentFlags().StringVar(&serverArgs.ValidationArgs.PilotAdmissionPath,
+		"validation-pilot-path", serverArgs.ValidationArgs.PilotAdmissionPath,
+		"Path pilot configuration is admitted on.")
+	svr.PersistentFlags().StringVar(&serverArgs.ValidationArgs.MixerAdmissionPath,
+		"validation-mixer-path", serverArgs.ValidationArgs.MixerAdmissionPath,
+		"Path mixer configuration is admitted on.")
+	svr.PersistentFlags().DurationVar(&serverArgs.ValidationArgs.TerminationGracePeriod,
+		"validation-termination-grace-period", serverArgs.ValidationArgs.TerminationGracePeriod,
+		"Maximum time to drain in-flight admission requests on shutdown.")
+	svr.PersistentFlags().BoolVar(&serverArgs.ValidationArgs.StrictGateway,
+		"validation-strict-gateway", serverArgs.ValidationArgs.StrictGateway,
+		"Reject gateways that declare overlapping hosts on the same port in different servers.")
+	svr.PersistentFlags().BoolVar(&serverArgs.ValidationArgs.StrictServiceEntry,
+		"validation-strict-service-entry", serverArgs.ValidationArgs.StrictServiceEntry,
+		"Reject service entries with STATIC resolution and no endpoints, or DNS resolution and IP endpoints.")
+	svr.PersistentFlags().BoolVar(&serverArgs.ValidationArgs.StrictMetadataKeys,
+		"validation-strict-metadata-keys", serverArgs.ValidationArgs.StrictMetadataKeys,
+		"Reject resources whose label or annotation keys are not Kubernetes qualified names.")
+	svr.PersistentFlags().BoolVar(&serverArgs.ValidationArgs.StrictSidecar,
+		"validation-strict-sidecar", serverArgs.ValidationArgs.StrictSidecar,
+		"Reject a sidecar without workloadSelector in a namespace that already has one.")
+	svr.PersistentFlags().BoolVar(&serverArgs.ValidationArgs.StrictPortNaming,
+		"validation-strict-port-naming", serverArgs.ValidationArgs.StrictPortNaming,
+		"Reject gateway and service entry ports whose name is not prefixed by their protocol, e.g. http-web.")
+	svr.PersistentFlags().BoolVar(&serverArgs.ValidationArgs.StrictTLSSettings,
+		"validation-strict-tls-settings", serverArgs.ValidationArgs.StrictTLSSettings,
+		"Reject gateway and destination rule TLS settings that are inconsistent with their mode.")
+	svr.PersistentFlags().BoolVar(&serverArgs.ValidationArgs.StrictEnvoyFilter,
+		"validation-strict-envoy-filter", serverArgs.ValidationArgs.StrictEnvoyFilter,
+		"Reject envoy filters that use the deprecated workloadLabels or filters.")
+	svr.PersistentFlags().BoolVar(&serverArgs.ValidationArgs.EmitRejectionEvents,
+		"validation-emit-rejection-events", serverArgs.ValidationArgs.EmitRejectionEvents,
+		"Record a Warning event for resources rejected by validation.")
+	svr.PersistentFlags().DurationVar(&serverArgs.ValidationArgs.ReadinessHeartbeatInterval,
+		"validation-readiness-heartbeat-interval", serverArgs.ValidationArgs.ReadinessHeartbeatInterval,
+		"Interval at which to log that the validation webhook is still ready, or 0 to only log transitions.")
+	svr.PersistentFlags().BoolVar(&serverArgs.ValidationArgs.VerifyCertDNSNames,
+		"validation-verify-cert-dns-names", serverArgs.ValidationArgs.VerifyCertDNSNames,
+		"Fail at startup if the server certificate is not valid for the validation service DNS name.")
+	svr.PersistentFlags().BoolVar(&serverArgs.ValidationArgs.ReportAllErrors, "validation-report-all-errors",
+		serverArgs.ValidationArgs.ReportAllErrors, "Reject invalid resources with all of their validation errors rather than only the first.")
+	svr.PersistentFlags().StringVar(&serverArgs.ValidationArgs.ClientCAFile, "validation-client-ca-file",
+		serverArgs.ValidationArgs.ClientCAFile, "File containing the x509 CA bundle used to verify client certificates")
+	svr.PersistentFlags().BoolVar(&serverArgs.ValidationArgs.RequireClientCert, "validation-require-client-cert",
+		serverArgs.ValidationArgs.RequireClientCert, "Require client certificates signed by the client CA to call the validation webhook")
+	svr.PersistentFlags().StringVar(&serverArgs.ValidationArgs.ReadinessClientCertFile, "validation-readiness-client-cert-file",
+		serverArgs.ValidationArgs.ReadinessClientCertFile, "File containing the x509 certificate presented by the validation readiness check")
+	svr.PersistentFlags().StringVar(&serverArgs.ValidationArgs.ReadinessClientKeyFile, "validation-readiness-client-key-file",
+		serverArgs.ValidationArgs.ReadinessClientKeyFile, "File containing the private key of the validation readiness check certificate")
+	svr.PersistentFlags().StringVar(&serverArgs.ValidationArgs.EnforcementConfigMapName, "validation-enforcement-configmap",
+		serverArgs.ValidationArgs.EnforcementConfigMapName,
+		"Name of a ConfigMap in the validation namespace that sets the enforcement mode (on, warn or off) at runtime")
+	svr.PersistentFlags().StringVar(&serverArgs.ValidationArgs.EnforcementConfigMapKey, "validation-enforcement-configmap-key",
+		serverArgs.ValidationArgs.EnforcementConfigMapKey, "Key of the enforcement mode in the validation enforcement ConfigMap")
+	svr.PersistentFlags().StringVar(&serverArgs.ValidationArgs.SchemaRegistryURL, "validation-schema-registry-url",
+		serverArgs.ValidationArgs.SchemaRegistryURL, "URL of a schema registry serving additional OpenAPI v3 schemas to validate resources against")
+	svr.PersistentFlags().DurationVar(&serverArgs.ValidationArgs.SchemaRegistryRefreshInterval, "validation-schema-registry-refresh-interval",
+		serverArgs.ValidationArgs.SchemaRegistryRefreshInterval, "Interval at which schemas are refetched from the schema registry, or 0 to only fetch them at startup")
+	svr.PersistentFlags().IntVar(&serverArgs.ValidationArgs.PerNamespaceConcurrency, "validation-per-namespace-concurrency",
+		serverArgs.ValidationArgs.PerNamespaceConcurrency, "Maximum number of concurrent admission requests per namespace, or 0 for no limit")
+	svr.PersistentFlags().StringVar((*string)(&serverArgs.ValidationArgs.StrictnessProfile), "validation-strictness-profile",
+		string(serverArgs.ValidationArgs.StrictnessProfile),
+		"Preset of optional validation checks: permissive, standard or strict")
+	svr.PersistentFlags().BoolVar(&serverArgs.ValidationArgs.AuditLogDecisions, "validation-audit-log-decisions",
+		serverArgs.ValidationArgs.AuditLogDecisions, "Log each admission decision as a JSON line on the validation-audit log scope")
+	svr.PersistentFlags().StringVar(&validationObjectSelector, "validation-object-selector", "",
+		"Label selector, e.g. istio-validation=enabled, of the objects sent to the validation webhook")
+	svr.PersistentFlags().StringToStringVar(&validationRuleOperations, "validation-rule-operations", nil,
+		"Comma-separated list of resource=operations overriding the operations of the validatingwebhookconfiguration rules, "+
+			"with operations separated by +. Ex: 'gateways=CREATE,virtualservices=CREATE+UPDATE'")
+	svr.PersistentFlags().BoolVar(&serverArgs.ValidationArgs.DisableWebhookConfigEnforcement, "validation-disable-webhook-config-enforcement",
+		serverArgs.ValidationArgs.DisableWebhookConfigEnforcement, "Stop restoring the validatingwebhookconfiguration when it is deleted or modified")
+	svr.PersistentFlags().DurationVar(&serverArgs.ValidationArgs.WatchIdleRestart, "validation-watch-idle-restart",
+		serverArgs.ValidationArgs.WatchIdleRestart, "Restart the certificate, webhook configuration and validatingwebhookconfiguration "+
+			"watches after no events for this long. Zero disables the restarts.")
+	svr.PersistentFlags().BoolVar(&serverArgs.ValidationArgs.EnforceRequestDeadline, "validation-enforce-request-deadline",
+		serverArgs.ValidationArgs.EnforceRequestDeadline, "Reject admission requests that take longer than the timeoutSeconds of their webhook, "+
+			"less the request deadline margin")
+	svr.PersistentFlags().DurationVar(&serverArgs.ValidationArgs.RequestDeadlineMargin, "validation-request-deadline-margin",
+		serverArgs.ValidationArgs.RequestDeadlineMargin, "Time left to respond before the webhook timeout when enforcing request deadlines")
+	svr.PersistentFlags().DurationVar(&serverArgs.ValidationArgs.WebhookConfigResyncInterval, "validation-webhook-config-resync-interval",
+		serverArgs.ValidationArgs.WebhookConfigResyncInterval,
+		"Interval at which the enforced validatingwebhookconfiguration is reconciled, or 0 to only reconcile on changes")
+	svr.PersistentFlags().DurationVar(&serverArgs.ValidationArgs.CertExpiryWarningThreshold, "validation-cert-expiry-warning-threshold",
+		serverArgs.ValidationArgs.CertExpiryWarningThreshold,
+		"Log a warning when the validation server certificate expires within the threshold, or 0 to disable the warning")
+	svr.PersistentFlags().BoolVar(&serverArgs.ValidationArgs.CheckServiceReachability, "validation-check-service-reachability",
+		serverArgs.ValidationArgs.CheckServiceReachability,
+		"Check validation readiness through the validation service instead of only on the loopback address")
+	svr.PersistentFlags().StringSliceVar(&serverArgs.ValidationArgs.RedactedFields, "validation-redacted-fields",
+		serverArgs.ValidationArgs.RedactedFields,
+		"Comma-separated list of object fields, e.g. spec.servers[*].tls.privateKey, redacted from rejection reasons in logs and events")
+	svr.PersistentFlags().BoolVar(&serverArgs.ValidationArgs.DisableLivenessProbe, "validation-disable-liveness-probe",
+		serverArgs.ValidationArgs.DisableLivenessProbe, "Do not register the validation liveness probe")
+	svr.PersistentFlags().IntVar(&serverArgs.ValidationArgs.ResponseCompressionThreshold,
+		"validation-response-compression-threshold", serverArgs.ValidationArgs.ResponseCompressionThreshold,
+		"Size in bytes above which admission responses are gzip encoded if accepted by the API server. Zero disables compression.")
+	svr.PersistentFlags().IntVar(&serverArgs.ValidationArgs.MaxReportedErrors, "validation-max-reported-errors",
+		serverArgs.ValidationArgs.MaxReportedErrors,
+		"Maximum number of validation errors reported with --validation-report-all-errors. Zero reports all errors.")
+	svr.PersistentFlags().IntVar(&serverArgs.ValidationArgs.ResourceVersionCacheSize, "validation-resource-version-cache-size",
+		serverArgs.ValidationArgs.ResourceVersionCacheSize,
+		"Number of objects whose last accepted resourceVersion is cached to skip repeated validation of unchanged updates. "+
+			"Zero disables the cache.")
+	svr.PersistentFlags().BoolVar(&serverArgs.ValidationArgs.DeduplicateConcurrentRequests,
+		"validation-deduplicate-concurrent-requests", serverArgs.ValidationArgs.DeduplicateConcurrentRequests,
+		"Validate concurrent identical admission requests, e.g. retries, once and share the response.")
+	svr.PersistentFlags().StringVar(&serverArgs.ValidationArgs.BindAddress, "validation-bind-address",
+		serverArgs.ValidationArgs.BindAddress, "IP address the validation admission server listens on. Empty listens on all interfaces.")
+	svr.PersistentFlags().StringSliceVar(&serverArgs.ValidationArgs.RequiredLabels, "validation-required-labels",
+		serverArgs.ValidationArgs.RequiredLabels, "Labels that created and updated Istio resources must carry.")
+	svr.PersistentFlags().StringSliceVar(&serverArgs.ValidationArgs.RequiredLabelsExemptNamespaces,
+		"validation-required-labels-exempt-namespaces", serverArgs.ValidationArgs.RequiredLabelsExemptNamespaces,
+		"Namespaces whose resources are not required to carry --validation-required-labels.")
+	svr.PersistentFlags().IntVar(&serverArgs.ValidationArgs.ValidatorErrorRestartThreshold, "validation-error-restart-threshold",
+		serverArgs.ValidationArgs.ValidatorErrorRestartThreshold,
+		"Number of consecutive internal validator errors after which the liveness probe fails. Zero disables the check.")
+	svr.PersistentFlags().DurationVar(&serverArgs.ValidationArgs.ValidatorErrorRestartWindow, "validation-error-restart-window",
+		serverArgs.ValidationArgs.ValidatorErrorRestartWindow,
+		"Interval within which the consecutive errors of --validation-error-restart-threshold must occur.")
+	svr.PersistentFlags().Float64Var(&serverArgs.ValidationArgs.DebugSampleRate, "validation-debug-sample-rate",
+		serverArgs.ValidationArgs.DebugSampleRate,
+		"Fraction, from 0 to 1, of admission requests whose debug logs are logged when the validation scope is at debug level.")
+	svr.PersistentFlags().StringVar(&serverArgs.ValidationArgs.GRPCAddress, "validation-grpc-address",
+		serverArgs.ValidationArgs.GRPCAddress, "Address, e.g. :9444, of the gRPC server exposing the validators. Empty disables it.")
+	svr.PersistentFlags().StringVar(&serverArgs.ValidationArgs.ReadinessHTTPMethod, "validation-readiness-http-method",
+		serverArgs.ValidationArgs.ReadinessHTTPMethod, "HTTP method, GET or HEAD, of the readiness check of the validation webhook.")
+	svr.PersistentFlags().BoolVar(&validationDecisionsToStdout, "validation-decisions-to-stdout", false,
+		"Write each admission decision of the validation webhook to stdout as a JSON line.")
+	svr.PersistentFlags().StringSliceVar(&validationDisabledRules, "validation-disabled-rules", nil,
+		"Optional validation rules to turn off, e.g. gateway-hosts. The disabledRules key of the enforcement ConfigMap overrides them.")
+	svr.PersistentFlags().StringToStringVar(&serverArgs.ValidationArgs.ViolationCodes, "validation-violation-codes",
+		serverArgs.ValidationArgs.ViolationCodes, "Comma-separated list of check=code mappings replacing the violation codes "+
+			"listed in rejections. Ex: 'gateway-hosts=ACME0001'")
+	svr.PersistentFlags().BoolVar(&serverArgs.ValidationArgs.PreflightAPICheck, "validation-preflight-api-check",
+		serverArgs.ValidationArgs.PreflightAPICheck, "Check that the API server is reachable before starting the validation webhook.")
+	svr.PersistentFlags().BoolVar(&serverArgs.ValidationArgs.RejectEmptySpec, "validation-reject-empty-spec",
+		serverArgs.ValidationArgs.RejectEmptySpec, "Reject virtual services, destination rules, gateways, service entries, "+
+			"envoy filters and sidecars with an empty spec.")
+	svr.PersistentFlags().BoolVar(&serverArgs.ValidationArgs.StandbyMode, "validation-standby-mode",
+		serverArgs.ValidationArgs.StandbyMode, "Serve the validation webhook without registering the validatingwebhookconfiguration. "+
+			"The standby key of the enforcement ConfigMap switches the mode at runtime.")
+	svr.PersistentFlags().BoolVar(&serverArgs.ValidationArgs.VerifyNamespaceExists, "validation-verify-namespace-exists",
+		serverArgs.ValidationArgs.VerifyNamespaceExists, "Reject objects whose namespace does not exist.")
+	svr.PersistentFlags().BoolVar(&serverArgs.ValidationArgs.AllowNamespaceMaintenanceMode, "validation-allow-namespace-maintenance-mode",
+		serverArgs.ValidationArgs.AllowNamespaceMaintenanceMode,
+		"Validate the objects of namespaces annotated with validation.istio.io/mode=warn warn-only")
+	svr.PersistentFlags().BoolVar(&serverArgs.ValidationArgs.CheckGatewayCredentials, "validation-check-gateway-credentials",
+		serverArgs.ValidationArgs.CheckGatewayCredentials, "Warn about Gateways whose credentialName references a Secret that does not exist.")
+	svr.PersistentFlags().BoolVar(&serverArgs.ValidationArgs.RejectMissingGatewayCredentials, "validation-reject-missing-gateway-credentials",
+		serverArgs.ValidationArgs.RejectMissingGatewayCredentials, "Reject rather than warn about the Gateways of --validation-check-gateway-credentials.")
+	svr.PersistentFlags().BoolVar(&serverArgs.ValidationArgs.DegradeReferenceChecks, "validation-degrade-reference-checks",
+		serverArgs.ValidationArgs.DegradeReferenceChecks, "Admit objects with a warning when a check that lists objects "+
+			"on the API server cannot reach it.")
+	svr.PersistentFlags().StringVar((*string)(&serverArgs.ValidationArgs.UnhandledOperationPolicy),
+		"validation-unhandled-operation-policy", string(serverArgs.ValidationArgs.UnhandledOperationPolicy),
+		"Response to admission operations other than CREATE, UPDATE and DELETE, accept or reject.")
+	svr.PersistentFlags().StringVar((*string)(&serverArgs.ValidationArgs.DefaultDecisionForUnmatched),
+		"validation-default-decision-for-unmatched", string(serverArgs.ValidationArgs.DefaultDecisionForUnmatched),
+		"Response to created and updated objects of a kind without validation, accept or reject. By default "+
+			"pilot objects are rejected and mixer objects are accepted.")
+	svr.PersistentFlags().BoolVar(&serverArgs.ValidationArgs.EnforceGlobalNameUniqueness, "validation-enforce-global-name-uniqueness",
+		serverArgs.ValidationArgs.EnforceGlobalNameUniqueness, "Reject the creation of an object whose name is used by an object "+
+			"of the same kind in another namespace, for the kinds of validation-global-name-uniqueness-kinds.")
+	svr.PersistentFlags().StringSliceVar(&serverArgs.ValidationArgs.GlobalNameUniquenessKinds, "validation-global-name-uniqueness-kinds",
+		serverArgs.ValidationArgs.GlobalNameUniquenessKinds, "Kinds whose names must be unique across namespaces, e.g. Gateway.")
+	svr.PersistentFlags().StringSliceVar(&serverArgs.ValidationArgs.AdditionalCACertFiles, "validation-additional-ca-cert-files",
+		serverArgs.ValidationArgs.AdditionalCACertFiles, "CA bundle files appended to the caBundle of the validatingwebhookconfiguration.")
+	svr.PersistentFlags().StringSliceVar(&serverArgs.ValidationArgs.CABundleSecrets, "validation-ca-bundle-secrets",
+		serverArgs.ValidationArgs.CABundleSecrets, "Secrets, as namespace/name, whose ca.crt is appended to the caBundle "+
+			"of the validatingwebhookconfiguration.")
+	svr.PersistentFlags().Int32Var(&serverArgs.ValidationArgs.PanicResponseCode, "validation-panic-response-code",
+		serverArgs.ValidationArgs.PanicResponseCode, "HTTP status code of the rejection of requests whose validation panicked.")
+	svr.PersistentFlags().StringVar((*string)(&serverArgs.ValidationArgs.PanicResponseReason), "validation-panic-response-reason",
+		string(serverArgs.ValidationArgs.PanicResponseReason), "Status reason of the rejection of requests whose validation panicked.")
+	svr.PersistentFlags().StringVar(&serverArgs.ValidationArgs.SchemaSnapshotFile,
+		"validation-schema-snapshot-file", serverArgs.ValidationArgs.SchemaSnapshotFile,
+		"File of pilot schemas to validate against instead of the built-in schemas, e.g. to reproduce past decisions.")
+	svr.PersistentFlags().BoolVar(&serverArgs.ValidationArgs.ObjectCache, "validation-object-cache",
+		serverArgs.ValidationArgs.ObjectCache, "Look up the objects listed by reference, sidecar and name uniqueness checks "+
+			"in informer caches rather than on the API server.")
+	svr.PersistentFlags().DurationVar(&serverArgs.ValidationArgs.ObjectCacheResyncPeriod, "validation-object-cache-resync-period",
+		serverArgs.ValidationArgs.ObjectCacheResyncPeriod, "Resync period of the informers of --validation-object-cache. Zero disables the resync.")
 	svr.PersistentFlags().StringVar(&serverArgs.ValidationArgs.DeploymentAndServiceNamespace, "deployment-namespace", "istio-system",
 		"Namespace of the deployment for the validation pod")
 	svr.PersistentFlags().StringVar(&serverArgs.ValidationArgs.DeploymentName, "deployment-name", "istio-galley",
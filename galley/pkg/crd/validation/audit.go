@@ -0,0 +1,77 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"encoding/json"
+
+	"istio.io/pkg/log"
+)
+
+// auditScope is the dedicated scope of the decision audit log, so that it can be
+// routed separately from the validation logs.
+var auditScope = log.RegisterScope("validation-audit", "Validation admission decision audit log", 0)
+
+const (
+	auditDecisionAllowed = "allowed"
+	auditDecisionDenied  = "denied"
+)
+
+// auditEntry is the JSON audit log entry of an admission decision.
+type auditEntry struct {
+	User      string `json:"user"`
+	Group     string `json:"group"`
+	Version   string `json:"version"`
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Operation string `json:"operation"`
+	Decision  string `json:"decision"`
+	Reason    string `json:"reason,omitempty"`
+	RequestID string `json:"requestID,omitempty"`
+	Schema    string `json:"schemaVersion,omitempty"`
+}
+
+// auditDecision logs the decision as a single JSON line. It is called on the
+// admission path rather than by the decision sink, so that no entry is dropped.
+func auditDecision(record DecisionRecord) {
+	line, err := auditLine(record)
+	if err != nil {
+		scope.Errorf("cannot encode audit log entry for %s %s/%s: %v", record.Kind.Kind, record.Namespace, record.Name, err)
+		return
+	}
+	auditScope.Info(string(line))
+}
+
+// auditLine returns the JSON audit log entry of the decision.
+func auditLine(record DecisionRecord) ([]byte, error) {
+	entry := auditEntry{
+		User:      record.User,
+		Group:     record.Kind.Group,
+		Version:   record.Kind.Version,
+		Kind:      record.Kind.Kind,
+		Namespace: record.Namespace,
+		Name:      record.Name,
+		Operation: string(record.Operation),
+		Decision:  auditDecisionDenied,
+		Reason:    record.Error,
+		RequestID: record.RequestID,
+		Schema:    record.SchemaVersion,
+	}
+	if record.Allowed {
+		entry.Decision = auditDecisionAllowed
+	}
+	return json.Marshal(entry)
+}
@@ -0,0 +1,84 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestAuditLine(t *testing.T) {
+	line, err := auditLine(DecisionRecord{
+		Kind:          metav1.GroupVersionKind{Group: "networking.istio.io", Version: "v1alpha3", Kind: "Gateway"},
+		Name:          "ingress",
+		Namespace:     "default",
+		Operation:     admissionv1beta1.Create,
+		User:          "alice",
+		RequestID:     "abc-123",
+		Error:         "configuration is invalid",
+		SchemaVersion: "sha256:0123456789abcdef",
+	})
+	if err != nil {
+		t.Fatalf("auditLine() failed: %v", err)
+	}
+
+	var got map[string]string
+	if err := json.Unmarshal(line, &got); err != nil {
+		t.Fatalf("audit line %s is not a JSON object: %v", line, err)
+	}
+	want := map[string]string{
+		"user":          "alice",
+		"group":         "networking.istio.io",
+		"version":       "v1alpha3",
+		"kind":          "Gateway",
+		"namespace":     "default",
+		"name":          "ingress",
+		"operation":     "CREATE",
+		"decision":      "denied",
+		"reason":        "configuration is invalid",
+		"requestID":     "abc-123",
+		"schemaVersion": "sha256:0123456789abcdef",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v want %v", got, want)
+	}
+}
+
+func TestRecordDecisions_AuditWithoutSink(t *testing.T) {
+	wh := &Webhook{auditDecisions: true}
+
+	var admitted bool
+	admit := wh.recordDecisions(func(context.Context, *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+		admitted = true
+		return &admissionv1beta1.AdmissionResponse{Allowed: true}
+	})
+	resp := admit(context.Background(), &admissionv1beta1.AdmissionRequest{
+		Kind:      metav1.GroupVersionKind{Kind: "mock"},
+		Namespace: "default",
+		Object:    runtime.RawExtension{Raw: makePilotConfig(t, 0, true, false)},
+		Operation: admissionv1beta1.Create,
+		UserInfo:  authenticationv1.UserInfo{Username: "alice"},
+	})
+	if !admitted || !resp.Allowed {
+		t.Fatalf("got admitted %v response %v want allowed", admitted, resp)
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"k8s.io/api/admissionregistration/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// caBundleSecretKey is the key of the CA bundle in the CABundleSecrets.
+const caBundleSecretKey = "ca.crt"
+
+// parseCABundleSecret splits a namespace/name reference to a CA bundle Secret.
+func parseCABundleSecret(ref string) (namespace, name string, err error) {
+	parts := strings.Split(ref, "/")
+	if len(parts) != 2 || !isDNS1123Label(parts[0]) || parts[1] == "" {
+		return "", "", fmt.Errorf("invalid CA bundle secret %q, want namespace/name", ref)
+	}
+	return parts[0], parts[1], nil
+}
+
+// parseCABundle returns the certificates of the PEM bundle read from source. It
+// returns an error if the bundle has no certificate, or a block that is not a
+// valid x509 certificate.
+func parseCABundle(source string, bundle []byte) ([]*pem.Block, error) {
+	var blocks []*pem.Block
+	for rest := bytes.TrimSpace(bundle); len(rest) > 0; rest = bytes.TrimSpace(rest) {
+		var block *pem.Block
+		if block, rest = pem.Decode(rest); block == nil {
+			return nil, fmt.Errorf("ca bundle %v: could not decode pem", source)
+		}
+		if block.Type != "CERTIFICATE" {
+			return nil, fmt.Errorf("ca bundle %v contains wrong pem type: %q", source, block.Type)
+		}
+		if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+			return nil, fmt.Errorf("ca bundle %v contains invalid x509 certificate: %v", source, err)
+		}
+		blocks = append(blocks, block)
+	}
+	if len(blocks) == 0 {
+		return nil, fmt.Errorf("ca bundle %v contains no certificate", source)
+	}
+	return blocks, nil
+}
+
+// hasAdditionalCABundles returns true if the caBundle is assembled from more
+// than the CACertFile.
+func (p *WebhookParameters) hasAdditionalCABundles() bool {
+	return len(p.AdditionalCACertFiles) > 0 || len(p.CABundleSecrets) > 0
+}
+
+// loadAdditionalCABundles reads the AdditionalCACertFiles and CABundleSecrets.
+func (whc *WebhookConfigController) loadAdditionalCABundles() (map[string][]byte, []string, error) {
+	p := whc.webhookParameters
+	bundles := make(map[string][]byte)
+	var sources []string
+	for _, file := range p.AdditionalCACertFiles {
+		bundle, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read ca bundle from %v: %v", file, err)
+		}
+		bundles[file] = bundle
+		sources = append(sources, file)
+	}
+	for _, ref := range p.CABundleSecrets {
+		namespace, name, err := parseCABundleSecret(ref)
+		if err != nil {
+			return nil, nil, err
+		}
+		secret, err := p.Clientset.CoreV1().Secrets(namespace).Get(name, metav1.GetOptions{})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read ca bundle secret %v: %v", ref, err)
+		}
+		bundle, ok := secret.Data[caBundleSecretKey]
+		if !ok {
+			return nil, nil, fmt.Errorf("ca bundle secret %v has no %q key", ref, caBundleSecretKey)
+		}
+		source := "secret " + ref
+		bundles[source] = bundle
+		sources = append(sources, source)
+	}
+	return bundles, sources, nil
+}
+
+// mergeCABundles concatenates the certificates of the bundles, in order,
+// omitting the certificates that appear in an earlier bundle.
+func mergeCABundles(bundles map[string][]byte, sources []string) ([]byte, error) {
+	var merged bytes.Buffer
+	seen := make(map[string]bool)
+	for _, source := range sources {
+		blocks, err := parseCABundle(source, bundles[source])
+		if err != nil {
+			return nil, err
+		}
+		for _, block := range blocks {
+			if seen[string(block.Bytes)] {
+				continue
+			}
+			seen[string(block.Bytes)] = true
+			if err := pem.Encode(&merged, &pem.Block{Type: block.Type, Bytes: block.Bytes}); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return merged.Bytes(), nil
+}
+
+// addCABundles patches the caBundle of the webhooks, loaded from the CACertFile,
+// with the certificates of the AdditionalCACertFiles and CABundleSecrets.
+func (whc *WebhookConfigController) addCABundles(config *v1beta1.ValidatingWebhookConfiguration) error {
+	if len(config.Webhooks) == 0 {
+		return nil
+	}
+	bundles, sources, err := whc.loadAdditionalCABundles()
+	if err != nil {
+		return err
+	}
+	primary := whc.webhookParameters.CACertFile
+	bundles[primary] = config.Webhooks[0].ClientConfig.CABundle
+	caBundle, err := mergeCABundles(bundles, append([]string{primary}, sources...))
+	if err != nil {
+		return err
+	}
+	for i := range config.Webhooks {
+		config.Webhooks[i].ClientConfig.CABundle = caBundle
+	}
+	return nil
+}
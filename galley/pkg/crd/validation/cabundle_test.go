@@ -0,0 +1,160 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"istio.io/istio/pkg/mcp/testing/testcerts"
+)
+
+// concatPEM concatenates PEM bundles, which may lack a trailing newline.
+func concatPEM(bundles ...[]byte) []byte {
+	return bytes.Join(bundles, []byte("\n"))
+}
+
+func countCertificates(t *testing.T, bundle []byte) int {
+	t.Helper()
+	blocks, err := parseCABundle("merged", bundle)
+	if err != nil {
+		t.Fatalf("merged bundle is invalid: %v", err)
+	}
+	return len(blocks)
+}
+
+func TestParseCABundle(t *testing.T) {
+	cases := []struct {
+		name      string
+		bundle    []byte
+		wantCerts int
+		wantErr   string
+	}{
+		{name: "single cert", bundle: testcerts.CACert, wantCerts: 1},
+		{name: "concatenated certs", bundle: concatPEM(testcerts.CACert, testcerts.RotatedCert), wantCerts: 2},
+		{name: "empty", bundle: []byte("\n"), wantErr: "contains no certificate"},
+		{name: "trailing garbage", bundle: concatPEM(testcerts.CACert, []byte("garbage")), wantErr: "could not decode pem"},
+		{name: "wrong type", bundle: testcerts.ServerKey, wantErr: "wrong pem type"},
+		{name: "invalid x509", bundle: testcerts.BadCert, wantErr: "invalid x509 certificate"},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			blocks, err := parseCABundle("test", c.bundle)
+			if c.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+					t.Fatalf("got error %v want %q", err, c.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("got unexpected error: %v", err)
+			}
+			if len(blocks) != c.wantCerts {
+				t.Fatalf("got %d certificates want %d", len(blocks), c.wantCerts)
+			}
+		})
+	}
+}
+
+func TestParseCABundleSecret(t *testing.T) {
+	for _, ref := range []string{"istio-system", "istio-system/", "Istio_System/ca", "a/b/c"} {
+		if _, _, err := parseCABundleSecret(ref); err == nil {
+			t.Fatalf("parseCABundleSecret(%q) succeeded, want error", ref)
+		}
+	}
+	namespace, name, err := parseCABundleSecret("istio-system/remote-ca")
+	if err != nil || namespace != "istio-system" || name != "remote-ca" {
+		t.Fatalf("got %q %q %v want istio-system remote-ca", namespace, name, err)
+	}
+}
+
+func TestAddCABundles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "galley_validation_cabundle")
+	if err != nil {
+		t.Fatalf("TempDir() failed: %v", err)
+	}
+	defer os.RemoveAll(dir) // nolint: errcheck
+	remoteFile := filepath.Join(dir, "remote-ca.pem")
+	// the remote bundle repeats the local CA, which is omitted
+	if err := ioutil.WriteFile(remoteFile, concatPEM(testcerts.RotatedCert, testcerts.CACert), 0644); err != nil {
+		t.Fatalf("WriteFile(%v) failed: %v", remoteFile, err)
+	}
+
+	client := fake.NewSimpleClientset(
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "istio-system", Name: "remote-ca"},
+			Data:       map[string][]byte{caBundleSecretKey: testcerts.ServerCert},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "istio-system", Name: "no-ca"},
+			Data:       map[string][]byte{"tls.crt": testcerts.ServerCert},
+		},
+	)
+
+	cases := []struct {
+		name      string
+		files     []string
+		secrets   []string
+		wantCerts int
+		wantErr   string
+	}{
+		{name: "file", files: []string{remoteFile}, wantCerts: 2},
+		{name: "file and secret", files: []string{remoteFile}, secrets: []string{"istio-system/remote-ca"}, wantCerts: 3},
+		{name: "missing file", files: []string{filepath.Join(dir, "missing.pem")}, wantErr: "failed to read ca bundle"},
+		{name: "missing secret", secrets: []string{"istio-system/missing"}, wantErr: "failed to read ca bundle secret"},
+		{name: "secret without key", secrets: []string{"istio-system/no-ca"}, wantErr: `has no "ca.crt" key`},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			whc := &WebhookConfigController{webhookParameters: &WebhookParameters{
+				CACertFile:            "ca.pem",
+				AdditionalCACertFiles: c.files,
+				CABundleSecrets:       c.secrets,
+				Clientset:             client,
+			}}
+			config := dummyConfig.DeepCopy()
+			for i := range config.Webhooks {
+				config.Webhooks[i].ClientConfig.CABundle = testcerts.CACert
+			}
+
+			err := whc.addCABundles(config)
+			if c.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+					t.Fatalf("got error %v want %q", err, c.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("got unexpected error: %v", err)
+			}
+			for _, webhook := range config.Webhooks {
+				if got := countCertificates(t, webhook.ClientConfig.CABundle); got != c.wantCerts {
+					t.Fatalf("webhook %v got %d certificates want %d", webhook.Name, got, c.wantCerts)
+				}
+			}
+		})
+	}
+}
@@ -22,11 +22,12 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
-	"path/filepath"
 	"reflect"
+	"strings"
 	"time"
 
 	"github.com/ghodss/yaml"
+	"github.com/hashicorp/go-multierror"
 	"github.com/howeyc/fsnotify"
 	"k8s.io/api/admissionregistration/v1beta1"
 	corev1 "k8s.io/api/core/v1"
@@ -63,8 +64,21 @@ type WebhookConfigController struct {
 	ownerRefs            []metav1.OwnerReference
 	webhookConfiguration *v1beta1.ValidatingWebhookConfiguration
 
-	// test hook for informers
-	createInformerWebhookSource createInformerWebhookSource
+	// registered is true once the configuration has been created, updated or
+	// found up to date.
+	registered bool
+
+	// onError is called with the failures to reconcile the configuration, if set.
+	// It must not block.
+	onError func(error)
+
+	// standby is true while the configuration is left to another Galley, see
+	// StandbyMode. It is only accessed by the reconcile goroutine.
+	standby bool
+
+	// test hooks for informers
+	createInformerWebhookSource   createInformerWebhookSource
+	createInformerConfigMapSource createInformerConfigMapSource
 }
 
 // Run an informer that watches the current webhook configuration
@@ -95,10 +109,17 @@ func (whc *WebhookConfigController) monitorWebhookChanges(stopC <-chan struct{})
 	return webhookChangedCh
 }
 
+func (whc *WebhookConfigController) reportError(err error) {
+	if whc.onError != nil {
+		whc.onError(err)
+	}
+}
+
 func (whc *WebhookConfigController) createOrUpdateWebhookConfig() (retry bool) {
 	if whc.webhookConfiguration == nil {
 		scope.Error("validatingwebhookconfiguration update failed: no configuration loaded")
 		reportValidationConfigUpdateError(errors.New("no configuration loaded"))
+		reportWebhookRegisterError()
 		return false
 	}
 
@@ -106,9 +127,21 @@ func (whc *WebhookConfigController) createOrUpdateWebhookConfig() (retry bool) {
 	updated, err := createOrUpdateWebhookConfigHelper(client, whc.webhookConfiguration)
 	if err != nil {
 		scope.Errorf("%v validatingwebhookconfiguration update failed: %v", whc.webhookConfiguration.Name, err)
+		whc.reportError(fmt.Errorf("%v validatingwebhookconfiguration update failed: %v", whc.webhookConfiguration.Name, err))
 		reportValidationConfigUpdateError(fmt.Errorf("createOrUpdate failed: %v", kerrors.ReasonForError(err)))
+		reportWebhookRegisterError()
+		// a failed update leaves the current configuration registered, so only
+		// report it as unregistered if it is known to be absent
+		if _, getErr := client.Get(whc.webhookConfiguration.Name, metav1.GetOptions{}); k8serrors.IsNotFound(getErr) {
+			reportWebhookRegistered(false)
+		}
 		return true
 	}
+	reportWebhookRegistered(true)
+	if !whc.registered {
+		whc.registered = true
+		lifecycleObserver(whc.webhookParameters.LifecycleObserver).WebhookRegistered()
+	}
 
 	if updated {
 		scope.Infof("%v validatingwebhookconfiguration updated", whc.webhookConfiguration.Name)
@@ -156,10 +189,12 @@ func (whc *WebhookConfigController) deleteWebhookConfig() (retry bool) {
 	deleted, err := deleteWebhookConfigHelper(client, whc.webhookParameters.WebhookName)
 	if err != nil {
 		scope.Errorf("%v validatingwebhookconfiguration delete failed: %v", whc.webhookParameters.WebhookName, err)
+		whc.reportError(fmt.Errorf("%v validatingwebhookconfiguration delete failed: %v", whc.webhookParameters.WebhookName, err))
 		reportValidationConfigDeleteError(fmt.Errorf("delete failed: %v", kerrors.ReasonForError(err)))
 		return true
 	}
 	scope.Infof("Delete %v validatingwebhookconfiguration is %v", whc.webhookParameters.WebhookName, deleted)
+	reportWebhookRegistered(false)
 	return false
 }
 
@@ -192,6 +227,22 @@ func (whc *WebhookConfigController) rebuildWebhookConfig() error {
 	if err != nil {
 		reportValidationConfigLoadError(err)
 		scope.Errorf("validatingwebhookconfiguration (re)load failed: %v", err)
+		whc.reportError(fmt.Errorf("validatingwebhookconfiguration (re)load failed: %v", err))
+		return err
+	}
+	if whc.webhookParameters.hasAdditionalCABundles() {
+		if err := whc.addCABundles(webhookConfig); err != nil {
+			reportValidationConfigLoadError(err)
+			scope.Errorf("validatingwebhookconfiguration caBundle (re)load failed: %v", err)
+			whc.reportError(fmt.Errorf("validatingwebhookconfiguration caBundle (re)load failed: %v", err))
+			return err
+		}
+	}
+	customizeWebhookConfig(webhookConfig, whc.webhookParameters)
+	if err := validateGeneratedConfig(webhookConfig); err != nil {
+		reportValidationConfigLoadError(err)
+		scope.Errorf("validatingwebhookconfiguration %v is invalid: %v", webhookConfig.Name, err)
+		whc.reportError(fmt.Errorf("validatingwebhookconfiguration %v is invalid: %v", webhookConfig.Name, err))
 		return err
 	}
 	whc.webhookConfiguration = webhookConfig
@@ -209,6 +260,178 @@ func (whc *WebhookConfigController) rebuildWebhookConfig() error {
 	return nil
 }
 
+// customizeWebhookConfig applies the webhook parameters to the webhooks of the
+// configuration read from the WebhookConfigFile.
+func customizeWebhookConfig(config *v1beta1.ValidatingWebhookConfiguration, p *WebhookParameters) {
+	setAdmissionPaths(config, p)
+	setObjectSelector(config, p.ObjectSelector)
+	setRuleOperations(config, p.RuleOperations)
+}
+
+// validateGeneratedConfig checks the validatingwebhookconfiguration for errors
+// that the API server would reject it for, so that they are reported before
+// registration.
+func validateGeneratedConfig(config *v1beta1.ValidatingWebhookConfiguration) error {
+	var errs *multierror.Error
+	if len(config.Webhooks) == 0 {
+		errs = multierror.Append(errs, errors.New("no webhooks"))
+	}
+
+	names := make(map[string]bool, len(config.Webhooks))
+	for i := range config.Webhooks {
+		webhook := &config.Webhooks[i]
+		fail := func(format string, args ...interface{}) {
+			errs = multierror.Append(errs, fmt.Errorf("webhook[%d] %q: %s", i, webhook.Name, fmt.Sprintf(format, args...)))
+		}
+
+		if webhook.Name == "" {
+			fail("name is empty")
+		} else if names[webhook.Name] {
+			fail("duplicate name")
+		}
+		names[webhook.Name] = true
+
+		if len(webhook.Rules) == 0 {
+			fail("no rules")
+		}
+		for j, rule := range webhook.Rules {
+			if len(rule.Operations) == 0 {
+				fail("rule[%d] has no operations", j)
+			}
+			for _, operation := range rule.Operations {
+				if !knownOperations[operation] {
+					fail("rule[%d] has unknown operation %q", j, operation)
+				}
+			}
+			if len(rule.APIGroups) == 0 {
+				fail("rule[%d] has no apiGroups", j)
+			}
+			if len(rule.APIVersions) == 0 {
+				fail("rule[%d] has no apiVersions", j)
+			}
+			if len(rule.Resources) == 0 {
+				fail("rule[%d] has no resources", j)
+			}
+		}
+
+		clientConfig := webhook.ClientConfig
+		if (clientConfig.URL == nil) == (clientConfig.Service == nil) {
+			fail("clientConfig must specify exactly one of url and service")
+		}
+		if service := clientConfig.Service; service != nil {
+			if service.Name == "" || service.Namespace == "" {
+				fail("clientConfig.service must specify a name and namespace")
+			}
+			if service.Path != nil && !strings.HasPrefix(*service.Path, "/") {
+				fail("clientConfig.service.path %q must begin with /", *service.Path)
+			}
+			if service.Port != nil {
+				if err := validatePort(int(*service.Port)); err != nil {
+					fail("clientConfig.service.port: %v", err)
+				}
+			}
+		}
+		if len(clientConfig.CABundle) == 0 {
+			fail("clientConfig.caBundle is empty")
+		}
+
+		if webhook.NamespaceSelector != nil {
+			if _, err := metav1.LabelSelectorAsSelector(webhook.NamespaceSelector); err != nil {
+				fail("invalid namespaceSelector: %v", err)
+			}
+		}
+		if webhook.ObjectSelector != nil {
+			if _, err := metav1.LabelSelectorAsSelector(webhook.ObjectSelector); err != nil {
+				fail("invalid objectSelector: %v", err)
+			}
+		}
+		if timeout := webhook.TimeoutSeconds; timeout != nil && (*timeout < 1 || *timeout > 30) {
+			fail("timeoutSeconds %d must be in the range 1..30", *timeout)
+		}
+	}
+	return errs.ErrorOrNil()
+}
+
+// setAdmissionPaths registers the webhooks served on the default admission paths
+// with the configured admission paths.
+func setAdmissionPaths(config *v1beta1.ValidatingWebhookConfiguration, p *WebhookParameters) {
+	pilot, mixer := p.admissionPaths()
+	paths := map[string]string{
+		defaultPilotAdmissionPath: pilot,
+		defaultMixerAdmissionPath: mixer,
+	}
+	for i := range config.Webhooks {
+		service := config.Webhooks[i].ClientConfig.Service
+		if service == nil || service.Path == nil {
+			continue
+		}
+		if path, ok := paths[*service.Path]; ok {
+			service.Path = &path
+		}
+	}
+}
+
+// setObjectSelector overrides the objectSelector of the webhooks with the
+// configured selector, if any.
+func setObjectSelector(config *v1beta1.ValidatingWebhookConfiguration, selector *metav1.LabelSelector) {
+	if selector == nil {
+		return
+	}
+	for i := range config.Webhooks {
+		config.Webhooks[i].ObjectSelector = selector.DeepCopy()
+	}
+}
+
+// knownOperations are the operations a webhook rule can apply to.
+var knownOperations = map[v1beta1.OperationType]bool{
+	v1beta1.OperationAll: true,
+	v1beta1.Create:       true,
+	v1beta1.Update:       true,
+	v1beta1.Delete:       true,
+	v1beta1.Connect:      true,
+}
+
+// setRuleOperations overrides the operations of the webhook rules for the
+// resources configured in operations. A rule for several resources is split
+// into rules for the resources with the same operations, in order of the first
+// resource of each.
+func setRuleOperations(config *v1beta1.ValidatingWebhookConfiguration, operations map[string][]v1beta1.OperationType) {
+	if len(operations) == 0 {
+		return
+	}
+	for i := range config.Webhooks {
+		webhook := &config.Webhooks[i]
+		var rules []v1beta1.RuleWithOperations
+		for _, rule := range webhook.Rules {
+			var split []v1beta1.RuleWithOperations
+			for _, resource := range rule.Resources {
+				ops, ok := operations[resource]
+				if !ok {
+					ops = rule.Operations
+				}
+				j := 0
+				for ; j < len(split); j++ {
+					if reflect.DeepEqual(split[j].Operations, ops) {
+						break
+					}
+				}
+				if j == len(split) {
+					r := *rule.DeepCopy()
+					r.Operations = append([]v1beta1.OperationType(nil), ops...)
+					r.Resources = nil
+					split = append(split, r)
+				}
+				split[j].Resources = append(split[j].Resources, resource)
+			}
+			if len(split) == 0 {
+				split = append(split, rule)
+			}
+			rules = append(rules, split...)
+		}
+		webhook.Rules = rules
+	}
+}
+
 // Load the CA Cert PEM from the input reader. This also verifies that the certificate is a validate x509 cert.
 func loadCaCertPem(in io.Reader) ([]byte, error) {
 	caCertPemBytes, err := ioutil.ReadAll(in)
@@ -237,15 +460,10 @@ func rebuildWebhookConfigHelper(
 	ownerRefs []metav1.OwnerReference,
 ) (*v1beta1.ValidatingWebhookConfiguration, error) {
 	// load and validate configuration
-	webhookConfigData, err := ioutil.ReadFile(webhookConfigFile)
+	webhookConfig, err := readWebhookConfigFile(webhookConfigFile)
 	if err != nil {
 		return nil, err
 	}
-	var webhookConfig v1beta1.ValidatingWebhookConfiguration
-	if err := yaml.Unmarshal(webhookConfigData, &webhookConfig); err != nil {
-		return nil, fmt.Errorf("could not decode validatingwebhookconfiguration from %v: %v",
-			webhookConfigFile, err)
-	}
 
 	// fill in missing defaults to minimize desired vs. actual diffs later.
 	for i := 0; i < len(webhookConfig.Webhooks); i++ {
@@ -280,29 +498,44 @@ func rebuildWebhookConfigHelper(
 		webhookConfig.Webhooks[i].ClientConfig.CABundle = caPem
 	}
 
+	return webhookConfig, nil
+}
+
+// readWebhookConfigFile decodes the validatingwebhookconfiguration of the file.
+func readWebhookConfigFile(webhookConfigFile string) (*v1beta1.ValidatingWebhookConfiguration, error) {
+	webhookConfigData, err := ioutil.ReadFile(webhookConfigFile)
+	if err != nil {
+		return nil, err
+	}
+	var webhookConfig v1beta1.ValidatingWebhookConfiguration
+	if err := yaml.Unmarshal(webhookConfigData, &webhookConfig); err != nil {
+		return nil, fmt.Errorf("could not decode validatingwebhookconfiguration from %v: %v",
+			webhookConfigFile, err)
+	}
 	return &webhookConfig, nil
 }
 
+// watchedConfigFiles are the files the validatingwebhookconfiguration is built from.
+func (p *WebhookParameters) watchedConfigFiles() []string {
+	return append([]string{p.CACertFile, p.WebhookConfigFile}, p.AdditionalCACertFiles...)
+}
+
 // NewWebhookConfigController manages validating webhook configuration.
 func NewWebhookConfigController(p WebhookParameters) (*WebhookConfigController, error) {
 
 	// Configuration must be updated whenever the caBundle changes. watch the parent directory of
 	// the target files so we can catch symlink updates of k8s secrets.
-	fileWatcher, err := fsnotify.NewWatcher()
+	fileWatcher, err := newDirWatcher(p.watchedConfigFiles())
 	if err != nil {
 		return nil, err
 	}
-	for _, file := range []string{p.CACertFile, p.WebhookConfigFile} {
-		watchDir, _ := filepath.Split(file)
-		if err := fileWatcher.Watch(watchDir); err != nil {
-			return nil, fmt.Errorf("could not watch %v: %v", file, err)
-		}
-	}
 
 	whc := &WebhookConfigController{
-		configWatcher:               fileWatcher,
-		webhookParameters:           &p,
-		createInformerWebhookSource: defaultCreateInformerWebhookSource,
+		configWatcher:                 fileWatcher,
+		webhookParameters:             &p,
+		standby:                       p.StandbyMode,
+		createInformerWebhookSource:   defaultCreateInformerWebhookSource,
+		createInformerConfigMapSource: defaultCreateInformerConfigMapSource,
 	}
 
 	galleyNamespace, err := whc.webhookParameters.Clientset.CoreV1().Namespaces().Get(
@@ -325,17 +558,51 @@ func NewWebhookConfigController(p WebhookParameters) (*WebhookConfigController,
 
 //reconcile monitors the keycert and webhook configuration changes, rebuild and reconcile the configuration
 func (whc *WebhookConfigController) reconcile(stopCh <-chan struct{}) {
-	defer whc.configWatcher.Close() // nolint: errcheck
+	// the watcher is replaced when it is restarted
+	defer func() {
+		whc.configWatcher.Close() // nolint: errcheck
+	}()
 
 	// Try to create the initial webhook configuration (if it doesn't
 	// already exist). Setup a persistent monitor to reconcile the
 	// configuration if the observed configuration doesn't match
 	// the desired configuration.
 	var retryAfterSetup bool
-	if err := whc.rebuildWebhookConfig(); err == nil {
+	if err := whc.rebuildWebhookConfig(); err == nil && !whc.standby {
 		retryAfterSetup = whc.createOrUpdateWebhookConfig()
 	}
-	webhookChangedCh := whc.monitorWebhookChanges(stopCh)
+	if whc.standby {
+		scope.Infof("Standby mode: %v validatingwebhookconfiguration is not registered", whc.webhookParameters.WebhookName)
+	}
+	standbyCh := whc.watchStandby(stopCh)
+
+	// Changes to the configuration by others are only reconciled when it is
+	// enforced. Otherwise the configuration is reconciled once, as if the
+	// informer had observed it, and again on file changes.
+	// The events of the informer are forwarded to webhookChangedCh, so that the
+	// informer can be restarted when it is idle.
+	webhookChangedCh := make(chan struct{}, 1000)
+	var webhookEventCh chan struct{}
+	var resyncC <-chan time.Time
+	informerIdle := newIdleTimer(0)
+	informerStopCh := make(chan struct{})
+	defer func() {
+		close(informerStopCh)
+	}()
+	if !whc.webhookParameters.DisableWebhookConfigEnforcement {
+		webhookEventCh = whc.monitorWebhookChanges(informerStopCh)
+		informerIdle = newIdleTimer(whc.webhookParameters.WatchIdleRestart)
+		if interval := whc.webhookParameters.WebhookConfigResyncInterval; interval > 0 {
+			resync := time.NewTicker(interval)
+			defer resync.Stop()
+			resyncC = resync.C
+		}
+	} else {
+		webhookChangedCh <- struct{}{}
+	}
+	defer informerIdle.stop()
+	configIdle := newIdleTimer(whc.webhookParameters.WatchIdleRestart)
+	defer configIdle.stop()
 
 	// use a timer to debounce file updates
 	var configTimerC <-chan time.Time
@@ -352,7 +619,7 @@ func (whc *WebhookConfigController) reconcile(stopCh <-chan struct{}) {
 
 			// rebuild the desired configuration and reconcile with the
 			// existing configuration.
-			if err := whc.rebuildWebhookConfig(); err == nil {
+			if err := whc.rebuildWebhookConfig(); err == nil && !whc.standby {
 				if retry := whc.createOrUpdateWebhookConfig(); retry {
 					configTimerC = time.After(retryUpdateAfterFailureTimeout)
 					if !retrying {
@@ -365,6 +632,11 @@ func (whc *WebhookConfigController) reconcile(stopCh <-chan struct{}) {
 				}
 			}
 		case <-webhookChangedCh:
+			if whc.standby {
+				// the active Galley owns the configuration
+				retrying = false
+				continue
+			}
 			var retry bool
 			if whc.webhookParameters.EnableValidation {
 				// reconcile the desired configuration
@@ -380,10 +652,62 @@ func (whc *WebhookConfigController) reconcile(stopCh <-chan struct{}) {
 			if retry {
 				time.AfterFunc(retryUpdateAfterFailureTimeout, func() { webhookChangedCh <- struct{}{} })
 			}
+		case standby := <-standbyCh:
+			if standby == whc.standby {
+				continue
+			}
+			whc.standby = standby
+			if standby {
+				scope.Warnf("!!! Standby mode enabled: %v validatingwebhookconfiguration is no longer reconciled !!!",
+					whc.webhookParameters.WebhookName)
+				continue
+			}
+			scope.Warnf("!!! Standby mode disabled: reconciling %v validatingwebhookconfiguration !!!",
+				whc.webhookParameters.WebhookName)
+			if whc.webhookConfiguration == nil {
+				if err := whc.rebuildWebhookConfig(); err != nil {
+					continue
+				}
+			}
+			select {
+			case webhookChangedCh <- struct{}{}:
+			default:
+				// a reconcile is already pending
+			}
+		case <-resyncC:
+			select {
+			case webhookChangedCh <- struct{}{}:
+			default:
+				// a reconcile is already pending
+			}
+		case <-webhookEventCh:
+			informerIdle.reset()
+			select {
+			case webhookChangedCh <- struct{}{}:
+			default:
+				// a reconcile is already pending
+			}
+		case <-informerIdle.C():
+			// the new informer lists the configuration, which is then reconciled
+			scope.Warnf("No events from the %v validatingwebhookconfiguration watch for %v, restarting it",
+				whc.webhookParameters.WebhookName, whc.webhookParameters.WatchIdleRestart)
+			close(informerStopCh)
+			informerStopCh = make(chan struct{})
+			webhookEventCh = whc.monitorWebhookChanges(informerStopCh)
+			informerIdle.reset()
 		case event, more := <-whc.configWatcher.Event:
+			configIdle.reset()
 			if more && (event.IsModify() || event.IsCreate()) && configTimerC == nil {
 				configTimerC = time.After(watchDebounceDelay)
 			}
+		case <-configIdle.C():
+			whc.configWatcher = rewatch("webhook configuration", whc.configWatcher,
+				whc.webhookParameters.watchedConfigFiles(), whc.webhookParameters.WatchIdleRestart)
+			configIdle.reset()
+			// rebuild in case the stalled watch missed an update
+			if configTimerC == nil {
+				configTimerC = time.After(watchDebounceDelay)
+			}
 		case err := <-whc.configWatcher.Error:
 			scope.Errorf("configWatcher error: %v", err)
 		case <-stopCh:
@@ -396,15 +720,9 @@ func (whc *WebhookConfigController) reconcile(stopCh <-chan struct{}) {
 func ReconcileWebhookConfiguration(webhookServerReady, stopCh <-chan struct{},
 	vc *WebhookParameters, kubeConfig string) {
 
-	clientset, err := kube.CreateClientset(kubeConfig, "")
-	if err != nil {
-		log.Fatalf("could not create k8s clientset: %v", err)
-	}
-	vc.Clientset = clientset
-
-	whc, err := NewWebhookConfigController(*vc)
+	whc, err := createWebhookConfigController(vc, kubeConfig)
 	if err != nil {
-		log.Fatalf("cannot create validation webhook config: %v", err)
+		log.Fatal(err.Error())
 	}
 
 	if vc.EnableValidation {
@@ -414,3 +732,19 @@ func ReconcileWebhookConfiguration(webhookServerReady, stopCh <-chan struct{},
 	whc.reconcile(stopCh)
 
 }
+
+// createWebhookConfigController creates the webhook configuration controller with
+// a clientset for kubeConfig, which is also set in vc.
+func createWebhookConfigController(vc *WebhookParameters, kubeConfig string) (*WebhookConfigController, error) {
+	clientset, err := kube.CreateClientset(kubeConfig, "")
+	if err != nil {
+		return nil, fmt.Errorf("could not create k8s clientset: %v", err)
+	}
+	vc.Clientset = clientset
+
+	whc, err := NewWebhookConfigController(*vc)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create validation webhook config: %v", err)
+	}
+	return whc, nil
+}
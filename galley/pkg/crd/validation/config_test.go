@@ -16,19 +16,24 @@ package validation
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"reflect"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/ghodss/yaml"
 	"github.com/onsi/gomega"
+	"go.opencensus.io/stats/view"
 	admissionregistrationv1beta1 "k8s.io/api/admissionregistration/v1beta1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	clientset "k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/fake"
 	k8stesting "k8s.io/client-go/testing"
@@ -377,6 +382,62 @@ func initValidatingWebhookConfiguration() *admissionregistrationv1beta1.Validati
 	}
 }
 
+// webhookRegistered returns the last value of the webhook_registered gauge.
+func webhookRegistered(t *testing.T) int64 {
+	t.Helper()
+	rows, err := view.RetrieveData(metricWebhookRegistered.Name())
+	if err != nil {
+		t.Fatalf("RetrieveData() failed: %v", err)
+	}
+	if len(rows) != 1 {
+		t.Fatalf("got %v rows of %v want 1", len(rows), metricWebhookRegistered.Name())
+	}
+	return int64(rows[0].Data.(*view.LastValueData).Value)
+}
+
+func TestWebhookRegisteredOnError(t *testing.T) {
+	initConfig := initValidatingWebhookConfiguration()
+	failing := func(verb string) *fake.Clientset {
+		client := fake.NewSimpleClientset()
+		client.PrependReactor(verb, "validatingwebhookconfigurations",
+			func(k8stesting.Action) (bool, runtime.Object, error) {
+				return true, nil, errors.New("fake apiserver error")
+			})
+		return client
+	}
+
+	// a failed update leaves the configuration registered
+	client := failing("update")
+	whc, cancel := createTestWebhookConfigController(t, client, createFakeWebhookSource(), initConfig)
+	defer cancel()
+	config, err := rebuildWebhookConfigHelper(whc.webhookParameters.CACertFile, whc.webhookParameters.WebhookConfigFile,
+		whc.webhookParameters.WebhookName, whc.ownerRefs)
+	if err != nil {
+		t.Fatalf("Got unexpected error: %v", err)
+	}
+	whc.webhookConfiguration = config
+	if retry := whc.createOrUpdateWebhookConfig(); retry {
+		t.Fatal("createOrUpdateWebhookConfig() failed to create the configuration")
+	}
+	whc.webhookConfiguration = config.DeepCopy()
+	whc.webhookConfiguration.Webhooks = whc.webhookConfiguration.Webhooks[:1]
+	if retry := whc.createOrUpdateWebhookConfig(); !retry {
+		t.Fatal("createOrUpdateWebhookConfig() did not fail to update the configuration")
+	}
+	if got := webhookRegistered(t); got != 1 {
+		t.Fatalf("got webhook_registered %v after a failed update want 1", got)
+	}
+
+	// a failed create leaves the configuration absent
+	whc.webhookParameters.Clientset = failing("create")
+	if retry := whc.createOrUpdateWebhookConfig(); !retry {
+		t.Fatal("createOrUpdateWebhookConfig() did not fail to create the configuration")
+	}
+	if got := webhookRegistered(t); got != 0 {
+		t.Fatalf("got webhook_registered %v after a failed create want 0", got)
+	}
+}
+
 func TestDeleteValidatingWebhookConfig(t *testing.T) {
 
 	initConfig := initValidatingWebhookConfiguration()
@@ -452,6 +513,307 @@ func TestReloadConfig(t *testing.T) {
 	}, "10s", "100ms").Should(gomega.BeTrue())
 }
 
+func TestEnforceWebhookConfig(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	whc, cleanup := createTestWebhookConfigController(t,
+		client,
+		createFakeWebhookSource(),
+		dummyConfig)
+	defer cleanup()
+	whc.webhookParameters.EnableValidation = true
+	whc.webhookParameters.WebhookConfigResyncInterval = 100 * time.Millisecond
+	stop := make(chan struct{})
+	defer func() { close(stop) }()
+	go whc.reconcile(stop)
+
+	g := gomega.NewGomegaWithT(t)
+	configs := client.AdmissionregistrationV1beta1().ValidatingWebhookConfigurations()
+	exists := func() bool {
+		_, err := configs.Get(dummyConfig.Name, metav1.GetOptions{})
+		return err == nil
+	}
+	g.Eventually(exists, "10s", "100ms").Should(gomega.BeTrue())
+
+	// The fake informer source does not observe the deletion, so the
+	// configuration is restored by the resync.
+	if err := configs.Delete(dummyConfig.Name, &metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("Delete() failed: %v", err)
+	}
+	g.Eventually(exists, "10s", "100ms").Should(gomega.BeTrue())
+}
+
+func TestDisableWebhookConfigEnforcement(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	whc, cleanup := createTestWebhookConfigController(t,
+		client,
+		createFakeWebhookSource(),
+		dummyConfig)
+	defer cleanup()
+	whc.webhookParameters.EnableValidation = true
+	whc.webhookParameters.DisableWebhookConfigEnforcement = true
+	whc.webhookParameters.WebhookConfigResyncInterval = 100 * time.Millisecond
+	stop := make(chan struct{})
+	defer func() { close(stop) }()
+	go whc.reconcile(stop)
+
+	g := gomega.NewGomegaWithT(t)
+	configs := client.AdmissionregistrationV1beta1().ValidatingWebhookConfigurations()
+	exists := func() bool {
+		_, err := configs.Get(dummyConfig.Name, metav1.GetOptions{})
+		return err == nil
+	}
+	g.Eventually(exists, "10s", "100ms").Should(gomega.BeTrue())
+
+	if err := configs.Delete(dummyConfig.Name, &metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("Delete() failed: %v", err)
+	}
+	g.Consistently(exists, "500ms", "100ms").Should(gomega.BeFalse())
+}
+
+func TestWatchIdleRestart(t *testing.T) {
+	whc, cleanup := createTestWebhookConfigController(t,
+		fake.NewSimpleClientset(),
+		createFakeWebhookSource(),
+		dummyConfig)
+	defer cleanup()
+	whc.webhookParameters.WatchIdleRestart = 100 * time.Millisecond
+	var informers int32
+	source := whc.createInformerWebhookSource
+	whc.createInformerWebhookSource = func(cl clientset.Interface, name string) cache.ListerWatcher {
+		atomic.AddInt32(&informers, 1)
+		return source(cl, name)
+	}
+	stop := make(chan struct{})
+	defer func() { close(stop) }()
+	go whc.reconcile(stop)
+
+	// the fake informer source produces no events after the initial list
+	g := gomega.NewGomegaWithT(t)
+	g.Eventually(func() int32 { return atomic.LoadInt32(&informers) }, "10s", "50ms").Should(gomega.BeNumerically(">=", 2))
+}
+
+func TestSetAdmissionPaths(t *testing.T) {
+	webhook := func(path string) admissionregistrationv1beta1.ValidatingWebhook {
+		return admissionregistrationv1beta1.ValidatingWebhook{
+			ClientConfig: admissionregistrationv1beta1.WebhookClientConfig{
+				Service: &admissionregistrationv1beta1.ServiceReference{Path: &path},
+			},
+		}
+	}
+
+	config := &admissionregistrationv1beta1.ValidatingWebhookConfiguration{
+		Webhooks: []admissionregistrationv1beta1.ValidatingWebhook{
+			webhook("/admitpilot"),
+			webhook("/admitmixer"),
+			webhook("/other"),
+			{},
+		},
+	}
+	setAdmissionPaths(config, &WebhookParameters{PilotAdmissionPath: "/pilot/v2"})
+
+	want := []string{"/pilot/v2", "/admitmixer", "/other"}
+	for i, path := range want {
+		if got := *config.Webhooks[i].ClientConfig.Service.Path; got != path {
+			t.Fatalf("webhook %d: got path %v want %v", i, got, path)
+		}
+	}
+}
+
+func TestSetObjectSelector(t *testing.T) {
+	existing := &metav1.LabelSelector{MatchLabels: map[string]string{"app": "foo"}}
+	config := &admissionregistrationv1beta1.ValidatingWebhookConfiguration{
+		Webhooks: []admissionregistrationv1beta1.ValidatingWebhook{
+			{Name: "pilot", ObjectSelector: existing},
+			{Name: "mixer"},
+		},
+	}
+
+	setObjectSelector(config, nil)
+	if got := config.Webhooks[0].ObjectSelector; got != existing {
+		t.Fatalf("nil selector replaced objectSelector: got %v want %v", got, existing)
+	}
+
+	selector := &metav1.LabelSelector{MatchLabels: map[string]string{"istio-validation": "enabled"}}
+	setObjectSelector(config, selector)
+	for i, webhook := range config.Webhooks {
+		if !reflect.DeepEqual(webhook.ObjectSelector, selector) {
+			t.Fatalf("webhook %d: got objectSelector %v want %v", i, webhook.ObjectSelector, selector)
+		}
+	}
+}
+
+func TestSetRuleOperations(t *testing.T) {
+	create := []admissionregistrationv1beta1.OperationType{admissionregistrationv1beta1.Create}
+	createUpdate := []admissionregistrationv1beta1.OperationType{admissionregistrationv1beta1.Create, admissionregistrationv1beta1.Update}
+	rule := func(operations []admissionregistrationv1beta1.OperationType, resources ...string) admissionregistrationv1beta1.RuleWithOperations {
+		return admissionregistrationv1beta1.RuleWithOperations{
+			Operations: operations,
+			Rule: admissionregistrationv1beta1.Rule{
+				APIGroups:   []string{"networking.istio.io"},
+				APIVersions: []string{"*"},
+				Resources:   resources,
+			},
+		}
+	}
+
+	cases := []struct {
+		name       string
+		operations map[string][]admissionregistrationv1beta1.OperationType
+		want       []admissionregistrationv1beta1.RuleWithOperations
+	}{
+		{
+			name: "none",
+			want: []admissionregistrationv1beta1.RuleWithOperations{rule(createUpdate, "gateways", "virtualservices", "sidecars")},
+		},
+		{
+			name:       "unlisted resource",
+			operations: map[string][]admissionregistrationv1beta1.OperationType{"rules": create},
+			want:       []admissionregistrationv1beta1.RuleWithOperations{rule(createUpdate, "gateways", "virtualservices", "sidecars")},
+		},
+		{
+			name:       "split",
+			operations: map[string][]admissionregistrationv1beta1.OperationType{"virtualservices": create},
+			want: []admissionregistrationv1beta1.RuleWithOperations{
+				rule(createUpdate, "gateways", "sidecars"),
+				rule(create, "virtualservices"),
+			},
+		},
+		{
+			name: "all resources",
+			operations: map[string][]admissionregistrationv1beta1.OperationType{
+				"gateways":        create,
+				"virtualservices": create,
+				"sidecars":        {admissionregistrationv1beta1.Delete},
+			},
+			want: []admissionregistrationv1beta1.RuleWithOperations{
+				rule(create, "gateways", "virtualservices"),
+				rule([]admissionregistrationv1beta1.OperationType{admissionregistrationv1beta1.Delete}, "sidecars"),
+			},
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			config := &admissionregistrationv1beta1.ValidatingWebhookConfiguration{
+				Webhooks: []admissionregistrationv1beta1.ValidatingWebhook{
+					{Name: "pilot", Rules: []admissionregistrationv1beta1.RuleWithOperations{rule(createUpdate, "gateways", "virtualservices", "sidecars")}},
+				},
+			}
+			setRuleOperations(config, c.operations)
+			if got := config.Webhooks[0].Rules; !reflect.DeepEqual(got, c.want) {
+				t.Fatalf("got rules %v want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestValidateGeneratedConfig(t *testing.T) {
+	url := "https://galley.example.com/admitpilot"
+	badPath := "admitpilot"
+	badPort := int32(0)
+	badTimeout := int32(60)
+
+	cases := []struct {
+		name    string
+		update  func(*admissionregistrationv1beta1.ValidatingWebhookConfiguration)
+		wantErr string
+	}{
+		{name: "valid", update: func(*admissionregistrationv1beta1.ValidatingWebhookConfiguration) {}},
+		{
+			name:    "no webhooks",
+			update:  func(c *admissionregistrationv1beta1.ValidatingWebhookConfiguration) { c.Webhooks = nil },
+			wantErr: "no webhooks",
+		},
+		{
+			name: "duplicate name",
+			update: func(c *admissionregistrationv1beta1.ValidatingWebhookConfiguration) {
+				c.Webhooks = append(c.Webhooks, *c.Webhooks[0].DeepCopy())
+			},
+			wantErr: `webhook[1] "hook-foo": duplicate name`,
+		},
+		{
+			name:    "empty rules",
+			update:  func(c *admissionregistrationv1beta1.ValidatingWebhookConfiguration) { c.Webhooks[0].Rules = nil },
+			wantErr: "no rules",
+		},
+		{
+			name: "rule without resources",
+			update: func(c *admissionregistrationv1beta1.ValidatingWebhookConfiguration) {
+				c.Webhooks[0].Rules[0].Resources = nil
+			},
+			wantErr: "rule[0] has no resources",
+		},
+		{
+			name: "unknown operation",
+			update: func(c *admissionregistrationv1beta1.ValidatingWebhookConfiguration) {
+				c.Webhooks[0].Rules[0].Operations = []admissionregistrationv1beta1.OperationType{"PATCH"}
+			},
+			wantErr: `rule[0] has unknown operation "PATCH"`,
+		},
+		{
+			name: "url and service",
+			update: func(c *admissionregistrationv1beta1.ValidatingWebhookConfiguration) {
+				c.Webhooks[0].ClientConfig.URL = &url
+			},
+			wantErr: "exactly one of url and service",
+		},
+		{
+			name: "invalid path",
+			update: func(c *admissionregistrationv1beta1.ValidatingWebhookConfiguration) {
+				c.Webhooks[0].ClientConfig.Service.Path = &badPath
+			},
+			wantErr: `path "admitpilot" must begin with /`,
+		},
+		{
+			name: "invalid port",
+			update: func(c *admissionregistrationv1beta1.ValidatingWebhookConfiguration) {
+				c.Webhooks[0].ClientConfig.Service.Port = &badPort
+			},
+			wantErr: "clientConfig.service.port: port number 0 must be in the range 1..65535",
+		},
+		{
+			name: "empty caBundle",
+			update: func(c *admissionregistrationv1beta1.ValidatingWebhookConfiguration) {
+				c.Webhooks[0].ClientConfig.CABundle = nil
+			},
+			wantErr: "caBundle is empty",
+		},
+		{
+			name: "malformed selector",
+			update: func(c *admissionregistrationv1beta1.ValidatingWebhookConfiguration) {
+				c.Webhooks[0].NamespaceSelector = &metav1.LabelSelector{
+					MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "env", Operator: "Bogus"}},
+				}
+			},
+			wantErr: "invalid namespaceSelector",
+		},
+		{
+			name: "invalid timeout",
+			update: func(c *admissionregistrationv1beta1.ValidatingWebhookConfiguration) {
+				c.Webhooks[0].TimeoutSeconds = &badTimeout
+			},
+			wantErr: "timeoutSeconds 60 must be in the range 1..30",
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			config := dummyConfig.DeepCopy()
+			c.update(config)
+			err := validateGeneratedConfig(config)
+			if c.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+				t.Fatalf("got %v want error containing %q", err, c.wantErr)
+			}
+		})
+	}
+}
+
 func TestLoadCaCertPem(t *testing.T) {
 	cases := []struct {
 		name      string
@@ -0,0 +1,95 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// defaultWebhookTimeout is the timeout of the API server for webhooks without timeoutSeconds.
+const defaultWebhookTimeout = 30 * time.Second
+
+// requestDeadlines returns the time the pilot and mixer admission requests
+// may take, i.e. the shortest timeoutSeconds of the webhooks of the
+// configuration file served on each path, less the RequestDeadlineMargin. A
+// path that no webhook is served on has no deadline.
+func requestDeadlines(p *WebhookParameters) (pilot, mixer time.Duration, err error) {
+	config, err := readWebhookConfigFile(p.WebhookConfigFile)
+	if err != nil {
+		return 0, 0, fmt.Errorf("cannot read the webhook timeouts: %v", err)
+	}
+	setAdmissionPaths(config, p)
+
+	deadlines := make(map[string]time.Duration)
+	for _, webhook := range config.Webhooks {
+		service := webhook.ClientConfig.Service
+		if service == nil || service.Path == nil {
+			continue
+		}
+		timeout := defaultWebhookTimeout
+		if webhook.TimeoutSeconds != nil {
+			timeout = time.Duration(*webhook.TimeoutSeconds) * time.Second
+		}
+		deadline := timeout - p.RequestDeadlineMargin
+		if deadline <= 0 {
+			return 0, 0, fmt.Errorf("webhook %s: timeout %v leaves no time after the request deadline margin %v",
+				webhook.Name, timeout, p.RequestDeadlineMargin)
+		}
+		if current, ok := deadlines[*service.Path]; !ok || deadline < current {
+			deadlines[*service.Path] = deadline
+		}
+	}
+	pilotPath, mixerPath := p.admissionPaths()
+	return deadlines[pilotPath], deadlines[mixerPath], nil
+}
+
+// limitDeadline wraps an admitFunc so that the request is rejected with a
+// Timeout status once it took longer than the deadline, as the API server no
+// longer waits for the response. The context of admit is canceled, and its
+// result is discarded if it completes afterwards.
+func (wh *Webhook) limitDeadline(deadline time.Duration, admit admitFunc) admitFunc {
+	if deadline <= 0 {
+		return admit
+	}
+	return func(ctx context.Context, request *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+		deadlineCtx, cancel := context.WithTimeout(ctx, deadline)
+		defer cancel()
+
+		result := make(chan *admissionv1beta1.AdmissionResponse, 1)
+		go func() {
+			result <- admit(deadlineCtx, request)
+		}()
+
+		select {
+		case resp := <-result:
+			return resp
+		case <-deadlineCtx.Done():
+			requestLog(ctx).Infof("Validation of %s %s/%s did not complete within %v",
+				request.Kind.Kind, request.Namespace, request.Name, deadline)
+			reportValidationFailed(request, reasonDeadlineExceeded)
+			return &admissionv1beta1.AdmissionResponse{Result: &v1.Status{
+				Message: fmt.Sprintf("validation did not complete within %v", deadline),
+				Reason:  v1.StatusReasonTimeout,
+				Code:    http.StatusGatewayTimeout,
+			}}
+		}
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ghodss/yaml"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	admissionregistrationv1beta1 "k8s.io/api/admissionregistration/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRequestDeadlines(t *testing.T) {
+	dir, err := ioutil.TempDir("", "galley_validation_deadlines")
+	if err != nil {
+		t.Fatalf("TempDir() failed: %v", err)
+	}
+	defer os.RemoveAll(dir) // nolint: errcheck
+
+	webhook := func(name, path string, timeoutSeconds int32) admissionregistrationv1beta1.ValidatingWebhook {
+		w := admissionregistrationv1beta1.ValidatingWebhook{
+			Name: name,
+			ClientConfig: admissionregistrationv1beta1.WebhookClientConfig{
+				Service: &admissionregistrationv1beta1.ServiceReference{Name: "istio-galley", Path: &path},
+			},
+		}
+		if timeoutSeconds > 0 {
+			w.TimeoutSeconds = &timeoutSeconds
+		}
+		return w
+	}
+
+	cases := []struct {
+		name      string
+		webhooks  []admissionregistrationv1beta1.ValidatingWebhook
+		margin    time.Duration
+		wantPilot time.Duration
+		wantMixer time.Duration
+		wantErr   string
+	}{
+		{
+			name: "timeouts",
+			webhooks: []admissionregistrationv1beta1.ValidatingWebhook{
+				webhook("pilot.validation.istio.io", defaultPilotAdmissionPath, 10),
+				webhook("mixer.validation.istio.io", defaultMixerAdmissionPath, 5),
+			},
+			margin:    time.Second,
+			wantPilot: 9 * time.Second,
+			wantMixer: 4 * time.Second,
+		},
+		{
+			name: "default timeout",
+			webhooks: []admissionregistrationv1beta1.ValidatingWebhook{
+				webhook("pilot.validation.istio.io", defaultPilotAdmissionPath, 0),
+			},
+			margin:    time.Second,
+			wantPilot: 29 * time.Second,
+		},
+		{
+			name: "shortest timeout of a path",
+			webhooks: []admissionregistrationv1beta1.ValidatingWebhook{
+				webhook("pilot.validation.istio.io", defaultPilotAdmissionPath, 10),
+				webhook("gateways.validation.istio.io", defaultPilotAdmissionPath, 3),
+			},
+			wantPilot: 3 * time.Second,
+		},
+		{
+			name: "no time left",
+			webhooks: []admissionregistrationv1beta1.ValidatingWebhook{
+				webhook("pilot.validation.istio.io", defaultPilotAdmissionPath, 1),
+			},
+			margin:  time.Second,
+			wantErr: "leaves no time after the request deadline margin",
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			p := &WebhookParameters{
+				WebhookConfigFile:     filepath.Join(dir, fmt.Sprintf("config%d.yaml", i)),
+				RequestDeadlineMargin: c.margin,
+			}
+			raw, err := yaml.Marshal(&admissionregistrationv1beta1.ValidatingWebhookConfiguration{Webhooks: c.webhooks})
+			if err != nil {
+				t.Fatalf("Marshal() failed: %v", err)
+			}
+			if err := ioutil.WriteFile(p.WebhookConfigFile, raw, 0644); err != nil {
+				t.Fatalf("WriteFile(%v) failed: %v", p.WebhookConfigFile, err)
+			}
+
+			pilot, mixer, err := requestDeadlines(p)
+			if c.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+					t.Fatalf("got error %v want %q", err, c.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("requestDeadlines() failed: %v", err)
+			}
+			if pilot != c.wantPilot || mixer != c.wantMixer {
+				t.Fatalf("got deadlines %v and %v want %v and %v", pilot, mixer, c.wantPilot, c.wantMixer)
+			}
+		})
+	}
+}
+
+func TestLimitDeadline(t *testing.T) {
+	wh := &Webhook{}
+	release := make(chan struct{})
+	defer close(release)
+	canceled := make(chan struct{})
+	slow := wh.limitDeadline(10*time.Millisecond, func(ctx context.Context, _ *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+		<-ctx.Done()
+		close(canceled)
+		<-release
+		return wh.acceptResponse()
+	})
+	request := &admissionv1beta1.AdmissionRequest{Kind: metav1.GroupVersionKind{Kind: "Gateway"}, Namespace: "default", Name: "gateway"}
+
+	resp := slow(context.Background(), request)
+	if resp.Allowed || resp.Result.Reason != metav1.StatusReasonTimeout || resp.Result.Code != http.StatusGatewayTimeout {
+		t.Fatalf("got %v want a timeout", resp.Result)
+	}
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("the context of the validation was not canceled")
+	}
+
+	fast := wh.limitDeadline(time.Minute, func(context.Context, *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+		return wh.acceptResponse()
+	})
+	if resp := fast(context.Background(), request); !resp.Allowed {
+		t.Fatalf("got %v want the object allowed", resp.Result)
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"k8s.io/api/admissionregistration/v1beta1"
+
+	"istio.io/pkg/log"
+	buildversion "istio.io/pkg/version"
+)
+
+const (
+	debugLogLevelPath   = "/debug/loglevel"
+	debugVersionPath    = "/debug/version"
+	debugReloadCertPath = "/debug/reload-cert"
+	debugRulesPath      = "/debug/rules"
+
+	debugSupportBundlePath = "/debug/support-bundle"
+)
+
+var logLevels = map[string]log.Level{
+	"debug": log.DebugLevel,
+	"info":  log.InfoLevel,
+	"warn":  log.WarnLevel,
+	"error": log.ErrorLevel,
+	"fatal": log.FatalLevel,
+	"none":  log.NoneLevel,
+}
+
+// registerDebugHandlers adds the debug endpoints to the status mux.
+func (wh *Webhook) registerDebugHandlers() {
+	wh.statusMux.Handle(debugLogLevelPath, noCache(wh.authorizeDebug(wh.serveLogLevel)))
+	wh.statusMux.Handle(debugReloadCertPath, noCache(wh.authorizeDebug(wh.serveReloadCert)))
+	wh.statusMux.Handle(debugRulesPath, noCache(wh.authorizeDebug(wh.serveRules)))
+	wh.statusMux.Handle(debugSupportBundlePath, noCache(wh.authorizeDebug(wh.serveSupportBundle)))
+}
+
+// authorizeDebug rejects requests that do not carry the debug token. Requests are
+// rejected if no token is configured, which the webhook parameters do not allow.
+func (wh *Webhook) authorizeDebug(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if wh.debugToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(wh.debugToken)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// serveVersion reports the build information set at link time. It is served
+// regardless of EnableDebugEndpoints so that fleets can be audited by default.
+func serveVersion(w http.ResponseWriter, _ *http.Request) {
+	resp, err := json.Marshal(buildversion.Info)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("could not encode version: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(resp) // nolint: errcheck
+}
+
+// serveLogLevel sets the output level of the validation log scope from the `level`
+// form value, e.g. `curl -X POST <addr>/debug/loglevel?level=debug`.
+func (wh *Webhook) serveLogLevel(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := strings.ToLower(r.FormValue("level"))
+	level, ok := logLevels[name]
+	if !ok {
+		http.Error(w, fmt.Sprintf("invalid log level %q", name), http.StatusBadRequest)
+		return
+	}
+
+	scope.SetOutputLevel(level)
+	scope.Infof("%s scope log level set to %s", scope.Name(), name)
+	fmt.Fprintf(w, "%s\n", name)
+}
+
+// certReloadStatus is the JSON body returned by the cert reload endpoint.
+type certReloadStatus struct {
+	NotAfter  time.Time `json:"notAfter"`
+	ExpiresIn string    `json:"expiresIn"`
+}
+
+// serveReloadCert reloads the cert/key from file, e.g. after an out-of-band
+// rotation, and reports the expiry of the new cert.
+func (wh *Webhook) serveReloadCert(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	pair, err := wh.reloadKeyCert()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("could not reload cert: %v", err), http.StatusInternalServerError)
+		return
+	}
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		http.Error(w, fmt.Sprintf("could not parse cert: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	scope.Infof("Cert reloaded from %s, expires at %v", debugReloadCertPath, leaf.NotAfter.Format(time.RFC3339))
+	resp, err := json.Marshal(certReloadStatus{
+		NotAfter:  leaf.NotAfter,
+		ExpiresIn: time.Until(leaf.NotAfter).Round(time.Second).String(),
+	})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("could not encode status: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(resp) // nolint: errcheck
+}
+
+// webhookRules are the admission rules of a webhook of the
+// validatingwebhookconfiguration, as served by the rules endpoint.
+type webhookRules struct {
+	Name  string                       `json:"name"`
+	Path  string                       `json:"path,omitempty"`
+	Rules []v1beta1.RuleWithOperations `json:"rules"`
+}
+
+// effectiveWebhookRules returns the rules of the webhooks of the
+// validatingwebhookconfiguration built from the configuration file like the
+// WebhookConfigController does, i.e. with the parameters applied. It fails if
+// the configuration would not be registered.
+func effectiveWebhookRules(p *WebhookParameters) ([]webhookRules, error) {
+	config, err := rebuildWebhookConfigHelper(p.CACertFile, p.WebhookConfigFile, p.WebhookName, nil)
+	if err != nil {
+		return nil, err
+	}
+	customizeWebhookConfig(config, p)
+	if err := validateGeneratedConfig(config); err != nil {
+		return nil, fmt.Errorf("validatingwebhookconfiguration is invalid: %v", err)
+	}
+
+	rules := make([]webhookRules, 0, len(config.Webhooks))
+	for _, webhook := range config.Webhooks {
+		r := webhookRules{Name: webhook.Name, Rules: webhook.Rules}
+		if service := webhook.ClientConfig.Service; service != nil && service.Path != nil {
+			r.Path = *service.Path
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// serveRules reports the admission rules in effect, i.e. the groups, versions,
+// resources and operations of each webhook, as the current configuration file
+// would be registered.
+func (wh *Webhook) serveRules(w http.ResponseWriter, _ *http.Request) {
+	rules, err := wh.webhookRules()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("could not load rules: %v", err), http.StatusInternalServerError)
+		return
+	}
+	resp, err := json.Marshal(rules)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("could not encode rules: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(resp) // nolint: errcheck
+}
@@ -0,0 +1,368 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	admissionregistrationv1beta1 "k8s.io/api/admissionregistration/v1beta1"
+
+	"istio.io/pkg/log"
+	buildversion "istio.io/pkg/version"
+
+	"istio.io/istio/pkg/mcp/testing/testcerts"
+)
+
+// debugRequest returns a request of a debug endpoint with the bearer token.
+func debugRequest(method, target, token string) *http.Request {
+	req := httptest.NewRequest(method, target, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
+
+func TestAuthorizeDebugWithoutToken(t *testing.T) {
+	wh := &Webhook{statusMux: http.NewServeMux()}
+	wh.registerDebugHandlers()
+
+	for _, path := range []string{debugLogLevelPath, debugReloadCertPath, debugRulesPath, debugSupportBundlePath} {
+		for _, token := range []string{"", "secret"} {
+			w := httptest.NewRecorder()
+			wh.statusMux.ServeHTTP(w, debugRequest(http.MethodPost, path, token))
+			if w.Code != http.StatusUnauthorized {
+				t.Fatalf("got status %v for %s with token %q want %v", w.Code, path, token, http.StatusUnauthorized)
+			}
+		}
+	}
+}
+
+func TestServeLogLevel(t *testing.T) {
+	original := scope.GetOutputLevel()
+	defer scope.SetOutputLevel(original)
+
+	wh := &Webhook{statusMux: http.NewServeMux(), debugToken: "secret"}
+	wh.registerDebugHandlers()
+
+	cases := []struct {
+		name       string
+		method     string
+		target     string
+		token      string
+		wantStatus int
+		wantLevel  log.Level
+	}{
+		{
+			name:       "missing token",
+			method:     http.MethodPost,
+			target:     debugLogLevelPath + "?level=debug",
+			wantStatus: http.StatusUnauthorized,
+			wantLevel:  original,
+		},
+		{
+			name:       "wrong token",
+			method:     http.MethodPost,
+			target:     debugLogLevelPath + "?level=debug",
+			token:      "guess",
+			wantStatus: http.StatusUnauthorized,
+			wantLevel:  original,
+		},
+		{
+			name:       "wrong method",
+			method:     http.MethodGet,
+			target:     debugLogLevelPath + "?level=debug",
+			token:      "secret",
+			wantStatus: http.StatusMethodNotAllowed,
+			wantLevel:  original,
+		},
+		{
+			name:       "invalid level",
+			method:     http.MethodPost,
+			target:     debugLogLevelPath + "?level=verbose",
+			token:      "secret",
+			wantStatus: http.StatusBadRequest,
+			wantLevel:  original,
+		},
+		{
+			name:       "set debug",
+			method:     http.MethodPost,
+			target:     debugLogLevelPath + "?level=debug",
+			token:      "secret",
+			wantStatus: http.StatusOK,
+			wantLevel:  log.DebugLevel,
+		},
+		{
+			name:       "set error",
+			method:     http.MethodPost,
+			target:     debugLogLevelPath + "?level=ERROR",
+			token:      "secret",
+			wantStatus: http.StatusOK,
+			wantLevel:  log.ErrorLevel,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest(c.method, c.target, nil)
+			if c.token != "" {
+				req.Header.Set("Authorization", "Bearer "+c.token)
+			}
+			w := httptest.NewRecorder()
+			wh.statusMux.ServeHTTP(w, req)
+
+			if w.Code != c.wantStatus {
+				t.Fatalf("got status %v want %v", w.Code, c.wantStatus)
+			}
+			if got := scope.GetOutputLevel(); got != c.wantLevel {
+				t.Fatalf("got level %v want %v", got, c.wantLevel)
+			}
+		})
+	}
+}
+
+func TestServeVersion(t *testing.T) {
+	wh, cancel := createTestWebhook(t, dummyClient, createFakeEndpointsSource(), dummyConfig)
+	defer cancel()
+
+	w := httptest.NewRecorder()
+	wh.statusMux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, debugVersionPath, nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %v want %v", w.Code, http.StatusOK)
+	}
+	var got buildversion.BuildInfo
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("cannot decode version response: %v", err)
+	}
+	if got != buildversion.Info {
+		t.Fatalf("got version %v want %v", got, buildversion.Info)
+	}
+	if cacheControl := w.Header().Get("Cache-Control"); !strings.Contains(cacheControl, "no-store") {
+		t.Fatalf("got Cache-Control %q want no-store", cacheControl)
+	}
+}
+
+func TestServeReloadCert(t *testing.T) {
+	wh, cleanup := createTestWebhook(t, dummyClient, createFakeEndpointsSource(), dummyConfig)
+	defer cleanup()
+	wh.statusMux = http.NewServeMux()
+	wh.debugToken = "secret"
+	wh.registerDebugHandlers()
+
+	rotated, err := tls.X509KeyPair(testcerts.RotatedCert, testcerts.RotatedKey)
+	if err != nil {
+		t.Fatalf("cannot load rotated cert: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(rotated.Certificate[0])
+	if err != nil {
+		t.Fatalf("cannot parse rotated cert: %v", err)
+	}
+
+	cases := []struct {
+		name       string
+		method     string
+		token      string
+		keyFile    []byte
+		wantStatus int
+		wantCert   []byte
+		wantKey    []byte
+	}{
+		{
+			name:       "missing token",
+			method:     http.MethodPost,
+			keyFile:    testcerts.RotatedKey,
+			wantStatus: http.StatusUnauthorized,
+			wantCert:   testcerts.ServerCert,
+			wantKey:    testcerts.ServerKey,
+		},
+		{
+			name:       "wrong method",
+			method:     http.MethodGet,
+			token:      "secret",
+			keyFile:    testcerts.RotatedKey,
+			wantStatus: http.StatusMethodNotAllowed,
+			wantCert:   testcerts.ServerCert,
+			wantKey:    testcerts.ServerKey,
+		},
+		{
+			name:       "mismatched key",
+			method:     http.MethodPost,
+			token:      "secret",
+			keyFile:    testcerts.ServerKey,
+			wantStatus: http.StatusInternalServerError,
+			wantCert:   testcerts.ServerCert,
+			wantKey:    testcerts.ServerKey,
+		},
+		{
+			name:       "reloaded",
+			method:     http.MethodPost,
+			token:      "secret",
+			keyFile:    testcerts.RotatedKey,
+			wantStatus: http.StatusOK,
+			wantCert:   testcerts.RotatedCert,
+			wantKey:    testcerts.RotatedKey,
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			if err := ioutil.WriteFile(wh.certFile, testcerts.RotatedCert, 0644); err != nil {
+				t.Fatalf("WriteFile(%v) failed: %v", wh.certFile, err)
+			}
+			if err := ioutil.WriteFile(wh.keyFile, c.keyFile, 0644); err != nil {
+				t.Fatalf("WriteFile(%v) failed: %v", wh.keyFile, err)
+			}
+
+			req := httptest.NewRequest(c.method, debugReloadCertPath, nil)
+			if c.token != "" {
+				req.Header.Set("Authorization", "Bearer "+c.token)
+			}
+			w := httptest.NewRecorder()
+			wh.statusMux.ServeHTTP(w, req)
+
+			if w.Code != c.wantStatus {
+				t.Fatalf("got status %v want %v: %s", w.Code, c.wantStatus, w.Body.String())
+			}
+			if !checkCert(t, wh, c.wantCert, c.wantKey) {
+				t.Fatal("got unexpected cert")
+			}
+			if c.wantStatus != http.StatusOK {
+				return
+			}
+			var got certReloadStatus
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatalf("cannot decode reload response: %v", err)
+			}
+			if !got.NotAfter.Equal(leaf.NotAfter) {
+				t.Fatalf("got expiry %v want %v", got.NotAfter, leaf.NotAfter)
+			}
+		})
+	}
+}
+
+func TestServeRules(t *testing.T) {
+	wh, cleanup := createTestWebhook(t, dummyClient, createFakeEndpointsSource(), dummyConfig)
+	defer cleanup()
+	wh.statusMux = http.NewServeMux()
+	wh.debugToken = "secret"
+	wh.registerDebugHandlers()
+
+	w := httptest.NewRecorder()
+	wh.statusMux.ServeHTTP(w, debugRequest(http.MethodGet, debugRulesPath, "secret"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %v want %v: %s", w.Code, http.StatusOK, w.Body)
+	}
+	var got []webhookRules
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("cannot decode rules %q: %v", w.Body, err)
+	}
+	want := []webhookRules{{Name: "hook-foo", Rules: dummyConfig.Webhooks[0].Rules}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got rules %+v want %+v", got, want)
+	}
+}
+
+func TestEffectiveWebhookRules(t *testing.T) {
+	dir, err := ioutil.TempDir("", "galley_validation_rules")
+	if err != nil {
+		t.Fatalf("TempDir() failed: %v", err)
+	}
+	defer os.RemoveAll(dir) // nolint: errcheck
+
+	path := defaultPilotAdmissionPath
+	config := dummyConfig.DeepCopy()
+	config.Webhooks[0].ClientConfig.Service.Path = &path
+	p := &WebhookParameters{
+		CACertFile:         filepath.Join(dir, "ca.pem"),
+		WebhookConfigFile:  filepath.Join(dir, "config.yaml"),
+		WebhookName:        config.Name,
+		PilotAdmissionPath: "/admitpilot-v2",
+	}
+	if err := ioutil.WriteFile(p.CACertFile, testcerts.CACert, 0644); err != nil {
+		t.Fatalf("WriteFile(%v) failed: %v", p.CACertFile, err)
+	}
+	write := func(config *admissionregistrationv1beta1.ValidatingWebhookConfiguration) {
+		t.Helper()
+		raw, err := yaml.Marshal(config)
+		if err != nil {
+			t.Fatalf("Marshal() failed: %v", err)
+		}
+		if err := ioutil.WriteFile(p.WebhookConfigFile, raw, 0644); err != nil {
+			t.Fatalf("WriteFile(%v) failed: %v", p.WebhookConfigFile, err)
+		}
+	}
+
+	write(config)
+	rules, err := effectiveWebhookRules(p)
+	if err != nil {
+		t.Fatalf("effectiveWebhookRules() failed: %v", err)
+	}
+	if len(rules) != 1 || rules[0].Path != "/admitpilot-v2" {
+		t.Fatalf("got rules %+v want the webhook on the configured admission path", rules)
+	}
+
+	// the rules are read again, so that changes to the file are reported
+	config.Webhooks[0].Rules[0].Resources = []string{"r2"}
+	write(config)
+	if rules, err = effectiveWebhookRules(p); err != nil {
+		t.Fatalf("effectiveWebhookRules() failed: %v", err)
+	}
+	if got := rules[0].Rules[0].Resources; !reflect.DeepEqual(got, []string{"r2"}) {
+		t.Fatalf("got resources %v want [r2]", got)
+	}
+
+	// the rule operations are applied like for the registered configuration
+	config.Webhooks[0].Rules[0].Resources = []string{"r1", "r2"}
+	write(config)
+	p.RuleOperations = map[string][]admissionregistrationv1beta1.OperationType{
+		"r2": {admissionregistrationv1beta1.Create},
+	}
+	if rules, err = effectiveWebhookRules(p); err != nil {
+		t.Fatalf("effectiveWebhookRules() failed: %v", err)
+	}
+	want := []admissionregistrationv1beta1.RuleWithOperations{
+		{
+			Operations: []admissionregistrationv1beta1.OperationType{
+				admissionregistrationv1beta1.Create,
+				admissionregistrationv1beta1.Update,
+			},
+			Rule: admissionregistrationv1beta1.Rule{APIGroups: []string{"g1"}, APIVersions: []string{"v1"}, Resources: []string{"r1"}},
+		},
+		{
+			Operations: []admissionregistrationv1beta1.OperationType{admissionregistrationv1beta1.Create},
+			Rule:       admissionregistrationv1beta1.Rule{APIGroups: []string{"g1"}, APIVersions: []string{"v1"}, Resources: []string{"r2"}},
+		},
+	}
+	if !reflect.DeepEqual(rules[0].Rules, want) {
+		t.Fatalf("got rules %+v want %+v", rules[0].Rules, want)
+	}
+
+	// a configuration that would not be registered is not reported as in effect
+	config.Webhooks[0].Rules = nil
+	write(config)
+	if _, err = effectiveWebhookRules(p); err == nil {
+		t.Fatal("effectiveWebhookRules() succeeded on a configuration without rules")
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const defaultDebugSampleRate = 1
+
+type debugSampledKey struct{}
+
+func withDebugSampled(ctx context.Context, sampled bool) context.Context {
+	return context.WithValue(ctx, debugSampledKey{}, sampled)
+}
+
+// debugSampled returns true if the debug logs of the request served with the
+// context are sampled. Requests are sampled unless a sample rate is configured.
+func debugSampled(ctx context.Context) bool {
+	sampled, ok := ctx.Value(debugSampledKey{}).(bool)
+	return !ok || sampled
+}
+
+// sampleRequest returns true if the request with the UID is in the sampled
+// fraction of requests. The decision depends only on the UID, so that the logs
+// of a request are sampled by every webhook replica alike.
+func sampleRequest(uid types.UID, rate float64) bool {
+	if rate >= 1 {
+		return true
+	}
+	if rate <= 0 {
+		return false
+	}
+	h := fnv.New32a()
+	h.Write([]byte(uid)) // nolint: errcheck
+	return float64(h.Sum32()) < rate*math.MaxUint32
+}
+
+// sampleDebugLogs wraps an admitFunc so that the request, and the debug logs
+// of its admission, are logged for the sampled fraction of requests only.
+func (wh *Webhook) sampleDebugLogs(admit admitFunc) admitFunc {
+	return func(ctx context.Context, request *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+		if wh.debugSampleRate < 1 {
+			ctx = withDebugSampled(ctx, sampleRequest(request.UID, wh.debugSampleRate))
+		}
+		requestLog(ctx).Debugf("Admitting %s of %s %s/%s (uid %s)",
+			request.Operation, request.Kind.Kind, request.Namespace, request.Name, request.UID)
+		response := admit(ctx, request)
+		if response != nil {
+			requestLog(ctx).Debugf("Admitted %s of %s %s/%s: allowed %v",
+				request.Operation, request.Kind.Kind, request.Namespace, request.Name, response.Allowed)
+		}
+		return response
+	}
+}
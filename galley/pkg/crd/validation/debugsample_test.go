@@ -0,0 +1,89 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestSampleRequest(t *testing.T) {
+	cases := []struct {
+		rate float64
+		min  int
+		max  int
+	}{
+		{rate: 0, min: 0, max: 0},
+		{rate: 0.1, min: 800, max: 1200},
+		{rate: 0.5, min: 4500, max: 5500},
+		{rate: 1, min: 10000, max: 10000},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %v", i, c.rate), func(t *testing.T) {
+			var sampled int
+			for n := 0; n < 10000; n++ {
+				uid := types.UID(fmt.Sprintf("uid-%d", n))
+				got := sampleRequest(uid, c.rate)
+				if got != sampleRequest(uid, c.rate) {
+					t.Fatalf("sampling of %v is not deterministic", uid)
+				}
+				if got {
+					sampled++
+				}
+			}
+			if sampled < c.min || sampled > c.max {
+				t.Fatalf("got %d sampled requests want %d to %d", sampled, c.min, c.max)
+			}
+		})
+	}
+}
+
+func TestSampleDebugLogs(t *testing.T) {
+	cases := []struct {
+		name        string
+		rate        float64
+		wantSampled bool
+	}{
+		{name: "all", rate: 1, wantSampled: true},
+		{name: "none", rate: 0, wantSampled: false},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			wh := &Webhook{debugSampleRate: c.rate}
+			var sampled, logged bool
+			admit := wh.sampleDebugLogs(func(ctx context.Context, _ *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+				sampled = debugSampled(ctx)
+				logged = requestLog(ctx).sampled
+				return &admissionv1beta1.AdmissionResponse{Allowed: true}
+			})
+			if resp := admit(context.Background(), &admissionv1beta1.AdmissionRequest{UID: "uid"}); !resp.Allowed {
+				t.Fatal("response of the wrapped admitFunc not returned")
+			}
+			if sampled != c.wantSampled || logged != c.wantSampled {
+				t.Fatalf("got sampled %v, logger sampled %v want %v", sampled, logged, c.wantSampled)
+			}
+		})
+	}
+
+	if !debugSampled(context.Background()) {
+		t.Fatal("requests without a sampling decision are not sampled")
+	}
+}
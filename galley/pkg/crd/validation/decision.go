@@ -0,0 +1,150 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"context"
+	"time"
+
+	"github.com/ghodss/yaml"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const decisionBufferSize = 1000
+
+// DecisionRecord describes a single admission decision made by the webhook.
+type DecisionRecord struct {
+	// Kind of the admitted object.
+	Kind v1.GroupVersionKind
+
+	// Name and Namespace of the admitted object.
+	Name      string
+	Namespace string
+
+	// UID of the admitted object, if it is set by the API server.
+	UID types.UID
+
+	// Operation of the admission request, e.g. CREATE.
+	Operation admissionv1beta1.Operation
+
+	// User is the name of the user that made the request.
+	User string
+
+	// RequestID is the X-Request-Id of the admission request.
+	RequestID string
+
+	// Allowed is true if the object was admitted.
+	Allowed bool
+
+	// Error is the reason the object was rejected, if any.
+	Error string
+
+	// SchemaVersion identifies the pilot schemas the object was validated
+	// against, see SchemaSnapshotFile.
+	SchemaVersion string
+}
+
+// DecisionSink receives admission decisions. It is invoked asynchronously and
+// never blocks admission; records are dropped if the sink falls behind.
+type DecisionSink func(DecisionRecord)
+
+// recordDecisions wraps an admitFunc so that each decision is queued for the decision sink.
+func (wh *Webhook) recordDecisions(admit admitFunc) admitFunc {
+	if wh.decisionSink == nil && !wh.auditDecisions && wh.decisionStats == nil {
+		return admit
+	}
+	return func(ctx context.Context, request *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+		var schemaVersion string
+		if validators, ok := wh.validators.Load().(*validatorSet); ok {
+			schemaVersion = validators.schemaVersion
+		}
+		response := admit(ctx, request)
+
+		name, uid := objectMeta(request)
+		record := DecisionRecord{
+			Kind:          request.Kind,
+			Name:          name,
+			Namespace:     request.Namespace,
+			UID:           uid,
+			Operation:     request.Operation,
+			User:          request.UserInfo.Username,
+			RequestID:     RequestIDFromContext(ctx),
+			SchemaVersion: schemaVersion,
+		}
+		if response != nil {
+			record.Allowed = response.Allowed
+			if !response.Allowed && response.Result != nil {
+				record.Error = response.Result.Message
+				if wh.redactor != nil {
+					record.Error = wh.redactor.redactMessage(record.Error, request.Object.Raw)
+					if scope.DebugEnabled() && debugSampled(ctx) && len(request.Object.Raw) > 0 {
+						if object, _, err := wh.redactor.redact(request.Object.Raw); err == nil {
+							requestLog(ctx).Debugf("Rejected %s %s/%s: %s", request.Kind.Kind, request.Namespace, name, object)
+						}
+					}
+				}
+			}
+		}
+
+		if wh.auditDecisions {
+			auditDecision(record)
+		}
+		if wh.decisionStats != nil {
+			wh.decisionStats.add(record, time.Now())
+		}
+		if wh.decisionSink != nil {
+			select {
+			case wh.decisions <- record:
+			default:
+				reportDecisionDropped()
+			}
+		}
+		return response
+	}
+}
+
+// runDecisionSink delivers queued decisions to the sink until stopped.
+func (wh *Webhook) runDecisionSink(stopCh <-chan struct{}) {
+	for {
+		select {
+		case record := <-wh.decisions:
+			wh.decisionSink(record)
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// objectMeta returns the name and UID of the object in the request. Clients may omit
+// the request name on create, in which case it is read from the object metadata.
+func objectMeta(request *admissionv1beta1.AdmissionRequest) (string, types.UID) {
+	raw := request.Object.Raw
+	if len(raw) == 0 {
+		// deletes only include the old object
+		raw = request.OldObject.Raw
+	}
+	var obj struct {
+		Metadata v1.ObjectMeta `json:"metadata"`
+	}
+	if err := yaml.Unmarshal(raw, &obj); err != nil {
+		return request.Name, ""
+	}
+	if request.Name != "" {
+		return request.Name, obj.Metadata.UID
+	}
+	return obj.Metadata.Name, obj.Metadata.UID
+}
@@ -0,0 +1,104 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRecordDecisions(t *testing.T) {
+	valid := makePilotConfig(t, 0, true, false)
+	invalid := makePilotConfig(t, 1, false, false)
+
+	wh, cleanup := createTestWebhook(t, fake.NewSimpleClientset(), createFakeEndpointsSource(), dummyConfig)
+	defer cleanup()
+
+	records := make(chan DecisionRecord, 2)
+	wh.decisionSink = func(record DecisionRecord) { records <- record }
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go wh.runDecisionSink(stop)
+
+	admit := wh.recordDecisions(wh.admitPilot)
+	for _, raw := range [][]byte{valid, invalid} {
+		admit(context.Background(), &admissionv1beta1.AdmissionRequest{
+			Kind:      metav1.GroupVersionKind{Kind: "mock"},
+			Namespace: "default",
+			Object:    runtime.RawExtension{Raw: raw},
+			Operation: admissionv1beta1.Create,
+			UserInfo:  authenticationv1.UserInfo{Username: "alice"},
+		})
+	}
+
+	want := []struct {
+		name    string
+		allowed bool
+	}{
+		{"mock-config0", true},
+		{"mock-config1", false},
+	}
+	for _, w := range want {
+		select {
+		case got := <-records:
+			if got.Name != w.name || got.Namespace != "default" || got.Kind.Kind != "mock" ||
+				got.Operation != admissionv1beta1.Create || got.User != "alice" || got.Allowed != w.allowed {
+				t.Fatalf("got %+v want name %v allowed %v", got, w.name, w.allowed)
+			}
+			if !got.Allowed && got.Error == "" {
+				t.Fatalf("rejected decision is missing the error: %+v", got)
+			}
+			if want := wh.activeValidators().schemaVersion; want == "" || got.SchemaVersion != want {
+				t.Fatalf("got schema version %q want %q", got.SchemaVersion, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("decision for %v not delivered", w.name)
+		}
+	}
+}
+
+func TestRecordDecisions_DropWhenFull(t *testing.T) {
+	wh := &Webhook{
+		decisionSink: func(DecisionRecord) {},
+		decisions:    make(chan DecisionRecord, 1),
+	}
+	admit := wh.recordDecisions(func(context.Context, *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+		return &admissionv1beta1.AdmissionResponse{Allowed: true}
+	})
+
+	// No consumer is running: the second decision must be dropped rather than block.
+	done := make(chan struct{})
+	go func() {
+		admit(context.Background(), &admissionv1beta1.AdmissionRequest{Name: "first"})
+		admit(context.Background(), &admissionv1beta1.AdmissionRequest{Name: "second"})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("admission blocked on a full decision buffer")
+	}
+	if got := (<-wh.decisions).Name; got != "first" {
+		t.Fatalf("got %v want first", got)
+	}
+}
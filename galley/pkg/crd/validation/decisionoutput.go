@@ -0,0 +1,60 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"io"
+)
+
+// decisionOutput writes each decision as an NDJSON line with the fields of the
+// audit log entry. It is a DecisionSink, so it is only invoked from the
+// decision sink goroutine and the writes are not interleaved.
+type decisionOutput struct {
+	w io.Writer
+}
+
+func (o *decisionOutput) sink(record DecisionRecord) {
+	line, err := auditLine(record)
+	if err != nil {
+		scope.Errorf("cannot encode decision output for %s %s/%s: %v", record.Kind.Kind, record.Namespace, record.Name, err)
+		return
+	}
+	if _, err := o.w.Write(append(line, '\n')); err != nil {
+		scope.Errorf("cannot write decision output for %s %s/%s: %v", record.Kind.Kind, record.Namespace, record.Name, err)
+	}
+}
+
+// combineDecisionSinks returns a DecisionSink that calls each of the sinks in
+// order, or nil if there are none.
+func combineDecisionSinks(sinks ...DecisionSink) DecisionSink {
+	var active []DecisionSink
+	for _, sink := range sinks {
+		if sink != nil {
+			active = append(active, sink)
+		}
+	}
+	switch len(active) {
+	case 0:
+		return nil
+	case 1:
+		return active[0]
+	default:
+		return func(record DecisionRecord) {
+			for _, sink := range active {
+				sink(record)
+			}
+		}
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDecisionOutput(t *testing.T) {
+	var buf bytes.Buffer
+	output := &decisionOutput{w: &buf}
+	output.sink(DecisionRecord{
+		Kind:      metav1.GroupVersionKind{Group: "networking.istio.io", Version: "v1alpha3", Kind: "Gateway"},
+		Name:      "ingress",
+		Namespace: "default",
+		Operation: admissionv1beta1.Create,
+		User:      "alice",
+		Allowed:   true,
+	})
+	output.sink(DecisionRecord{
+		Kind:      metav1.GroupVersionKind{Group: "networking.istio.io", Version: "v1alpha3", Kind: "Gateway"},
+		Name:      "egress",
+		Namespace: "default",
+		Operation: admissionv1beta1.Update,
+		User:      "bob",
+		Error:     "configuration is invalid",
+	})
+
+	var got []map[string]string
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var entry map[string]string
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("line %q is not a JSON object: %v", scanner.Text(), err)
+		}
+		got = append(got, entry)
+	}
+	want := []map[string]string{
+		{
+			"user": "alice", "group": "networking.istio.io", "version": "v1alpha3", "kind": "Gateway",
+			"namespace": "default", "name": "ingress", "operation": "CREATE", "decision": "allowed",
+		},
+		{
+			"user": "bob", "group": "networking.istio.io", "version": "v1alpha3", "kind": "Gateway",
+			"namespace": "default", "name": "egress", "operation": "UPDATE", "decision": "denied",
+			"reason": "configuration is invalid",
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v want %v", got, want)
+	}
+}
+
+func TestCombineDecisionSinks(t *testing.T) {
+	if sink := combineDecisionSinks(nil, nil); sink != nil {
+		t.Fatal("got a sink for no sinks")
+	}
+
+	var got []string
+	first := func(record DecisionRecord) { got = append(got, "first "+record.Name) }
+	second := func(record DecisionRecord) { got = append(got, "second "+record.Name) }
+	combineDecisionSinks(first, nil, second)(DecisionRecord{Name: "ingress"})
+	if want := []string{"first ingress", "second ingress"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v want %v", got, want)
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+)
+
+// requestKey returns the key of identical admission requests, i.e. a digest of
+// the request without its UID. The digest is cryptographic so that an object
+// cannot be crafted to share the response of another.
+func requestKey(request *admissionv1beta1.AdmissionRequest) (string, error) {
+	key := *request
+	key.UID = ""
+	b, err := json.Marshal(&key)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// detachedContext has the values of its parent, e.g. the request ID, but not
+// its deadline or cancellation.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool)         { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}               { return nil }
+func (detachedContext) Err() error                          { return nil }
+func (c detachedContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
+
+// deduplicate wraps an admitFunc so that concurrent identical requests share
+// one validation, see DeduplicateConcurrentRequests. The validation runs with
+// the values of the context of the first request, but is not canceled with it,
+// since it is shared with requests of later deadlines. Each request still waits
+// for it at most until its own deadline, as deduplicate runs within
+// limitDeadline. Each request gets its own copy of the response, since serve
+// sets the UID of the request on it.
+func (wh *Webhook) deduplicate(admit admitFunc) admitFunc {
+	if wh.inflight == nil {
+		return admit
+	}
+	return func(ctx context.Context, request *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+		key, err := requestKey(request)
+		if err != nil {
+			return admit(ctx, request)
+		}
+		v, err, shared := wh.inflight.Do(key, func() (v interface{}, err error) {
+			// a panic would otherwise leave the concurrent requests waiting forever
+			defer func() {
+				if r := recover(); r != nil {
+					err = fmt.Errorf("validation panicked: %v", r)
+				}
+			}()
+			return admit(detachedContext{parent: ctx}, request), nil
+		})
+		if err != nil {
+			requestLog(ctx).Errorf("%v", err)
+			return toInternalErrorResponse(err)
+		}
+		if shared {
+			requestLog(ctx).Debugf("Sharing the validation of %s %s/%s with concurrent identical requests",
+				request.Kind.Kind, request.Namespace, request.Name)
+			reportRequestDeduplicated(request)
+		}
+		response, _ := v.(*admissionv1beta1.AdmissionResponse)
+		if response == nil {
+			return nil
+		}
+		return response.DeepCopy()
+	}
+}
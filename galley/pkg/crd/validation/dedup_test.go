@@ -0,0 +1,188 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// blockingAdmit counts its calls and blocks them until release is closed.
+type blockingAdmit struct {
+	calls   int32
+	release chan struct{}
+	panics  bool
+}
+
+func (b *blockingAdmit) admit(_ context.Context, request *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+	atomic.AddInt32(&b.calls, 1)
+	<-b.release
+	if b.panics {
+		panic("boom")
+	}
+	return &admissionv1beta1.AdmissionResponse{
+		Allowed: false,
+		Result:  &metav1.Status{Message: "rejected " + request.Name},
+	}
+}
+
+// admitConcurrently calls admit with the requests at the same time, releasing
+// the validations once all requests are in flight.
+func admitConcurrently(admit admitFunc, b *blockingAdmit,
+	requests []*admissionv1beta1.AdmissionRequest) []*admissionv1beta1.AdmissionResponse {
+
+	responses := make([]*admissionv1beta1.AdmissionResponse, len(requests))
+	var started, done sync.WaitGroup
+	started.Add(len(requests))
+	done.Add(len(requests))
+	for i, request := range requests {
+		go func(i int, request *admissionv1beta1.AdmissionRequest) {
+			defer done.Done()
+			started.Done()
+			responses[i] = admit(context.Background(), request)
+		}(i, request)
+	}
+	started.Wait()
+	// let the requests reach the validation before releasing it
+	time.Sleep(50 * time.Millisecond)
+	close(b.release)
+	done.Wait()
+	return responses
+}
+
+func makeDedupRequest(uid, name string) *admissionv1beta1.AdmissionRequest {
+	return &admissionv1beta1.AdmissionRequest{
+		UID:       types.UID(uid),
+		Kind:      metav1.GroupVersionKind{Kind: "Gateway"},
+		Namespace: "default",
+		Name:      name,
+		Operation: admissionv1beta1.Create,
+		Object:    runtime.RawExtension{Raw: []byte(fmt.Sprintf(`{"metadata":{"name":%q}}`, name))},
+	}
+}
+
+func TestDeduplicateIdenticalRequests(t *testing.T) {
+	const n = 50
+	wh := &Webhook{inflight: &singleflight.Group{}}
+	b := &blockingAdmit{release: make(chan struct{})}
+
+	requests := make([]*admissionv1beta1.AdmissionRequest, n)
+	for i := range requests {
+		requests[i] = makeDedupRequest(fmt.Sprintf("uid-%d", i), "bookinfo")
+	}
+	responses := admitConcurrently(wh.deduplicate(b.admit), b, requests)
+
+	if calls := atomic.LoadInt32(&b.calls); calls != 1 {
+		t.Fatalf("got %d validations of %d identical requests, want 1", calls, n)
+	}
+	seen := make(map[*admissionv1beta1.AdmissionResponse]bool)
+	for i, response := range responses {
+		if response == nil || response.Allowed || response.Result.Message != "rejected bookinfo" {
+			t.Fatalf("got response %v for request %d, want the shared rejection", response, i)
+		}
+		if seen[response] {
+			t.Fatalf("response of request %d is shared with another request, want a copy", i)
+		}
+		seen[response] = true
+	}
+}
+
+func TestDeduplicateDetachedFromFirstRequest(t *testing.T) {
+	wh := &Webhook{inflight: &singleflight.Group{}}
+	release := make(chan struct{})
+	admit := wh.deduplicate(func(ctx context.Context, request *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+		<-release
+		if ctx.Err() != nil {
+			return toAdmissionResponse(ctx.Err())
+		}
+		if id := RequestIDFromContext(ctx); id != "first" {
+			return toAdmissionResponse(fmt.Errorf("got request ID %q want that of the first request", id))
+		}
+		return &admissionv1beta1.AdmissionResponse{Allowed: true}
+	})
+
+	// the first request is canceled while the second waits for the shared validation
+	first, cancel := context.WithCancel(withRequestID(context.Background(), "first"))
+	go admit(first, makeDedupRequest("uid-1", "bookinfo"))
+	time.Sleep(50 * time.Millisecond)
+	second := make(chan *admissionv1beta1.AdmissionResponse, 1)
+	go func() { second <- admit(context.Background(), makeDedupRequest("uid-2", "bookinfo")) }()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	close(release)
+
+	if response := <-second; response == nil || !response.Allowed {
+		t.Fatalf("got response %v for the second request, want it allowed", response)
+	}
+}
+
+func TestDeduplicateDifferentRequests(t *testing.T) {
+	wh := &Webhook{inflight: &singleflight.Group{}}
+	b := &blockingAdmit{release: make(chan struct{})}
+
+	responses := admitConcurrently(wh.deduplicate(b.admit), b, []*admissionv1beta1.AdmissionRequest{
+		makeDedupRequest("uid-0", "bookinfo"),
+		makeDedupRequest("uid-1", "reviews"),
+	})
+
+	if calls := atomic.LoadInt32(&b.calls); calls != 2 {
+		t.Fatalf("got %d validations of 2 different requests, want 2", calls)
+	}
+	for i, want := range []string{"rejected bookinfo", "rejected reviews"} {
+		if responses[i].Result.Message != want {
+			t.Fatalf("got response %q for request %d, want %q", responses[i].Result.Message, i, want)
+		}
+	}
+}
+
+func TestDeduplicatePanic(t *testing.T) {
+	wh := &Webhook{inflight: &singleflight.Group{}}
+	b := &blockingAdmit{release: make(chan struct{}), panics: true}
+
+	responses := admitConcurrently(wh.deduplicate(b.admit), b, []*admissionv1beta1.AdmissionRequest{
+		makeDedupRequest("uid-0", "bookinfo"),
+		makeDedupRequest("uid-1", "bookinfo"),
+	})
+
+	for i, response := range responses {
+		if !isInternalError(response) {
+			t.Fatalf("got response %v for request %d, want an internal error", response, i)
+		}
+	}
+}
+
+func TestDeduplicateDisabled(t *testing.T) {
+	wh := &Webhook{}
+	b := &blockingAdmit{release: make(chan struct{})}
+
+	admitConcurrently(wh.deduplicate(b.admit), b, []*admissionv1beta1.AdmissionRequest{
+		makeDedupRequest("uid-0", "bookinfo"),
+		makeDedupRequest("uid-1", "bookinfo"),
+	})
+
+	if calls := atomic.LoadInt32(&b.calls); calls != 2 {
+		t.Fatalf("got %d validations of 2 identical requests, want 2 without deduplication", calls)
+	}
+}
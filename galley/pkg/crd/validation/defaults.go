@@ -0,0 +1,59 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"github.com/gogo/protobuf/proto"
+
+	networking "istio.io/api/networking/v1alpha3"
+
+	"istio.io/istio/pkg/config/schema"
+)
+
+// defaultWeight is the weight of the only destination of a route, which
+// receives all of its traffic.
+const defaultWeight = 100
+
+// defaultPilotSpec is the Defaulter of ApplyDefaultsBeforeValidate if none is
+// configured. It applies the Istio defaults that the schemas leave implicit,
+// i.e. the weight of the only destination of the routes of a VirtualService.
+func defaultPilotSpec(_ schema.Instance, spec proto.Message) {
+	virtualService, ok := spec.(*networking.VirtualService)
+	if !ok {
+		return
+	}
+	for _, route := range virtualService.Http {
+		if route != nil && len(route.Route) == 1 && route.Route[0] != nil && route.Route[0].Weight == 0 {
+			route.Route[0].Weight = defaultWeight
+		}
+	}
+	for _, route := range virtualService.Tcp {
+		if route != nil {
+			defaultRouteWeight(route.Route)
+		}
+	}
+	for _, route := range virtualService.Tls {
+		if route != nil {
+			defaultRouteWeight(route.Route)
+		}
+	}
+}
+
+// defaultRouteWeight sets the weight of the only destination of a TCP or TLS route.
+func defaultRouteWeight(destinations []*networking.RouteDestination) {
+	if len(destinations) == 1 && destinations[0] != nil && destinations[0].Weight == 0 {
+		destinations[0].Weight = defaultWeight
+	}
+}
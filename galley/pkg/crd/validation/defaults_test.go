@@ -0,0 +1,85 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"reflect"
+	"testing"
+
+	networking "istio.io/api/networking/v1alpha3"
+
+	"istio.io/istio/pkg/config/schemas"
+)
+
+func TestDefaultPilotSpec(t *testing.T) {
+	destination := func(host string, weight int32) *networking.RouteDestination {
+		return &networking.RouteDestination{Destination: &networking.Destination{Host: host}, Weight: weight}
+	}
+	spec := &networking.VirtualService{
+		Http: []*networking.HTTPRoute{
+			{Route: []*networking.HTTPRouteDestination{{Destination: &networking.Destination{Host: "a"}}}},
+			{Route: []*networking.HTTPRouteDestination{
+				{Destination: &networking.Destination{Host: "a"}, Weight: 20},
+				{Destination: &networking.Destination{Host: "b"}, Weight: 80},
+			}},
+		},
+		Tcp: []*networking.TCPRoute{{Route: []*networking.RouteDestination{destination("a", 0)}}},
+		Tls: []*networking.TLSRoute{{Route: []*networking.RouteDestination{destination("a", 30), destination("b", 0)}}},
+	}
+
+	defaultPilotSpec(schemas.VirtualService, spec)
+
+	if got := spec.Http[0].Route[0].Weight; got != defaultWeight {
+		t.Fatalf("got weight %d of the only HTTP destination want %d", got, defaultWeight)
+	}
+	if got := []int32{spec.Http[1].Route[0].Weight, spec.Http[1].Route[1].Weight}; !reflect.DeepEqual(got, []int32{20, 80}) {
+		t.Fatalf("got weights %v of the split HTTP route want [20 80]", got)
+	}
+	if got := spec.Tcp[0].Route[0].Weight; got != defaultWeight {
+		t.Fatalf("got weight %d of the only TCP destination want %d", got, defaultWeight)
+	}
+	if got := []int32{spec.Tls[0].Route[0].Weight, spec.Tls[0].Route[1].Weight}; !reflect.DeepEqual(got, []int32{30, 0}) {
+		t.Fatalf("got weights %v of the split TLS route want them unchanged", got)
+	}
+
+	// other kinds are left alone
+	defaultPilotSpec(schemas.Gateway, &networking.Gateway{})
+}
+
+func TestApplyDefaultsBeforeValidateBuiltIn(t *testing.T) {
+	wh, cancel := createTestWebhook(t, dummyClient, createFakeEndpointsSource(), dummyConfig)
+	defer cancel()
+
+	defaulted, err := NewWebhook(WebhookParameters{
+		CertFile:                    wh.certFile,
+		KeyFile:                     wh.keyFile,
+		ApplyDefaultsBeforeValidate: true,
+	})
+	if err != nil {
+		t.Fatalf("NewWebhook() failed: %v", err)
+	}
+	defer defaulted.Stop()
+
+	if defaulted.defaulter == nil {
+		t.Fatal("ApplyDefaultsBeforeValidate without a Defaulter should apply the built-in defaults")
+	}
+	spec := &networking.VirtualService{
+		Tcp: []*networking.TCPRoute{{Route: []*networking.RouteDestination{{Destination: &networking.Destination{Host: "a"}}}}},
+	}
+	defaulted.defaulter(schemas.VirtualService, spec)
+	if got := spec.Tcp[0].Route[0].Weight; got != defaultWeight {
+		t.Fatalf("got weight %d want %d", got, defaultWeight)
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"fmt"
+
+	"github.com/gogo/protobuf/proto"
+
+	"istio.io/istio/pkg/config/schema"
+	"istio.io/istio/pkg/config/schemas"
+)
+
+// emptySpecKinds are the types whose objects have no effect with an empty spec.
+// Kinds whose empty spec is meaningful, e.g. a Policy that disables
+// authentication, are not checked.
+var emptySpecKinds = map[string]bool{
+	schemas.VirtualService.Type:  true,
+	schemas.DestinationRule.Type: true,
+	schemas.Gateway.Type:         true,
+	schemas.ServiceEntry.Type:    true,
+	schemas.EnvoyFilter.Type:     true,
+	schemas.Sidecar.Type:         true,
+}
+
+// validateNonEmptySpec returns an error naming the kind if the spec of a kind
+// in emptySpecKinds has no fields set.
+func validateNonEmptySpec(s schema.Instance, kind string, spec proto.Message) error {
+	if !emptySpecKinds[s.Type] || spec == nil || proto.Size(spec) > 0 {
+		return nil
+	}
+	return fmt.Errorf("%s spec is empty, the object would have no effect", kind)
+}
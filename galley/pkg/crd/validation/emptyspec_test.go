@@ -0,0 +1,91 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gogo/protobuf/proto"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	authn "istio.io/api/authentication/v1alpha1"
+	networking "istio.io/api/networking/v1alpha3"
+
+	"istio.io/istio/pilot/test/mock"
+	"istio.io/istio/pkg/config/schema"
+	"istio.io/istio/pkg/config/schemas"
+)
+
+func TestValidateNonEmptySpec(t *testing.T) {
+	cases := []struct {
+		name    string
+		schema  schema.Instance
+		spec    proto.Message
+		wantErr bool
+	}{
+		{name: "empty virtual service", schema: schemas.VirtualService, spec: &networking.VirtualService{}, wantErr: true},
+		{name: "virtual service", schema: schemas.VirtualService, spec: &networking.VirtualService{Hosts: []string{"reviews"}}},
+		{name: "empty sidecar", schema: schemas.Sidecar, spec: &networking.Sidecar{}, wantErr: true},
+		{name: "empty authentication policy", schema: schemas.AuthenticationPolicy, spec: &authn.Policy{}},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			err := validateNonEmptySpec(c.schema, "Kind", c.spec)
+			if gotErr := err != nil; gotErr != c.wantErr {
+				t.Fatalf("got error %v want error %v", err, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestAdmitPilotRejectEmptySpec(t *testing.T) {
+	wh, cancel := createTestWebhook(t, dummyClient, createFakeEndpointsSource(), dummyConfig)
+	defer cancel()
+	descriptor := append(append(schema.Set{}, schemas.Istio...), mock.Types...)
+	if err := wh.ReloadValidators(descriptor, wh.activeValidators().mixer); err != nil {
+		t.Fatalf("ReloadValidators() failed: %v", err)
+	}
+
+	vs := makeIstioKind(t, schemas.VirtualService, "default", "reviews", &networking.VirtualService{})
+	raw, err := json.Marshal(&vs)
+	if err != nil {
+		t.Fatalf("Marshal(%v) failed: %v", vs.Name, err)
+	}
+	request := &admissionv1beta1.AdmissionRequest{
+		Kind:      metav1.GroupVersionKind{Kind: "VirtualService"},
+		Namespace: "default",
+		Object:    runtime.RawExtension{Raw: raw},
+		Operation: admissionv1beta1.Create,
+	}
+	const want = "VirtualService spec is empty"
+
+	resp := wh.admitPilot(context.Background(), request)
+	if resp.Allowed || strings.Contains(resp.Result.Message, want) {
+		t.Fatalf("got %v want the schema rejection only", resp.Result)
+	}
+
+	wh.rejectEmptySpec = true
+	resp = wh.admitPilot(context.Background(), request)
+	if resp.Allowed || !strings.Contains(resp.Result.Message, want) {
+		t.Fatalf("got %v want a rejection containing %q", resp.Result, want)
+	}
+}
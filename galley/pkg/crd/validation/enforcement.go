@@ -0,0 +1,147 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"context"
+	"fmt"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// enforcement is the validation enforcement mode, set at runtime by the
+// enforcement ConfigMap.
+type enforcement string
+
+const (
+	// enforcementOn rejects invalid objects.
+	enforcementOn enforcement = "on"
+	// enforcementWarn admits invalid objects, logging the rejection they would have received.
+	enforcementWarn enforcement = "warn"
+	// enforcementOff admits all objects without validating them.
+	enforcementOff enforcement = "off"
+
+	defaultEnforcementConfigMapKey = "enforcement"
+)
+
+type createInformerConfigMapSource func(cl clientset.Interface, namespace, name string) cache.ListerWatcher
+
+var (
+	defaultCreateInformerConfigMapSource = func(cl clientset.Interface, namespace, name string) cache.ListerWatcher {
+		return cache.NewListWatchFromClient(
+			cl.CoreV1().RESTClient(),
+			"configmaps",
+			namespace,
+			fields.ParseSelectorOrDie(fmt.Sprintf("metadata.name=%s", name)))
+	}
+)
+
+// parseEnforcement returns the enforcement mode of the ConfigMap value. An
+// empty value enforces validation.
+func parseEnforcement(value string) (enforcement, error) {
+	switch e := enforcement(value); e {
+	case "", enforcementOn:
+		return enforcementOn, nil
+	case enforcementWarn, enforcementOff:
+		return e, nil
+	default:
+		return enforcementOn, fmt.Errorf("unknown enforcement mode %q, want one of %q, %q or %q",
+			value, enforcementOn, enforcementWarn, enforcementOff)
+	}
+}
+
+func (wh *Webhook) enforcementMode() enforcement {
+	if mode, ok := wh.enforcement.Load().(enforcement); ok {
+		return mode
+	}
+	return enforcementOn
+}
+
+// setEnforcement updates the enforcement mode from the ConfigMap, which is nil if
+// it was deleted. Unknown modes enforce validation.
+func (wh *Webhook) setEnforcement(cm *v1.ConfigMap) {
+	mode := enforcementOn
+	if cm != nil {
+		var err error
+		if mode, err = parseEnforcement(cm.Data[wh.enforcementConfigMapKey]); err != nil {
+			scope.Errorf("Invalid %q in ConfigMap %s/%s, enforcing validation: %v",
+				wh.enforcementConfigMapKey, cm.Namespace, cm.Name, err)
+		}
+	}
+
+	if prev := wh.enforcementMode(); prev != mode {
+		wh.enforcement.Store(mode)
+		scope.Warnf("!!! Validation enforcement changed from %q to %q by ConfigMap %s/%s !!!",
+			prev, mode, wh.deploymentAndServiceNamespace, wh.enforcementConfigMapName)
+	}
+}
+
+// watchEnforcement watches the enforcement ConfigMap until stopped.
+func (wh *Webhook) watchEnforcement(stopCh <-chan struct{}) {
+	_, controller := cache.NewInformer(
+		wh.createInformerConfigMapSource(wh.clientset, wh.deploymentAndServiceNamespace, wh.enforcementConfigMapName),
+		&v1.ConfigMap{},
+		0,
+		cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+				wh.setEnforcement(obj.(*v1.ConfigMap))
+				wh.setDisabledRules(obj.(*v1.ConfigMap))
+			},
+			UpdateFunc: func(prev, curr interface{}) {
+				wh.setEnforcement(curr.(*v1.ConfigMap))
+				wh.setDisabledRules(curr.(*v1.ConfigMap))
+			},
+			DeleteFunc: func(obj interface{}) {
+				wh.setEnforcement(nil)
+				wh.setDisabledRules(nil)
+			},
+		},
+	)
+	controller.Run(stopCh)
+}
+
+// enforced applies the enforcement mode to the admission decisions of admit. The
+// objects of namespaces in maintenance are validated warn-only unless validation is off.
+func (wh *Webhook) enforced(admit admitFunc) admitFunc {
+	return func(ctx context.Context, request *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+		mode, source := wh.enforcementMode(), "Validation enforcement"
+		if mode == enforcementOn && wh.namespaceInMaintenance(request.Namespace) {
+			mode, source = enforcementWarn, fmt.Sprintf("Validation of namespace %s", request.Namespace)
+		}
+
+		switch mode {
+		case enforcementOff:
+			return wh.acceptResponse()
+		case enforcementWarn:
+			response := admit(ctx, request)
+			if response != nil && !response.Allowed {
+				var reason string
+				if response.Result != nil {
+					reason = response.Result.Message
+				}
+				requestLog(ctx).Warnf("%s is %q, admitting %s of %s %s/%s: %s",
+					source, enforcementWarn, request.Operation, request.Kind.Kind, request.Namespace, request.Name, reason)
+				return wh.acceptResponse()
+			}
+			return response
+		default:
+			return admit(ctx, request)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestParseEnforcement(t *testing.T) {
+	cases := []struct {
+		value   string
+		want    enforcement
+		wantErr bool
+	}{
+		{value: "", want: enforcementOn},
+		{value: "on", want: enforcementOn},
+		{value: "warn", want: enforcementWarn},
+		{value: "off", want: enforcementOff},
+		{value: "OFF", want: enforcementOn, wantErr: true},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %q", i, c.value), func(t *testing.T) {
+			got, err := parseEnforcement(c.value)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("got error %v want error %v", err, c.wantErr)
+			}
+			if got != c.want {
+				t.Fatalf("got %q want %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestEnforced(t *testing.T) {
+	wh := &Webhook{enforcementConfigMapKey: defaultEnforcementConfigMapKey}
+
+	var admitted int
+	reject := func(context.Context, *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+		admitted++
+		return toAdmissionResponse(fmt.Errorf("invalid"))
+	}
+	admit := wh.enforced(reject)
+	request := &admissionv1beta1.AdmissionRequest{Kind: metav1.GroupVersionKind{Kind: "mock"}}
+
+	cases := []struct {
+		mode         string
+		allowed      bool
+		wantAdmitted int
+	}{
+		{mode: "on", allowed: false, wantAdmitted: 1},
+		{mode: "warn", allowed: true, wantAdmitted: 1},
+		{mode: "off", allowed: true, wantAdmitted: 0},
+		{mode: "bogus", allowed: false, wantAdmitted: 1},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.mode), func(t *testing.T) {
+			wh.setEnforcement(&v1.ConfigMap{Data: map[string]string{defaultEnforcementConfigMapKey: c.mode}})
+			admitted = 0
+			if got := admit(context.Background(), request).Allowed; got != c.allowed {
+				t.Fatalf("got allowed %v want %v", got, c.allowed)
+			}
+			if admitted != c.wantAdmitted {
+				t.Fatalf("admit called %d times want %d", admitted, c.wantAdmitted)
+			}
+		})
+	}
+
+	wh.setEnforcement(&v1.ConfigMap{Data: map[string]string{defaultEnforcementConfigMapKey: "off"}})
+	wh.setEnforcement(nil)
+	if got := wh.enforcementMode(); got != enforcementOn {
+		t.Fatalf("got %q after ConfigMap deletion want %q", got, enforcementOn)
+	}
+}
+
+func TestWatchEnforcement(t *testing.T) {
+	cl := fake.NewSimpleClientset()
+	wh := &Webhook{
+		clientset:                     cl,
+		deploymentAndServiceNamespace: "istio-system",
+		enforcementConfigMapName:      "galley-enforcement",
+		enforcementConfigMapKey:       defaultEnforcementConfigMapKey,
+		createInformerConfigMapSource: func(cl clientset.Interface, namespace, name string) cache.ListerWatcher {
+			return &cache.ListWatch{
+				ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+					return cl.CoreV1().ConfigMaps(namespace).List(options)
+				},
+				WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+					return cl.CoreV1().ConfigMaps(namespace).Watch(options)
+				},
+			}
+		},
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	go wh.watchEnforcement(stop)
+
+	waitFor := func(want enforcement) {
+		t.Helper()
+		deadline := time.Now().Add(10 * time.Second)
+		for wh.enforcementMode() != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for enforcement %q, got %q", want, wh.enforcementMode())
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	cm := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "galley-enforcement", Namespace: "istio-system"},
+		Data:       map[string]string{defaultEnforcementConfigMapKey: "off"},
+	}
+	if _, err := cl.CoreV1().ConfigMaps(cm.Namespace).Create(cm); err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	waitFor(enforcementOff)
+
+	cm.Data[defaultEnforcementConfigMapKey] = "warn"
+	if _, err := cl.CoreV1().ConfigMaps(cm.Namespace).Update(cm); err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+	waitFor(enforcementWarn)
+
+	if err := cl.CoreV1().ConfigMaps(cm.Namespace).Delete(cm.Name, &metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("Delete() failed: %v", err)
+	}
+	waitFor(enforcementOn)
+}
@@ -0,0 +1,38 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"errors"
+
+	"github.com/hashicorp/go-multierror"
+
+	networking "istio.io/api/networking/v1alpha3"
+)
+
+// validateEnvoyFilterFields rejects the deprecated fields of an EnvoyFilter.
+// Pilot still applies workloadLabels and filters, but they are superseded by
+// workloadSelector and configPatches, which the schema validation checks more
+// thoroughly.
+func validateEnvoyFilterFields(filter *networking.EnvoyFilter) error {
+	var errs *multierror.Error
+	if len(filter.WorkloadLabels) > 0 {
+		errs = multierror.Append(errs, errors.New("workloadLabels is deprecated, use workloadSelector"))
+	}
+	if len(filter.Filters) > 0 {
+		errs = multierror.Append(errs, errors.New("filters is deprecated, use configPatches"))
+	}
+	return errs.ErrorOrNil()
+}
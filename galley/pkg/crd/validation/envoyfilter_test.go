@@ -0,0 +1,113 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	networking "istio.io/api/networking/v1alpha3"
+
+	"istio.io/istio/pilot/test/mock"
+	"istio.io/istio/pkg/config/schema"
+	"istio.io/istio/pkg/config/schemas"
+)
+
+func TestValidateEnvoyFilterFields(t *testing.T) {
+	cases := []struct {
+		name    string
+		filter  *networking.EnvoyFilter
+		wantErr []string
+	}{
+		{name: "config patches", filter: &networking.EnvoyFilter{
+			WorkloadSelector: &networking.WorkloadSelector{Labels: map[string]string{"app": "api"}},
+			ConfigPatches:    []*networking.EnvoyFilter_EnvoyConfigObjectPatch{{ApplyTo: networking.EnvoyFilter_LISTENER}},
+		}},
+		{
+			name:    "workload labels",
+			filter:  &networking.EnvoyFilter{WorkloadLabels: map[string]string{"app": "api"}},
+			wantErr: []string{"workloadLabels is deprecated, use workloadSelector"},
+		},
+		{
+			name: "workload labels and filters",
+			filter: &networking.EnvoyFilter{
+				WorkloadLabels: map[string]string{"app": "api"},
+				Filters:        []*networking.EnvoyFilter_Filter{{FilterName: "envoy.lua"}},
+			},
+			wantErr: []string{"workloadLabels is deprecated", "filters is deprecated, use configPatches"},
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			err := validateEnvoyFilterFields(c.filter)
+			if len(c.wantErr) == 0 {
+				if err != nil {
+					t.Fatalf("got unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("got no error want %q", c.wantErr)
+			}
+			for _, want := range c.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Fatalf("got error %v want it to contain %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestAdmitPilotStrictEnvoyFilter(t *testing.T) {
+	wh, cancel := createTestWebhook(t, dummyClient, createFakeEndpointsSource(), dummyConfig)
+	defer cancel()
+	descriptor := append(append(schema.Set{}, schemas.Istio...), mock.Types...)
+	if err := wh.ReloadValidators(descriptor, wh.activeValidators().mixer); err != nil {
+		t.Fatalf("ReloadValidators() failed: %v", err)
+	}
+
+	filter := makeIstioKind(t, schemas.EnvoyFilter, "default", "lua", &networking.EnvoyFilter{
+		WorkloadLabels: map[string]string{"app": "api"},
+	})
+	raw, err := json.Marshal(&filter)
+	if err != nil {
+		t.Fatalf("Marshal(%v) failed: %v", filter.Name, err)
+	}
+	request := &admissionv1beta1.AdmissionRequest{
+		Kind:      metav1.GroupVersionKind{Kind: "EnvoyFilter"},
+		Namespace: "default",
+		Object:    runtime.RawExtension{Raw: raw},
+		Operation: admissionv1beta1.Create,
+	}
+
+	if resp := wh.admitPilot(context.Background(), request); !resp.Allowed {
+		t.Fatalf("got %v want the envoy filter allowed", resp.Result)
+	}
+
+	wh.strictEnvoyFilter = true
+	const want = "workloadLabels is deprecated, use workloadSelector"
+	resp := wh.admitPilot(context.Background(), request)
+	if resp.Allowed || !strings.Contains(resp.Result.Message, want) {
+		t.Fatalf("got %v want a rejection containing %q", resp.Result, want)
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	kubeschema "k8s.io/apimachinery/pkg/runtime/schema"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+const (
+	rejectionEventComponent = "galley-validation"
+	rejectionEventReason    = "ValidationFailed"
+
+	// rejectionEventInterval is the minimum interval between events for the same object.
+	rejectionEventInterval = time.Minute
+)
+
+// rejectionEvents records a Warning event for each rejected object, at most once
+// per rejectionEventInterval for each object. It is a DecisionSink, so it is only
+// invoked from the decision sink goroutine.
+type rejectionEvents struct {
+	recorder record.EventRecorder
+	interval time.Duration
+	now      func() time.Time
+
+	// last is the time of the last event for each object.
+	last map[string]time.Time
+}
+
+func newRejectionEvents(cl clientset.Interface) *rejectionEvents {
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: cl.CoreV1().Events("")})
+	return &rejectionEvents{
+		recorder: broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: rejectionEventComponent}),
+		interval: rejectionEventInterval,
+		now:      time.Now,
+		last:     make(map[string]time.Time),
+	}
+}
+
+func (e *rejectionEvents) sink(decision DecisionRecord) {
+	if decision.Allowed {
+		return
+	}
+
+	now := e.now()
+	for key, last := range e.last {
+		if now.Sub(last) >= e.interval {
+			delete(e.last, key)
+		}
+	}
+	key := fmt.Sprintf("%s/%s/%s/%s", decision.Kind.Group, decision.Kind.Kind, decision.Namespace, decision.Name)
+	if _, ok := e.last[key]; ok {
+		return
+	}
+	e.last[key] = now
+
+	ref := &corev1.ObjectReference{
+		APIVersion: kubeschema.GroupVersion{Group: decision.Kind.Group, Version: decision.Kind.Version}.String(),
+		Kind:       decision.Kind.Kind,
+		Namespace:  decision.Namespace,
+		Name:       decision.Name,
+		UID:        decision.UID,
+	}
+	e.recorder.Eventf(ref, corev1.EventTypeWarning, rejectionEventReason,
+		"Rejected %s of %s %s: %s", decision.Operation, decision.Kind.Kind, decision.Name, decision.Error)
+}
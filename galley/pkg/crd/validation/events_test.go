@@ -0,0 +1,97 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"testing"
+	"time"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestRejectionEvents(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	now := time.Unix(0, 0)
+	events := &rejectionEvents{
+		recorder: recorder,
+		interval: time.Minute,
+		now:      func() time.Time { return now },
+		last:     make(map[string]time.Time),
+	}
+
+	rejected := DecisionRecord{
+		Kind:      metav1.GroupVersionKind{Group: "networking.istio.io", Version: "v1alpha3", Kind: "Gateway"},
+		Name:      "ingress",
+		Namespace: "istio-system",
+		Operation: admissionv1beta1.Create,
+		Error:     "configuration is invalid",
+	}
+	other := rejected
+	other.Name = "egress"
+	allowed := rejected
+	allowed.Allowed = true
+	allowed.Error = ""
+
+	cases := []struct {
+		name     string
+		advance  time.Duration
+		decision DecisionRecord
+		want     string
+	}{
+		{
+			name:     "allowed",
+			decision: allowed,
+		},
+		{
+			name:     "rejected",
+			decision: rejected,
+			want:     "Warning ValidationFailed Rejected CREATE of Gateway ingress: configuration is invalid",
+		},
+		{
+			name:     "rate limited",
+			advance:  30 * time.Second,
+			decision: rejected,
+		},
+		{
+			name:     "other object",
+			decision: other,
+			want:     "Warning ValidationFailed Rejected CREATE of Gateway egress: configuration is invalid",
+		},
+		{
+			name:     "after interval",
+			advance:  30 * time.Second,
+			decision: rejected,
+			want:     "Warning ValidationFailed Rejected CREATE of Gateway ingress: configuration is invalid",
+		},
+	}
+
+	for _, c := range cases {
+		now = now.Add(c.advance)
+		events.sink(c.decision)
+
+		select {
+		case got := <-recorder.Events:
+			if got != c.want {
+				t.Fatalf("%s: got event %q want %q", c.name, got, c.want)
+			}
+		default:
+			if c.want != "" {
+				t.Fatalf("%s: got no event want %q", c.name, c.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+
+	networking "istio.io/api/networking/v1alpha3"
+
+	"istio.io/istio/pkg/config/host"
+)
+
+// gatewayHost is a host exposed by a Gateway server.
+type gatewayHost struct {
+	server    int
+	raw       string
+	namespace string
+	name      host.Name
+}
+
+// overlaps returns true if both hosts can match the same virtual service host.
+func (h gatewayHost) overlaps(o gatewayHost) bool {
+	if h.namespace != o.namespace && h.namespace != "*" && o.namespace != "*" {
+		return false
+	}
+	return h.name.Matches(o.name)
+}
+
+func parseGatewayHost(server int, h string) gatewayHost {
+	namespace, name := "*", h
+	if i := strings.Index(h, "/"); i >= 0 {
+		namespace, name = h[:i], h[i+1:]
+	}
+	return gatewayHost{server: server, raw: h, namespace: namespace, name: host.Name(name)}
+}
+
+// validateGatewayServers rejects Gateways that declare duplicate or overlapping
+// hosts on the same port and bind address in different servers. Such servers
+// are ambiguous, and the routing of the host is undefined.
+func validateGatewayServers(gateway *networking.Gateway) error {
+	hostsByPort := make(map[string][]gatewayHost)
+
+	var errs *multierror.Error
+	for i, server := range gateway.Servers {
+		if server == nil || server.Port == nil {
+			continue
+		}
+		key := fmt.Sprintf("%s:%d", server.Bind, server.Port.Number)
+		existing := hostsByPort[key]
+		for _, h := range server.Hosts {
+			gh := parseGatewayHost(i, h)
+			for _, o := range existing {
+				if gh.overlaps(o) {
+					errs = multierror.Append(errs, fmt.Errorf("host %q of server %d overlaps host %q of server %d on port %d",
+						h, i, o.raw, o.server, server.Port.Number))
+				}
+			}
+			hostsByPort[key] = append(hostsByPort[key], gh)
+		}
+	}
+	return errs.ErrorOrNil()
+}
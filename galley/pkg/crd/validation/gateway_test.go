@@ -0,0 +1,131 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	networking "istio.io/api/networking/v1alpha3"
+
+	"istio.io/istio/pilot/test/mock"
+	"istio.io/istio/pkg/config/schema"
+	"istio.io/istio/pkg/config/schemas"
+)
+
+func makeServer(number uint32, bind string, hosts ...string) *networking.Server {
+	return &networking.Server{
+		Port:  &networking.Port{Number: number, Protocol: "HTTP", Name: fmt.Sprintf("http-%d", number)},
+		Bind:  bind,
+		Hosts: hosts,
+	}
+}
+
+func TestValidateGatewayServers(t *testing.T) {
+	cases := []struct {
+		name    string
+		servers []*networking.Server
+		wantErr string
+	}{
+		{
+			name:    "distinct hosts on the same port",
+			servers: []*networking.Server{makeServer(80, "", "foo.com"), makeServer(80, "", "bar.com")},
+		},
+		{
+			name:    "same host on different ports",
+			servers: []*networking.Server{makeServer(80, "", "foo.com"), makeServer(8080, "", "foo.com")},
+		},
+		{
+			name:    "same host on different bind addresses",
+			servers: []*networking.Server{makeServer(80, "10.0.0.1", "foo.com"), makeServer(80, "10.0.0.2", "foo.com")},
+		},
+		{
+			name:    "same host in different namespaces",
+			servers: []*networking.Server{makeServer(80, "", "ns1/foo.com"), makeServer(80, "", "ns2/foo.com")},
+		},
+		{
+			name:    "duplicate host",
+			servers: []*networking.Server{makeServer(80, "", "foo.com"), makeServer(80, "", "foo.com")},
+			wantErr: `host "foo.com" of server 1 overlaps host "foo.com" of server 0 on port 80`,
+		},
+		{
+			name:    "wildcard overlap",
+			servers: []*networking.Server{makeServer(443, "", "*.foo.com"), makeServer(443, "", "bar.foo.com")},
+			wantErr: `host "bar.foo.com" of server 1 overlaps host "*.foo.com" of server 0 on port 443`,
+		},
+		{
+			name:    "namespace wildcard overlap",
+			servers: []*networking.Server{makeServer(80, "", "*/foo.com"), makeServer(80, "", "ns1/foo.com")},
+			wantErr: `host "ns1/foo.com" of server 1 overlaps host "*/foo.com" of server 0 on port 80`,
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			err := validateGatewayServers(&networking.Gateway{Servers: c.servers})
+			if c.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+				t.Fatalf("got error %v want %v", err, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestAdmitPilotStrictGateway(t *testing.T) {
+	duplicate := makeServer(80, "", "foo.com")
+	duplicate.Port.Name = "http-duplicate"
+	gateway := makeIstioKind(t, schemas.Gateway, "istio-system", "ingress", &networking.Gateway{
+		Selector: map[string]string{"istio": "ingressgateway"},
+		Servers:  []*networking.Server{makeServer(80, "", "foo.com"), duplicate},
+	})
+	raw, err := json.Marshal(&gateway)
+	if err != nil {
+		t.Fatalf("Marshal(%v) failed: %v", gateway.Name, err)
+	}
+
+	wh, cancel := createTestWebhook(t, dummyClient, createFakeEndpointsSource(), dummyConfig)
+	defer cancel()
+	descriptor := append(append(schema.Set{}, schemas.Istio...), mock.Types...)
+	if err := wh.ReloadValidators(descriptor, wh.activeValidators().mixer); err != nil {
+		t.Fatalf("ReloadValidators() failed: %v", err)
+	}
+
+	for _, strict := range []bool{false, true} {
+		t.Run(fmt.Sprintf("strict=%v", strict), func(t *testing.T) {
+			wh.strictGateway = strict
+			got := wh.admitPilot(context.Background(), &admissionv1beta1.AdmissionRequest{
+				Kind:      metav1.GroupVersionKind{Kind: "Gateway"},
+				Namespace: "istio-system",
+				Object:    runtime.RawExtension{Raw: raw},
+				Operation: admissionv1beta1.Create,
+			})
+			if got.Allowed == strict {
+				t.Fatalf("got %v want %v: %v", got.Allowed, !strict, got.Result)
+			}
+		})
+	}
+}
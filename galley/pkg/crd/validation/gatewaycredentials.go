@@ -0,0 +1,75 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"fmt"
+	"sort"
+
+	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientset "k8s.io/client-go/kubernetes"
+
+	networking "istio.io/api/networking/v1alpha3"
+)
+
+// secretLookup returns whether the secret exists in the namespace.
+type secretLookup func(namespace, name string) (bool, error)
+
+// kubeSecretLookup looks up secrets on the API server.
+func kubeSecretLookup(cl clientset.Interface) secretLookup {
+	return func(namespace, name string) (bool, error) {
+		_, err := cl.CoreV1().Secrets(namespace).Get(name, metav1.GetOptions{})
+		if kubeerrors.IsNotFound(err) {
+			return false, nil
+		}
+		if kubeerrors.IsForbidden(err) {
+			return false, fmt.Errorf("not permitted to read secrets, grant it in the Galley ClusterRole: %v", err)
+		}
+		return err == nil, err
+	}
+}
+
+// gatewayCredentialNames returns the sorted distinct credentialNames of the
+// servers of the gateway.
+func gatewayCredentialNames(gateway *networking.Gateway) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, server := range gateway.Servers {
+		if server.GetTls().GetCredentialName() == "" || seen[server.Tls.CredentialName] {
+			continue
+		}
+		seen[server.Tls.CredentialName] = true
+		names = append(names, server.Tls.CredentialName)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// missingGatewayCredentials returns the sorted credentialNames of the gateway
+// whose Secret does not exist in the namespace, see CheckGatewayCredentials.
+func (wh *Webhook) missingGatewayCredentials(namespace string, gateway *networking.Gateway) ([]string, error) {
+	var missing []string
+	for _, name := range gatewayCredentialNames(gateway) {
+		exists, err := wh.gatewayCredentials(namespace, name)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			missing = append(missing, name)
+		}
+	}
+	return missing, nil
+}
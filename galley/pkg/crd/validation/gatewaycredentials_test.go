@@ -0,0 +1,123 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+
+	networking "istio.io/api/networking/v1alpha3"
+
+	"istio.io/istio/pkg/config/schemas"
+)
+
+func TestGatewayCredentialNames(t *testing.T) {
+	gateway := &networking.Gateway{Servers: []*networking.Server{
+		{Tls: &networking.Server_TLSOptions{CredentialName: "web"}},
+		{Tls: &networking.Server_TLSOptions{ServerCertificate: "cert.pem"}},
+		{},
+		{Tls: &networking.Server_TLSOptions{CredentialName: "api"}},
+		{Tls: &networking.Server_TLSOptions{CredentialName: "web"}},
+	}}
+	if got, want := gatewayCredentialNames(gateway), []string{"api", "web"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v want %v", got, want)
+	}
+}
+
+func TestAdmitPilotGatewayCredentials(t *testing.T) {
+	wh, cancel := createTestWebhook(t, dummyClient, createFakeEndpointsSource(), dummyConfig)
+	defer cancel()
+	if err := wh.ReloadValidators(schemas.Istio, wh.activeValidators().mixer); err != nil {
+		t.Fatalf("ReloadValidators() failed: %v", err)
+	}
+
+	secrets := kubeSecretLookup(fake.NewSimpleClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "present"},
+	}))
+	unavailable := func(string, string) (bool, error) { return false, errors.New("connection refused") }
+
+	cases := []struct {
+		name           string
+		credentialName string
+		lookup         secretLookup
+		reject         bool
+		degrade        bool
+		wantAllowed    bool
+		wantInternal   bool
+	}{
+		{name: "present", credentialName: "present", lookup: secrets, reject: true, wantAllowed: true},
+		{name: "missing warns", credentialName: "absent", lookup: secrets, wantAllowed: true},
+		{name: "missing rejected", credentialName: "absent", lookup: secrets, reject: true},
+		{name: "lookup error", credentialName: "present", lookup: unavailable, wantInternal: true},
+		{name: "lookup error degraded", credentialName: "present", lookup: unavailable, degrade: true, wantAllowed: true},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			wh.gatewayCredentials = c.lookup
+			wh.rejectMissingCredentials = c.reject
+			wh.degradeReferenceChecks = c.degrade
+
+			gateway := makeIstioKind(t, schemas.Gateway, "default", "gateway", &networking.Gateway{
+				Selector: map[string]string{"istio": "ingressgateway"},
+				Servers: []*networking.Server{{
+					Port:  &networking.Port{Number: 443, Protocol: "HTTPS", Name: "https"},
+					Hosts: []string{"example.com"},
+					Tls:   &networking.Server_TLSOptions{Mode: networking.Server_TLSOptions_SIMPLE, CredentialName: c.credentialName},
+				}},
+			})
+			raw, err := json.Marshal(&gateway)
+			if err != nil {
+				t.Fatalf("Marshal(%v) failed: %v", gateway.Name, err)
+			}
+			resp := wh.admitPilot(context.Background(), &admissionv1beta1.AdmissionRequest{
+				Kind:      metav1.GroupVersionKind{Kind: "Gateway"},
+				Namespace: "default",
+				Object:    runtime.RawExtension{Raw: raw},
+				Operation: admissionv1beta1.Create,
+			})
+			if resp.Allowed != c.wantAllowed {
+				t.Fatalf("got allowed %v want %v: %v", resp.Allowed, c.wantAllowed, resp.Result)
+			}
+			if c.wantAllowed {
+				return
+			}
+			if isInternalError(resp) != c.wantInternal {
+				t.Fatalf("got %v want internal error %v", resp.Result, c.wantInternal)
+			}
+			if c.wantInternal {
+				return
+			}
+			if !strings.Contains(resp.Result.Message, "do not exist in namespace default: absent") {
+				t.Fatalf("got message %q want the missing secret", resp.Result.Message)
+			}
+			want := violationCause("IST0115", "spec.servers")
+			if resp.Result.Details == nil || !reflect.DeepEqual(resp.Result.Details.Causes, []metav1.StatusCause{want}) {
+				t.Fatalf("got %v want the cause %v", resp.Result.Details, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/ghodss/yaml"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	grpcstatus "google.golang.org/grpc/status"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+
+	"istio.io/istio/galley/pkg/crd/validation/validationpb"
+)
+
+// validationServer serves the validators of the webhook over gRPC, so that tools
+// can validate objects without the Kubernetes admission envelope.
+type validationServer struct {
+	wh *Webhook
+}
+
+var _ validationpb.ValidationServer = &validationServer{}
+
+// newGRPCServer returns a gRPC server of the validation service, serving with the
+// same cert and client authentication as the admission server.
+func (wh *Webhook) newGRPCServer() *grpc.Server {
+	server := grpc.NewServer(grpc.Creds(credentials.NewTLS(wh.server.TLSConfig.Clone())))
+	validationpb.RegisterValidationServer(server, &validationServer{wh: wh})
+	return server
+}
+
+// serveGRPC serves the validation service on the gRPC address until the server is stopped.
+func (wh *Webhook) serveGRPC() error {
+	listener, err := net.Listen("tcp", wh.grpcAddress)
+	if err != nil {
+		return fmt.Errorf("validation gRPC listen failed: %v", err)
+	}
+	if err := wh.grpcServer.Serve(listener); err != nil && err != grpc.ErrServerStopped {
+		return fmt.Errorf("validation gRPC Serve failed: %v", err)
+	}
+	return nil
+}
+
+// Validate validates the object as if it were created. Objects are routed to the
+// pilot or mixer validators by kind like those of ValidateYAML, and admitted by
+// the same checks as admission requests, but not subject to the admission
+// controls of the webhook, e.g. the enforcement mode or namespace throttling.
+// Objects of kinds without validation get the DefaultDecisionForUnmatched.
+func (s *validationServer) Validate(ctx context.Context, req *validationpb.ValidateRequest) (*validationpb.ValidateResponse, error) {
+	if req.Version == "" || req.Kind == "" {
+		return nil, grpcstatus.Error(codes.InvalidArgument, "version and kind are required")
+	}
+	raw, err := yaml.YAMLToJSON(req.Object)
+	if err != nil {
+		return nil, grpcstatus.Errorf(codes.InvalidArgument, "cannot decode object: %v", err)
+	}
+
+	id := uuid.New().String()
+	ctx = withRequestID(ctx, id)
+	request := &admissionv1beta1.AdmissionRequest{
+		UID:       types.UID(id),
+		Kind:      v1.GroupVersionKind{Group: req.Group, Version: req.Version, Kind: req.Kind},
+		Namespace: req.Namespace,
+		Operation: admissionv1beta1.Create,
+		Object:    runtime.RawExtension{Raw: raw},
+	}
+
+	admit, ok := s.wh.routeObject(request)
+	if !ok {
+		admit = func(ctx context.Context, request *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+			return s.wh.admitUnmatched(ctx, request, request.Kind.Kind, UnmatchedReject)
+		}
+	}
+	response := s.wh.validateWith(admit)(ctx, request)
+
+	resp := &validationpb.ValidateResponse{Allowed: response.Allowed}
+	if !response.Allowed && response.Result != nil {
+		resp.Errors = []string{response.Result.Message}
+	}
+	return resp, nil
+}
@@ -0,0 +1,199 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	grpcstatus "google.golang.org/grpc/status"
+
+	"istio.io/istio/galley/pkg/crd/validation/validationpb"
+	"istio.io/istio/pilot/test/mock"
+	"istio.io/istio/pkg/config/schema"
+	"istio.io/istio/pkg/config/schemas"
+	"istio.io/istio/pkg/mcp/testing/testcerts"
+)
+
+// startValidationServer serves the validation service of the webhook and
+// returns a client of it, and a function stopping both.
+func startValidationServer(t *testing.T, wh *Webhook) (validationpb.ValidationClient, func()) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() failed: %v", err)
+	}
+	server := wh.newGRPCServer()
+	go server.Serve(listener) // nolint: errcheck
+
+	conn, err := grpc.Dial(listener.Addr().String(),
+		grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{InsecureSkipVerify: true})))
+	if err != nil {
+		server.Stop()
+		t.Fatalf("Dial() failed: %v", err)
+	}
+	return validationpb.NewValidationClient(conn), func() {
+		conn.Close() // nolint: errcheck
+		server.Stop()
+	}
+}
+
+func TestValidationServer(t *testing.T) {
+	wh, cancel := createTestWebhook(t, dummyClient, createFakeEndpointsSource(), dummyConfig)
+	defer cancel()
+	descriptor := append(append(schema.Set{}, schemas.Istio...), mock.Types...)
+	mixer := &kindValidator{fakeValidator: fakeValidator{err: errors.New("fake mixer error")}, kinds: map[string]bool{"rule": true}}
+	if err := wh.ReloadValidators(descriptor, mixer); err != nil {
+		t.Fatalf("ReloadValidators() failed: %v", err)
+	}
+	client, stop := startValidationServer(t, wh)
+	defer stop()
+
+	validYAML, err := yaml.JSONToYAML(makePilotConfig(t, 0, true, false))
+	if err != nil {
+		t.Fatalf("JSONToYAML() failed: %v", err)
+	}
+	mock := func(object []byte) *validationpb.ValidateRequest {
+		return &validationpb.ValidateRequest{Group: "test.istio.io", Version: "v1", Kind: "MockConfig", Namespace: "default",
+			Object: object}
+	}
+
+	cases := []struct {
+		name        string
+		request     *validationpb.ValidateRequest
+		wantCode    codes.Code
+		wantAllowed bool
+		wantError   string
+	}{
+		{
+			name:        "valid JSON",
+			request:     mock(makePilotConfig(t, 0, true, false)),
+			wantAllowed: true,
+		},
+		{
+			name:        "valid YAML",
+			request:     mock(validYAML),
+			wantAllowed: true,
+		},
+		{
+			name:      "invalid",
+			request:   mock(makePilotConfig(t, 0, false, false)),
+			wantError: "configuration is invalid",
+		},
+		{
+			name: "mixer",
+			request: &validationpb.ValidateRequest{Group: "config.istio.io", Version: "v1alpha2", Kind: "rule",
+				Object: []byte(`{"apiVersion": "config.istio.io/v1alpha2", "kind": "rule", "metadata": {"name": "r"}}`)},
+			wantError: "fake mixer error",
+		},
+		{
+			name: "pilot kind of the mixer group",
+			request: &validationpb.ValidateRequest{Group: "config.istio.io", Version: "v1alpha2", Kind: "QuotaSpec", Namespace: "default",
+				Object: []byte(`{"apiVersion": "config.istio.io/v1alpha2", "kind": "QuotaSpec", "metadata": {"name": "q"},
+					"spec": {"rules": [{"quotas": [{"quota": "requestcount", "charge": 1}]}]}}`)},
+			wantAllowed: true,
+		},
+		{
+			name: "invalid pilot kind of the mixer group",
+			request: &validationpb.ValidateRequest{Group: "config.istio.io", Version: "v1alpha2", Kind: "QuotaSpec", Namespace: "default",
+				Object: []byte(`{"apiVersion": "config.istio.io/v1alpha2", "kind": "QuotaSpec", "metadata": {"name": "q"}, "spec": {}}`)},
+			wantError: "a least one rule must be specified",
+		},
+		{
+			name: "unknown kind",
+			request: &validationpb.ValidateRequest{Group: "unknown.istio.io", Version: "v1", Kind: "Unknown", Namespace: "default",
+				Object: []byte(`{"apiVersion": "unknown.istio.io/v1", "kind": "Unknown", "metadata": {"name": "u"}}`)},
+			wantError: "unrecognized type Unknown",
+		},
+		{
+			name:     "missing kind",
+			request:  &validationpb.ValidateRequest{Group: "test.istio.io", Version: "v1"},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name:     "malformed object",
+			request:  mock([]byte("{")),
+			wantCode: codes.InvalidArgument,
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			resp, err := client.Validate(context.Background(), c.request)
+			if c.wantCode != codes.OK {
+				if got := grpcstatus.Code(err); got != c.wantCode {
+					t.Fatalf("got code %v want %v: %v", got, c.wantCode, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Validate() failed: %v", err)
+			}
+			if resp.Allowed != c.wantAllowed {
+				t.Fatalf("got allowed %v want %v: %v", resp.Allowed, c.wantAllowed, resp.Errors)
+			}
+			if c.wantError != "" && (len(resp.Errors) == 0 || !strings.Contains(resp.Errors[0], c.wantError)) {
+				t.Fatalf("got errors %q want them to contain %q", resp.Errors, c.wantError)
+			}
+		})
+	}
+}
+
+func TestValidationServerClientAuth(t *testing.T) {
+	wh, cancel := createTestWebhook(t, dummyClient, createFakeEndpointsSource(), dummyConfig)
+	defer cancel()
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(testcerts.CACert) {
+		t.Fatal("AppendCertsFromPEM() failed")
+	}
+	wh.server.TLSConfig.ClientCAs = pool
+	wh.server.TLSConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	client, stop := startValidationServer(t, wh)
+	defer stop()
+
+	_, err := client.Validate(context.Background(), &validationpb.ValidateRequest{Group: "test.istio.io", Version: "v1", Kind: "MockConfig",
+		Object: makePilotConfig(t, 0, true, false)})
+	if got := grpcstatus.Code(err); got != codes.Unavailable {
+		t.Fatalf("got code %v want %v for a client without a cert: %v", got, codes.Unavailable, err)
+	}
+}
+
+func TestValidationServerPanics(t *testing.T) {
+	wh, cancel := createTestWebhook(t, dummyClient, createFakeEndpointsSource(), dummyConfig)
+	defer cancel()
+	setMixerValidator(t, wh, &panickingValidator{})
+	client, stop := startValidationServer(t, wh)
+	defer stop()
+
+	resp, err := client.Validate(context.Background(), &validationpb.ValidateRequest{
+		Group: "config.istio.io", Version: "v1alpha2", Kind: "mock", Object: makeMixerConfig(t, 0, false),
+	})
+	if err != nil {
+		t.Fatalf("Validate() failed: %v", err)
+	}
+	if resp.Allowed || len(resp.Errors) == 0 || !strings.Contains(resp.Errors[0], "validation panicked: validator bug") {
+		t.Fatalf("got %+v want the panic reported", resp)
+	}
+}
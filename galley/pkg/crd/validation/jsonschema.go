@@ -0,0 +1,144 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/ghodss/yaml"
+	"github.com/hashicorp/go-multierror"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	kubeschema "k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// jsonSchemaTypes are the types validateSchema checks.
+var jsonSchemaTypes = map[string]bool{
+	"":        true,
+	"object":  true,
+	"array":   true,
+	"string":  true,
+	"boolean": true,
+	"number":  true,
+	"integer": true,
+}
+
+// compileJSONSchemas decodes the JSON Schema document of each kind. It fails if
+// a document is malformed, e.g. it has an unknown keyword, an unknown type or an
+// invalid pattern, so that a typo is not silently ignored.
+func compileJSONSchemas(docs map[kubeschema.GroupVersionKind]json.RawMessage) (
+	map[kubeschema.GroupVersionKind]*apiextensionsv1beta1.JSONSchemaProps, error) {
+
+	gvks := make([]kubeschema.GroupVersionKind, 0, len(docs))
+	for gvk := range docs {
+		gvks = append(gvks, gvk)
+	}
+	sort.Slice(gvks, func(i, j int) bool { return gvks[i].String() < gvks[j].String() })
+
+	var errs *multierror.Error
+	schemas := make(map[kubeschema.GroupVersionKind]*apiextensionsv1beta1.JSONSchemaProps, len(docs))
+	for _, gvk := range gvks {
+		s := &apiextensionsv1beta1.JSONSchemaProps{}
+		decoder := json.NewDecoder(bytes.NewReader(docs[gvk]))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(s); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("invalid JSON schema of %v: %v", gvk, err))
+			continue
+		}
+		if err := checkJSONSchema("", s); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("invalid JSON schema of %v: %v", gvk, err))
+			continue
+		}
+		schemas[gvk] = s
+	}
+	if err := errs.ErrorOrNil(); err != nil {
+		return nil, err
+	}
+	return schemas, nil
+}
+
+// checkJSONSchema returns an error if the schema, or a schema nested in it, has
+// a type or pattern that validateSchema cannot check.
+func checkJSONSchema(path string, s *apiextensionsv1beta1.JSONSchemaProps) error {
+	field := path
+	if field == "" {
+		field = "<root>"
+	}
+	if !jsonSchemaTypes[s.Type] {
+		return fmt.Errorf("%s: unknown type %q", field, s.Type)
+	}
+	if s.Pattern != "" {
+		if _, err := regexp.Compile(s.Pattern); err != nil {
+			return fmt.Errorf("%s: invalid pattern %q: %v", field, s.Pattern, err)
+		}
+	}
+
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		prop := s.Properties[name]
+		if err := checkJSONSchema(path+"."+name, &prop); err != nil {
+			return err
+		}
+	}
+	if s.AdditionalProperties != nil && s.AdditionalProperties.Schema != nil {
+		if err := checkJSONSchema(path+".*", s.AdditionalProperties.Schema); err != nil {
+			return err
+		}
+	}
+	if s.Items != nil && s.Items.Schema != nil {
+		if err := checkJSONSchema(path+"[*]", s.Items.Schema); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasJSONSchema returns true if a JSON schema is configured for the kind of the request.
+func (wh *Webhook) hasJSONSchema(request *admissionv1beta1.AdmissionRequest) bool {
+	gvk := kubeschema.GroupVersionKind{Group: request.Kind.Group, Version: request.Kind.Version, Kind: request.Kind.Kind}
+	_, ok := wh.jsonSchemas[gvk]
+	return ok
+}
+
+// admitJSONSchema rejects the request if the object does not match the JSON
+// schema configured for its kind. It returns nil if the request is allowed.
+func (wh *Webhook) admitJSONSchema(ctx context.Context, request *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+	gvk := kubeschema.GroupVersionKind{Group: request.Kind.Group, Version: request.Kind.Version, Kind: request.Kind.Kind}
+	s, ok := wh.jsonSchemas[gvk]
+	if !ok {
+		return nil
+	}
+
+	var object interface{}
+	if err := yaml.Unmarshal(request.Object.Raw, &object); err != nil {
+		reportStageFailed(ctx, request, reasonYamlDecodeError)
+		return toAdmissionResponse(fmt.Errorf("cannot decode configuration: %v", err))
+	}
+	if err := validateSchema("", s, object); err != nil {
+		requestLog(ctx).Infof("configuration does not match JSON schema: %v", wh.redactedError(request, err))
+		reportStageFailed(ctx, request, reasonInvalidConfig)
+		return wh.withViolation(request, CheckSchema, "", toAdmissionResponse(fmt.Errorf("configuration does not match JSON schema: %v", err)))
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubeschema "k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var widgetGVK = kubeschema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"}
+
+const widgetSchema = `{
+	"type": "object",
+	"required": ["spec"],
+	"properties": {
+		"spec": {
+			"type": "object",
+			"required": ["size"],
+			"properties": {
+				"size": {"type": "integer"},
+				"color": {"type": "string", "pattern": "^[a-z]+$"}
+			}
+		}
+	}
+}`
+
+func TestCompileJSONSchemas(t *testing.T) {
+	cases := []struct {
+		name    string
+		schema  string
+		wantErr string
+	}{
+		{
+			name:   "valid",
+			schema: widgetSchema,
+		},
+		{
+			name:    "malformed",
+			schema:  `{"type": "object"`,
+			wantErr: "invalid JSON schema of example.com/v1, Kind=Widget",
+		},
+		{
+			name:    "unknown keyword",
+			schema:  `{"type": "object", "requried": ["spec"]}`,
+			wantErr: `unknown field "requried"`,
+		},
+		{
+			name:    "unknown type",
+			schema:  `{"type": "object", "properties": {"spec": {"type": "map"}}}`,
+			wantErr: `.spec: unknown type "map"`,
+		},
+		{
+			name:    "invalid pattern",
+			schema:  `{"type": "array", "items": {"type": "string", "pattern": "[a-"}}`,
+			wantErr: `[*]: invalid pattern "[a-"`,
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			schemas, err := compileJSONSchemas(map[kubeschema.GroupVersionKind]json.RawMessage{
+				widgetGVK: json.RawMessage(c.schema),
+			})
+			if c.wantErr == "" {
+				if err != nil {
+					t.Fatalf("got unexpected error: %v", err)
+				}
+				if schemas[widgetGVK] == nil {
+					t.Fatalf("schema of %v not compiled", widgetGVK)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+				t.Fatalf("got error %v want %q", err, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestAdmitPilot_JSONSchema(t *testing.T) {
+	wh, cancel := createTestWebhook(t, dummyClient, createFakeEndpointsSource(), dummyConfig)
+	defer cancel()
+
+	schemas, err := compileJSONSchemas(map[kubeschema.GroupVersionKind]json.RawMessage{
+		widgetGVK: json.RawMessage(widgetSchema),
+		{Group: "test.istio.io", Version: "v1", Kind: "MockConfig"}: json.RawMessage(
+			`{"properties": {"metadata": {"properties": {"labels": {"properties": {"key": {"pattern": "^mock-config0$"}}}}}}}`),
+	})
+	if err != nil {
+		t.Fatalf("compileJSONSchemas() failed: %v", err)
+	}
+	wh.jsonSchemas = schemas
+	mockKind := metav1.GroupVersionKind{Group: "test.istio.io", Version: "v1", Kind: "MockConfig"}
+
+	cases := []struct {
+		name        string
+		kind        metav1.GroupVersionKind
+		object      string
+		wantAllowed bool
+		wantMessage string
+	}{
+		{
+			name:        "valid custom kind",
+			kind:        metav1.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"},
+			object:      `{"apiVersion": "example.com/v1", "kind": "Widget", "metadata": {"name": "w"}, "spec": {"size": 3}}`,
+			wantAllowed: true,
+		},
+		{
+			name:        "invalid custom kind",
+			kind:        metav1.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"},
+			object:      `{"apiVersion": "example.com/v1", "kind": "Widget", "metadata": {"name": "w"}, "spec": {"color": "Red"}}`,
+			wantMessage: `spec: missing required field "size"`,
+		},
+		{
+			name:        "unknown kind without schema",
+			kind:        metav1.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Gadget"},
+			object:      `{"apiVersion": "example.com/v1", "kind": "Gadget", "metadata": {"name": "g"}, "spec": {}}`,
+			wantMessage: "unrecognized type Gadget",
+		},
+		{
+			name:        "built-in kind matching schema",
+			kind:        mockKind,
+			object:      string(makePilotConfig(t, 0, true, false)),
+			wantAllowed: true,
+		},
+		{
+			name:        "built-in kind not matching schema",
+			kind:        mockKind,
+			object:      string(makePilotConfig(t, 1, true, false)),
+			wantMessage: `metadata.labels.key: "mock-config1" does not match pattern "^mock-config0$"`,
+		},
+		{
+			name:        "built-in validation still applies",
+			kind:        mockKind,
+			object:      string(makePilotConfig(t, 0, false, false)),
+			wantMessage: "configuration is invalid",
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			resp := wh.admitPilot(context.Background(), &admissionv1beta1.AdmissionRequest{
+				Kind:      c.kind,
+				Object:    runtime.RawExtension{Raw: []byte(c.object)},
+				Operation: admissionv1beta1.Create,
+			})
+			if resp.Allowed != c.wantAllowed {
+				t.Fatalf("got allowed %v want %v: %v", resp.Allowed, c.wantAllowed, resp.Result)
+			}
+			if c.wantMessage != "" && !strings.Contains(resp.Result.Message, c.wantMessage) {
+				t.Fatalf("got message %q want it to contain %q", resp.Result.Message, c.wantMessage)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/hashicorp/go-multierror"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8svalidation "k8s.io/apimachinery/pkg/util/validation"
+)
+
+// missingLabels returns the required labels that the raw object lacks, in order.
+func missingLabels(raw []byte, required []string) ([]string, error) {
+	var obj struct {
+		Metadata v1.ObjectMeta `json:"metadata"`
+	}
+	if err := yaml.Unmarshal(raw, &obj); err != nil {
+		return nil, fmt.Errorf("cannot decode configuration metadata: %v", err)
+	}
+	var missing []string
+	for _, label := range required {
+		if _, ok := obj.Metadata.Labels[label]; !ok {
+			missing = append(missing, label)
+		}
+	}
+	return missing, nil
+}
+
+// requireLabels wraps an admitFunc so that created and updated objects lacking
+// a required label are rejected, unless their namespace is exempt.
+func (wh *Webhook) requireLabels(admit admitFunc) admitFunc {
+	if len(wh.requiredLabels) == 0 {
+		return admit
+	}
+	return func(ctx context.Context, request *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+		switch request.Operation {
+		case admissionv1beta1.Create, admissionv1beta1.Update:
+		default:
+			return admit(ctx, request)
+		}
+		if wh.requiredLabelsExempt[request.Namespace] || !wh.ruleActive(RuleRequiredLabels) {
+			return admit(ctx, request)
+		}
+
+		missing, err := missingLabels(request.Object.Raw, wh.requiredLabels)
+		if err != nil {
+			reportValidationFailed(request, reasonYamlDecodeError)
+			return toAdmissionResponse(err)
+		}
+		if len(missing) > 0 {
+			requestLog(ctx).Infof("%s %s/%s is missing required labels %q",
+				request.Kind.Kind, request.Namespace, request.Name, missing)
+			reportValidationFailed(request, reasonMissingLabels)
+			return wh.withViolation(request, RuleRequiredLabels, "metadata.labels",
+				toAdmissionResponse(fmt.Errorf("missing required labels: %s", strings.Join(quoteAll(missing), ", "))))
+		}
+		return admit(ctx, request)
+	}
+}
+
+// validateMetadataKeys returns an error naming each label and annotation key of the
+// raw object that is not a Kubernetes qualified name, i.e. an optional DNS-1123
+// subdomain prefix and a name. Annotation keys are checked case insensitively,
+// as by the API server.
+func validateMetadataKeys(raw []byte) error {
+	var obj struct {
+		Metadata v1.ObjectMeta `json:"metadata"`
+	}
+	if err := yaml.Unmarshal(raw, &obj); err != nil {
+		return fmt.Errorf("cannot decode configuration metadata: %v", err)
+	}
+
+	var errs *multierror.Error
+	for _, key := range sortedKeys(obj.Metadata.Labels) {
+		if reasons := k8svalidation.IsQualifiedName(key); len(reasons) > 0 {
+			errs = multierror.Append(errs, fmt.Errorf("invalid label key %q: %s", key, strings.Join(reasons, "; ")))
+		}
+	}
+	for _, key := range sortedKeys(obj.Metadata.Annotations) {
+		if reasons := k8svalidation.IsQualifiedName(strings.ToLower(key)); len(reasons) > 0 {
+			errs = multierror.Append(errs, fmt.Errorf("invalid annotation key %q: %s", key, strings.Join(reasons, "; ")))
+		}
+	}
+	return errs.ErrorOrNil()
+}
+
+// checkMetadataKeys wraps an admitFunc so that created and updated objects with
+// malformed label or annotation keys are rejected.
+func (wh *Webhook) checkMetadataKeys(admit admitFunc) admitFunc {
+	if !wh.strictMetadataKeys {
+		return admit
+	}
+	return func(ctx context.Context, request *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+		switch request.Operation {
+		case admissionv1beta1.Create, admissionv1beta1.Update:
+		default:
+			return admit(ctx, request)
+		}
+
+		if !wh.ruleActive(RuleMetadataKeys) {
+			return admit(ctx, request)
+		}
+		if err := validateMetadataKeys(request.Object.Raw); err != nil {
+			requestLog(ctx).Infof("%s %s/%s has invalid metadata keys: %v",
+				request.Kind.Kind, request.Namespace, request.Name, err)
+			reportValidationFailed(request, reasonInvalidMetadataKey)
+			return wh.withViolation(request, RuleMetadataKeys, "metadata", toAdmissionResponse(fmt.Errorf("invalid metadata: %v", err)))
+		}
+		return admit(ctx, request)
+	}
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func quoteAll(values []string) []string {
+	quoted := make([]string, 0, len(values))
+	for _, value := range values {
+		quoted = append(quoted, fmt.Sprintf("%q", value))
+	}
+	return quoted
+}
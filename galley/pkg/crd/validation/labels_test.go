@@ -0,0 +1,171 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestMissingLabels(t *testing.T) {
+	required := []string{"owner", "team"}
+	cases := []struct {
+		name    string
+		raw     string
+		want    []string
+		wantErr bool
+	}{
+		{name: "all labels", raw: `{"metadata": {"labels": {"owner": "alice", "team": "mesh"}}}`},
+		{name: "empty value", raw: `{"metadata": {"labels": {"owner": "", "team": "mesh"}}}`},
+		{name: "one missing", raw: `{"metadata": {"labels": {"team": "mesh"}}}`, want: []string{"owner"}},
+		{name: "no labels", raw: `{"metadata": {}}`, want: []string{"owner", "team"}},
+		{name: "malformed", raw: `{`, wantErr: true},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			got, err := missingLabels([]byte(c.raw), required)
+			if gotErr := err != nil; gotErr != c.wantErr {
+				t.Fatalf("got error %v want error %v", err, c.wantErr)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Fatalf("got %v want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestRequireLabels(t *testing.T) {
+	wh := &Webhook{
+		requiredLabels:       []string{"owner", "team"},
+		requiredLabelsExempt: map[string]bool{"istio-system": true},
+	}
+	admit := wh.requireLabels(func(context.Context, *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+		return &admissionv1beta1.AdmissionResponse{Allowed: true}
+	})
+
+	labeled := `{"metadata": {"labels": {"owner": "alice", "team": "mesh"}}}`
+	unlabeled := `{"metadata": {"labels": {"team": "mesh"}}}`
+	cases := []struct {
+		name        string
+		namespace   string
+		operation   admissionv1beta1.Operation
+		raw         string
+		wantAllowed bool
+		wantMessage string
+	}{
+		{name: "labeled create", namespace: "default", operation: admissionv1beta1.Create, raw: labeled, wantAllowed: true},
+		{name: "unlabeled create", namespace: "default", operation: admissionv1beta1.Create, raw: unlabeled,
+			wantMessage: `missing required labels: "owner"`},
+		{name: "unlabeled update", namespace: "default", operation: admissionv1beta1.Update, raw: `{"metadata": {}}`,
+			wantMessage: `missing required labels: "owner", "team"`},
+		{name: "exempt namespace", namespace: "istio-system", operation: admissionv1beta1.Create, raw: unlabeled, wantAllowed: true},
+		{name: "delete", namespace: "default", operation: admissionv1beta1.Delete, wantAllowed: true},
+		{name: "malformed", namespace: "default", operation: admissionv1beta1.Create, raw: `{`,
+			wantMessage: "cannot decode configuration metadata"},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			resp := admit(context.Background(), &admissionv1beta1.AdmissionRequest{
+				Namespace: c.namespace,
+				Operation: c.operation,
+				Object:    runtime.RawExtension{Raw: []byte(c.raw)},
+			})
+			if resp.Allowed != c.wantAllowed {
+				t.Fatalf("got allowed %v want %v: %v", resp.Allowed, c.wantAllowed, resp.Result)
+			}
+			if c.wantMessage != "" && !strings.Contains(resp.Result.Message, c.wantMessage) {
+				t.Fatalf("got message %q want %q", resp.Result.Message, c.wantMessage)
+			}
+		})
+	}
+}
+
+func TestCheckMetadataKeys(t *testing.T) {
+	wh := &Webhook{strictMetadataKeys: true}
+	admit := wh.checkMetadataKeys(func(context.Context, *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+		return &admissionv1beta1.AdmissionResponse{Allowed: true}
+	})
+
+	cases := []struct {
+		name        string
+		operation   admissionv1beta1.Operation
+		raw         string
+		wantAllowed bool
+		wantMessage []string
+	}{
+		{
+			name:      "valid keys",
+			operation: admissionv1beta1.Create,
+			raw: `{"metadata": {"labels": {"app": "a", "example.com/team": "mesh"},
+				"annotations": {"sidecar.istio.io/Inject": "false"}}}`,
+			wantAllowed: true,
+		},
+		{
+			name:        "no metadata",
+			operation:   admissionv1beta1.Update,
+			raw:         `{"spec": {}}`,
+			wantAllowed: true,
+		},
+		{
+			name:        "invalid label key",
+			operation:   admissionv1beta1.Create,
+			raw:         `{"metadata": {"labels": {"bad key": "a"}}}`,
+			wantMessage: []string{`invalid label key "bad key"`},
+		},
+		{
+			name:        "invalid annotation prefix",
+			operation:   admissionv1beta1.Update,
+			raw:         `{"metadata": {"annotations": {"Example_COM/owner": "a", "-bad/owner": "b"}}}`,
+			wantMessage: []string{`invalid annotation key "-bad/owner"`, `invalid annotation key "Example_COM/owner"`},
+		},
+		{
+			name:        "delete",
+			operation:   admissionv1beta1.Delete,
+			raw:         `{"metadata": {"labels": {"bad key": "a"}}}`,
+			wantAllowed: true,
+		},
+		{
+			name:        "malformed",
+			operation:   admissionv1beta1.Create,
+			raw:         `{`,
+			wantMessage: []string{"cannot decode configuration metadata"},
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			resp := admit(context.Background(), &admissionv1beta1.AdmissionRequest{
+				Operation: c.operation,
+				Object:    runtime.RawExtension{Raw: []byte(c.raw)},
+			})
+			if resp.Allowed != c.wantAllowed {
+				t.Fatalf("got allowed %v want %v: %v", resp.Allowed, c.wantAllowed, resp.Result)
+			}
+			for _, message := range c.wantMessage {
+				if !strings.Contains(resp.Result.Message, message) {
+					t.Fatalf("got message %q want it to contain %q", resp.Result.Message, message)
+				}
+			}
+		})
+	}
+}
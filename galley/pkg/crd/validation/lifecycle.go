@@ -0,0 +1,54 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+// LifecycleObserver is notified of the lifecycle of the validation webhook, e.g.
+// to coordinate startup and shutdown with other components. Its methods are
+// called synchronously on the webhook goroutines, so they must not block.
+type LifecycleObserver interface {
+	// ServerStarted is called once the webhook server listens for admission requests.
+	ServerStarted()
+
+	// WebhookRegistered is called the first time the validatingwebhookconfiguration
+	// is created, updated or found up to date.
+	WebhookRegistered()
+
+	// Ready is called once the webhook endpoint is available, when the webhook
+	// signals that it is ready.
+	Ready()
+
+	// ShutdownStarted is called when the webhook begins to stop, before in-flight
+	// admission requests are drained.
+	ShutdownStarted()
+
+	// ShutdownComplete is called once the webhook servers are closed.
+	ShutdownComplete()
+}
+
+type nopLifecycleObserver struct{}
+
+func (nopLifecycleObserver) ServerStarted()     {}
+func (nopLifecycleObserver) WebhookRegistered() {}
+func (nopLifecycleObserver) Ready()             {}
+func (nopLifecycleObserver) ShutdownStarted()   {}
+func (nopLifecycleObserver) ShutdownComplete()  {}
+
+// lifecycleObserver returns the observer, or an observer that ignores all events if it is nil.
+func lifecycleObserver(observer LifecycleObserver) LifecycleObserver {
+	if observer == nil {
+		return nopLifecycleObserver{}
+	}
+	return observer
+}
@@ -0,0 +1,117 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+type recordingObserver struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (o *recordingObserver) record(event string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.events = append(o.events, event)
+}
+
+func (o *recordingObserver) recorded() []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]string(nil), o.events...)
+}
+
+func (o *recordingObserver) ServerStarted()     { o.record("started") }
+func (o *recordingObserver) WebhookRegistered() { o.record("registered") }
+func (o *recordingObserver) Ready()             { o.record("ready") }
+func (o *recordingObserver) ShutdownStarted()   { o.record("shutdown") }
+func (o *recordingObserver) ShutdownComplete()  { o.record("stopped") }
+
+func TestLifecycleObserver_Serve(t *testing.T) {
+	wh, cleanup := createTestWebhook(t,
+		fake.NewSimpleClientset(),
+		createFakeEndpointsSource(),
+		dummyConfig)
+	defer cleanup()
+	observer := &recordingObserver{}
+	wh.lifecycle = observer
+
+	stop := make(chan struct{})
+	ready := make(chan struct{})
+	errCh := make(chan error)
+	go func() {
+		errCh <- wh.Serve(ready, stop)
+	}()
+
+	select {
+	case <-ready:
+	case <-time.After(10 * time.Second):
+		t.Fatal("The webhook serve cannot be started in 10 seconds")
+	}
+	for deadline := time.Now().Add(10 * time.Second); len(observer.recorded()) < 2; {
+		if time.Now().After(deadline) {
+			t.Fatalf("got events %v want the server started and ready", observer.recorded())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	close(stop)
+	if err := <-errCh; err != nil {
+		t.Fatalf("Serve() failed: %v", err)
+	}
+
+	// the server starts listening concurrently with the readiness check
+	got := observer.recorded()
+	if len(got) != 4 {
+		t.Fatalf("got events %v want 4 events", got)
+	}
+	started := map[string]bool{got[0]: true, got[1]: true}
+	if !started["started"] || !started["ready"] {
+		t.Fatalf("got events %v want the server started and ready first", got)
+	}
+	if want := []string{"shutdown", "stopped"}; !reflect.DeepEqual(got[2:], want) {
+		t.Fatalf("got events %v want %v last", got, want)
+	}
+}
+
+func TestLifecycleObserver_WebhookRegistered(t *testing.T) {
+	whc, cleanup := createTestWebhookConfigController(t,
+		fake.NewSimpleClientset(),
+		createFakeWebhookSource(),
+		dummyConfig)
+	defer cleanup()
+	observer := &recordingObserver{}
+	whc.webhookParameters.LifecycleObserver = observer
+
+	if err := whc.rebuildWebhookConfig(); err != nil {
+		t.Fatalf("rebuildWebhookConfig() failed: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if retry := whc.createOrUpdateWebhookConfig(); retry {
+			t.Fatal("createOrUpdateWebhookConfig() failed")
+		}
+	}
+
+	if got, want := observer.recorded(), []string{"registered"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got events %v want %v", got, want)
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// namespaceModeAnnotation switches the validation of the objects of a namespace
+// to warn-only when set to "warn", see AllowNamespaceMaintenanceMode.
+const namespaceModeAnnotation = "validation.istio.io/mode"
+
+type createInformerNamespaceSource func(cl clientset.Interface) cache.ListerWatcher
+
+var (
+	defaultCreateInformerNamespaceSource = func(cl clientset.Interface) cache.ListerWatcher {
+		return cache.NewListWatchFromClient(
+			cl.CoreV1().RESTClient(),
+			"namespaces",
+			metav1.NamespaceAll,
+			fields.Everything())
+	}
+)
+
+// maintenanceMode returns true if the namespace is annotated to be in maintenance.
+func maintenanceMode(ns *v1.Namespace) bool {
+	return ns != nil && enforcement(ns.Annotations[namespaceModeAnnotation]) == enforcementWarn
+}
+
+// namespaceInMaintenance returns true if the objects of the namespace are
+// validated warn-only. Namespaces are enforced until their informer has synced.
+func (wh *Webhook) namespaceInMaintenance(namespace string) bool {
+	store, ok := wh.maintenanceNamespaces.Load().(cache.Store)
+	if !ok || namespace == "" {
+		return false
+	}
+	obj, exists, err := store.GetByKey(namespace)
+	if err != nil || !exists {
+		return false
+	}
+	ns, _ := obj.(*v1.Namespace)
+	return maintenanceMode(ns)
+}
+
+// watchMaintenanceNamespaces caches the namespaces until stopped, logging the
+// namespaces entering and leaving maintenance.
+func (wh *Webhook) watchMaintenanceNamespaces(stopCh <-chan struct{}) {
+	logChange := func(prev, curr *v1.Namespace) {
+		switch was, is := maintenanceMode(prev), maintenanceMode(curr); {
+		case !was && is:
+			scope.Warnf("!!! Validation of namespace %s is %q by annotation %s !!!",
+				curr.Name, enforcementWarn, namespaceModeAnnotation)
+		case was && !is:
+			scope.Infof("Validation of namespace %s is enforced again", prev.Name)
+		}
+	}
+	store, controller := cache.NewInformer(
+		wh.createInformerNamespaceSource(wh.clientset),
+		&v1.Namespace{},
+		0,
+		cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+				logChange(nil, obj.(*v1.Namespace))
+			},
+			UpdateFunc: func(prev, curr interface{}) {
+				logChange(prev.(*v1.Namespace), curr.(*v1.Namespace))
+			},
+			DeleteFunc: func(obj interface{}) {
+				if ns, ok := obj.(*v1.Namespace); ok {
+					logChange(ns, nil)
+				}
+			},
+		},
+	)
+	wh.maintenanceNamespaces.Store(store)
+	controller.Run(stopCh)
+}
@@ -0,0 +1,116 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestMaintenanceMode(t *testing.T) {
+	cases := []struct {
+		name        string
+		annotations map[string]string
+		want        bool
+	}{
+		{name: "warn", annotations: map[string]string{namespaceModeAnnotation: "warn"}, want: true},
+		{name: "on", annotations: map[string]string{namespaceModeAnnotation: "on"}},
+		{name: "off is not allowed", annotations: map[string]string{namespaceModeAnnotation: "off"}},
+		{name: "no annotation"},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			ns := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default", Annotations: c.annotations}}
+			if got := maintenanceMode(ns); got != c.want {
+				t.Fatalf("got %v want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestEnforcedMaintenanceNamespaces(t *testing.T) {
+	cl := fake.NewSimpleClientset(
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "maintained", Annotations: map[string]string{namespaceModeAnnotation: "warn"}}},
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "enforced"}},
+	)
+	wh := &Webhook{
+		clientset:               cl,
+		enforcementConfigMapKey: defaultEnforcementConfigMapKey,
+		createInformerNamespaceSource: func(cl clientset.Interface) cache.ListerWatcher {
+			return &cache.ListWatch{
+				ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+					return cl.CoreV1().Namespaces().List(options)
+				},
+				WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+					return cl.CoreV1().Namespaces().Watch(options)
+				},
+			}
+		},
+	}
+	admit := wh.enforced(func(context.Context, *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+		return toAdmissionResponse(fmt.Errorf("invalid"))
+	})
+	allowed := func(namespace string) bool {
+		return admit(context.Background(), &admissionv1beta1.AdmissionRequest{Namespace: namespace}).Allowed
+	}
+
+	if allowed("maintained") {
+		t.Fatal("got the object allowed before the namespaces are watched")
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go wh.watchMaintenanceNamespaces(stop)
+
+	waitFor := func(namespace string, want bool) {
+		t.Helper()
+		deadline := time.Now().Add(10 * time.Second)
+		for allowed(namespace) != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for namespace %s allowed %v", namespace, want)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	waitFor("maintained", true)
+	if allowed("enforced") {
+		t.Fatal("got the object of an enforced namespace allowed")
+	}
+
+	wh.setEnforcement(&v1.ConfigMap{Data: map[string]string{defaultEnforcementConfigMapKey: "off"}})
+	if !allowed("enforced") {
+		t.Fatal("got the object rejected with enforcement off")
+	}
+	wh.setEnforcement(nil)
+
+	ns := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "maintained"}}
+	if _, err := cl.CoreV1().Namespaces().Update(ns); err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+	waitFor("maintained", false)
+}
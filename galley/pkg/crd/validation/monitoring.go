@@ -17,6 +17,7 @@ package validation
 import (
 	"context"
 	"strconv"
+	"time"
 
 	"go.opencensus.io/stats"
 	"go.opencensus.io/stats/view"
@@ -27,12 +28,20 @@ import (
 )
 
 const (
-	errorStr = "error"
-	group    = "group"
-	version  = "version"
-	resource = "resource"
-	reason   = "reason"
-	status   = "status"
+	errorStr     = "error"
+	group        = "group"
+	version      = "version"
+	resource     = "resource"
+	reason       = "reason"
+	status       = "status"
+	policy       = "policy"
+	namespaceStr = "namespace"
+	stage        = "stage"
+	kindStr      = "kind"
+	rule         = "rule"
+	schemaStr    = "schema_version"
+	codeStr      = "code"
+	outcome      = "outcome"
 )
 
 var (
@@ -53,6 +62,31 @@ var (
 
 	// StatusTag holds the error code for the context.
 	StatusTag tag.Key
+
+	// PolicyTag holds the name of the Rego policy for the context.
+	PolicyTag tag.Key
+
+	// NamespaceTag holds the namespace of the request for the context.
+	NamespaceTag tag.Key
+
+	// StageTag holds the name of the validation stage for the context.
+	StageTag tag.Key
+
+	// KindTag holds the kind of the admitted object for the context.
+	KindTag tag.Key
+
+	// RuleTag holds the name of the validation rule for the context.
+	RuleTag tag.Key
+
+	// SchemaVersionTag holds the identifier of the pilot schemas for the context.
+	SchemaVersionTag tag.Key
+
+	// CodeTag holds the violation code of the failed check for the context.
+	CodeTag tag.Key
+
+	// OutcomeTag holds whether a request acquired a slot in its namespace, or
+	// timed out, for the context.
+	OutcomeTag tag.Key
 )
 
 var (
@@ -96,8 +130,72 @@ var (
 		"galley/validation/config_load",
 		"k8s webhook configuration (re)loads",
 		stats.UnitDimensionless)
+	metricWebhookRegistered = stats.Int64(
+		"galley/validation/webhook_registered",
+		"Whether the k8s webhook configuration is registered (1) or not (0)",
+		stats.UnitDimensionless)
+	metricWebhookRegisterError = stats.Int64(
+		"galley/validation/webhook_register_errors_total",
+		"k8s webhook configuration registration errors",
+		stats.UnitDimensionless)
+	metricDecisionDropped = stats.Int64(
+		"galley/validation/decision_records_dropped",
+		"Admission decisions dropped because the decision sink fell behind",
+		stats.UnitDimensionless)
+	metricPolicyDenied = stats.Int64(
+		"galley/validation/policy_denied",
+		"Resource denied by a Rego policy",
+		stats.UnitDimensionless)
+	metricNamespaceQueueDepth = stats.Int64(
+		"galley/validation/namespace_queue_depth",
+		"Admission requests waiting for a slot in their namespace",
+		stats.UnitDimensionless)
+	metricCertExpiry = stats.Float64(
+		"galley/validation/cert_expiry_seconds",
+		"Seconds until the validation webhook certificate expires, as of its last reload",
+		"s")
+	metricStageTimeout = stats.Int64(
+		"galley/validation/stage_timeouts",
+		"Validation stages that timed out",
+		stats.UnitDimensionless)
+	metricQueueWait = stats.Float64(
+		"galley/validation/queue_wait_seconds",
+		"Seconds admission requests waited for a slot in their namespace, until they acquired it or timed out",
+		"s")
+	metricReferenceCheckDegraded = stats.Int64(
+		"galley/validation/reference_check_degraded",
+		"Reference checks degraded to warn-only because the API server was unavailable",
+		stats.UnitDimensionless)
+	metricRequestBytes = stats.Int64(
+		"galley/validation/request_bytes",
+		"Size in bytes of the objects of admission requests",
+		stats.UnitBytes)
+	metricSchemaVersion = stats.Int64(
+		"galley/validation/schema_version",
+		"Set to 1 for the identifier of the pilot schemas in use, and 0 for those replaced",
+		stats.UnitDimensionless)
+	metricRequestDeduplicated = stats.Int64(
+		"galley/validation/request_deduplicated",
+		"Admission requests whose validation was shared with concurrent identical requests",
+		stats.UnitDimensionless)
+	metricGatewayCredentialsMissing = stats.Int64(
+		"galley/validation/gateway_credentials_missing",
+		"Gateways admitted with a warning as the secrets of their credentialName do not exist",
+		stats.UnitDimensionless)
+	metricViolation = stats.Int64(
+		"galley/validation/violations",
+		"Rejected resources by failed check and violation code",
+		stats.UnitDimensionless)
 )
 
+// queueWaitBuckets are the bucket boundaries of the queue wait distribution, in
+// seconds, up to namespaceQueueTimeout.
+var queueWaitBuckets = []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// requestBytesBuckets are the bucket boundaries of the request size
+// distribution, in bytes, up to maxRequestBytes.
+var requestBytesBuckets = []float64{256, 1 << 10, 4 << 10, 16 << 10, 64 << 10, 256 << 10, 1 << 20, 4 << 20, maxRequestBytes}
+
 func newView(measure stats.Measure, keys []tag.Key, aggregation *view.Aggregation) *view.View {
 	return &view.View{
 		Name:        measure.Name(),
@@ -128,12 +226,44 @@ func init() {
 	if StatusTag, err = tag.NewKey(status); err != nil {
 		panic(err)
 	}
+	if PolicyTag, err = tag.NewKey(policy); err != nil {
+		panic(err)
+	}
+	if NamespaceTag, err = tag.NewKey(namespaceStr); err != nil {
+		panic(err)
+	}
+	if StageTag, err = tag.NewKey(stage); err != nil {
+		panic(err)
+	}
+	if KindTag, err = tag.NewKey(kindStr); err != nil {
+		panic(err)
+	}
+	if RuleTag, err = tag.NewKey(rule); err != nil {
+		panic(err)
+	}
+	if SchemaVersionTag, err = tag.NewKey(schemaStr); err != nil {
+		panic(err)
+	}
+	if CodeTag, err = tag.NewKey(codeStr); err != nil {
+		panic(err)
+	}
+	if OutcomeTag, err = tag.NewKey(outcome); err != nil {
+		panic(err)
+	}
 
 	var noKeys []tag.Key
 	errorKey := []tag.Key{ErrorTag}
 	resourceKeys := []tag.Key{GroupTag, VersionTag, ResourceTag}
 	resourceErrorKeys := []tag.Key{GroupTag, VersionTag, ResourceTag, ReasonTag}
 	statusKey := []tag.Key{StatusTag}
+	resourcePolicyKeys := []tag.Key{GroupTag, VersionTag, ResourceTag, PolicyTag}
+	namespaceKey := []tag.Key{NamespaceTag}
+	resourceStageKeys := []tag.Key{GroupTag, VersionTag, ResourceTag, StageTag}
+	kindKeys := []tag.Key{GroupTag, VersionTag, KindTag}
+	resourceRuleKeys := []tag.Key{GroupTag, VersionTag, ResourceTag, RuleTag}
+	schemaVersionKey := []tag.Key{SchemaVersionTag}
+	outcomeKey := []tag.Key{OutcomeTag}
+	resourceViolationKeys := []tag.Key{GroupTag, VersionTag, ResourceTag, RuleTag, CodeTag}
 
 	err = view.Register(
 		newView(metricCertKeyUpdate, noKeys, view.Count()),
@@ -145,6 +275,20 @@ func init() {
 		newView(metricWebhookConfigurationUpdates, noKeys, view.Count()),
 		newView(metricWebhookConfigurationLoadError, errorKey, view.Count()),
 		newView(metricWebhookConfigurationLoad, noKeys, view.Count()),
+		newView(metricWebhookRegistered, noKeys, view.LastValue()),
+		newView(metricWebhookRegisterError, noKeys, view.Count()),
+		newView(metricDecisionDropped, noKeys, view.Count()),
+		newView(metricPolicyDenied, resourcePolicyKeys, view.Count()),
+		newView(metricNamespaceQueueDepth, namespaceKey, view.LastValue()),
+		newView(metricCertExpiry, noKeys, view.LastValue()),
+		newView(metricStageTimeout, resourceStageKeys, view.Count()),
+		newView(metricQueueWait, outcomeKey, view.Distribution(queueWaitBuckets...)),
+		newView(metricRequestBytes, kindKeys, view.Distribution(requestBytesBuckets...)),
+		newView(metricReferenceCheckDegraded, resourceRuleKeys, view.Count()),
+		newView(metricRequestDeduplicated, resourceKeys, view.Count()),
+		newView(metricSchemaVersion, schemaVersionKey, view.LastValue()),
+		newView(metricViolation, resourceViolationKeys, view.Count()),
+		newView(metricGatewayCredentialsMissing, resourceKeys, view.Count()),
 	)
 
 	if err != nil {
@@ -165,6 +309,130 @@ func reportValidationFailed(request *admissionv1beta1.AdmissionRequest, reason s
 	}
 }
 
+func reportPolicyDenied(request *admissionv1beta1.AdmissionRequest, policy string) {
+	ctx, err := tag.New(context.Background(),
+		tag.Insert(GroupTag, request.Resource.Group),
+		tag.Insert(VersionTag, request.Resource.Version),
+		tag.Insert(ResourceTag, request.Resource.Resource),
+		tag.Insert(PolicyTag, policy))
+	if err != nil {
+		scope.Errorf("Error creating monitoring context for reportPolicyDenied: %v", err)
+	} else {
+		stats.Record(ctx, metricPolicyDenied.M(1))
+	}
+}
+
+func reportGatewayCredentialsMissing(request *admissionv1beta1.AdmissionRequest) {
+	ctx, err := tag.New(context.Background(),
+		tag.Insert(GroupTag, request.Resource.Group),
+		tag.Insert(VersionTag, request.Resource.Version),
+		tag.Insert(ResourceTag, request.Resource.Resource))
+	if err != nil {
+		scope.Errorf("Error creating monitoring context for reportGatewayCredentialsMissing: %v", err)
+	} else {
+		stats.Record(ctx, metricGatewayCredentialsMissing.M(1))
+	}
+}
+
+func reportViolation(request *admissionv1beta1.AdmissionRequest, check, code string) {
+	ctx, err := tag.New(context.Background(),
+		tag.Insert(GroupTag, request.Resource.Group),
+		tag.Insert(VersionTag, request.Resource.Version),
+		tag.Insert(ResourceTag, request.Resource.Resource),
+		tag.Insert(RuleTag, check),
+		tag.Insert(CodeTag, code))
+	if err != nil {
+		scope.Errorf("Error creating monitoring context for reportViolation: %v", err)
+	} else {
+		stats.Record(ctx, metricViolation.M(1))
+	}
+}
+
+func reportStageTimeout(request *admissionv1beta1.AdmissionRequest, stage ValidationStageName) {
+	ctx, err := tag.New(context.Background(),
+		tag.Insert(GroupTag, request.Resource.Group),
+		tag.Insert(VersionTag, request.Resource.Version),
+		tag.Insert(ResourceTag, request.Resource.Resource),
+		tag.Insert(StageTag, string(stage)))
+	if err != nil {
+		scope.Errorf("Error creating monitoring context for reportStageTimeout: %v", err)
+	} else {
+		stats.Record(ctx, metricStageTimeout.M(1))
+	}
+}
+
+func reportReferenceCheckDegraded(request *admissionv1beta1.AdmissionRequest, rule string) {
+	ctx, err := tag.New(context.Background(),
+		tag.Insert(GroupTag, request.Resource.Group),
+		tag.Insert(VersionTag, request.Resource.Version),
+		tag.Insert(ResourceTag, request.Resource.Resource),
+		tag.Insert(RuleTag, rule))
+	if err != nil {
+		scope.Errorf("Error creating monitoring context for reportReferenceCheckDegraded: %v", err)
+	} else {
+		stats.Record(ctx, metricReferenceCheckDegraded.M(1))
+	}
+}
+
+func reportNamespaceQueueDepth(namespace string, depth int) {
+	ctx, err := tag.New(context.Background(), tag.Insert(NamespaceTag, namespace))
+	if err != nil {
+		scope.Errorf("Error creating monitoring context for reportNamespaceQueueDepth: %v", err)
+	} else {
+		stats.Record(ctx, metricNamespaceQueueDepth.M(int64(depth)))
+	}
+}
+
+func reportQueueWait(wait time.Duration, outcome string) {
+	ctx, err := tag.New(context.Background(), tag.Insert(OutcomeTag, outcome))
+	if err != nil {
+		scope.Errorf("Error creating monitoring context for reportQueueWait: %v", err)
+	} else {
+		stats.Record(ctx, metricQueueWait.M(wait.Seconds()))
+	}
+}
+
+func reportRequestBytes(request *admissionv1beta1.AdmissionRequest) {
+	ctx, err := tag.New(context.Background(),
+		tag.Insert(GroupTag, request.Kind.Group),
+		tag.Insert(VersionTag, request.Kind.Version),
+		tag.Insert(KindTag, request.Kind.Kind))
+	if err != nil {
+		scope.Errorf("Error creating monitoring context for reportRequestBytes: %v", err)
+	} else {
+		stats.Record(ctx, metricRequestBytes.M(int64(len(request.Object.Raw))))
+	}
+}
+
+func reportRequestDeduplicated(request *admissionv1beta1.AdmissionRequest) {
+	ctx, err := tag.New(context.Background(),
+		tag.Insert(GroupTag, request.Resource.Group),
+		tag.Insert(VersionTag, request.Resource.Version),
+		tag.Insert(ResourceTag, request.Resource.Resource))
+	if err != nil {
+		scope.Errorf("Error creating monitoring context for reportRequestDeduplicated: %v", err)
+	} else {
+		stats.Record(ctx, metricRequestDeduplicated.M(1))
+	}
+}
+
+func reportSchemaVersion(schemaVersion string, inUse bool) {
+	ctx, err := tag.New(context.Background(), tag.Insert(SchemaVersionTag, schemaVersion))
+	if err != nil {
+		scope.Errorf("Error creating monitoring context for reportSchemaVersion: %v", err)
+		return
+	}
+	var value int64
+	if inUse {
+		value = 1
+	}
+	stats.Record(ctx, metricSchemaVersion.M(value))
+}
+
+func reportCertExpiry(remaining time.Duration) {
+	stats.Record(context.Background(), metricCertExpiry.M(remaining.Seconds()))
+}
+
 func reportValidationPass(request *admissionv1beta1.AdmissionRequest) {
 	ctx, err := tag.New(context.Background(),
 		tag.Insert(GroupTag, request.Resource.Group),
@@ -221,6 +489,22 @@ func reportValidationConfigUpdate() {
 	stats.Record(context.Background(), metricWebhookConfigurationUpdates.M(1))
 }
 
+func reportWebhookRegistered(registered bool) {
+	var value int64
+	if registered {
+		value = 1
+	}
+	stats.Record(context.Background(), metricWebhookRegistered.M(value))
+}
+
+func reportWebhookRegisterError() {
+	stats.Record(context.Background(), metricWebhookRegisterError.M(1))
+}
+
+func reportDecisionDropped() {
+	stats.Record(context.Background(), metricDecisionDropped.M(1))
+}
+
 func reportValidationCertKeyUpdate() {
 	stats.Record(context.Background(), metricCertKeyUpdate.M(1))
 }
@@ -240,4 +524,26 @@ const (
 	reasonUnknownType          = "unknown_type"
 	reasonCRDConversionError   = "crd_conversion_error"
 	reasonInvalidConfig        = "invalid_resource"
+	reasonReferenceCheckError  = "reference_check_error"
+	reasonReferencedObject     = "referenced_resource"
+	reasonPolicyError          = "policy_error"
+	reasonPolicyDenied         = "policy_denied"
+
+	reasonSchemaRegistryUnavailable = "schema_registry_unavailable"
+	reasonNamespaceThrottled        = "namespace_throttled"
+	reasonMissingLabels             = "missing_labels"
+	reasonStageTimeout              = "stage_timeout"
+	reasonInvalidMetadataKey        = "invalid_metadata_key"
+	reasonConflictingSidecar        = "conflicting_sidecar"
+	reasonEmptySpec                 = "empty_spec"
+	reasonTransformError            = "transform_error"
+	reasonInvalidPortName           = "invalid_port_name"
+	reasonMissingNamespace          = "missing_namespace"
+	reasonNamespaceCheckError       = "namespace_check_error"
+	reasonNameCollision             = "name_collision"
+	reasonInconsistentTLS           = "inconsistent_tls"
+	reasonValidatorPanic            = "validator_panic"
+	reasonMissingCredential         = "missing_credential"
+	reasonDeadlineExceeded          = "deadline_exceeded"
+	reasonDeprecatedField           = "deprecated_field"
 )
@@ -0,0 +1,132 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+)
+
+// namespaceQueueTimeout bounds the time a request waits for a slot in its
+// namespace, well within the API server's webhook timeout.
+const namespaceQueueTimeout = 10 * time.Second
+
+// The outcomes of the wait for a slot, see reportQueueWait.
+const (
+	queueAcquired = "acquired"
+	queueTimedOut = "timeout"
+)
+
+// namespaceLimiter bounds the number of concurrent admission requests per
+// namespace, so that a burst in one namespace cannot consume all handler capacity.
+// Cluster-scoped objects share the "" namespace.
+type namespaceLimiter struct {
+	limit   int
+	timeout time.Duration
+
+	mu         sync.Mutex
+	namespaces map[string]*namespaceSlots
+}
+
+// namespaceSlots are the slots of a namespace. They are removed once no request
+// holds or waits for a slot.
+type namespaceSlots struct {
+	sem     chan struct{}
+	users   int
+	waiting int
+}
+
+func newNamespaceLimiter(limit int) *namespaceLimiter {
+	return &namespaceLimiter{
+		limit:      limit,
+		timeout:    namespaceQueueTimeout,
+		namespaces: make(map[string]*namespaceSlots),
+	}
+}
+
+// acquire waits for a slot in the namespace. It returns false if no slot became
+// available within the timeout; otherwise release must be called when done.
+func (l *namespaceLimiter) acquire(namespace string) (release func(), ok bool) {
+	l.mu.Lock()
+	s, exists := l.namespaces[namespace]
+	if !exists {
+		s = &namespaceSlots{sem: make(chan struct{}, l.limit)}
+		l.namespaces[namespace] = s
+	}
+	s.users++
+	s.waiting++
+	reportNamespaceQueueDepth(namespace, s.waiting)
+	l.mu.Unlock()
+
+	start := time.Now()
+	timer := time.NewTimer(l.timeout)
+	defer timer.Stop()
+	select {
+	case s.sem <- struct{}{}:
+		ok = true
+		reportQueueWait(time.Since(start), queueAcquired)
+	case <-timer.C:
+		reportQueueWait(time.Since(start), queueTimedOut)
+	}
+
+	l.mu.Lock()
+	s.waiting--
+	reportNamespaceQueueDepth(namespace, s.waiting)
+	if !ok {
+		l.done(namespace, s)
+	}
+	l.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+
+	return func() {
+		<-s.sem
+		l.mu.Lock()
+		l.done(namespace, s)
+		l.mu.Unlock()
+	}, true
+}
+
+// done removes a user of the namespace slots. It must be called with mu held.
+func (l *namespaceLimiter) done(namespace string, s *namespaceSlots) {
+	if s.users--; s.users == 0 {
+		delete(l.namespaces, namespace)
+	}
+}
+
+// limitNamespace admits the request once a slot in its namespace is available,
+// rejecting it if the namespace stays saturated.
+func (wh *Webhook) limitNamespace(admit admitFunc) admitFunc {
+	if wh.namespaceLimiter == nil {
+		return admit
+	}
+	return func(ctx context.Context, request *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+		release, ok := wh.namespaceLimiter.acquire(request.Namespace)
+		if !ok {
+			requestLog(ctx).Warnf("Too many concurrent admission requests in namespace %q, rejecting %s of %s %s",
+				request.Namespace, request.Operation, request.Kind.Kind, request.Name)
+			reportValidationFailed(request, reasonNamespaceThrottled)
+			return toAdmissionResponse(fmt.Errorf("too many concurrent admission requests in namespace %q, try again later",
+				request.Namespace))
+		}
+		defer release()
+		return admit(ctx, request)
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opencensus.io/stats/view"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+)
+
+// queueWaits returns the number of queue waits recorded with the outcome.
+func queueWaits(t *testing.T, outcome string) int64 {
+	t.Helper()
+	rows, err := view.RetrieveData(metricQueueWait.Name())
+	if err != nil {
+		t.Fatalf("RetrieveData() failed: %v", err)
+	}
+	for _, row := range rows {
+		if len(row.Tags) == 1 && row.Tags[0].Key == OutcomeTag && row.Tags[0].Value == outcome {
+			return row.Data.(*view.DistributionData).Count
+		}
+	}
+	return 0
+}
+
+func TestNamespaceLimiter(t *testing.T) {
+	l := newNamespaceLimiter(1)
+	l.timeout = 10 * time.Millisecond
+
+	releaseA, ok := l.acquire("a")
+	if !ok {
+		t.Fatal("first request in namespace a was throttled")
+	}
+	timeouts := queueWaits(t, queueTimedOut)
+	if _, ok := l.acquire("a"); ok {
+		t.Fatal("second request in saturated namespace a was not throttled")
+	}
+	if got := queueWaits(t, queueTimedOut); got != timeouts+1 {
+		t.Fatalf("got %d timed out waits want %d", got, timeouts+1)
+	}
+	releaseB, ok := l.acquire("b")
+	if !ok {
+		t.Fatal("request in namespace b was throttled by namespace a")
+	}
+	releaseB()
+
+	// a waiting request gets the slot once it is released
+	acquired := make(chan func())
+	l.timeout = 10 * time.Second
+	go func() {
+		release, ok := l.acquire("a")
+		if !ok {
+			release = nil
+		}
+		acquired <- release
+	}()
+	releaseA()
+	release := <-acquired
+	if release == nil {
+		t.Fatal("waiting request in namespace a was throttled after release")
+	}
+	release()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.namespaces) != 0 {
+		t.Fatalf("idle namespaces not removed: %v", l.namespaces)
+	}
+}
+
+func TestLimitNamespace(t *testing.T) {
+	wh := &Webhook{namespaceLimiter: newNamespaceLimiter(1)}
+	wh.namespaceLimiter.timeout = 10 * time.Millisecond
+
+	block := make(chan struct{})
+	started := make(chan struct{})
+	admit := wh.limitNamespace(func(_ context.Context, request *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+		if request.Name == "slow" {
+			close(started)
+			<-block
+		}
+		return &admissionv1beta1.AdmissionResponse{Allowed: true}
+	})
+
+	done := make(chan *admissionv1beta1.AdmissionResponse)
+	go func() {
+		done <- admit(context.Background(), &admissionv1beta1.AdmissionRequest{Namespace: "tenant", Name: "slow"})
+	}()
+	<-started
+
+	if resp := admit(context.Background(), &admissionv1beta1.AdmissionRequest{Namespace: "tenant", Name: "burst"}); resp.Allowed {
+		t.Fatal("request in saturated namespace was admitted")
+	}
+	if resp := admit(context.Background(), &admissionv1beta1.AdmissionRequest{Namespace: "other", Name: "fast"}); !resp.Allowed {
+		t.Fatalf("request in other namespace was rejected: %v", resp.Result)
+	}
+
+	close(block)
+	if resp := <-done; !resp.Allowed {
+		t.Fatalf("slow request was rejected: %v", resp.Result)
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	kubeerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientset "k8s.io/client-go/kubernetes"
+)
+
+// namespaceCacheTTL is how long the existence of a namespace is cached, so that
+// a burst of applies to a namespace looks it up once.
+const namespaceCacheTTL = 30 * time.Second
+
+// namespaceLookup returns whether the namespace exists.
+type namespaceLookup func(namespace string) (bool, error)
+
+// namespaceCache caches the results of a namespaceLookup for ttl. Lookup errors
+// are not cached.
+type namespaceCache struct {
+	lookup namespaceLookup
+	ttl    time.Duration
+	now    func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]namespaceEntry
+}
+
+type namespaceEntry struct {
+	exists  bool
+	expires time.Time
+}
+
+func newNamespaceCache(lookup namespaceLookup, ttl time.Duration) *namespaceCache {
+	return &namespaceCache{
+		lookup:  lookup,
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]namespaceEntry),
+	}
+}
+
+// kubeNamespaceLookup looks up namespaces on the API server.
+func kubeNamespaceLookup(cl clientset.Interface) namespaceLookup {
+	return func(namespace string) (bool, error) {
+		_, err := cl.CoreV1().Namespaces().Get(namespace, metav1.GetOptions{})
+		if kubeerrors.IsNotFound(err) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+}
+
+func (c *namespaceCache) exists(namespace string) (bool, error) {
+	now := c.now()
+	c.mu.Lock()
+	entry, ok := c.entries[namespace]
+	c.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.exists, nil
+	}
+
+	exists, err := c.lookup(namespace)
+	if err != nil {
+		return false, err
+	}
+	c.mu.Lock()
+	for name, entry := range c.entries {
+		if !now.Before(entry.expires) {
+			delete(c.entries, name)
+		}
+	}
+	c.entries[namespace] = namespaceEntry{exists: exists, expires: now.Add(c.ttl)}
+	c.mu.Unlock()
+	return exists, nil
+}
+
+// requireNamespace wraps an admitFunc so that created and updated objects in a
+// namespace that does not exist are rejected. Cluster scoped objects are not
+// checked.
+func (wh *Webhook) requireNamespace(admit admitFunc) admitFunc {
+	if wh.namespaces == nil {
+		return admit
+	}
+	return func(ctx context.Context, request *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+		switch request.Operation {
+		case admissionv1beta1.Create, admissionv1beta1.Update:
+		default:
+			return admit(ctx, request)
+		}
+		if request.Namespace == "" || !wh.ruleActive(RuleNamespaceExists) {
+			return admit(ctx, request)
+		}
+
+		exists, err := wh.namespaces.exists(request.Namespace)
+		if err != nil && wh.degradeReferenceCheck(ctx, request, RuleNamespaceExists, err) {
+			return admit(ctx, request)
+		} else if err != nil {
+			requestLog(ctx).Infof("cannot look up namespace %q of %s %s: %v",
+				request.Namespace, request.Kind.Kind, request.Name, err)
+			reportValidationFailed(request, reasonNamespaceCheckError)
+			return toInternalErrorResponse(fmt.Errorf("cannot look up namespace %q: %v", request.Namespace, err))
+		}
+		if !exists {
+			requestLog(ctx).Infof("namespace %q of %s %s does not exist",
+				request.Namespace, request.Kind.Kind, request.Name)
+			reportValidationFailed(request, reasonMissingNamespace)
+			return wh.withViolation(request, RuleNamespaceExists, "metadata.namespace",
+				toAdmissionResponse(fmt.Errorf("namespace %q does not exist", request.Namespace)))
+		}
+		return admit(ctx, request)
+	}
+}
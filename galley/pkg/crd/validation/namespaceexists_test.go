@@ -0,0 +1,134 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestNamespaceCache(t *testing.T) {
+	lookups := 0
+	lookupErr := errors.New("unavailable")
+	var failLookup bool
+	namespaces := newNamespaceCache(func(namespace string) (bool, error) {
+		lookups++
+		if failLookup {
+			return false, lookupErr
+		}
+		return namespace == "default", nil
+	}, time.Minute)
+	now := time.Unix(0, 0)
+	namespaces.now = func() time.Time { return now }
+
+	cases := []struct {
+		name        string
+		namespace   string
+		advance     time.Duration
+		failLookup  bool
+		wantExists  bool
+		wantErr     bool
+		wantLookups int
+	}{
+		{name: "existing", namespace: "default", wantExists: true, wantLookups: 1},
+		{name: "existing cached", namespace: "default", advance: 30 * time.Second, wantExists: true, wantLookups: 1},
+		{name: "missing", namespace: "defualt", wantLookups: 2},
+		{name: "missing cached", namespace: "defualt", wantLookups: 2},
+		{name: "expired", namespace: "default", advance: time.Minute, wantExists: true, wantLookups: 3},
+		{name: "error", namespace: "other", failLookup: true, wantErr: true, wantLookups: 4},
+		{name: "error not cached", namespace: "other", failLookup: true, wantErr: true, wantLookups: 5},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			now = now.Add(c.advance)
+			failLookup = c.failLookup
+			exists, err := namespaces.exists(c.namespace)
+			if gotErr := err != nil; gotErr != c.wantErr {
+				t.Fatalf("got error %v want error %v", err, c.wantErr)
+			}
+			if exists != c.wantExists {
+				t.Fatalf("got exists %v want %v", exists, c.wantExists)
+			}
+			if lookups != c.wantLookups {
+				t.Fatalf("got %d lookups want %d", lookups, c.wantLookups)
+			}
+		})
+	}
+}
+
+func TestRequireNamespace(t *testing.T) {
+	wh := &Webhook{
+		namespaces: newNamespaceCache(kubeNamespaceLookup(fake.NewSimpleClientset(dummyNamespace)), time.Minute),
+	}
+	admit := wh.requireNamespace(func(context.Context, *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+		return wh.acceptResponse()
+	})
+
+	cases := []struct {
+		name        string
+		namespace   string
+		operation   admissionv1beta1.Operation
+		wantAllowed bool
+	}{
+		{name: "existing namespace", namespace: "istio-system", operation: admissionv1beta1.Create, wantAllowed: true},
+		{name: "missing namespace", namespace: "istio-sytsem", operation: admissionv1beta1.Create},
+		{name: "missing namespace on update", namespace: "istio-sytsem", operation: admissionv1beta1.Update},
+		{name: "delete", namespace: "istio-sytsem", operation: admissionv1beta1.Delete, wantAllowed: true},
+		{name: "cluster scoped", operation: admissionv1beta1.Create, wantAllowed: true},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			resp := admit(context.Background(), &admissionv1beta1.AdmissionRequest{
+				Namespace: c.namespace,
+				Operation: c.operation,
+			})
+			if resp.Allowed != c.wantAllowed {
+				t.Fatalf("got allowed %v want %v: %v", resp.Allowed, c.wantAllowed, resp.Result)
+			}
+			if !c.wantAllowed && !strings.Contains(resp.Result.Message, `namespace "istio-sytsem" does not exist`) {
+				t.Fatalf("got message %q want the missing namespace", resp.Result.Message)
+			}
+		})
+	}
+}
+
+func TestRequireNamespaceDegraded(t *testing.T) {
+	wh := &Webhook{
+		namespaces: newNamespaceCache(func(string) (bool, error) {
+			return false, errors.New("connection refused")
+		}, time.Minute),
+	}
+	admit := wh.requireNamespace(func(context.Context, *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+		return wh.acceptResponse()
+	})
+	request := &admissionv1beta1.AdmissionRequest{Namespace: "default", Operation: admissionv1beta1.Create}
+
+	if resp := admit(context.Background(), request); resp.Allowed || !isInternalError(resp) {
+		t.Fatalf("got %v want an internal error", resp.Result)
+	}
+	wh.degradeReferenceChecks = true
+	if resp := admit(context.Background(), request); !resp.Allowed {
+		t.Fatalf("got %v want the object allowed", resp.Result)
+	}
+}
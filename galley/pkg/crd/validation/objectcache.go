@@ -0,0 +1,153 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubeschema "k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
+
+	"istio.io/istio/pilot/pkg/config/kube/crd"
+	"istio.io/istio/pkg/config/schema"
+	"istio.io/istio/pkg/config/schemas"
+)
+
+// objectCache holds the objects listed by the checks that look up other
+// objects, maintained by shared informers so that lookups are in memory.
+type objectCache struct {
+	factory   dynamicinformer.DynamicSharedInformerFactory
+	informers map[string]informers.GenericInformer
+}
+
+// newObjectCache returns a cache of the objects of the kinds. It is filled once started.
+func newObjectCache(client dynamic.Interface, resync time.Duration, kinds []schema.Instance) *objectCache {
+	c := &objectCache{
+		factory:   dynamicinformer.NewDynamicSharedInformerFactory(client, resync),
+		informers: make(map[string]informers.GenericInformer, len(kinds)),
+	}
+	for _, s := range kinds {
+		c.informers[s.Type] = c.factory.ForResource(resourceOf(s))
+	}
+	return c
+}
+
+// resourceOf returns the API resource of the objects of the kind.
+func resourceOf(s schema.Instance) kubeschema.GroupVersionResource {
+	return kubeschema.GroupVersionResource{
+		Group:    crd.ResourceGroup(&s),
+		Version:  s.Version,
+		Resource: crd.ResourceName(s.Plural),
+	}
+}
+
+// start runs the informers until stopCh is closed.
+func (c *objectCache) start(stopCh <-chan struct{}) {
+	c.factory.Start(stopCh)
+}
+
+// synced returns true once the informers have listed all the objects.
+func (c *objectCache) synced() bool {
+	for _, informer := range c.informers {
+		if !informer.Informer().HasSynced() {
+			return false
+		}
+	}
+	return true
+}
+
+// list returns the cached objects of the kind in the namespace, or all
+// namespaces if empty. It returns false if the kind is not cached, or its
+// informer has not synced yet.
+func (c *objectCache) list(s schema.Instance, namespace string) ([]crd.IstioKind, bool) {
+	informer, ok := c.informers[s.Type]
+	if !ok || !informer.Informer().HasSynced() {
+		return nil, false
+	}
+	var objects []runtime.Object
+	var err error
+	if namespace == "" {
+		objects, err = informer.Lister().List(labels.Everything())
+	} else {
+		objects, err = informer.Lister().ByNamespace(namespace).List(labels.Everything())
+	}
+	if err != nil {
+		return nil, false
+	}
+
+	items := make([]crd.IstioKind, 0, len(objects))
+	for _, object := range objects {
+		u, ok := object.(*unstructured.Unstructured)
+		if !ok {
+			return nil, false
+		}
+		var item crd.IstioKind
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.UnstructuredContent(), &item); err != nil {
+			return nil, false
+		}
+		items = append(items, item)
+	}
+	return items, true
+}
+
+// cachedKinds returns the kinds listed by the configured checks.
+func (wh *Webhook) cachedKinds() []schema.Instance {
+	var kinds []schema.Instance
+	if wh.protectReferencedObjects {
+		kinds = append(kinds, schemas.VirtualService)
+	}
+	if wh.strictSidecar {
+		kinds = append(kinds, schemas.Sidecar)
+	}
+	for kind := range wh.globalNameKinds {
+		if s, ok := schemas.Istio.GetByType(crd.CamelCaseToKebabCase(kind)); ok {
+			kinds = append(kinds, s)
+		}
+	}
+	return kinds
+}
+
+// useObjectCache serves the lookups of the checks from the object cache, and
+// from the API server until the cache is synced.
+func (wh *Webhook) useObjectCache(client dynamic.Interface, resync time.Duration) {
+	wh.objectCache = newObjectCache(client, resync, wh.cachedKinds())
+
+	listVirtualServices := wh.virtualServiceLister
+	wh.virtualServiceLister = func() ([]crd.IstioKind, error) {
+		if items, ok := wh.objectCache.list(schemas.VirtualService, ""); ok {
+			return items, nil
+		}
+		return listVirtualServices()
+	}
+	listSidecars := wh.sidecarLister
+	wh.sidecarLister = func(namespace string) ([]crd.IstioKind, error) {
+		if items, ok := wh.objectCache.list(schemas.Sidecar, namespace); ok {
+			return items, nil
+		}
+		return listSidecars(namespace)
+	}
+	listKind := wh.kindLister
+	wh.kindLister = func(s schema.Instance) ([]crd.IstioKind, error) {
+		if items, ok := wh.objectCache.list(s, ""); ok {
+			return items, nil
+		}
+		return listKind(s)
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+
+	"istio.io/istio/pilot/pkg/config/kube/crd"
+	"istio.io/istio/pkg/config/schema"
+	"istio.io/istio/pkg/config/schemas"
+)
+
+// cachedObject is an object of the fake dynamic client.
+type cachedObject struct {
+	schema    schema.Instance
+	namespace string
+	name      string
+	spec      map[string]interface{}
+}
+
+// newFakeDynamicClient returns a dynamic client with the objects. They are
+// created with their resource, which the fake client cannot always guess from
+// their kind, e.g. gatewaies.
+func newFakeDynamicClient(t *testing.T, objects ...cachedObject) dynamic.Interface {
+	t.Helper()
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	for _, o := range objects {
+		u := &unstructured.Unstructured{Object: map[string]interface{}{"spec": o.spec}}
+		u.SetAPIVersion(crd.APIVersion(&o.schema))
+		u.SetKind(crd.KebabCaseToCamelCase(o.schema.Type))
+		u.SetNamespace(o.namespace)
+		u.SetName(o.name)
+		if _, err := client.Resource(resourceOf(o.schema)).Namespace(o.namespace).Create(u, metav1.CreateOptions{}); err != nil {
+			t.Fatalf("Create(%v) failed: %v", o.name, err)
+		}
+	}
+	return client
+}
+
+// waitForObjectCache waits until the informers of the cache are synced.
+func waitForObjectCache(t *testing.T, c *objectCache) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !c.synced() {
+		if time.Now().After(deadline) {
+			t.Fatal("object cache not synced")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func cachedNames(items []crd.IstioKind) []string {
+	var out []string
+	for _, item := range items {
+		out = append(out, item.Namespace+"/"+item.Name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func TestObjectCache(t *testing.T) {
+	client := newFakeDynamicClient(t,
+		cachedObject{schemas.VirtualService, "default", "reviews", map[string]interface{}{"gateways": []interface{}{"ingress"}}},
+		cachedObject{schemas.VirtualService, "prod", "ratings", nil},
+		cachedObject{schemas.Sidecar, "default", "default", nil},
+		cachedObject{schemas.Sidecar, "prod", "default", nil})
+	c := newObjectCache(client, 0, []schema.Instance{schemas.VirtualService, schemas.Sidecar})
+
+	if c.synced() {
+		t.Fatal("object cache synced before it is started")
+	}
+	if _, ok := c.list(schemas.VirtualService, ""); ok {
+		t.Fatal("listed virtual services before the object cache is started")
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	c.start(stop)
+	waitForObjectCache(t, c)
+
+	items, ok := c.list(schemas.VirtualService, "")
+	if got, want := cachedNames(items), []string{"default/reviews", "prod/ratings"}; !ok || len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("got virtual services %v (%v) want %v", got, ok, want)
+	}
+	for _, item := range items {
+		if item.Name == "reviews" && len(item.Spec["gateways"].([]interface{})) != 1 {
+			t.Fatalf("got spec %v of reviews want its gateways", item.Spec)
+		}
+	}
+
+	items, ok = c.list(schemas.Sidecar, "prod")
+	if got := cachedNames(items); !ok || len(got) != 1 || got[0] != "prod/default" {
+		t.Fatalf("got sidecars %v (%v) in prod want prod/default", got, ok)
+	}
+
+	if _, ok := c.list(schemas.Gateway, ""); ok {
+		t.Fatal("listed gateways, which are not cached")
+	}
+}
+
+func TestUseObjectCache(t *testing.T) {
+	wh := &Webhook{protectReferencedObjects: true, globalNameKinds: map[string]bool{"Gateway": true}}
+	var apiServerLists int
+	wh.virtualServiceLister = func() ([]crd.IstioKind, error) {
+		apiServerLists++
+		return nil, nil
+	}
+	wh.sidecarLister = func(string) ([]crd.IstioKind, error) {
+		apiServerLists++
+		return nil, nil
+	}
+	wh.kindLister = func(schema.Instance) ([]crd.IstioKind, error) {
+		apiServerLists++
+		return nil, nil
+	}
+
+	client := newFakeDynamicClient(t,
+		cachedObject{schemas.VirtualService, "default", "reviews", nil},
+		cachedObject{schemas.Gateway, "default", "ingress", nil})
+	wh.useObjectCache(client, 0)
+
+	// the API server is listed until the cache is synced
+	if _, err := wh.virtualServiceLister(); err != nil || apiServerLists != 1 {
+		t.Fatalf("got error %v and %d API server lists want the API server listed", err, apiServerLists)
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	wh.objectCache.start(stop)
+	waitForObjectCache(t, wh.objectCache)
+
+	items, err := wh.virtualServiceLister()
+	if err != nil || len(items) != 1 || items[0].Name != "reviews" {
+		t.Fatalf("got virtual services %v and error %v want reviews", cachedNames(items), err)
+	}
+	items, err = wh.kindLister(schemas.Gateway)
+	if err != nil || len(items) != 1 || items[0].Name != "ingress" {
+		t.Fatalf("got gateways %v and error %v want ingress", cachedNames(items), err)
+	}
+	// sidecars are not cached without StrictSidecar
+	if _, err := wh.sidecarLister("default"); err != nil {
+		t.Fatalf("sidecarLister() failed: %v", err)
+	}
+	if apiServerLists != 2 {
+		t.Fatalf("got %d API server lists want 2", apiServerLists)
+	}
+}
+
+func TestServeReady_ObjectCacheNotSynced(t *testing.T) {
+	wh := &Webhook{objectCache: newObjectCache(newFakeDynamicClient(t), 0, []schema.Instance{schemas.VirtualService})}
+
+	w := httptest.NewRecorder()
+	wh.serveReady(w, httptest.NewRequest("GET", httpsHandlerReadyPath, nil))
+	res := w.Result()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("could not read body: %v", err)
+	}
+	if want := `{"reason":"object cache is not synced"}`; res.StatusCode != http.StatusServiceUnavailable || string(body) != want {
+		t.Fatalf("got status %v and body %s want %v and %s", res.StatusCode, body, http.StatusServiceUnavailable, want)
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	wh.objectCache.start(stop)
+	waitForObjectCache(t, wh.objectCache)
+
+	w = httptest.NewRecorder()
+	wh.serveReady(w, httptest.NewRequest("GET", httpsHandlerReadyPath, nil))
+	if res := w.Result(); res.StatusCode != http.StatusOK {
+		t.Fatalf("got status %v want %v once the object cache is synced", res.StatusCode, http.StatusOK)
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"context"
+	"fmt"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+)
+
+// UnhandledOperationPolicy is the response to admission requests for an
+// operation the webhook does not handle, i.e. other than CREATE, UPDATE and
+// DELETE, e.g. CONNECT.
+type UnhandledOperationPolicy string
+
+const (
+	// UnhandledOperationAccept admits unhandled operations.
+	UnhandledOperationAccept UnhandledOperationPolicy = "accept"
+
+	// UnhandledOperationReject rejects unhandled operations.
+	UnhandledOperationReject UnhandledOperationPolicy = "reject"
+)
+
+// validate returns an error if the policy is unknown. An empty policy accepts.
+func (p UnhandledOperationPolicy) validate() error {
+	switch p {
+	case "", UnhandledOperationAccept, UnhandledOperationReject:
+		return nil
+	default:
+		return fmt.Errorf("invalid unhandled operation policy %q, want %q or %q",
+			p, UnhandledOperationAccept, UnhandledOperationReject)
+	}
+}
+
+// admitUnhandledOperation responds to a request for an operation the webhook
+// does not handle according to the UnhandledOperationPolicy.
+func (wh *Webhook) admitUnhandledOperation(ctx context.Context,
+	request *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+
+	reportValidationFailed(request, reasonUnsupportedOperation)
+	if wh.unhandledOperationPolicy == UnhandledOperationReject {
+		requestLog(ctx).Warnf("Rejecting unsupported webhook operation %v of %s %s/%s",
+			request.Operation, request.Kind.Kind, request.Namespace, request.Name)
+		return toAdmissionResponse(fmt.Errorf("unsupported operation %v", request.Operation))
+	}
+	requestLog(ctx).Warnf("Accepting unsupported webhook operation %v of %s %s/%s",
+		request.Operation, request.Kind.Kind, request.Namespace, request.Name)
+	return wh.acceptResponse()
+}
@@ -0,0 +1,80 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestAdmitUnhandledOperation(t *testing.T) {
+	wh, cancel := createTestWebhook(t, dummyClient, createFakeEndpointsSource(), dummyConfig)
+	defer cancel()
+	pilotConfig := makePilotConfig(t, 0, true, false)
+	mixerConfig := makeMixerConfig(t, 0, false)
+
+	cases := []struct {
+		name        string
+		policy      UnhandledOperationPolicy
+		admit       admitFunc
+		raw         []byte
+		operation   admissionv1beta1.Operation
+		wantAllowed bool
+	}{
+		{name: "pilot default", admit: wh.admitPilot, raw: pilotConfig, operation: admissionv1beta1.Connect, wantAllowed: true},
+		{
+			name:        "pilot accept",
+			policy:      UnhandledOperationAccept,
+			admit:       wh.admitPilot,
+			raw:         pilotConfig,
+			operation:   admissionv1beta1.Connect,
+			wantAllowed: true,
+		},
+		{name: "pilot reject", policy: UnhandledOperationReject, admit: wh.admitPilot, raw: pilotConfig, operation: admissionv1beta1.Connect},
+		{
+			name:        "pilot delete",
+			policy:      UnhandledOperationReject,
+			admit:       wh.admitPilot,
+			raw:         pilotConfig,
+			operation:   admissionv1beta1.Delete,
+			wantAllowed: true,
+		},
+		{name: "mixer reject", policy: UnhandledOperationReject, admit: wh.admitMixer, raw: mixerConfig, operation: "PATCH"},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			wh.unhandledOperationPolicy = c.policy
+			resp := c.admit(context.Background(), &admissionv1beta1.AdmissionRequest{
+				Kind:      metav1.GroupVersionKind{Kind: "mock"},
+				Name:      "unhandled",
+				Object:    runtime.RawExtension{Raw: c.raw},
+				Operation: c.operation,
+			})
+			if resp.Allowed != c.wantAllowed {
+				t.Fatalf("got allowed %v want %v: %v", resp.Allowed, c.wantAllowed, resp.Result)
+			}
+			if !c.wantAllowed && !strings.Contains(resp.Result.Message, "unsupported operation "+string(c.operation)) {
+				t.Fatalf("got message %q want the unsupported operation", resp.Result.Message)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"encoding/json"
+	"fmt"
+
+	kubeschema "k8s.io/apimachinery/pkg/runtime/schema"
+	clientset "k8s.io/client-go/kubernetes"
+
+	"istio.io/istio/mixer/pkg/config/store"
+	"istio.io/istio/pilot/pkg/config/kube/crd"
+	"istio.io/istio/pkg/config/schema"
+	"istio.io/istio/pkg/config/schemas"
+)
+
+// Option configures the WebhookParameters built by NewParameters.
+type Option func(*options)
+
+type options struct {
+	params WebhookParameters
+
+	// kinds restricts the pilot descriptor, if set.
+	kinds []string
+}
+
+// WithPort sets the port the webhook is served on.
+func WithPort(port uint) Option {
+	return func(o *options) {
+		o.params.Port = port
+	}
+}
+
+// WithStatusPort serves the readiness and debug endpoints on a separate port.
+func WithStatusPort(port uint) Option {
+	return func(o *options) {
+		o.params.StatusPort = port
+	}
+}
+
+// WithCertFiles sets the x509 certificate, private key and CA bundle files.
+func WithCertFiles(certFile, keyFile, caCertFile string) Option {
+	return func(o *options) {
+		o.params.CertFile = certFile
+		o.params.KeyFile = keyFile
+		o.params.CACertFile = caCertFile
+	}
+}
+
+// WithWebhookConfigFile sets the validatingwebhookconfiguration file used for self-registration.
+func WithWebhookConfigFile(file string) Option {
+	return func(o *options) {
+		o.params.WebhookConfigFile = file
+	}
+}
+
+// WithClientset sets the k8s client.
+func WithClientset(cl clientset.Interface) Option {
+	return func(o *options) {
+		o.params.Clientset = cl
+	}
+}
+
+// WithLifecycleObserver sets the observer notified of the webhook lifecycle.
+func WithLifecycleObserver(observer LifecycleObserver) Option {
+	return func(o *options) {
+		o.params.LifecycleObserver = observer
+	}
+}
+
+// WithDomainSuffix sets the DNS domain suffix for pilot configuration.
+func WithDomainSuffix(domainSuffix string) Option {
+	return func(o *options) {
+		o.params.DomainSuffix = domainSuffix
+	}
+}
+
+// WithPilotDescriptor sets the schemas of the pilot configuration that is validated.
+func WithPilotDescriptor(descriptor schema.Set) Option {
+	return func(o *options) {
+		o.params.PilotDescriptor = descriptor
+	}
+}
+
+// WithValidatedKinds restricts pilot validation to the kinds, e.g. VirtualService,
+// of the pilot descriptor. Objects of other kinds are unmatched, i.e. rejected as
+// unrecognized unless the DefaultDecisionForUnmatched is accept.
+func WithValidatedKinds(kinds ...string) Option {
+	return func(o *options) {
+		o.kinds = append(o.kinds, kinds...)
+	}
+}
+
+// WithMixerValidator sets the mixer validator.
+func WithMixerValidator(validator store.BackendValidator) Option {
+	return func(o *options) {
+		o.params.MixerValidator = validator
+	}
+}
+
+// WithMixerValidation enables or disables mixer validation.
+func WithMixerValidation(enabled bool) Option {
+	return func(o *options) {
+		o.params.EnableMixerValidation = enabled
+	}
+}
+
+// WithReconcileWebhookConfiguration enables or disables reconciling the validatingwebhookconfiguration.
+func WithReconcileWebhookConfiguration(enabled bool) Option {
+	return func(o *options) {
+		o.params.EnableReconcileWebhookConfiguration = enabled
+	}
+}
+
+// WithDebugEndpoints enables the debug endpoints, protected by the bearer token.
+func WithDebugEndpoints(token string) Option {
+	return func(o *options) {
+		o.params.EnableDebugEndpoints = true
+		o.params.DebugToken = token
+	}
+}
+
+// WithValidationPipeline sets the ordered stages objects are validated by.
+func WithValidationPipeline(stages ...ValidationStage) Option {
+	return func(o *options) {
+		o.params.ValidationPipeline = append(o.params.ValidationPipeline, stages...)
+	}
+}
+
+// WithJSONSchema validates objects of the kind against the JSON Schema document.
+func WithJSONSchema(gvk kubeschema.GroupVersionKind, schema json.RawMessage) Option {
+	return func(o *options) {
+		if o.params.JSONSchemas == nil {
+			o.params.JSONSchemas = make(map[kubeschema.GroupVersionKind]json.RawMessage)
+		}
+		o.params.JSONSchemas[gvk] = schema
+	}
+}
+
+// WithPreValidateTransform rewrites objects with the transform before they are validated.
+func WithPreValidateTransform(transform PreValidateTransform) Option {
+	return func(o *options) {
+		o.params.PreValidateTransform = transform
+	}
+}
+
+// NewParameters returns the DefaultArgs with the options applied. The pilot
+// descriptor defaults to the Istio schemas. The parameters are validated.
+func NewParameters(opts ...Option) (*WebhookParameters, error) {
+	o := &options{params: *DefaultArgs()}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.params.PilotDescriptor == nil {
+		o.params.PilotDescriptor = schemas.Istio
+	}
+
+	if len(o.kinds) > 0 {
+		descriptor := make(schema.Set, 0, len(o.kinds))
+		for _, kind := range o.kinds {
+			s, ok := o.params.PilotDescriptor.GetByType(crd.CamelCaseToKebabCase(kind))
+			if !ok {
+				return nil, fmt.Errorf("validated kind %q is not in the pilot descriptor", kind)
+			}
+			descriptor = append(descriptor, s)
+		}
+		o.params.PilotDescriptor = descriptor
+	}
+
+	if err := o.params.Validate(); err != nil {
+		return nil, err
+	}
+	return &o.params, nil
+}
+
+// NewWebhookWithOptions creates a new instance of the admission webhook
+// controller from the parameters built by NewParameters.
+func NewWebhookWithOptions(opts ...Option) (*Webhook, error) {
+	p, err := NewParameters(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return NewWebhook(*p)
+}
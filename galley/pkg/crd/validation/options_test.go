@@ -0,0 +1,82 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"reflect"
+	"testing"
+
+	"istio.io/istio/pkg/config/schemas"
+)
+
+func TestNewParameters(t *testing.T) {
+	p, err := NewParameters(
+		WithPort(8443),
+		WithCertFiles("cert.pem", "key.pem", "ca.pem"),
+		WithWebhookConfigFile("webhook.yaml"),
+		WithValidatedKinds("VirtualService", "Gateway"),
+		WithMixerValidation(false),
+		WithDebugEndpoints("secret"),
+	)
+	if err != nil {
+		t.Fatalf("NewParameters() failed: %v", err)
+	}
+
+	want := DefaultArgs()
+	want.Port = 8443
+	want.CertFile = "cert.pem"
+	want.KeyFile = "key.pem"
+	want.CACertFile = "ca.pem"
+	want.WebhookConfigFile = "webhook.yaml"
+	want.EnableMixerValidation = false
+	want.EnableDebugEndpoints = true
+	want.DebugToken = "secret"
+
+	// schemas hold validation functions, so compare the descriptor by type.
+	wantTypes := []string{schemas.VirtualService.Type, schemas.Gateway.Type}
+	if got := p.PilotDescriptor.Types(); !reflect.DeepEqual(got, wantTypes) {
+		t.Fatalf("got descriptor %v want %v", got, wantTypes)
+	}
+	want.PilotDescriptor = p.PilotDescriptor
+	if !reflect.DeepEqual(p, want) {
+		t.Fatalf("got\n%v\nwant\n%v", p, want)
+	}
+}
+
+func TestNewParameters_Errors(t *testing.T) {
+	cases := map[string][]Option{
+		"unknown kind": {
+			WithWebhookConfigFile("webhook.yaml"),
+			WithValidatedKinds("Unknown"),
+		},
+		"invalid port": {
+			WithWebhookConfigFile("webhook.yaml"),
+			WithPort(100000),
+		},
+		"missing webhook config file": {},
+		"debug endpoints without token": {
+			WithWebhookConfigFile("webhook.yaml"),
+			WithDebugEndpoints(""),
+		},
+	}
+
+	for name, opts := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := NewParameters(opts...); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// recoverPanics wraps an admitFunc so that a panic, e.g. a bug of a validator,
+// rejects the request with the PanicResponseCode and PanicResponseReason rather
+// than dropping the connection of the API server. The stack of the panic is logged.
+func (wh *Webhook) recoverPanics(admit admitFunc) admitFunc {
+	return func(ctx context.Context, request *admissionv1beta1.AdmissionRequest) (response *admissionv1beta1.AdmissionResponse) {
+		defer func() {
+			if r := recover(); r != nil {
+				requestLog(ctx).Errorf("Validation of %s %s/%s panicked: %v\n%s",
+					request.Kind.Kind, request.Namespace, request.Name, r, debug.Stack())
+				reportValidationFailed(request, reasonValidatorPanic)
+				response = wh.panicResponse(r)
+			}
+		}()
+		return admit(ctx, request)
+	}
+}
+
+// panicResponse returns the rejection of a request whose validation panicked with r.
+func (wh *Webhook) panicResponse(r interface{}) *admissionv1beta1.AdmissionResponse {
+	code, reason := wh.panicResponseCode, wh.panicResponseReason
+	if code == 0 {
+		code = http.StatusInternalServerError
+	}
+	if reason == "" {
+		reason = v1.StatusReasonInternalError
+	}
+	return &admissionv1beta1.AdmissionResponse{Result: &v1.Status{
+		Message: fmt.Sprintf("internal error: validation panicked: %v", r),
+		Reason:  reason,
+		Code:    code,
+	}}
+}
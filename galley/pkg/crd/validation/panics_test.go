@@ -0,0 +1,115 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/sync/singleflight"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"istio.io/istio/mixer/pkg/config/store"
+)
+
+// panickingValidator is a mixer validator with a bug.
+type panickingValidator struct{ fakeValidator }
+
+func (*panickingValidator) Validate(*store.BackendEvent) error {
+	panic("validator bug")
+}
+
+func TestRecoverPanics(t *testing.T) {
+	wh, cancel := createTestWebhook(t, dummyClient, createFakeEndpointsSource(), dummyConfig)
+	defer cancel()
+	setMixerValidator(t, wh, &panickingValidator{})
+
+	review, err := json.Marshal(admissionv1beta1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1beta1", Kind: "AdmissionReview"},
+		Request: &admissionv1beta1.AdmissionRequest{
+			UID:       "uid",
+			Kind:      metav1.GroupVersionKind{Kind: "mock"},
+			Name:      "panics",
+			Object:    runtime.RawExtension{Raw: makeMixerConfig(t, 0, false)},
+			Operation: admissionv1beta1.Create,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create AdmissionReview: %v", err)
+	}
+
+	cases := []struct {
+		name        string
+		code        int32
+		reason      metav1.StatusReason
+		deduplicate bool
+		wantCode    int32
+		wantReason  metav1.StatusReason
+	}{
+		{name: "default", wantCode: http.StatusInternalServerError, wantReason: metav1.StatusReasonInternalError},
+		{
+			name:       "configured",
+			code:       http.StatusServiceUnavailable,
+			reason:     "ValidatorPanic",
+			wantCode:   http.StatusServiceUnavailable,
+			wantReason: "ValidatorPanic",
+		},
+		{
+			name:        "configured and deduplicated",
+			code:        http.StatusServiceUnavailable,
+			reason:      "ValidatorPanic",
+			deduplicate: true,
+			wantCode:    http.StatusServiceUnavailable,
+			wantReason:  "ValidatorPanic",
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			wh.panicResponseCode, wh.panicResponseReason = c.code, c.reason
+			wh.inflight = nil
+			if c.deduplicate {
+				wh.inflight = &singleflight.Group{}
+			}
+			req := httptest.NewRequest("POST", "http://validator", bytes.NewReader(review))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			wh.serveAdmitMixer(w, req)
+
+			var got admissionv1beta1.AdmissionReview
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatalf("Failed to decode the response %q: %v", w.Body.String(), err)
+			}
+			resp := got.Response
+			if resp == nil || resp.Allowed || resp.Result == nil {
+				t.Fatalf("got response %v want a rejection", resp)
+			}
+			if resp.Result.Code != c.wantCode || resp.Result.Reason != c.wantReason {
+				t.Fatalf("got code %d reason %q want code %d reason %q",
+					resp.Result.Code, resp.Result.Reason, c.wantCode, c.wantReason)
+			}
+			if !strings.Contains(resp.Result.Message, "validator bug") {
+				t.Fatalf("got message %q want the panic", resp.Result.Message)
+			}
+		})
+	}
+}
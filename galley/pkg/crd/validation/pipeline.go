@@ -0,0 +1,256 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ValidationStageName names a stage of the validation pipeline.
+type ValidationStageName string
+
+const (
+	// StageSchema validates the object against its pilot schema or the mixer
+	// validator, depending on the admission path.
+	StageSchema ValidationStageName = "schema"
+
+	// StageRegistry validates the object against the schema registry, if configured.
+	StageRegistry ValidationStageName = "registry"
+
+	// StagePolicy evaluates the Rego policies, if configured.
+	StagePolicy ValidationStageName = "policy"
+)
+
+// ValidationStage is a stage of the validation pipeline.
+type ValidationStage struct {
+	Name ValidationStageName
+
+	// ContinueOnFailure runs the later stages even if this stage rejects the
+	// object. The rejections of all stages are then reported together.
+	ContinueOnFailure bool
+
+	// Timeout bounds the time the stage runs, if it is positive. A stage that
+	// times out rejects the object, unless AcceptOnTimeout is set.
+	Timeout time.Duration
+
+	// AcceptOnTimeout treats a stage that times out as if it accepted the object.
+	AcceptOnTimeout bool
+}
+
+func (s ValidationStage) String() string {
+	var options []string
+	if s.ContinueOnFailure {
+		options = append(options, "continue")
+	}
+	if s.Timeout > 0 {
+		options = append(options, fmt.Sprintf("timeout=%v", s.Timeout))
+	}
+	if s.AcceptOnTimeout {
+		options = append(options, "accept-on-timeout")
+	}
+	if len(options) == 0 {
+		return string(s.Name)
+	}
+	return fmt.Sprintf("%s(%s)", s.Name, strings.Join(options, ","))
+}
+
+// defaultValidationPipeline runs the stages in order and stops at the first failure.
+func defaultValidationPipeline() []ValidationStage {
+	return []ValidationStage{{Name: StageSchema}, {Name: StageRegistry}, {Name: StagePolicy}}
+}
+
+// validateValidationPipeline returns an error if a stage is unknown or repeated,
+// or the schema stage is missing. An empty pipeline is the default pipeline.
+func validateValidationPipeline(stages []ValidationStage) error {
+	if len(stages) == 0 {
+		return nil
+	}
+
+	var errs *multierror.Error
+	seen := make(map[ValidationStageName]bool, len(stages))
+	for _, stage := range stages {
+		switch stage.Name {
+		case StageSchema, StageRegistry, StagePolicy:
+		default:
+			errs = multierror.Append(errs, fmt.Errorf("invalid validation stage %q, want one of %q, %q or %q",
+				stage.Name, StageSchema, StageRegistry, StagePolicy))
+			continue
+		}
+		if stage.Timeout < 0 {
+			errs = multierror.Append(errs, fmt.Errorf("invalid timeout of validation stage %q: %v", stage.Name, stage.Timeout))
+		} else if stage.AcceptOnTimeout && stage.Timeout == 0 {
+			errs = multierror.Append(errs, fmt.Errorf("validation stage %q accepts on timeout but has no timeout", stage.Name))
+		}
+		if seen[stage.Name] {
+			errs = multierror.Append(errs, fmt.Errorf("duplicate validation stage %q", stage.Name))
+		}
+		seen[stage.Name] = true
+	}
+	if !seen[StageSchema] {
+		errs = multierror.Append(errs, fmt.Errorf("validation pipeline is missing the %q stage", StageSchema))
+	}
+	return errs.ErrorOrNil()
+}
+
+// runPipeline runs the stages of the validation pipeline on the request, with
+// the schema stage of the admission path, which is called with the context of
+// the stage. It returns the first rejection of a
+// stage that stops on failure, or the rejections collected so far, otherwise
+// the request is accepted.
+func (wh *Webhook) runPipeline(ctx context.Context, request *admissionv1beta1.AdmissionRequest,
+	schemaStage func(context.Context) *admissionv1beta1.AdmissionResponse) *admissionv1beta1.AdmissionResponse {
+
+	stages := map[ValidationStageName]func(context.Context) *admissionv1beta1.AdmissionResponse{
+		StageSchema: func(ctx context.Context) *admissionv1beta1.AdmissionResponse {
+			if resp := schemaStage(ctx); resp != nil {
+				return resp
+			}
+			return wh.admitJSONSchema(ctx, request)
+		},
+		StageRegistry: func(ctx context.Context) *admissionv1beta1.AdmissionResponse {
+			return wh.admitRegistrySchema(ctx, request)
+		},
+		StagePolicy: func(ctx context.Context) *admissionv1beta1.AdmissionResponse { return wh.admitPolicies(ctx, request) },
+	}
+
+	var rejected []*admissionv1beta1.AdmissionResponse
+	for _, stage := range wh.pipeline {
+		resp := runStage(ctx, request, stage, stages[stage.Name])
+		if resp == nil {
+			continue
+		}
+		rejected = append(rejected, resp)
+		if !stage.ContinueOnFailure {
+			break
+		}
+	}
+
+	switch len(rejected) {
+	case 0:
+		reportValidationPass(request)
+		return wh.acceptResponse()
+	case 1:
+		return rejected[0]
+	default:
+		return mergeRejections(rejected)
+	}
+}
+
+// mergeRejections merges the rejections of several stages into one with all
+// their messages and causes, including the violation codes, and the status of
+// the most severe rejection, i.e. that with the highest code, so that e.g. an
+// internal error of a stage is still reported as such.
+func mergeRejections(rejected []*admissionv1beta1.AdmissionResponse) *admissionv1beta1.AdmissionResponse {
+	var errs *multierror.Error
+	var details *v1.StatusDetails
+	severe := rejected[0].Result
+	for _, resp := range rejected {
+		errs = multierror.Append(errs, errors.New(resp.Result.Message))
+		if resp.Result.Code > severe.Code {
+			severe = resp.Result
+		}
+		if resp.Result.Details == nil {
+			continue
+		}
+		if details == nil {
+			details = &v1.StatusDetails{
+				Name:  resp.Result.Details.Name,
+				Group: resp.Result.Details.Group,
+				Kind:  resp.Result.Details.Kind,
+			}
+		}
+		details.Causes = append(details.Causes, resp.Result.Details.Causes...)
+	}
+
+	merged := toAdmissionResponse(errs)
+	merged.Result.Status = severe.Status
+	merged.Result.Reason = severe.Reason
+	merged.Result.Code = severe.Code
+	merged.Result.Details = details
+	return merged
+}
+
+// stageOutcomeKey is the context key of the outcome of a stage with a timeout.
+type stageOutcomeKey struct{}
+
+// stageOutcome is claimed once, either by the stage reporting its failure or
+// by runStage reporting its timeout, so that each request is counted once.
+type stageOutcome struct {
+	once sync.Once
+}
+
+// claim returns true if the outcome was not claimed yet.
+func (o *stageOutcome) claim() bool {
+	claimed := false
+	o.once.Do(func() { claimed = true })
+	return claimed
+}
+
+// reportStageFailed reports the validation of the request as failed by a stage,
+// unless the stage already timed out and was reported as such by runStage.
+func reportStageFailed(ctx context.Context, request *admissionv1beta1.AdmissionRequest, reason string) {
+	if outcome, ok := ctx.Value(stageOutcomeKey{}).(*stageOutcome); ok && !outcome.claim() {
+		return
+	}
+	reportValidationFailed(request, reason)
+}
+
+// runStage runs the stage within its timeout. The stage keeps running in the
+// background if it times out, but its result is discarded and no longer
+// reported, see reportStageFailed.
+func runStage(ctx context.Context, request *admissionv1beta1.AdmissionRequest, stage ValidationStage,
+	run func(context.Context) *admissionv1beta1.AdmissionResponse) *admissionv1beta1.AdmissionResponse {
+
+	if stage.Timeout <= 0 {
+		return run(ctx)
+	}
+	outcome := &stageOutcome{}
+	stageCtx, cancel := context.WithTimeout(context.WithValue(ctx, stageOutcomeKey{}, outcome), stage.Timeout)
+	defer cancel()
+
+	result := make(chan *admissionv1beta1.AdmissionResponse, 1)
+	go func() {
+		result <- run(stageCtx)
+	}()
+
+	select {
+	case resp := <-result:
+		return resp
+	case <-stageCtx.Done():
+		if !outcome.claim() {
+			// the stage reported its failure just in time and returns it
+			return <-result
+		}
+		reportStageTimeout(request, stage.Name)
+		if stage.AcceptOnTimeout {
+			requestLog(ctx).Warnf("Validation stage %q of %s %s/%s timed out after %v, accepting",
+				stage.Name, request.Kind.Kind, request.Namespace, request.Name, stage.Timeout)
+			return nil
+		}
+		requestLog(ctx).Infof("Validation stage %q of %s %s/%s timed out after %v",
+			stage.Name, request.Kind.Kind, request.Namespace, request.Name, stage.Timeout)
+		reportValidationFailed(request, reasonStageTimeout)
+		return toInternalErrorResponse(fmt.Errorf("validation stage %q timed out after %v", stage.Name, stage.Timeout))
+	}
+}
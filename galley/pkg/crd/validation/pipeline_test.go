@@ -0,0 +1,268 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidateValidationPipeline(t *testing.T) {
+	cases := []struct {
+		name    string
+		stages  []ValidationStage
+		wantErr string
+	}{
+		{
+			name: "default",
+		},
+		{
+			name:   "reordered",
+			stages: []ValidationStage{{Name: StagePolicy, ContinueOnFailure: true}, {Name: StageSchema}},
+		},
+		{
+			name:    "unknown stage",
+			stages:  []ValidationStage{{Name: StageSchema}, {Name: "cel"}},
+			wantErr: `invalid validation stage "cel"`,
+		},
+		{
+			name:    "duplicate stage",
+			stages:  []ValidationStage{{Name: StageSchema}, {Name: StageSchema}},
+			wantErr: `duplicate validation stage "schema"`,
+		},
+		{
+			name:    "missing schema stage",
+			stages:  []ValidationStage{{Name: StagePolicy}},
+			wantErr: `validation pipeline is missing the "schema" stage`,
+		},
+		{
+			name:    "negative timeout",
+			stages:  []ValidationStage{{Name: StageSchema, Timeout: -time.Second}},
+			wantErr: `invalid timeout of validation stage "schema"`,
+		},
+		{
+			name:    "accept on timeout without timeout",
+			stages:  []ValidationStage{{Name: StageSchema, AcceptOnTimeout: true}},
+			wantErr: `validation stage "schema" accepts on timeout but has no timeout`,
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			err := validateValidationPipeline(c.stages)
+			if c.wantErr == "" {
+				if err != nil {
+					t.Fatalf("got unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+				t.Fatalf("got error %v want %q", err, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidationStageString(t *testing.T) {
+	cases := []struct {
+		stage ValidationStage
+		want  string
+	}{
+		{stage: ValidationStage{Name: StageSchema}, want: "schema"},
+		{stage: ValidationStage{Name: StagePolicy, ContinueOnFailure: true}, want: "policy(continue)"},
+		{
+			stage: ValidationStage{Name: StageRegistry, ContinueOnFailure: true, Timeout: time.Second, AcceptOnTimeout: true},
+			want:  "registry(continue,timeout=1s,accept-on-timeout)",
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.want), func(t *testing.T) {
+			if got := c.stage.String(); got != c.want {
+				t.Fatalf("got %q want %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestRunPipeline(t *testing.T) {
+	request := &admissionv1beta1.AdmissionRequest{
+		Kind:      metav1.GroupVersionKind{Group: "networking.istio.io", Version: "v1alpha3", Kind: "Gateway"},
+		Operation: admissionv1beta1.Create,
+	}
+	reject := func(context.Context) *admissionv1beta1.AdmissionResponse {
+		return toAdmissionResponse(errors.New("schema rejected"))
+	}
+	accept := func(context.Context) *admissionv1beta1.AdmissionResponse { return nil }
+	blocked := make(chan struct{})
+	defer close(blocked)
+	block := func(context.Context) *admissionv1beta1.AdmissionResponse {
+		<-blocked
+		return nil
+	}
+
+	cases := []struct {
+		name        string
+		pipeline    []ValidationStage
+		schemaStage func(context.Context) *admissionv1beta1.AdmissionResponse
+		wantAllowed bool
+		wantMessage []string
+		notMessage  string
+	}{
+		{
+			name:        "accepted",
+			pipeline:    defaultValidationPipeline(),
+			schemaStage: accept,
+			wantAllowed: true,
+		},
+		{
+			name:        "stop at first failure",
+			pipeline:    defaultValidationPipeline(),
+			schemaStage: reject,
+			wantMessage: []string{"schema rejected"},
+			notMessage:  "registry",
+		},
+		{
+			name:        "registry first",
+			pipeline:    []ValidationStage{{Name: StageRegistry}, {Name: StageSchema}},
+			schemaStage: reject,
+			wantMessage: []string{"schema registry http://registry is unavailable"},
+			notMessage:  "schema rejected",
+		},
+		{
+			name:        "collect all",
+			pipeline:    []ValidationStage{{Name: StageSchema, ContinueOnFailure: true}, {Name: StageRegistry}},
+			schemaStage: reject,
+			wantMessage: []string{"schema rejected", "schema registry http://registry is unavailable"},
+		},
+		{
+			name:        "reject on timeout",
+			pipeline:    []ValidationStage{{Name: StageSchema, Timeout: 10 * time.Millisecond}},
+			schemaStage: block,
+			wantMessage: []string{`validation stage "schema" timed out after 10ms`},
+		},
+		{
+			name:        "accept on timeout",
+			pipeline:    []ValidationStage{{Name: StageSchema, Timeout: 10 * time.Millisecond, AcceptOnTimeout: true}},
+			schemaStage: block,
+			wantAllowed: true,
+		},
+		{
+			name:        "within timeout",
+			pipeline:    []ValidationStage{{Name: StageSchema, Timeout: time.Minute}, {Name: StageRegistry}},
+			schemaStage: reject,
+			wantMessage: []string{"schema rejected"},
+			notMessage:  "registry",
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			// the registry is never refreshed, so it rejects all objects
+			wh := &Webhook{
+				pipeline:       c.pipeline,
+				schemaRegistry: newSchemaRegistry("http://registry", 0),
+			}
+			if c.wantAllowed {
+				wh.schemaRegistry = nil
+			}
+
+			resp := wh.runPipeline(context.Background(), request, c.schemaStage)
+			if resp.Allowed != c.wantAllowed {
+				t.Fatalf("got allowed %v want %v", resp.Allowed, c.wantAllowed)
+			}
+			for _, message := range c.wantMessage {
+				if !strings.Contains(resp.Result.Message, message) {
+					t.Fatalf("got message %q want it to contain %q", resp.Result.Message, message)
+				}
+			}
+			if c.notMessage != "" && strings.Contains(resp.Result.Message, c.notMessage) {
+				t.Fatalf("got message %q want it not to contain %q", resp.Result.Message, c.notMessage)
+			}
+		})
+	}
+}
+
+func TestRunStageReportsOnce(t *testing.T) {
+	request := &admissionv1beta1.AdmissionRequest{
+		Kind: metav1.GroupVersionKind{Group: "networking.istio.io", Version: "v1alpha3", Kind: "Gateway"},
+	}
+	stage := ValidationStage{Name: StageSchema, Timeout: 10 * time.Millisecond}
+
+	// a stage that fails after its timeout must not report its failure
+	release, claimed := make(chan struct{}), make(chan bool, 1)
+	resp := runStage(context.Background(), request, stage, func(ctx context.Context) *admissionv1beta1.AdmissionResponse {
+		<-release
+		claimed <- ctx.Value(stageOutcomeKey{}).(*stageOutcome).claim()
+		return toAdmissionResponse(errors.New("schema rejected"))
+	})
+	close(release)
+	if !isInternalError(resp) {
+		t.Fatalf("got %v want the timeout of the stage", resp.Result)
+	}
+	if <-claimed {
+		t.Fatal("the stage claimed its outcome after it timed out")
+	}
+
+	// a stage that reported its failure before its timeout returns it
+	resp = runStage(context.Background(), request, stage, func(ctx context.Context) *admissionv1beta1.AdmissionResponse {
+		ctx.Value(stageOutcomeKey{}).(*stageOutcome).claim()
+		<-ctx.Done()
+		return toAdmissionResponse(errors.New("schema rejected"))
+	})
+	if resp.Result.Message != "schema rejected" {
+		t.Fatalf("got %v want the failure reported by the stage", resp.Result)
+	}
+}
+
+func TestMergeRejections(t *testing.T) {
+	request := &admissionv1beta1.AdmissionRequest{
+		Kind: metav1.GroupVersionKind{Group: "networking.istio.io", Version: "v1alpha3", Kind: "VirtualService"},
+	}
+	report := validationReport{codes: defaultViolationCodes}
+	report.add(CheckSchema, "spec.hosts", errors.New("invalid host"))
+	invalid := report.response(request, "reviews")
+	timedOut := toInternalErrorResponse(errors.New(`validation stage "policy" timed out after 1s`))
+
+	merged := mergeRejections([]*admissionv1beta1.AdmissionResponse{invalid, timedOut})
+	if !isInternalError(merged) || merged.Result.Reason != metav1.StatusReasonInternalError {
+		t.Fatalf("got status %v %v want the internal error of the timed out stage", merged.Result.Code, merged.Result.Reason)
+	}
+	for _, message := range []string{"invalid host", "timed out"} {
+		if !strings.Contains(merged.Result.Message, message) {
+			t.Fatalf("got message %q want it to contain %q", merged.Result.Message, message)
+		}
+	}
+	if merged.Result.Details == nil || !reflect.DeepEqual(merged.Result.Details.Causes, invalid.Result.Details.Causes) {
+		t.Fatalf("got details %+v want the causes of the invalid object", merged.Result.Details)
+	}
+	if merged.Result.Details.Name != "reviews" {
+		t.Fatalf("got details name %q want reviews", merged.Result.Details.Name)
+	}
+
+	merged = mergeRejections([]*admissionv1beta1.AdmissionResponse{toAdmissionResponse(errors.New("denied")), invalid})
+	if merged.Result.Code != http.StatusUnprocessableEntity || merged.Result.Reason != metav1.StatusReasonInvalid {
+		t.Fatalf("got status %v %v want that of the invalid object", merged.Result.Code, merged.Result.Reason)
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/rego"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+)
+
+const regoPolicyExt = ".rego"
+
+// regoPolicies are the compiled Rego policies evaluated against admitted objects.
+// Each policy is a module that denies a request by adding messages to its `deny`
+// set, e.g.
+//
+//	package istio.gateways
+//
+//	deny[msg] {
+//		input.kind == "Gateway"
+//		not input.object.metadata.labels.owner
+//		msg := "gateways must have an owner label"
+//	}
+//
+// The input holds the `operation`, `kind` and `namespace` of the request and the
+// admitted `object`.
+type regoPolicies struct {
+	compiler *ast.Compiler
+
+	// names are the sorted package names of the policies, e.g. istio.gateways.
+	names []string
+}
+
+// loadRegoPolicies parses and compiles the Rego policies in the directory,
+// e.g. a mounted ConfigMap.
+func loadRegoPolicies(dir string) (*regoPolicies, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read rego policy directory %s: %v", dir, err)
+	}
+
+	modules := make(map[string]*ast.Module)
+	packages := make(map[string]bool)
+	for _, f := range files {
+		if f.IsDir() || filepath.Ext(f.Name()) != regoPolicyExt {
+			continue
+		}
+		filename := filepath.Join(dir, f.Name())
+		policy, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read rego policy %s: %v", filename, err)
+		}
+		module, err := ast.ParseModule(filename, string(policy))
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse rego policy %s: %v", filename, err)
+		}
+		modules[filename] = module
+		packages[strings.TrimPrefix(module.Package.Path.String(), "data.")] = true
+	}
+
+	compiler := ast.NewCompiler()
+	compiler.Compile(modules)
+	if compiler.Failed() {
+		return nil, fmt.Errorf("cannot compile rego policies in %s: %v", dir, compiler.Errors)
+	}
+
+	p := &regoPolicies{compiler: compiler}
+	for name := range packages {
+		p.names = append(p.names, name)
+	}
+	sort.Strings(p.names)
+	scope.Infof("Loaded rego policies %v from %s", p.names, dir)
+	return p, nil
+}
+
+// deny evaluates the policies in order and returns the name and messages of the
+// first policy that denies the request.
+func (p *regoPolicies) deny(ctx context.Context, request *admissionv1beta1.AdmissionRequest) (string, []string, error) {
+	var object interface{}
+	if err := yaml.Unmarshal(request.Object.Raw, &object); err != nil {
+		return "", nil, fmt.Errorf("cannot decode object: %v", err)
+	}
+	input := map[string]interface{}{
+		"operation": string(request.Operation),
+		"kind":      request.Kind.Kind,
+		"namespace": request.Namespace,
+		"object":    object,
+	}
+
+	for _, name := range p.names {
+		rs, err := rego.New(
+			rego.Compiler(p.compiler),
+			rego.Query("data."+name+".deny"),
+			rego.Input(input),
+		).Eval(ctx)
+		if err != nil {
+			return name, nil, err
+		}
+
+		var messages []string
+		for _, r := range rs {
+			for _, expr := range r.Expressions {
+				denials, ok := expr.Value.([]interface{})
+				if !ok {
+					continue
+				}
+				for _, d := range denials {
+					messages = append(messages, fmt.Sprint(d))
+				}
+			}
+		}
+		if len(messages) > 0 {
+			sort.Strings(messages)
+			return name, messages, nil
+		}
+	}
+	return "", nil, nil
+}
+
+// admitPolicies rejects the request if any of the configured Rego policies denies it.
+// It returns nil if the request is allowed.
+func (wh *Webhook) admitPolicies(ctx context.Context, request *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+	if wh.policies == nil {
+		return nil
+	}
+
+	policy, messages, err := wh.policies.deny(ctx, request)
+	if err != nil {
+		requestLog(ctx).Infof("cannot evaluate rego policy %s: %v", policy, err)
+		reportStageFailed(ctx, request, reasonPolicyError)
+		return toInternalErrorResponse(fmt.Errorf("cannot evaluate rego policy %s: %v", policy, err))
+	}
+	if len(messages) > 0 {
+		requestLog(ctx).Infof("rego policy %s denied %s %s/%s: %v",
+			policy, request.Kind.Kind, request.Namespace, request.Name, messages)
+		reportStageFailed(ctx, request, reasonPolicyDenied)
+		reportPolicyDenied(request, policy)
+		return wh.withViolation(request, CheckPolicy, "", toAdmissionResponse(fmt.Errorf("denied by rego policy %s: %s", policy, strings.Join(messages, "; "))))
+	}
+	return nil
+}
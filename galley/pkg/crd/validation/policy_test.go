@@ -0,0 +1,188 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	reservedNamePolicy = `
+package istio.names
+
+deny[msg] {
+	input.object.metadata.name == "mock-config0"
+	msg = "mock-config0 is reserved"
+}
+`
+	forbiddenNamespacePolicy = `
+package istio.namespaces
+
+deny[msg] {
+	input.namespace == "forbidden"
+	msg = sprintf("%s resources are not allowed in namespace forbidden", [input.kind])
+}
+`
+)
+
+func writeRegoPolicies(t *testing.T, policies map[string]string) string {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "rego")
+	if err != nil {
+		t.Fatalf("TempDir() failed: %v", err)
+	}
+	for name, policy := range policies {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(policy), 0644); err != nil {
+			t.Fatalf("WriteFile(%v) failed: %v", name, err)
+		}
+	}
+	return dir
+}
+
+func TestLoadRegoPolicies(t *testing.T) {
+	cases := []struct {
+		name      string
+		policies  map[string]string
+		wantNames []string
+		wantErr   bool
+	}{
+		{
+			name: "valid",
+			policies: map[string]string{
+				"names.rego":      reservedNamePolicy,
+				"namespaces.rego": forbiddenNamespacePolicy,
+				"README.md":       "not a policy",
+			},
+			wantNames: []string{"istio.names", "istio.namespaces"},
+		},
+		{
+			name:     "parse error",
+			policies: map[string]string{"bad.rego": "package istio.bad\n\ndeny[msg] {"},
+			wantErr:  true,
+		},
+		{
+			name:     "compile error",
+			policies: map[string]string{"bad.rego": "package istio.bad\n\ndeny[msg] { undefined_function(input) }"},
+			wantErr:  true,
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			dir := writeRegoPolicies(t, c.policies)
+			defer os.RemoveAll(dir) // nolint: errcheck
+
+			got, err := loadRegoPolicies(dir)
+			if c.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadRegoPolicies() failed: %v", err)
+			}
+			if !reflect.DeepEqual(got.names, c.wantNames) {
+				t.Fatalf("got policies %v want %v", got.names, c.wantNames)
+			}
+		})
+	}
+
+	if _, err := loadRegoPolicies("/does/not/exist"); err == nil {
+		t.Fatal("expected error for missing policy directory")
+	}
+}
+
+func TestAdmitRegoPolicies(t *testing.T) {
+	dir := writeRegoPolicies(t, map[string]string{
+		"names.rego":      reservedNamePolicy,
+		"namespaces.rego": forbiddenNamespacePolicy,
+	})
+	defer os.RemoveAll(dir) // nolint: errcheck
+
+	wh, cancel := createTestWebhook(t, dummyClient, createFakeEndpointsSource(), dummyConfig)
+	defer cancel()
+	policies, err := loadRegoPolicies(dir)
+	if err != nil {
+		t.Fatalf("loadRegoPolicies() failed: %v", err)
+	}
+	wh.policies = policies
+
+	cases := []struct {
+		name        string
+		admit       admitFunc
+		raw         []byte
+		namespace   string
+		allowed     bool
+		wantMessage string
+	}{
+		{
+			name:    "pilot allowed",
+			admit:   wh.admitPilot,
+			raw:     makePilotConfig(t, 1, true, false),
+			allowed: true,
+		},
+		{
+			name:        "pilot denied",
+			admit:       wh.admitPilot,
+			raw:         makePilotConfig(t, 0, true, false),
+			allowed:     false,
+			wantMessage: "denied by rego policy istio.names: mock-config0 is reserved",
+		},
+		{
+			name:    "mixer allowed",
+			admit:   wh.admitMixer,
+			raw:     makeMixerConfig(t, 1, false),
+			allowed: true,
+		},
+		{
+			name:        "mixer denied",
+			admit:       wh.admitMixer,
+			raw:         makeMixerConfig(t, 1, false),
+			namespace:   "forbidden",
+			allowed:     false,
+			wantMessage: "denied by rego policy istio.namespaces: mock resources are not allowed in namespace forbidden",
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			got := c.admit(context.Background(), &admissionv1beta1.AdmissionRequest{
+				Kind:      metav1.GroupVersionKind{Kind: "mock"},
+				Namespace: c.namespace,
+				Object:    runtime.RawExtension{Raw: c.raw},
+				Operation: admissionv1beta1.Create,
+			})
+			if got.Allowed != c.allowed {
+				t.Fatalf("got %v want %v", got.Allowed, c.allowed)
+			}
+			if c.wantMessage != "" && !strings.Contains(got.Result.Message, c.wantMessage) {
+				t.Fatalf("got message %q want %q", got.Result.Message, c.wantMessage)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/hashicorp/go-multierror"
+
+	networking "istio.io/api/networking/v1alpha3"
+
+	"istio.io/istio/pkg/config/protocol"
+)
+
+// portNamePrefixes are the protocol prefixes of port names, longest first so
+// that e.g. grpc-web is stripped before grpc.
+var portNamePrefixes = []protocol.Instance{
+	protocol.GRPCWeb,
+	protocol.GRPC,
+	protocol.HTTP2,
+	protocol.HTTPS,
+	protocol.HTTP,
+	protocol.TCP,
+	protocol.UDP,
+	protocol.TLS,
+	protocol.Mongo,
+	protocol.Redis,
+	protocol.MySQL,
+}
+
+// hasProtocolPrefix returns true if the port name is the protocol, or starts
+// with the protocol followed by a dash.
+func hasProtocolPrefix(name string, p protocol.Instance) bool {
+	prefix := strings.ToLower(string(p))
+	return name == prefix || strings.HasPrefix(name, prefix+"-")
+}
+
+// suggestPortName returns the name of the port following the convention, i.e.
+// the name with its protocol prefix, if any, replaced by the port protocol.
+func suggestPortName(name string, p protocol.Instance) string {
+	prefix := strings.ToLower(string(p))
+	for _, known := range portNamePrefixes {
+		if hasProtocolPrefix(name, known) {
+			name = strings.TrimPrefix(strings.TrimPrefix(name, strings.ToLower(string(known))), "-")
+			break
+		}
+	}
+	if name == "" {
+		return prefix
+	}
+	return prefix + "-" + name
+}
+
+// validatePortName returns an error suggesting a name if the port name is not
+// prefixed by its protocol, in which case the port is easily mistaken for
+// another protocol. Unnamed ports and unknown protocols are left to the schema
+// validation.
+func validatePortName(port *networking.Port) error {
+	if port == nil || port.Name == "" {
+		return nil
+	}
+	p := protocol.Parse(port.Protocol)
+	if p == protocol.Unsupported || hasProtocolPrefix(port.Name, p) {
+		return nil
+	}
+	return fmt.Errorf("port %d name %q is not prefixed by its protocol %v, e.g. %q",
+		port.Number, port.Name, p, suggestPortName(port.Name, p))
+}
+
+// validatePortNames checks the port names of Gateways and ServiceEntries.
+func validatePortNames(spec proto.Message) error {
+	var ports []*networking.Port
+	switch spec := spec.(type) {
+	case *networking.Gateway:
+		for _, server := range spec.Servers {
+			if server != nil {
+				ports = append(ports, server.Port)
+			}
+		}
+	case *networking.ServiceEntry:
+		ports = spec.Ports
+	}
+
+	var errs *multierror.Error
+	for _, port := range ports {
+		if err := validatePortName(port); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+	}
+	return errs.ErrorOrNil()
+}
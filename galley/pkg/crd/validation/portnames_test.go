@@ -0,0 +1,170 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gogo/protobuf/proto"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	networking "istio.io/api/networking/v1alpha3"
+
+	"istio.io/istio/pilot/test/mock"
+	"istio.io/istio/pkg/config/schema"
+	"istio.io/istio/pkg/config/schemas"
+)
+
+func TestValidatePortName(t *testing.T) {
+	cases := []struct {
+		name    string
+		port    *networking.Port
+		wantErr string
+	}{
+		{name: "unnamed", port: &networking.Port{Number: 80, Protocol: "HTTP"}},
+		{name: "protocol only", port: &networking.Port{Number: 80, Protocol: "HTTP", Name: "http"}},
+		{name: "prefixed", port: &networking.Port{Number: 80, Protocol: "HTTP", Name: "http-web"}},
+		{name: "grpc-web", port: &networking.Port{Number: 80, Protocol: "GRPC-Web", Name: "grpc-web-api"}},
+		{name: "unknown protocol", port: &networking.Port{Number: 80, Protocol: "FOO", Name: "web"}},
+		{
+			name:    "missing prefix",
+			port:    &networking.Port{Number: 80, Protocol: "HTTP", Name: "web"},
+			wantErr: `port 80 name "web" is not prefixed by its protocol HTTP, e.g. "http-web"`,
+		},
+		{
+			name:    "prefix without dash",
+			port:    &networking.Port{Number: 80, Protocol: "HTTP", Name: "httpweb"},
+			wantErr: `e.g. "http-httpweb"`,
+		},
+		{
+			name:    "wrong prefix",
+			port:    &networking.Port{Number: 443, Protocol: "HTTPS", Name: "http-web"},
+			wantErr: `e.g. "https-web"`,
+		},
+		{
+			name:    "wrong protocol only",
+			port:    &networking.Port{Number: 27017, Protocol: "TCP", Name: "mongo"},
+			wantErr: `e.g. "tcp"`,
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			err := validatePortName(c.port)
+			if c.wantErr == "" {
+				if err != nil {
+					t.Fatalf("got unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+				t.Fatalf("got error %v want %q", err, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidatePortNames(t *testing.T) {
+	cases := []struct {
+		name    string
+		spec    proto.Message
+		wantErr int
+	}{
+		{
+			name: "gateway",
+			spec: &networking.Gateway{Servers: []*networking.Server{
+				{Port: &networking.Port{Number: 80, Protocol: "HTTP", Name: "http"}},
+				{Port: &networking.Port{Number: 443, Protocol: "HTTPS", Name: "https"}},
+			}},
+		},
+		{
+			name: "invalid gateway",
+			spec: &networking.Gateway{Servers: []*networking.Server{
+				{Port: &networking.Port{Number: 80, Protocol: "HTTP", Name: "web"}},
+				{Port: &networking.Port{Number: 443, Protocol: "HTTPS", Name: "secure"}},
+			}},
+			wantErr: 2,
+		},
+		{
+			name: "invalid service entry",
+			spec: &networking.ServiceEntry{Ports: []*networking.Port{
+				{Number: 3306, Protocol: "MYSQL", Name: "db"},
+				{Number: 27017, Protocol: "MONGO", Name: "mongo"},
+			}},
+			wantErr: 1,
+		},
+		{
+			name: "other kind",
+			spec: &networking.VirtualService{Hosts: []string{"reviews"}},
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			err := validatePortNames(c.spec)
+			if c.wantErr == 0 {
+				if err != nil {
+					t.Fatalf("got unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || strings.Count(err.Error(), "is not prefixed") != c.wantErr {
+				t.Fatalf("got error %v want %d errors", err, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestAdmitPilotStrictPortNaming(t *testing.T) {
+	wh, cancel := createTestWebhook(t, dummyClient, createFakeEndpointsSource(), dummyConfig)
+	defer cancel()
+	descriptor := append(append(schema.Set{}, schemas.Istio...), mock.Types...)
+	if err := wh.ReloadValidators(descriptor, wh.activeValidators().mixer); err != nil {
+		t.Fatalf("ReloadValidators() failed: %v", err)
+	}
+
+	se := makeIstioKind(t, schemas.ServiceEntry, "default", "db", &networking.ServiceEntry{
+		Hosts:      []string{"db.example.com"},
+		Ports:      []*networking.Port{{Number: 3306, Protocol: "MYSQL", Name: "db"}},
+		Resolution: networking.ServiceEntry_DNS,
+	})
+	raw, err := json.Marshal(&se)
+	if err != nil {
+		t.Fatalf("Marshal(%v) failed: %v", se.Name, err)
+	}
+	request := &admissionv1beta1.AdmissionRequest{
+		Kind:      metav1.GroupVersionKind{Kind: "ServiceEntry"},
+		Namespace: "default",
+		Object:    runtime.RawExtension{Raw: raw},
+		Operation: admissionv1beta1.Create,
+	}
+
+	if resp := wh.admitPilot(context.Background(), request); !resp.Allowed {
+		t.Fatalf("got %v want the service entry allowed", resp.Result)
+	}
+
+	wh.strictPortNaming = true
+	const want = `e.g. "mysql-db"`
+	resp := wh.admitPilot(context.Background(), request)
+	if resp.Allowed || !strings.Contains(resp.Result.Message, want) {
+		t.Fatalf("got %v want a rejection containing %q", resp.Result, want)
+	}
+}
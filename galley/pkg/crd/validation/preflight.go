@@ -0,0 +1,102 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/client-go/discovery"
+	clientset "k8s.io/client-go/kubernetes"
+)
+
+var (
+	// preflightAttempts and preflightInterval bound the preflight API server
+	// check. Overridden by tests.
+	preflightAttempts = 5
+	preflightInterval = 2 * time.Second
+)
+
+// checkAPIServer confirms that the API server is reachable by requesting its
+// version, retrying up to preflightAttempts times. It returns the last error
+// if the API server never answers, or if stopped.
+func checkAPIServer(client discovery.ServerVersionInterface, stopCh <-chan struct{}) error {
+	var err error
+	for attempt := 1; attempt <= preflightAttempts; attempt++ {
+		info, versionErr := client.ServerVersion()
+		if versionErr == nil {
+			scope.Infof("Preflight check: API server version %s is reachable", info.GitVersion)
+			return nil
+		}
+		err = versionErr
+		scope.Warnf("Preflight check: API server is unreachable (attempt %d of %d): %v", attempt, preflightAttempts, err)
+		if attempt == preflightAttempts {
+			break
+		}
+		select {
+		case <-time.After(preflightInterval):
+		case <-stopCh:
+			return errors.New("preflight check stopped")
+		}
+	}
+	return fmt.Errorf("API server is unreachable after %d attempts: %v", preflightAttempts, err)
+}
+
+// requiredPermissions returns the API server permissions the webhook parameters
+// rely on beyond the baseline ones, i.e. reading Secrets for
+// CheckGatewayCredentials and CABundleSecrets, and creating Events for
+// EmitRejectionEvents.
+func requiredPermissions(p *WebhookParameters) []authorizationv1.ResourceAttributes {
+	var required []authorizationv1.ResourceAttributes
+	if p.CheckGatewayCredentials {
+		required = append(required, authorizationv1.ResourceAttributes{Verb: "get", Resource: "secrets"})
+	}
+	for _, ref := range p.CABundleSecrets {
+		namespace, name, err := parseCABundleSecret(ref)
+		if err != nil {
+			continue
+		}
+		required = append(required, authorizationv1.ResourceAttributes{Namespace: namespace, Verb: "get", Resource: "secrets", Name: name})
+	}
+	if p.EmitRejectionEvents {
+		required = append(required, authorizationv1.ResourceAttributes{Verb: "create", Resource: "events"})
+	}
+	return required
+}
+
+// checkPermissions confirms with SelfSubjectAccessReviews that the webhook is
+// allowed the requiredPermissions, so that a ClusterRole missing them is a
+// configuration error at startup rather than Forbidden lookups when serving.
+func checkPermissions(cl clientset.Interface, p *WebhookParameters) error {
+	for _, attributes := range requiredPermissions(p) {
+		attributes := attributes
+		review, err := cl.AuthorizationV1().SelfSubjectAccessReviews().Create(&authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{ResourceAttributes: &attributes},
+		})
+		if err != nil {
+			return fmt.Errorf("could not review the permission to %s %s: %v", attributes.Verb, attributes.Resource, err)
+		}
+		if !review.Status.Allowed {
+			target := attributes.Resource
+			if attributes.Name != "" {
+				target = fmt.Sprintf("%s %s/%s", attributes.Resource, attributes.Namespace, attributes.Name)
+			}
+			return fmt.Errorf("not permitted to %s %s, grant it in the Galley ClusterRole", attributes.Verb, target)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubeversion "k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// fakeServerVersion fails the first failures calls to ServerVersion.
+type fakeServerVersion struct {
+	failures int
+	calls    int
+}
+
+func (f *fakeServerVersion) ServerVersion() (*kubeversion.Info, error) {
+	f.calls++
+	if f.calls <= f.failures {
+		return nil, errors.New("connection refused")
+	}
+	return &kubeversion.Info{GitVersion: "v1.16.0"}, nil
+}
+
+func TestCheckAPIServer(t *testing.T) {
+	defer func(attempts int, interval time.Duration) {
+		preflightAttempts, preflightInterval = attempts, interval
+	}(preflightAttempts, preflightInterval)
+	preflightAttempts, preflightInterval = 3, time.Millisecond
+
+	cases := []struct {
+		name      string
+		failures  int
+		wantCalls int
+		wantErr   string
+	}{
+		{name: "reachable", wantCalls: 1},
+		{name: "reachable after retries", failures: 2, wantCalls: 3},
+		{name: "unreachable", failures: 3, wantCalls: 3, wantErr: "API server is unreachable after 3 attempts: connection refused"},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			client := &fakeServerVersion{failures: c.failures}
+			err := checkAPIServer(client, make(chan struct{}))
+			if c.wantErr == "" && err != nil {
+				t.Fatalf("got unexpected error: %v", err)
+			}
+			if c.wantErr != "" && (err == nil || !strings.Contains(err.Error(), c.wantErr)) {
+				t.Fatalf("got error %v want %q", err, c.wantErr)
+			}
+			if client.calls != c.wantCalls {
+				t.Fatalf("got %d calls want %d", client.calls, c.wantCalls)
+			}
+		})
+	}
+}
+
+func TestCheckAPIServerStopped(t *testing.T) {
+	defer func(interval time.Duration) { preflightInterval = interval }(preflightInterval)
+	preflightInterval = time.Hour
+
+	stopCh := make(chan struct{})
+	close(stopCh)
+	client := &fakeServerVersion{failures: preflightAttempts}
+	if err := checkAPIServer(client, stopCh); err == nil || !strings.Contains(err.Error(), "stopped") {
+		t.Fatalf("got error %v want it to be stopped", err)
+	}
+	if client.calls != 1 {
+		t.Fatalf("got %d calls want 1", client.calls)
+	}
+}
+
+func TestCheckPermissions(t *testing.T) {
+	cases := []struct {
+		name    string
+		params  WebhookParameters
+		denied  string
+		wantErr string
+	}{
+		{name: "nothing required"},
+		{name: "gateway credentials allowed", params: WebhookParameters{CheckGatewayCredentials: true}},
+		{
+			name:    "gateway credentials denied",
+			params:  WebhookParameters{CheckGatewayCredentials: true},
+			denied:  "secrets",
+			wantErr: "not permitted to get secrets",
+		},
+		{
+			name:    "ca bundle secret denied",
+			params:  WebhookParameters{CABundleSecrets: []string{"istio-system/remote-ca"}},
+			denied:  "secrets",
+			wantErr: "not permitted to get secrets istio-system/remote-ca",
+		},
+		{
+			name:    "rejection events denied",
+			params:  WebhookParameters{CheckGatewayCredentials: true, EmitRejectionEvents: true},
+			denied:  "events",
+			wantErr: "not permitted to create events",
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			cl := fake.NewSimpleClientset()
+			var reviews int
+			cl.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				reviews++
+				review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+				review.Status.Allowed = review.Spec.ResourceAttributes.Resource != c.denied
+				return true, review, nil
+			})
+			err := checkPermissions(cl, &c.params)
+			if c.wantErr == "" && err != nil {
+				t.Fatalf("got unexpected error: %v", err)
+			}
+			if c.wantErr != "" && (err == nil || !strings.Contains(err.Error(), c.wantErr)) {
+				t.Fatalf("got error %v want %q", err, c.wantErr)
+			}
+			if want := len(requiredPermissions(&c.params)); c.wantErr == "" && reviews != want {
+				t.Fatalf("got %d reviews want %d", reviews, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"istio.io/pkg/probe"
+)
+
+const (
+	livenessProbeName  = "validationLiveness"
+	readinessProbeName = "validationReadiness"
+)
+
+// availabilityProbe is the status registered with a probe.Controller. Tests
+// replace newAvailabilityProbe to observe the probes, since the controller
+// cannot be implemented outside of its package.
+type availabilityProbe interface {
+	RegisterProbe(c probe.Controller, name string)
+	SetAvailable(err error)
+}
+
+var newAvailabilityProbe = func() availabilityProbe { return probe.NewProbe() }
+
+// registerProbes registers the liveness and readiness probes of the webhook
+// with their controllers, if any. The liveness probe is available until the
+// validators are unhealthy, and the readiness probe while the https handler
+// is ready. Both are unavailable once stopCh is closed or the returned function
+// is called, which also stops watching the readiness of the handler.
+func (wh *Webhook) registerProbes(vc *WebhookParameters, stopCh <-chan struct{},
+	livenessProbeController, readinessProbeController probe.Controller) func() {
+
+	if vc.DisableLivenessProbe {
+		livenessProbeController = nil
+	}
+	livenessProbe := newAvailabilityProbe()
+	if livenessProbeController != nil {
+		livenessProbe.SetAvailable(nil)
+		livenessProbe.RegisterProbe(livenessProbeController, livenessProbeName)
+		if wh.validatorErrors != nil {
+			wh.validatorErrors.onUnhealthy = func(err error) {
+				livenessProbe.SetAvailable(fmt.Errorf("validator unhealthy: %v", err))
+			}
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	watching := make(chan struct{})
+	readinessProbe := newAvailabilityProbe()
+	if readinessProbeController != nil {
+		readinessProbe.SetAvailable(errors.New("init"))
+		readinessProbe.RegisterProbe(readinessProbeController, readinessProbeName)
+
+		client := &http.Client{
+			Timeout: time.Second,
+			Transport: &http.Transport{
+				TLSClientConfig: readinessTLSConfig(vc),
+			},
+		}
+		go func() {
+			defer close(watching)
+			WatchReadiness(ctx, client, vc, func(ready bool, err error) {
+				if wh.readinessHistory != nil {
+					wh.readinessHistory.record(ready, err, time.Now())
+				}
+				if ready {
+					readinessProbe.SetAvailable(nil)
+				} else {
+					readinessProbe.SetAvailable(fmt.Errorf("not ready: %v", err))
+				}
+			})
+		}()
+	} else {
+		close(watching)
+	}
+
+	go func() {
+		select {
+		case <-stopCh:
+		case <-ctx.Done():
+		}
+		cancel()
+		// a check in flight must not mark the readiness probe available again
+		<-watching
+		if livenessProbeController != nil {
+			livenessProbe.SetAvailable(errors.New("stopped"))
+		}
+		if readinessProbeController != nil {
+			readinessProbe.SetAvailable(errors.New("stopped"))
+		}
+	}()
+	return cancel
+}
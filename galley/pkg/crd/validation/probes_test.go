@@ -0,0 +1,179 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"istio.io/pkg/probe"
+)
+
+// fakeProbe records its registration and the statuses it is set to.
+type fakeProbe struct {
+	mu         sync.Mutex
+	controller probe.Controller
+	name       string
+	statuses   []string
+}
+
+func (p *fakeProbe) RegisterProbe(c probe.Controller, name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.controller, p.name = c, name
+}
+
+func (p *fakeProbe) SetAvailable(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	status := "available"
+	if err != nil {
+		status = err.Error()
+	}
+	p.statuses = append(p.statuses, status)
+}
+
+func (p *fakeProbe) registered() (probe.Controller, string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.controller, p.name
+}
+
+func (p *fakeProbe) lastStatus() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.statuses) == 0 {
+		return ""
+	}
+	return p.statuses[len(p.statuses)-1]
+}
+
+// waitForStatus waits until the last status of the probe has the prefix.
+func (p *fakeProbe) waitForStatus(t *testing.T, prefix string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.HasPrefix(p.lastStatus(), prefix) {
+		if time.Now().After(deadline) {
+			t.Fatalf("probe %q has status %q, want %q", p.name, p.lastStatus(), prefix)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// captureProbes replaces newAvailabilityProbe, returning the fake probes in
+// creation order, i.e. liveness then readiness, and a function restoring it.
+func captureProbes() (*[]*fakeProbe, func()) {
+	original := newAvailabilityProbe
+	var probes []*fakeProbe
+	newAvailabilityProbe = func() availabilityProbe {
+		p := &fakeProbe{}
+		probes = append(probes, p)
+		return p
+	}
+	return &probes, func() { newAvailabilityProbe = original }
+}
+
+func TestRegisterProbes(t *testing.T) {
+	controller := probe.NewFileController(&probe.Options{Path: "/dev/null", UpdateInterval: time.Second})
+
+	cases := []struct {
+		name            string
+		disableLiveness bool
+		liveness        probe.Controller
+		readiness       probe.Controller
+		wantLiveness    bool
+		wantReadiness   bool
+	}{
+		{name: "both", liveness: controller, readiness: controller, wantLiveness: true, wantReadiness: true},
+		{name: "liveness disabled", disableLiveness: true, liveness: controller, readiness: controller, wantReadiness: true},
+		{name: "no controllers"},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			probes, restore := captureProbes()
+			defer restore()
+
+			// nothing listens on the port, so the handler is not ready
+			vc := &WebhookParameters{Port: 1, DisableLivenessProbe: c.disableLiveness}
+			wh := &Webhook{}
+			stopCh := make(chan struct{})
+			stop := wh.registerProbes(vc, stopCh, c.liveness, c.readiness)
+			defer stop()
+
+			liveness, readiness := (*probes)[0], (*probes)[1]
+			for _, want := range []struct {
+				probe      *fakeProbe
+				registered bool
+				name       string
+			}{
+				{probe: liveness, registered: c.wantLiveness, name: livenessProbeName},
+				{probe: readiness, registered: c.wantReadiness, name: readinessProbeName},
+			} {
+				gotController, gotName := want.probe.registered()
+				if !want.registered {
+					if gotController != nil {
+						t.Fatalf("probe %q registered, want it unregistered", gotName)
+					}
+					continue
+				}
+				if gotController != controller || gotName != want.name {
+					t.Fatalf("got probe %q registered with %v, want %q registered with the controller",
+						gotName, gotController, want.name)
+				}
+			}
+
+			if c.wantLiveness {
+				liveness.waitForStatus(t, "available")
+			}
+			if c.wantReadiness {
+				readiness.waitForStatus(t, "not ready")
+			}
+
+			close(stopCh)
+			if c.wantLiveness {
+				liveness.waitForStatus(t, "stopped")
+			}
+			if c.wantReadiness {
+				readiness.waitForStatus(t, "stopped")
+			}
+			if !c.wantLiveness && !c.wantReadiness && (liveness.lastStatus() != "" || readiness.lastStatus() != "") {
+				t.Fatalf("got statuses %q and %q of unregistered probes, want none", liveness.lastStatus(), readiness.lastStatus())
+			}
+		})
+	}
+}
+
+func TestRegisterProbesStoppedOnReturn(t *testing.T) {
+	probes, restore := captureProbes()
+	defer restore()
+	controller := probe.NewFileController(&probe.Options{Path: "/dev/null", UpdateInterval: time.Second})
+
+	wh := &Webhook{validatorErrors: &validatorErrors{}}
+	stop := wh.registerProbes(&WebhookParameters{Port: 1}, make(chan struct{}), controller, controller)
+	liveness, readiness := (*probes)[0], (*probes)[1]
+
+	wh.validatorErrors.onUnhealthy(errors.New("too many errors"))
+	liveness.waitForStatus(t, "validator unhealthy: too many errors")
+
+	// the probes are unavailable once RunValidation returns, even if stopCh is open
+	stop()
+	liveness.waitForStatus(t, "stopped")
+	readiness.waitForStatus(t, "stopped")
+}
@@ -0,0 +1,102 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"fmt"
+)
+
+// StrictnessProfile is a preset of the optional validation checks.
+//
+//	check                              permissive  standard  strict
+//	unknown top-level fields rejected  no          yes       yes
+//	referenced objects protected       no          flag      yes
+//	overlapping gateway hosts rejected no          flag      yes
+//	service entry endpoints checked    no          flag      yes
+//	metadata keys checked              no          flag      yes
+//	second namespace-wide sidecar      no          flag      yes
+//	port names checked                 no          flag      yes
+//	TLS settings checked               no          flag      yes
+//	deprecated envoy filter fields     no          flag      yes
+//
+// "flag" means the check is enabled by its WebhookParameters field, i.e.
+// ProtectReferencedObjects, StrictGateway, StrictServiceEntry,
+// StrictMetadataKeys, StrictSidecar, StrictPortNaming, StrictTLSSettings and
+// StrictEnvoyFilter respectively.
+// The permissive profile disables the checks even if their fields are set.
+type StrictnessProfile string
+
+const (
+	// StrictnessPermissive disables all optional checks.
+	StrictnessPermissive StrictnessProfile = "permissive"
+
+	// StrictnessStandard enables the checks selected by their WebhookParameters fields.
+	StrictnessStandard StrictnessProfile = "standard"
+
+	// StrictnessStrict enables all optional checks.
+	StrictnessStrict StrictnessProfile = "strict"
+)
+
+// validate returns an error if the profile is unknown. An empty profile is standard.
+func (s StrictnessProfile) validate() error {
+	switch s {
+	case "", StrictnessPermissive, StrictnessStandard, StrictnessStrict:
+		return nil
+	default:
+		return fmt.Errorf("invalid strictness profile %q, want one of %q, %q or %q",
+			s, StrictnessPermissive, StrictnessStandard, StrictnessStrict)
+	}
+}
+
+// strictness are the optional checks in effect.
+type strictness struct {
+	unknownFields         bool
+	referencedObjects     bool
+	gatewayHosts          bool
+	serviceEntryEndpoints bool
+	metadataKeys          bool
+	sidecarSelectors      bool
+	portNames             bool
+	tlsSettings           bool
+	envoyFilters          bool
+}
+
+// strictness returns the optional checks enabled by the strictness profile and fields.
+func (p *WebhookParameters) strictness() strictness {
+	switch p.StrictnessProfile {
+	case StrictnessPermissive:
+		if p.ProtectReferencedObjects || p.StrictGateway || p.StrictServiceEntry || p.StrictMetadataKeys || p.StrictSidecar ||
+			p.StrictPortNaming || p.StrictTLSSettings || p.StrictEnvoyFilter {
+			scope.Warnf("Strictness profile %q disables ProtectReferencedObjects, StrictGateway, StrictServiceEntry, "+
+				"StrictMetadataKeys, StrictSidecar, StrictPortNaming, StrictTLSSettings and StrictEnvoyFilter", p.StrictnessProfile)
+		}
+		return strictness{}
+	case StrictnessStrict:
+		return strictness{unknownFields: true, referencedObjects: true, gatewayHosts: true, serviceEntryEndpoints: true,
+			metadataKeys: true, sidecarSelectors: true, portNames: true, tlsSettings: true, envoyFilters: true}
+	default:
+		return strictness{
+			unknownFields:         true,
+			referencedObjects:     p.ProtectReferencedObjects,
+			gatewayHosts:          p.StrictGateway,
+			serviceEntryEndpoints: p.StrictServiceEntry,
+			metadataKeys:          p.StrictMetadataKeys,
+			sidecarSelectors:      p.StrictSidecar,
+			portNames:             p.StrictPortNaming,
+			tlsSettings:           p.StrictTLSSettings,
+			envoyFilters:          p.StrictEnvoyFilter,
+		}
+	}
+}
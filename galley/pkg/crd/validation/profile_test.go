@@ -0,0 +1,83 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestStrictness(t *testing.T) {
+	cases := []struct {
+		name    string
+		profile StrictnessProfile
+		flags   bool
+		want    strictness
+	}{
+		{name: "default", want: strictness{unknownFields: true}},
+		{name: "default with flags", flags: true,
+			want: strictness{unknownFields: true, referencedObjects: true, gatewayHosts: true, serviceEntryEndpoints: true,
+				metadataKeys: true, sidecarSelectors: true, portNames: true, tlsSettings: true, envoyFilters: true}},
+		{name: "standard", profile: StrictnessStandard, want: strictness{unknownFields: true}},
+		{name: "permissive", profile: StrictnessPermissive, want: strictness{}},
+		{name: "permissive overrides flags", profile: StrictnessPermissive, flags: true, want: strictness{}},
+		{name: "strict", profile: StrictnessStrict,
+			want: strictness{unknownFields: true, referencedObjects: true, gatewayHosts: true, serviceEntryEndpoints: true,
+				metadataKeys: true, sidecarSelectors: true, portNames: true, tlsSettings: true, envoyFilters: true}},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			p := &WebhookParameters{
+				StrictnessProfile:        c.profile,
+				ProtectReferencedObjects: c.flags,
+				StrictGateway:            c.flags,
+				StrictServiceEntry:       c.flags,
+				StrictMetadataKeys:       c.flags,
+				StrictSidecar:            c.flags,
+				StrictPortNaming:         c.flags,
+				StrictTLSSettings:        c.flags,
+				StrictEnvoyFilter:        c.flags,
+			}
+			if got := p.strictness(); got != c.want {
+				t.Fatalf("got %+v want %+v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestAdmitPilotPermissiveUnknownFields(t *testing.T) {
+	wh, cancel := createTestWebhook(t, dummyClient, createFakeEndpointsSource(), dummyConfig)
+	defer cancel()
+
+	request := &admissionv1beta1.AdmissionRequest{
+		Kind:      metav1.GroupVersionKind{Kind: "mock"},
+		Object:    runtime.RawExtension{Raw: makePilotConfig(t, 0, true, true)},
+		Operation: admissionv1beta1.Create,
+	}
+	if resp := wh.admitPilot(context.Background(), request); resp.Allowed {
+		t.Fatal("unknown field admitted by standard profile")
+	}
+
+	wh.rejectUnknownFields = false
+	if resp := wh.admitPilot(context.Background(), request); !resp.Allowed {
+		t.Fatalf("unknown field rejected by permissive profile: %v", resp.Result)
+	}
+}
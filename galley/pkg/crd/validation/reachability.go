@@ -0,0 +1,85 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// serviceReachable checks the readiness endpoint through the validation service,
+// i.e. its ClusterIP, or its DNS name if it is headless. This catches selector
+// and network policy problems that a loopback check cannot.
+func serviceReachable(client httpClient, vc *WebhookParameters) error {
+	if vc.Clientset == nil {
+		return errors.New("service reachability check requires a k8s client")
+	}
+	namespace, name := vc.DeploymentAndServiceNamespace, vc.ServiceName
+
+	endpoints, err := vc.Clientset.CoreV1().Endpoints(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("could not get endpoints of service %s/%s: %v", namespace, name, err)
+	}
+	if !hasReadyAddress(endpoints) {
+		return fmt.Errorf("service %s/%s has no ready endpoints", namespace, name)
+	}
+
+	service, err := vc.Clientset.CoreV1().Services(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("could not get service %s/%s: %v", namespace, name, err)
+	}
+	port, err := servicePort(service, vc.Port)
+	if err != nil {
+		return err
+	}
+	host := service.Spec.ClusterIP
+	if host == "" || host == corev1.ClusterIPNone {
+		host = fmt.Sprintf("%s.%s.svc", name, namespace)
+	}
+	return httpsHandlerReady(client, net.JoinHostPort(host, strconv.Itoa(int(port))), vc.ReadinessHTTPMethod)
+}
+
+func hasReadyAddress(endpoints *corev1.Endpoints) bool {
+	for _, subset := range endpoints.Subsets {
+		if len(subset.Addresses) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// servicePort returns the port of the service that targets the webhook port. A
+// service with a single port is assumed to target it, e.g. by a named port.
+func servicePort(service *corev1.Service, targetPort uint) (int32, error) {
+	for _, p := range service.Spec.Ports {
+		target := p.TargetPort.IntValue()
+		if target == 0 && p.TargetPort.StrVal == "" {
+			// the target port defaults to the port
+			target = int(p.Port)
+		}
+		if target == int(targetPort) {
+			return p.Port, nil
+		}
+	}
+	if len(service.Spec.Ports) == 1 {
+		return service.Spec.Ports[0].Port, nil
+	}
+	return 0, fmt.Errorf("service %s/%s has no port targeting %d", service.Namespace, service.Name, targetPort)
+}
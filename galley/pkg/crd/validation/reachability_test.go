@@ -0,0 +1,105 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestServiceReachable(t *testing.T) {
+	meta := metav1.ObjectMeta{Name: "istio-galley", Namespace: "istio-system"}
+	service := func(clusterIP string, ports ...corev1.ServicePort) *corev1.Service {
+		return &corev1.Service{ObjectMeta: meta, Spec: corev1.ServiceSpec{ClusterIP: clusterIP, Ports: ports}}
+	}
+	readyEndpoints := &corev1.Endpoints{
+		ObjectMeta: meta,
+		Subsets:    []corev1.EndpointSubset{{Addresses: []corev1.EndpointAddress{{IP: "10.0.0.1"}}}},
+	}
+	webhookPort := corev1.ServicePort{Name: "https-validation", Port: 443, TargetPort: intstr.FromInt(9443)}
+	otherPort := corev1.ServicePort{Name: "https-status", Port: 8443, TargetPort: intstr.FromInt(8443)}
+
+	cases := []struct {
+		name     string
+		objects  []runtime.Object
+		wantHost string
+		wantErr  string
+	}{
+		{
+			name:     "cluster IP",
+			objects:  []runtime.Object{service("10.96.0.10", otherPort, webhookPort), readyEndpoints},
+			wantHost: "10.96.0.10:443",
+		},
+		{
+			name:     "headless",
+			objects:  []runtime.Object{service(corev1.ClusterIPNone, webhookPort), readyEndpoints},
+			wantHost: "istio-galley.istio-system.svc:443",
+		},
+		{
+			name:     "named target port",
+			objects:  []runtime.Object{service("10.96.0.10", corev1.ServicePort{Port: 443, TargetPort: intstr.FromString("https")}), readyEndpoints},
+			wantHost: "10.96.0.10:443",
+		},
+		{
+			name:    "no endpoints",
+			objects: []runtime.Object{service("10.96.0.10", webhookPort)},
+			wantErr: "could not get endpoints of service istio-system/istio-galley",
+		},
+		{
+			name:    "no ready endpoints",
+			objects: []runtime.Object{service("10.96.0.10", webhookPort), &corev1.Endpoints{ObjectMeta: meta}},
+			wantErr: "service istio-system/istio-galley has no ready endpoints",
+		},
+		{
+			name:    "no matching port",
+			objects: []runtime.Object{service("10.96.0.10", otherPort, otherPort), readyEndpoints},
+			wantErr: "service istio-system/istio-galley has no port targeting 9443",
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			vc := DefaultArgs()
+			vc.Clientset = fake.NewSimpleClientset(c.objects...)
+
+			var gotHost string
+			client := &fakeHTTPClient{handler: func(w http.ResponseWriter, r *http.Request) {
+				gotHost = r.URL.Host
+			}}
+
+			err := serviceReachable(client, vc)
+			if c.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+					t.Fatalf("got error %v want %q", err, c.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("serviceReachable() failed: %v", err)
+			}
+			if gotHost != c.wantHost {
+				t.Fatalf("got readiness request to %v want %v", gotHost, c.wantHost)
+			}
+		})
+	}
+}
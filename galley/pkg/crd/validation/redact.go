@@ -0,0 +1,179 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+)
+
+const (
+	redactedValue = "<redacted>"
+
+	// minRedactedMessageLength is the length below which redacted values are not
+	// replaced in messages, where they are likely to match unrelated text.
+	minRedactedMessageLength = 4
+)
+
+// defaultRedactedFields are the fields of Istio objects that may hold inline
+// TLS keys and certificates.
+var defaultRedactedFields = []string{
+	"spec.servers[*].tls.privateKey",
+	"spec.servers[*].tls.serverCertificate",
+	"spec.trafficPolicy.tls.privateKey",
+	"spec.trafficPolicy.portLevelSettings[*].tls.privateKey",
+	"spec.subsets[*].trafficPolicy.tls.privateKey",
+	"spec.subsets[*].trafficPolicy.portLevelSettings[*].tls.privateKey",
+}
+
+// redactSegment is a field of a redacted path. If each is set, the field is a
+// list and the rest of the path applies to each of its elements.
+type redactSegment struct {
+	field string
+	each  bool
+}
+
+// parseRedactPath parses a path of dot separated fields, where a field ending
+// in [*] selects each element of a list, e.g. spec.servers[*].tls.privateKey.
+func parseRedactPath(path string) ([]redactSegment, error) {
+	if path == "" {
+		return nil, fmt.Errorf("invalid redacted field %q: empty path", path)
+	}
+	var segments []redactSegment
+	for _, field := range strings.Split(path, ".") {
+		each := strings.HasSuffix(field, "[*]")
+		field = strings.TrimSuffix(field, "[*]")
+		if field == "" || strings.ContainsAny(field, "[]*") {
+			return nil, fmt.Errorf("invalid redacted field %q", path)
+		}
+		segments = append(segments, redactSegment{field: field, each: each})
+	}
+	return segments, nil
+}
+
+// redactor removes the values of sensitive fields from objects and messages
+// before they are logged or recorded in events.
+type redactor struct {
+	paths [][]redactSegment
+}
+
+func newRedactor(fields []string) (*redactor, error) {
+	r := &redactor{}
+	for _, field := range fields {
+		path, err := parseRedactPath(field)
+		if err != nil {
+			return nil, err
+		}
+		r.paths = append(r.paths, path)
+	}
+	return r, nil
+}
+
+// redact returns the object with the values of the redacted fields replaced,
+// and the string values that were replaced.
+func (r *redactor) redact(raw []byte) ([]byte, []string, error) {
+	var object interface{}
+	if err := yaml.Unmarshal(raw, &object); err != nil {
+		return nil, nil, fmt.Errorf("cannot decode object: %v", err)
+	}
+	var values []string
+	for _, path := range r.paths {
+		object = redactAt(object, path, &values)
+	}
+	// the redacted value is not HTML escaped, so that it is readable in logs
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(object); err != nil {
+		return nil, nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), values, nil
+}
+
+func redactAt(value interface{}, path []redactSegment, values *[]string) interface{} {
+	if len(path) == 0 {
+		if s, ok := value.(string); ok {
+			*values = append(*values, s)
+		}
+		return redactedValue
+	}
+
+	fields, ok := value.(map[string]interface{})
+	if !ok {
+		return value
+	}
+	segment := path[0]
+	child, ok := fields[segment.field]
+	if !ok {
+		return value
+	}
+	if !segment.each {
+		fields[segment.field] = redactAt(child, path[1:], values)
+		return value
+	}
+	if elements, ok := child.([]interface{}); ok {
+		for i := range elements {
+			elements[i] = redactAt(elements[i], path[1:], values)
+		}
+	}
+	return value
+}
+
+// redactMessage replaces the redacted values of the object in the message.
+func (r *redactor) redactMessage(message string, raw []byte) string {
+	if message == "" || len(raw) == 0 {
+		return message
+	}
+	_, values, err := r.redact(raw)
+	if err != nil {
+		return message
+	}
+	// replace longer values first, in case one contains another
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+	for _, value := range values {
+		if len(value) >= minRedactedMessageLength {
+			message = strings.Replace(message, value, redactedValue, -1)
+		}
+	}
+	return message
+}
+
+// redactedError returns the message of the error of the request with the
+// redacted values of its object replaced, e.g. to log it.
+func (wh *Webhook) redactedError(request *admissionv1beta1.AdmissionRequest, err error) string {
+	if wh.redactor == nil {
+		return err.Error()
+	}
+	return wh.redactor.redactMessage(err.Error(), request.Object.Raw)
+}
+
+// redactURL replaces the password of the URL, if any.
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return redactedValue
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), "redacted")
+	}
+	return u.String()
+}
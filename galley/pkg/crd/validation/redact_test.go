@@ -0,0 +1,165 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const testRedactedGateway = `{
+  "apiVersion": "networking.istio.io/v1alpha3",
+  "kind": "Gateway",
+  "metadata": {"name": "ingress", "namespace": "default"},
+  "spec": {
+    "servers": [
+      {"port": {"number": 443}, "tls": {"mode": "SIMPLE", "privateKey": "-----BEGIN KEY-----"}},
+      {"port": {"number": 80}},
+      {"port": {"number": 8443}, "tls": {"privateKey": "/etc/certs/key.pem"}}
+    ]
+  }
+}`
+
+func TestParseRedactPath(t *testing.T) {
+	cases := []struct {
+		path    string
+		want    []redactSegment
+		wantErr bool
+	}{
+		{path: "spec.servers[*].tls.privateKey", want: []redactSegment{
+			{field: "spec"}, {field: "servers", each: true}, {field: "tls"}, {field: "privateKey"},
+		}},
+		{path: "spec", want: []redactSegment{{field: "spec"}}},
+		{path: "", wantErr: true},
+		{path: "spec..tls", wantErr: true},
+		{path: "spec.servers[0].tls", wantErr: true},
+		{path: "spec.[*]", wantErr: true},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.path), func(t *testing.T) {
+			got, err := parseRedactPath(c.path)
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("parseRedactPath(%q) succeeded, want error", c.path)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseRedactPath(%q) failed: %v", c.path, err)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Fatalf("got %v want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestRedact(t *testing.T) {
+	r, err := newRedactor(defaultRedactedFields)
+	if err != nil {
+		t.Fatalf("newRedactor() failed: %v", err)
+	}
+
+	redacted, values, err := r.redact([]byte(testRedactedGateway))
+	if err != nil {
+		t.Fatalf("redact() failed: %v", err)
+	}
+	sort.Strings(values)
+	if want := []string{"-----BEGIN KEY-----", "/etc/certs/key.pem"}; !reflect.DeepEqual(values, want) {
+		t.Fatalf("got redacted values %v want %v", values, want)
+	}
+	for _, value := range values {
+		if strings.Contains(string(redacted), value) {
+			t.Fatalf("redacted object %s contains %q", redacted, value)
+		}
+	}
+	if strings.Count(string(redacted), redactedValue) != 2 || !strings.Contains(string(redacted), `"mode":"SIMPLE"`) {
+		t.Fatalf("got redacted object %s", redacted)
+	}
+
+	if _, _, err := r.redact([]byte("{")); err == nil {
+		t.Fatal("redact() of an invalid object succeeded")
+	}
+}
+
+func TestRedactMessage(t *testing.T) {
+	r, err := newRedactor([]string{"spec.servers[*].tls.privateKey", "spec.servers[*].port.number", "metadata.name"})
+	if err != nil {
+		t.Fatalf("newRedactor() failed: %v", err)
+	}
+
+	message := `invalid key "-----BEGIN KEY-----" and "/etc/certs/key.pem" on port 443 of ingress`
+	got := r.redactMessage(message, []byte(testRedactedGateway))
+	// the port is not a string and the name is too short to be replaced
+	want := `invalid key "<redacted>" and "<redacted>" on port 443 of <redacted>`
+	if got != want {
+		t.Fatalf("got %q want %q", got, want)
+	}
+	if got := r.redactMessage(message, []byte("{")); got != message {
+		t.Fatalf("got %q want the message unchanged for an invalid object", got)
+	}
+}
+
+func TestRedactedError(t *testing.T) {
+	request := &admissionv1beta1.AdmissionRequest{Object: runtime.RawExtension{Raw: []byte(testRedactedGateway)}}
+	err := fmt.Errorf(`invalid key "-----BEGIN KEY-----"`)
+
+	wh := &Webhook{}
+	if got := wh.redactedError(request, err); got != err.Error() {
+		t.Fatalf("got %q want the error unchanged without a redactor", got)
+	}
+	r, rerr := newRedactor(defaultRedactedFields)
+	if rerr != nil {
+		t.Fatalf("newRedactor() failed: %v", rerr)
+	}
+	wh.redactor = r
+	if got, want := wh.redactedError(request, err), `invalid key "<redacted>"`; got != want {
+		t.Fatalf("got %q want %q", got, want)
+	}
+}
+
+func TestRecordDecisions_Redacted(t *testing.T) {
+	r, err := newRedactor(defaultRedactedFields)
+	if err != nil {
+		t.Fatalf("newRedactor() failed: %v", err)
+	}
+	records := make(chan DecisionRecord, 1)
+	wh := &Webhook{
+		redactor:     r,
+		decisionSink: func(record DecisionRecord) { records <- record },
+		decisions:    make(chan DecisionRecord, 1),
+	}
+
+	admit := wh.recordDecisions(func(context.Context, *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+		return toAdmissionResponse(fmt.Errorf("invalid private key %q", "-----BEGIN KEY-----"))
+	})
+	admit(context.Background(), &admissionv1beta1.AdmissionRequest{
+		Object:    runtime.RawExtension{Raw: []byte(testRedactedGateway)},
+		Operation: admissionv1beta1.Create,
+	})
+
+	record := <-wh.decisions
+	if want := `invalid private key "<redacted>"`; record.Error != want {
+		t.Fatalf("got error %q want %q", record.Error, want)
+	}
+}
@@ -0,0 +1,225 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+
+	networking "istio.io/api/networking/v1alpha3"
+
+	"istio.io/istio/pilot/pkg/config/kube/crd"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/config/schemas"
+)
+
+// virtualServiceLister lists the VirtualServices in all namespaces.
+type virtualServiceLister func() ([]crd.IstioKind, error)
+
+// listVirtualServices lists the VirtualServices in all namespaces from the API server.
+func (wh *Webhook) listVirtualServices() ([]crd.IstioKind, error) {
+	if wh.clientset == nil {
+		return nil, errors.New("no kubernetes client available")
+	}
+	restClient := wh.clientset.Discovery().RESTClient()
+	if restClient == nil {
+		return nil, errors.New("no kubernetes REST client available")
+	}
+
+	s := schemas.VirtualService
+	raw, err := restClient.Get().
+		AbsPath("/apis", crd.ResourceGroup(&s), s.Version, crd.ResourceName(s.Plural)).
+		DoRaw()
+	if err != nil {
+		return nil, err
+	}
+
+	var list crd.IstioKindList
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// admitPilotDelete rejects the deletion of Gateways that are still referenced by
+// VirtualServices. The check is best-effort: a referencing VirtualService created
+// concurrently with the delete is not observed.
+func (wh *Webhook) admitPilotDelete(ctx context.Context, request *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+	// The API server only includes the deleted object from k8s 1.15 onwards.
+	if len(request.OldObject.Raw) == 0 {
+		requestLog(ctx).Infof("cannot check references of deleted %s %s/%s: no oldObject in request",
+			request.Kind.Kind, request.Namespace, request.Name)
+		return wh.acceptResponse()
+	}
+
+	var obj crd.IstioKind
+	if err := wh.decodeObject(request.OldObject.Raw, &obj); err != nil {
+		requestLog(ctx).Infof("cannot decode deleted configuration: %v", err)
+		reportValidationFailed(request, reasonYamlDecodeError)
+		return toAdmissionResponse(fmt.Errorf("cannot decode deleted configuration: %v", err))
+	}
+
+	if wh.skipValidation(ctx, request, obj.Name, obj.Annotations) {
+		return wh.acceptResponse()
+	}
+
+	s, exists := wh.lookupSchema(obj.APIVersion, obj.Kind)
+	if !exists || s.Type != schemas.Gateway.Type {
+		return wh.acceptResponse()
+	}
+
+	namespace := obj.Namespace
+	if namespace == "" {
+		namespace = request.Namespace
+	}
+
+	referrers, err := wh.gatewayReferrers(namespace, obj.Name)
+	if err != nil && wh.degradeReferenceCheck(ctx, request, RuleReferencedObjects, err) {
+		reportValidationPass(request)
+		return wh.acceptResponse()
+	} else if err != nil {
+		requestLog(ctx).Infof("cannot list references to gateway %s/%s: %v", namespace, obj.Name, err)
+		reportValidationFailed(request, reasonReferenceCheckError)
+		return toInternalErrorResponse(fmt.Errorf("cannot list references to gateway %s/%s: %v", namespace, obj.Name, err))
+	}
+	if len(referrers) > 0 {
+		requestLog(ctx).Infof("gateway %s/%s is referenced by virtual services %v", namespace, obj.Name, referrers)
+		reportValidationFailed(request, reasonReferencedObject)
+		return wh.withViolation(request, RuleReferencedObjects, "metadata.name",
+			toAdmissionResponse(fmt.Errorf("gateway %s/%s is referenced by virtual services: %s",
+				namespace, obj.Name, strings.Join(referrers, ", "))))
+	}
+
+	reportValidationPass(request)
+	return wh.acceptResponse()
+}
+
+// degradeReferenceCheck returns true if the rule, which failed to list objects
+// on the API server, is degraded to warn-only. The degradation is logged and
+// metered.
+func (wh *Webhook) degradeReferenceCheck(ctx context.Context, request *admissionv1beta1.AdmissionRequest,
+	rule string, err error) bool {
+
+	if !wh.degradeReferenceChecks {
+		return false
+	}
+	requestLog(ctx).Warnf("Check %q of %s %s/%s degraded to warn-only, the API server is unavailable: %v",
+		rule, request.Kind.Kind, request.Namespace, request.Name, err)
+	reportReferenceCheckDegraded(request, rule)
+	return true
+}
+
+// failedReferenceLookup returns the internal error response of the rule whose
+// lookup on the API server failed with err, or nil if the rule is degraded to
+// warn-only.
+func (wh *Webhook) failedReferenceLookup(ctx context.Context, request *admissionv1beta1.AdmissionRequest,
+	rule string, err error) *admissionv1beta1.AdmissionResponse {
+
+	if wh.degradeReferenceCheck(ctx, request, rule, err) {
+		return nil
+	}
+	requestLog(ctx).Infof("%v", err)
+	reportValidationFailed(request, reasonReferenceCheckError)
+	return toInternalErrorResponse(err)
+}
+
+// objectNamespace returns the namespace of the object, which defaults to that
+// of the request.
+func objectNamespace(out *model.Config, request *admissionv1beta1.AdmissionRequest) string {
+	if out.Namespace == "" {
+		return request.Namespace
+	}
+	return out.Namespace
+}
+
+// gatewayReferrers returns the sorted namespace/name of the VirtualServices
+// that reference the Gateway.
+func (wh *Webhook) gatewayReferrers(namespace, name string) ([]string, error) {
+	items, err := wh.virtualServiceLister()
+	if err != nil {
+		return nil, err
+	}
+
+	gateway := namespace + "/" + name
+	var referrers []string
+	for i := range items {
+		cfg, err := crd.ConvertObject(schemas.VirtualService, &items[i], wh.domainSuffix)
+		if err != nil {
+			scope.Warnf("skipping undecodable virtual service %s/%s: %v", items[i].Namespace, items[i].Name, err)
+			continue
+		}
+		vs, ok := cfg.Spec.(*networking.VirtualService)
+		if !ok {
+			continue
+		}
+		for _, g := range virtualServiceGateways(vs) {
+			if resolveGatewayName(g, cfg.Namespace) == gateway {
+				referrers = append(referrers, cfg.Namespace+"/"+cfg.Name)
+				break
+			}
+		}
+	}
+	sort.Strings(referrers)
+	return referrers, nil
+}
+
+// virtualServiceGateways returns the gateways referenced by the VirtualService and its routes.
+func virtualServiceGateways(vs *networking.VirtualService) []string {
+	gateways := append([]string{}, vs.Gateways...)
+	for _, route := range vs.Http {
+		for _, m := range route.Match {
+			gateways = append(gateways, m.Gateways...)
+		}
+	}
+	for _, route := range vs.Tls {
+		for _, m := range route.Match {
+			gateways = append(gateways, m.Gateways...)
+		}
+	}
+	for _, route := range vs.Tcp {
+		for _, m := range route.Match {
+			gateways = append(gateways, m.Gateways...)
+		}
+	}
+	return gateways
+}
+
+// resolveGatewayName resolves a gateway reference made from the namespace to
+// namespace/name, following the same rules as pilot.
+func resolveGatewayName(gateway, namespace string) string {
+	if gateway == constants.IstioMeshGateway {
+		return gateway
+	}
+	if !strings.Contains(gateway, "/") {
+		if !strings.Contains(gateway, ".") {
+			return namespace + "/" + gateway
+		}
+		// FQDN references are only supported for backward compatibility.
+		parts := strings.Split(gateway, ".")
+		return parts[1] + "/" + parts[0]
+	}
+	parts := strings.SplitN(gateway, "/", 2)
+	if parts[0] == "." {
+		return namespace + "/" + parts[1]
+	}
+	return gateway
+}
@@ -0,0 +1,203 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gogo/protobuf/proto"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	networking "istio.io/api/networking/v1alpha3"
+
+	"istio.io/istio/pilot/pkg/config/kube/crd"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/test/mock"
+	"istio.io/istio/pkg/config/schema"
+	"istio.io/istio/pkg/config/schemas"
+)
+
+func makeIstioKind(t *testing.T, s schema.Instance, namespace, name string, spec proto.Message) crd.IstioKind {
+	t.Helper()
+
+	obj, err := crd.ConvertConfig(s, model.Config{
+		ConfigMeta: model.ConfigMeta{
+			Type:      s.Type,
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: spec,
+	})
+	if err != nil {
+		t.Fatalf("ConvertConfig(%v) failed: %v", name, err)
+	}
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		t.Fatalf("Marshal(%v) failed: %v", name, err)
+	}
+	var out crd.IstioKind
+	if err := json.Unmarshal(raw, &out); err != nil {
+		t.Fatalf("Unmarshal(%v) failed: %v", name, err)
+	}
+	return out
+}
+
+func TestResolveGatewayName(t *testing.T) {
+	cases := []struct {
+		gateway string
+		want    string
+	}{
+		{gateway: "gw", want: "default/gw"},
+		{gateway: "./gw", want: "default/gw"},
+		{gateway: "other/gw", want: "other/gw"},
+		{gateway: "gw.other.svc.cluster.local", want: "other/gw"},
+		{gateway: "mesh", want: "mesh"},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.gateway), func(t *testing.T) {
+			if got := resolveGatewayName(c.gateway, "default"); got != c.want {
+				t.Fatalf("got %v want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestAdmitPilotDeleteReferencedGateway(t *testing.T) {
+	gateway := makeIstioKind(t, schemas.Gateway, "istio-system", "ingress", &networking.Gateway{
+		Selector: map[string]string{"istio": "ingressgateway"},
+	})
+	rawGateway, err := json.Marshal(&gateway)
+	if err != nil {
+		t.Fatalf("Marshal(%v) failed: %v", gateway.Name, err)
+	}
+	rawMock := makePilotConfig(t, 0, true, false)
+
+	referrer := makeIstioKind(t, schemas.VirtualService, "default", "reviews", &networking.VirtualService{
+		Hosts:    []string{"reviews"},
+		Gateways: []string{"istio-system/ingress"},
+	})
+	matchReferrer := makeIstioKind(t, schemas.VirtualService, "istio-system", "ratings", &networking.VirtualService{
+		Hosts: []string{"ratings"},
+		Http: []*networking.HTTPRoute{{
+			Match: []*networking.HTTPMatchRequest{{Gateways: []string{"ingress"}}},
+		}},
+	})
+	unrelated := makeIstioKind(t, schemas.VirtualService, "default", "details", &networking.VirtualService{
+		Hosts:    []string{"details"},
+		Gateways: []string{"ingress"},
+	})
+
+	wh, cancel := createTestWebhook(t, dummyClient, createFakeEndpointsSource(), dummyConfig)
+	defer cancel()
+	descriptor := append(append(schema.Set{}, schemas.Istio...), mock.Types...)
+	if err := wh.ReloadValidators(descriptor, wh.activeValidators().mixer); err != nil {
+		t.Fatalf("ReloadValidators() failed: %v", err)
+	}
+
+	cases := []struct {
+		name      string
+		protect   bool
+		oldObject []byte
+		items     []crd.IstioKind
+		listErr   error
+		degrade   bool
+		allowed   bool
+		referrers []string
+	}{
+		{
+			name:      "protection disabled",
+			oldObject: rawGateway,
+			items:     []crd.IstioKind{referrer},
+			allowed:   true,
+		},
+		{
+			name:      "unreferenced gateway",
+			protect:   true,
+			oldObject: rawGateway,
+			items:     []crd.IstioKind{unrelated},
+			allowed:   true,
+		},
+		{
+			name:      "referenced gateway",
+			protect:   true,
+			oldObject: rawGateway,
+			items:     []crd.IstioKind{referrer, unrelated, matchReferrer},
+			allowed:   false,
+			referrers: []string{"default/reviews", "istio-system/ratings"},
+		},
+		{
+			name:      "list error",
+			protect:   true,
+			oldObject: rawGateway,
+			listErr:   errors.New("forbidden"),
+			allowed:   false,
+		},
+		{
+			name:      "degraded list error",
+			protect:   true,
+			oldObject: rawGateway,
+			listErr:   errors.New("connection refused"),
+			degrade:   true,
+			allowed:   true,
+		},
+		{
+			name:    "no old object",
+			protect: true,
+			items:   []crd.IstioKind{referrer},
+			allowed: true,
+		},
+		{
+			name:      "not a gateway",
+			protect:   true,
+			oldObject: rawMock,
+			items:     []crd.IstioKind{referrer},
+			allowed:   true,
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			wh.protectReferencedObjects = c.protect
+			wh.degradeReferenceChecks = c.degrade
+			wh.virtualServiceLister = func() ([]crd.IstioKind, error) {
+				return c.items, c.listErr
+			}
+
+			got := wh.admitPilot(context.Background(), &admissionv1beta1.AdmissionRequest{
+				Kind:      metav1.GroupVersionKind{Kind: "Gateway"},
+				Namespace: "istio-system",
+				Name:      "ingress",
+				OldObject: runtime.RawExtension{Raw: c.oldObject},
+				Operation: admissionv1beta1.Delete,
+			})
+			if got.Allowed != c.allowed {
+				t.Fatalf("got %v want %v", got.Allowed, c.allowed)
+			}
+			for _, r := range c.referrers {
+				if !strings.Contains(got.Result.Message, r) {
+					t.Fatalf("response %q does not name referrer %v", got.Result.Message, r)
+				}
+			}
+		})
+	}
+}
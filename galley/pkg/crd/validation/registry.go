@@ -0,0 +1,259 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/hashicorp/go-multierror"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	kubeschema "k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	defaultSchemaRegistryRefreshInterval = 5 * time.Minute
+
+	schemaRegistryTimeout = 10 * time.Second
+)
+
+// schemaRegistryDocument is the document served by a schema registry. Each schema
+// is the OpenAPI v3 schema of the objects of a kind, as in a CRD validation.
+type schemaRegistryDocument struct {
+	Schemas []struct {
+		Group   string                               `json:"group"`
+		Version string                               `json:"version"`
+		Kind    string                               `json:"kind"`
+		Schema  apiextensionsv1beta1.JSONSchemaProps `json:"openAPIV3Schema"`
+	} `json:"schemas"`
+}
+
+// schemaRegistry caches the schemas fetched from a remote schema registry. Objects
+// are validated against the schema of their kind in addition to the built-in
+// validation. Until the schemas are fetched successfully all objects are rejected.
+type schemaRegistry struct {
+	url      string
+	name     string // the url without its credentials, for messages
+	interval time.Duration
+	client   *http.Client
+
+	// schemas holds the map[kubeschema.GroupVersionKind]*apiextensionsv1beta1.JSONSchemaProps
+	// of the last successful fetch.
+	schemas atomic.Value
+}
+
+func newSchemaRegistry(url string, interval time.Duration) *schemaRegistry {
+	return &schemaRegistry{
+		url:      url,
+		name:     redactURL(url),
+		interval: interval,
+		client:   &http.Client{Timeout: schemaRegistryTimeout},
+	}
+}
+
+// refresh fetches the schemas from the registry. The cached schemas are kept on error.
+func (r *schemaRegistry) refresh() error {
+	resp, err := r.client.Get(r.url)
+	if err != nil {
+		return fmt.Errorf("cannot fetch schemas from %s: %v", r.name, err)
+	}
+	defer resp.Body.Close() // nolint: errcheck
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("cannot fetch schemas from %s: status %v", r.name, resp.StatusCode)
+	}
+
+	var doc schemaRegistryDocument
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return fmt.Errorf("cannot decode schemas from %s: %v", r.name, err)
+	}
+	schemas := make(map[kubeschema.GroupVersionKind]*apiextensionsv1beta1.JSONSchemaProps, len(doc.Schemas))
+	for i := range doc.Schemas {
+		s := &doc.Schemas[i]
+		schemas[kubeschema.GroupVersionKind{Group: s.Group, Version: s.Version, Kind: s.Kind}] = &s.Schema
+	}
+	r.schemas.Store(schemas)
+	scope.Infof("Loaded %d schemas from schema registry %s", len(schemas), r.name)
+	return nil
+}
+
+// run refreshes the schemas at the refresh interval until stopped.
+func (r *schemaRegistry) run(stopCh <-chan struct{}) {
+	if r.interval == 0 {
+		return
+	}
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := r.refresh(); err != nil {
+				scope.Warnf("Schema registry refresh failed, keeping cached schemas: %v", err)
+			}
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// lookup returns the schema of the kind, if any. It fails if the schemas have
+// never been fetched.
+func (r *schemaRegistry) lookup(gvk kubeschema.GroupVersionKind) (*apiextensionsv1beta1.JSONSchemaProps, error) {
+	schemas, ok := r.schemas.Load().(map[kubeschema.GroupVersionKind]*apiextensionsv1beta1.JSONSchemaProps)
+	if !ok {
+		return nil, fmt.Errorf("schema registry %s is unavailable", r.name)
+	}
+	return schemas[gvk], nil
+}
+
+// admitRegistrySchema rejects the request if the object does not match the schema
+// of its kind in the schema registry. It returns nil if the request is allowed.
+func (wh *Webhook) admitRegistrySchema(ctx context.Context, request *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+	if wh.schemaRegistry == nil {
+		return nil
+	}
+
+	gvk := kubeschema.GroupVersionKind{Group: request.Kind.Group, Version: request.Kind.Version, Kind: request.Kind.Kind}
+	s, err := wh.schemaRegistry.lookup(gvk)
+	if err != nil {
+		requestLog(ctx).Infof("cannot validate %v: %v", gvk, err)
+		reportStageFailed(ctx, request, reasonSchemaRegistryUnavailable)
+		return toInternalErrorResponse(err)
+	}
+	if s == nil {
+		return nil
+	}
+
+	var object interface{}
+	if err := yaml.Unmarshal(request.Object.Raw, &object); err != nil {
+		reportStageFailed(ctx, request, reasonYamlDecodeError)
+		return toAdmissionResponse(fmt.Errorf("cannot decode configuration: %v", err))
+	}
+	if err := validateSchema("", s, object); err != nil {
+		requestLog(ctx).Infof("configuration does not match registry schema: %v", wh.redactedError(request, err))
+		reportStageFailed(ctx, request, reasonInvalidConfig)
+		return wh.withViolation(request, CheckSchema, "", toAdmissionResponse(fmt.Errorf("configuration does not match registry schema: %v", err)))
+	}
+	return nil
+}
+
+// validateSchema validates the decoded JSON value against the schema. It supports
+// the type, enum, pattern, required, properties, additionalProperties and items
+// keywords; other keywords are ignored.
+func validateSchema(path string, s *apiextensionsv1beta1.JSONSchemaProps, value interface{}) error {
+	var errs *multierror.Error
+	field := path
+	if field == "" {
+		field = "<root>"
+	}
+
+	if s.Type != "" && !schemaTypeMatches(s.Type, value) {
+		return multierror.Append(errs, fmt.Errorf("%s: must be of type %s", field, s.Type))
+	}
+
+	if len(s.Enum) > 0 {
+		found := false
+		for _, e := range s.Enum {
+			var allowed interface{}
+			if err := json.Unmarshal(e.Raw, &allowed); err == nil && reflect.DeepEqual(allowed, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			errs = multierror.Append(errs, fmt.Errorf("%s: value %v is not allowed", field, value))
+		}
+	}
+
+	switch v := value.(type) {
+	case string:
+		if s.Pattern != "" {
+			re, err := regexp.Compile(s.Pattern)
+			if err != nil {
+				errs = multierror.Append(errs, fmt.Errorf("%s: invalid schema pattern %q: %v", field, s.Pattern, err))
+			} else if !re.MatchString(v) {
+				errs = multierror.Append(errs, fmt.Errorf("%s: %q does not match pattern %q", field, v, s.Pattern))
+			}
+		}
+
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				errs = multierror.Append(errs, fmt.Errorf("%s: missing required field %q", field, name))
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			child := strings.TrimPrefix(path+"."+key, ".")
+			if prop, ok := s.Properties[key]; ok {
+				if err := validateSchema(child, &prop, v[key]); err != nil {
+					errs = multierror.Append(errs, err)
+				}
+				continue
+			}
+			if additional := s.AdditionalProperties; additional != nil {
+				if additional.Schema != nil {
+					if err := validateSchema(child, additional.Schema, v[key]); err != nil {
+						errs = multierror.Append(errs, err)
+					}
+				} else if !additional.Allows {
+					errs = multierror.Append(errs, fmt.Errorf("%s: unknown field", child))
+				}
+			}
+		}
+
+	case []interface{}:
+		if s.Items != nil && s.Items.Schema != nil {
+			for i, item := range v {
+				if err := validateSchema(fmt.Sprintf("%s[%d]", path, i), s.Items.Schema, item); err != nil {
+					errs = multierror.Append(errs, err)
+				}
+			}
+		}
+	}
+
+	return errs.ErrorOrNil()
+}
+
+// schemaTypeMatches returns true if the decoded JSON value is of the OpenAPI type.
+func schemaTypeMatches(t string, value interface{}) bool {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return t == "object"
+	case []interface{}:
+		return t == "array"
+	case string:
+		return t == "string"
+	case bool:
+		return t == "boolean"
+	case float64:
+		return t == "number" || (t == "integer" && v == float64(int64(v)))
+	default:
+		return false
+	}
+}
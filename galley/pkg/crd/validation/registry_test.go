@@ -0,0 +1,195 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const testRegistrySchemas = `{
+  "schemas": [{
+    "group": "networking.istio.io",
+    "version": "v1alpha3",
+    "kind": "Gateway",
+    "openAPIV3Schema": {
+      "type": "object",
+      "required": ["spec"],
+      "properties": {
+        "metadata": {"type": "object"},
+        "spec": {
+          "type": "object",
+          "additionalProperties": false,
+          "properties": {
+            "selector": {"type": "object", "additionalProperties": {"type": "string"}},
+            "servers": {
+              "type": "array",
+              "items": {
+                "type": "object",
+                "properties": {
+                  "hosts": {"type": "array", "items": {"type": "string", "pattern": "^[a-z*./-]+$"}},
+                  "port": {
+                    "type": "object",
+                    "properties": {
+                      "number": {"type": "integer"},
+                      "protocol": {"type": "string", "enum": ["HTTP", "HTTPS"]}
+                    }
+                  }
+                }
+              }
+            }
+          }
+        }
+      }
+    }
+  }]
+}`
+
+func TestValidateSchema(t *testing.T) {
+	var doc schemaRegistryDocument
+	if err := json.Unmarshal([]byte(testRegistrySchemas), &doc); err != nil {
+		t.Fatalf("Unmarshal() failed: %v", err)
+	}
+	s := &doc.Schemas[0].Schema
+
+	cases := []struct {
+		name    string
+		object  string
+		wantErr []string
+	}{
+		{
+			name:   "valid",
+			object: `{"spec": {"selector": {"istio": "ingress"}, "servers": [{"hosts": ["*.example.com"], "port": {"number": 80, "protocol": "HTTP"}}]}}`,
+		},
+		{
+			name:    "missing required",
+			object:  `{"metadata": {}}`,
+			wantErr: []string{`<root>: missing required field "spec"`},
+		},
+		{
+			name:    "wrong type",
+			object:  `{"spec": {"servers": {}}}`,
+			wantErr: []string{"spec.servers: must be of type array"},
+		},
+		{
+			name:    "not an integer",
+			object:  `{"spec": {"servers": [{"port": {"number": 80.5}}]}}`,
+			wantErr: []string{"spec.servers[0].port.number: must be of type integer"},
+		},
+		{
+			name:    "enum and pattern",
+			object:  `{"spec": {"servers": [{"hosts": ["Bad_Host"], "port": {"protocol": "TCP"}}]}}`,
+			wantErr: []string{`spec.servers[0].hosts[0]: "Bad_Host" does not match pattern`, "spec.servers[0].port.protocol: value TCP is not allowed"},
+		},
+		{
+			name:    "additional properties",
+			object:  `{"spec": {"bogus": 1, "selector": {"istio": 1}}}`,
+			wantErr: []string{"spec.bogus: unknown field", "spec.selector.istio: must be of type string"},
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			var object interface{}
+			if err := json.Unmarshal([]byte(c.object), &object); err != nil {
+				t.Fatalf("Unmarshal() failed: %v", err)
+			}
+			err := validateSchema("", s, object)
+			if len(c.wantErr) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("got no error")
+			}
+			for _, want := range c.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("got %v want error containing %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestAdmitRegistrySchema(t *testing.T) {
+	var available int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&available) == 0 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(testRegistrySchemas)) // nolint: errcheck
+	}))
+	defer server.Close()
+
+	registry := newSchemaRegistry(server.URL, 0)
+	wh := &Webhook{schemaRegistry: registry}
+
+	request := func(kind, object string) *admissionv1beta1.AdmissionRequest {
+		return &admissionv1beta1.AdmissionRequest{
+			Kind:      metav1.GroupVersionKind{Group: "networking.istio.io", Version: "v1alpha3", Kind: kind},
+			Object:    runtime.RawExtension{Raw: []byte(object)},
+			Operation: admissionv1beta1.Create,
+		}
+	}
+	valid := request("Gateway", `{"spec": {}}`)
+	invalid := request("Gateway", `{"metadata": {}}`)
+	other := request("VirtualService", `{"metadata": {}}`)
+
+	// fail closed until the schemas are fetched
+	if err := registry.refresh(); err == nil {
+		t.Fatal("refresh() succeeded while the registry is unavailable")
+	}
+	if resp := wh.admitRegistrySchema(context.Background(), other); resp == nil || resp.Allowed {
+		t.Fatalf("got %v want rejection while the registry is unavailable", resp)
+	}
+
+	atomic.StoreInt32(&available, 1)
+	if err := registry.refresh(); err != nil {
+		t.Fatalf("refresh() failed: %v", err)
+	}
+	check := func() {
+		t.Helper()
+		if resp := wh.admitRegistrySchema(context.Background(), valid); resp != nil {
+			t.Fatalf("valid object rejected: %v", resp.Result)
+		}
+		if resp := wh.admitRegistrySchema(context.Background(), other); resp != nil {
+			t.Fatalf("object without a registry schema rejected: %v", resp.Result)
+		}
+		if resp := wh.admitRegistrySchema(context.Background(), invalid); resp == nil || resp.Allowed {
+			t.Fatalf("got %v want rejection of invalid object", resp)
+		}
+	}
+	check()
+
+	// the cached schemas are used if a refresh fails
+	atomic.StoreInt32(&available, 0)
+	if err := registry.refresh(); err == nil {
+		t.Fatal("refresh() succeeded while the registry is unavailable")
+	}
+	check()
+}
@@ -0,0 +1,161 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/hashicorp/go-multierror"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// validationReport collects all validation errors of an object, so that they
+// can be returned together rather than failing on the first.
+type validationReport struct {
+	causes []v1.StatusCause
+
+	// maxCauses bounds the causes in the response, if it is positive.
+	maxCauses int
+
+	// codes are the violation codes by check, see ViolationCodes.
+	codes map[string]string
+
+	// violations are the failed checks with a code, of which each field is
+	// recorded once.
+	violations []violation
+}
+
+// violation is a check with a violation code that failed on a field.
+type violation struct {
+	check string
+	cause v1.StatusCause
+}
+
+// addViolation records that the check failed on the field, if it has a violation code.
+func (r *validationReport) addViolation(check, field string) {
+	code := r.codes[check]
+	if code == "" {
+		return
+	}
+	v := violation{check: check, cause: violationCause(code, field)}
+	for _, other := range r.violations {
+		if other == v {
+			return
+		}
+	}
+	r.violations = append(r.violations, v)
+}
+
+// add records the error of the check as an invalid value of the field. Each
+// error of a multierror is recorded as a separate cause.
+func (r *validationReport) add(check, field string, err error) {
+	r.addViolation(check, field)
+	errs := []error{err}
+	if merr, ok := err.(*multierror.Error); ok {
+		errs = merr.Errors
+	}
+	for _, err := range errs {
+		r.causes = append(r.causes, v1.StatusCause{
+			Type:    v1.CauseTypeFieldValueInvalid,
+			Message: err.Error(),
+			Field:   field,
+		})
+	}
+}
+
+// addUnknownFields records each unknown top-level field of the raw object.
+func (r *validationReport) addUnknownFields(raw []byte) error {
+	unknown, err := unknownFields(raw)
+	if err != nil {
+		return err
+	}
+	for _, key := range unknown {
+		r.addViolation(RuleUnknownFields, key)
+		r.causes = append(r.causes, v1.StatusCause{
+			Type:    v1.CauseTypeFieldValueNotSupported,
+			Message: fmt.Sprintf("unknown field %q", key),
+			Field:   key,
+		})
+	}
+	return nil
+}
+
+func (r *validationReport) empty() bool {
+	return len(r.causes) == 0
+}
+
+// response returns the admission response rejecting the object with the recorded
+// causes, of which at most maxCauses are reported, followed by the violation
+// codes of all failed checks.
+func (r *validationReport) response(request *admissionv1beta1.AdmissionRequest, name string) *admissionv1beta1.AdmissionResponse {
+	causes := r.causes
+	if r.maxCauses > 0 && len(causes) > r.maxCauses {
+		causes = causes[:r.maxCauses]
+	}
+	messages := make([]string, 0, len(causes)+1)
+	for _, cause := range causes {
+		messages = append(messages, fmt.Sprintf("%s: %s", cause.Field, cause.Message))
+	}
+	if omitted := len(r.causes) - len(causes); omitted > 0 {
+		messages = append(messages, fmt.Sprintf("+%d more", omitted))
+	}
+	causes = causes[:len(causes):len(causes)]
+	reported := make(map[string]bool, len(r.violations))
+	for _, v := range r.violations {
+		causes = append(causes, v.cause)
+		if !reported[v.check] {
+			reported[v.check] = true
+			reportViolation(request, v.check, v.cause.Message)
+		}
+	}
+	return &admissionv1beta1.AdmissionResponse{
+		Result: &v1.Status{
+			Status:  v1.StatusFailure,
+			Message: fmt.Sprintf("configuration is invalid: %s", strings.Join(messages, "; ")),
+			Reason:  v1.StatusReasonInvalid,
+			Code:    http.StatusUnprocessableEntity,
+			Details: &v1.StatusDetails{
+				Name:   name,
+				Group:  request.Kind.Group,
+				Kind:   request.Kind.Kind,
+				Causes: causes,
+			},
+		},
+	}
+}
+
+// unknownFields returns the sorted top-level field names of the raw object that
+// are not in validFields.
+func unknownFields(raw []byte) ([]string, error) {
+	trial := make(map[string]json.RawMessage)
+	if err := yaml.Unmarshal(raw, &trial); err != nil {
+		return nil, fmt.Errorf("cannot decode configuration fields: %v", err)
+	}
+
+	var unknown []string
+	for key := range trial {
+		if _, ok := validFields[key]; !ok {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown, nil
+}
@@ -0,0 +1,141 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/go-multierror"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestValidationReport(t *testing.T) {
+	var report validationReport
+	if !report.empty() {
+		t.Fatal("new report is not empty")
+	}
+
+	report.add(CheckSchema, "spec", multierror.Append(errors.New("first"), errors.New("second")))
+	if err := report.addUnknownFields([]byte(`{"kind": "mock", "bogus": 1, "another": 2}`)); err != nil {
+		t.Fatalf("addUnknownFields() failed: %v", err)
+	}
+
+	want := []metav1.StatusCause{
+		{Type: metav1.CauseTypeFieldValueInvalid, Message: "first", Field: "spec"},
+		{Type: metav1.CauseTypeFieldValueInvalid, Message: "second", Field: "spec"},
+		{Type: metav1.CauseTypeFieldValueNotSupported, Message: `unknown field "another"`, Field: "another"},
+		{Type: metav1.CauseTypeFieldValueNotSupported, Message: `unknown field "bogus"`, Field: "bogus"},
+	}
+	if !reflect.DeepEqual(report.causes, want) {
+		t.Fatalf("got causes %v want %v", report.causes, want)
+	}
+
+	if err := report.addUnknownFields([]byte("{")); err == nil {
+		t.Fatal("addUnknownFields() succeeded on malformed object")
+	}
+}
+
+func TestValidationReportMaxCauses(t *testing.T) {
+	request := &admissionv1beta1.AdmissionRequest{Kind: metav1.GroupVersionKind{Kind: "mock"}}
+
+	cases := []struct {
+		name        string
+		maxCauses   int
+		wantCauses  int
+		wantMessage string
+	}{
+		{name: "unlimited", wantCauses: 3, wantMessage: "configuration is invalid: spec: 0; spec: 1; spec: 2"},
+		{name: "under limit", maxCauses: 3, wantCauses: 3, wantMessage: "configuration is invalid: spec: 0; spec: 1; spec: 2"},
+		{name: "over limit", maxCauses: 2, wantCauses: 2, wantMessage: "configuration is invalid: spec: 0; spec: 1; +1 more"},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			report := validationReport{maxCauses: c.maxCauses}
+			for j := 0; j < 3; j++ {
+				report.add(CheckSchema, "spec", fmt.Errorf("%d", j))
+			}
+
+			resp := report.response(request, "name")
+			if resp.Allowed {
+				t.Fatal("report with causes admitted")
+			}
+			if got := len(resp.Result.Details.Causes); got != c.wantCauses {
+				t.Fatalf("got %d causes want %d", got, c.wantCauses)
+			}
+			if resp.Result.Message != c.wantMessage {
+				t.Fatalf("got message %q want %q", resp.Result.Message, c.wantMessage)
+			}
+		})
+	}
+}
+
+func TestAdmitPilotReportAllErrors(t *testing.T) {
+	wh, cancel := createTestWebhook(t, dummyClient, createFakeEndpointsSource(), dummyConfig)
+	defer cancel()
+	wh.reportAllErrors = true
+
+	cases := []struct {
+		name   string
+		raw    []byte
+		fields []string
+	}{
+		{name: "valid", raw: makePilotConfig(t, 0, true, false)},
+		{name: "unknown field", raw: makePilotConfig(t, 0, true, true), fields: []string{"unexpected_key"}},
+		{name: "invalid spec and unknown field", raw: makePilotConfig(t, 0, false, true),
+			fields: []string{"spec", "unexpected_key"}},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			resp := wh.admitPilot(context.Background(), &admissionv1beta1.AdmissionRequest{
+				Kind:      metav1.GroupVersionKind{Kind: "mock"},
+				Object:    runtime.RawExtension{Raw: c.raw},
+				Operation: admissionv1beta1.Create,
+			})
+			if len(c.fields) == 0 {
+				if !resp.Allowed {
+					t.Fatalf("got rejected, want allowed: %v", resp.Result)
+				}
+				return
+			}
+			if resp.Allowed {
+				t.Fatal("got allowed, want rejected")
+			}
+			if resp.Result.Details == nil {
+				t.Fatalf("missing status details: %v", resp.Result)
+			}
+
+			fields := make(map[string]bool)
+			for _, cause := range resp.Result.Details.Causes {
+				fields[cause.Field] = true
+			}
+			for _, field := range c.fields {
+				if !fields[field] {
+					t.Errorf("no cause for field %q in %v", field, resp.Result.Details.Causes)
+				}
+			}
+			if got := resp.Result.Details.Name; got != "mock-config0" {
+				t.Errorf("got name %q want %q", got, "mock-config0")
+			}
+		})
+	}
+}
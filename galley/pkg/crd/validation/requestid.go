@@ -0,0 +1,94 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+const (
+	requestIDHeader = "X-Request-Id"
+
+	// maxRequestIDLength bounds the length of request IDs accepted from clients.
+	maxRequestIDLength = 128
+)
+
+type requestIDKey struct{}
+
+// RequestIDFromContext returns the ID of the admission request served with the
+// context, or the empty string if there is none.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+func withRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// requestID returns the X-Request-Id of the request, or a new ID if it is absent
+// or is not a short printable string that is safe to log.
+func requestID(r *http.Request) string {
+	id := r.Header.Get(requestIDHeader)
+	if id == "" || len(id) > maxRequestIDLength {
+		return uuid.New().String()
+	}
+	for _, c := range id {
+		if c < '!' || c > '~' {
+			return uuid.New().String()
+		}
+	}
+	return id
+}
+
+// requestLogger logs to the validation scope with the ID of the admission request.
+// Debug logs are dropped unless the request is sampled.
+type requestLogger struct {
+	id      string
+	sampled bool
+}
+
+func requestLog(ctx context.Context) requestLogger {
+	return requestLogger{id: RequestIDFromContext(ctx), sampled: debugSampled(ctx)}
+}
+
+func (l requestLogger) message(format string, args []interface{}) string {
+	if l.id == "" {
+		return fmt.Sprintf(format, args...)
+	}
+	return fmt.Sprintf("[%s] %s", l.id, fmt.Sprintf(format, args...))
+}
+
+func (l requestLogger) Debugf(format string, args ...interface{}) {
+	if l.sampled && scope.DebugEnabled() {
+		scope.Debug(l.message(format, args))
+	}
+}
+
+func (l requestLogger) Infof(format string, args ...interface{}) {
+	scope.Info(l.message(format, args))
+}
+
+func (l requestLogger) Warnf(format string, args ...interface{}) {
+	scope.Warn(l.message(format, args))
+}
+
+func (l requestLogger) Errorf(format string, args ...interface{}) {
+	scope.Error(l.message(format, args))
+}
@@ -0,0 +1,145 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRequestID(t *testing.T) {
+	cases := []struct {
+		name     string
+		header   string
+		wantSame bool
+	}{
+		{
+			name:     "from header",
+			header:   "abc-123",
+			wantSame: true,
+		},
+		{
+			name: "absent",
+		},
+		{
+			name:   "too long",
+			header: strings.Repeat("a", maxRequestIDLength+1),
+		},
+		{
+			name:   "not printable",
+			header: "abc\x1b[31m",
+		},
+		{
+			name:   "space",
+			header: "abc 123",
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			req := httptest.NewRequest("POST", "http://validator", nil)
+			if c.header != "" {
+				req.Header.Set(requestIDHeader, c.header)
+			}
+			got := requestID(req)
+			if c.wantSame {
+				if got != c.header {
+					t.Fatalf("got request ID %q want %q", got, c.header)
+				}
+				return
+			}
+			if got == "" || got == c.header {
+				t.Fatalf("got request ID %q want a generated ID", got)
+			}
+		})
+	}
+}
+
+func TestServe_RequestID(t *testing.T) {
+	review, err := json.Marshal(admissionv1beta1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1beta1", Kind: "AdmissionReview"},
+		Request:  &admissionv1beta1.AdmissionRequest{UID: "uid", Operation: admissionv1beta1.Create},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create AdmissionReview: %v", err)
+	}
+
+	for i, header := range []string{"abc-123", ""} {
+		t.Run(fmt.Sprintf("[%d] %q", i, header), func(t *testing.T) {
+			req := httptest.NewRequest("POST", "http://validator", bytes.NewReader(review))
+			req.Header.Set("Content-Type", "application/json")
+			if header != "" {
+				req.Header.Set(requestIDHeader, header)
+			}
+			w := httptest.NewRecorder()
+
+			var gotContextID string
+			serve(w, req, func(ctx context.Context, request *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+				gotContextID = RequestIDFromContext(ctx)
+				return &admissionv1beta1.AdmissionResponse{Allowed: true}
+			}, 0, 0)
+
+			res := w.Result()
+			if res.StatusCode != http.StatusOK {
+				t.Fatalf("wrong status code: got %v want %v", res.StatusCode, http.StatusOK)
+			}
+			gotID := res.Header.Get(requestIDHeader)
+			if header != "" && gotID != header {
+				t.Fatalf("got echoed request ID %q want %q", gotID, header)
+			}
+			if gotID == "" {
+				t.Fatal("missing echoed request ID")
+			}
+			if gotContextID != gotID {
+				t.Fatalf("got request ID %q in context want %q", gotContextID, gotID)
+			}
+		})
+	}
+}
+
+func TestServe_RequestIDOnError(t *testing.T) {
+	req := httptest.NewRequest("POST", "http://validator", nil)
+	req.Header.Set(requestIDHeader, "abc-123")
+	w := httptest.NewRecorder()
+
+	serve(w, req, nil, 0, 0)
+
+	res := w.Result()
+	if res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("wrong status code: got %v want %v", res.StatusCode, http.StatusBadRequest)
+	}
+	if got := res.Header.Get(requestIDHeader); got != "abc-123" {
+		t.Fatalf("got echoed request ID %q want %q", got, "abc-123")
+	}
+}
+
+func TestRequestLogMessage(t *testing.T) {
+	if got := requestLog(context.Background()).message("rejected %s", []interface{}{"a%b"}); got != "rejected a%b" {
+		t.Fatalf("got %q want %q", got, "rejected a%b")
+	}
+	ctx := withRequestID(context.Background(), "abc-123")
+	if got := requestLog(ctx).message("rejected %s", []interface{}{"a"}); got != "[abc-123] rejected a" {
+		t.Fatalf("got %q want %q", got, "[abc-123] rejected a")
+	}
+}
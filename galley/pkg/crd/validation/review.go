@@ -0,0 +1,116 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"encoding/json"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var (
+	admissionV1Version      = admissionv1.SchemeGroupVersion.String()
+	admissionV1beta1Version = admissionv1beta1.SchemeGroupVersion.String()
+)
+
+// reviewVersion returns the apiVersion of the encoded AdmissionReview. Reviews
+// without a recognized apiVersion are treated as admission.k8s.io/v1beta1.
+func reviewVersion(body []byte) string {
+	var typeMeta v1.TypeMeta
+	if err := json.Unmarshal(body, &typeMeta); err == nil && typeMeta.APIVersion == admissionV1Version {
+		return admissionV1Version
+	}
+	return admissionV1beta1Version
+}
+
+// decodeReview decodes an AdmissionReview of the specified apiVersion and returns
+// the embedded request in the v1beta1 form used by the admit functions.
+func decodeReview(body []byte, apiVersion string) (*admissionv1beta1.AdmissionRequest, error) {
+	if apiVersion == admissionV1Version {
+		ar := admissionv1.AdmissionReview{}
+		if _, _, err := deserializer.Decode(body, nil, &ar); err != nil {
+			return nil, err
+		}
+		return v1ToV1beta1AdmissionRequest(ar.Request), nil
+	}
+
+	ar := admissionv1beta1.AdmissionReview{}
+	if _, _, err := deserializer.Decode(body, nil, &ar); err != nil {
+		return nil, err
+	}
+	return ar.Request, nil
+}
+
+// encodeReview wraps the response in an AdmissionReview of the specified apiVersion.
+func encodeReview(response *admissionv1beta1.AdmissionResponse, apiVersion string) ([]byte, error) {
+	typeMeta := v1.TypeMeta{
+		APIVersion: apiVersion,
+		Kind:       "AdmissionReview",
+	}
+
+	if apiVersion == admissionV1Version {
+		return json.Marshal(admissionv1.AdmissionReview{
+			TypeMeta: typeMeta,
+			Response: v1beta1ToV1AdmissionResponse(response),
+		})
+	}
+	return json.Marshal(admissionv1beta1.AdmissionReview{
+		TypeMeta: typeMeta,
+		Response: response,
+	})
+}
+
+func v1ToV1beta1AdmissionRequest(in *admissionv1.AdmissionRequest) *admissionv1beta1.AdmissionRequest {
+	if in == nil {
+		return nil
+	}
+	return &admissionv1beta1.AdmissionRequest{
+		UID:                in.UID,
+		Kind:               in.Kind,
+		Resource:           in.Resource,
+		SubResource:        in.SubResource,
+		RequestKind:        in.RequestKind,
+		RequestResource:    in.RequestResource,
+		RequestSubResource: in.RequestSubResource,
+		Name:               in.Name,
+		Namespace:          in.Namespace,
+		Operation:          admissionv1beta1.Operation(in.Operation),
+		UserInfo:           in.UserInfo,
+		Object:             in.Object,
+		OldObject:          in.OldObject,
+		DryRun:             in.DryRun,
+		Options:            in.Options,
+	}
+}
+
+func v1beta1ToV1AdmissionResponse(in *admissionv1beta1.AdmissionResponse) *admissionv1.AdmissionResponse {
+	if in == nil {
+		return nil
+	}
+	out := &admissionv1.AdmissionResponse{
+		UID:              in.UID,
+		Allowed:          in.Allowed,
+		Result:           in.Result,
+		Patch:            in.Patch,
+		AuditAnnotations: in.AuditAnnotations,
+	}
+	if in.PatchType != nil {
+		patchType := admissionv1.PatchType(*in.PatchType)
+		out.PatchType = &patchType
+	}
+	return out
+}
@@ -0,0 +1,230 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+	v1 "k8s.io/api/core/v1"
+)
+
+// Identifiers of the optional validation rules, for WebhookParameters.DisabledRules.
+const (
+	// RuleUnknownFields rejects unknown top-level fields.
+	RuleUnknownFields = "unknown-fields"
+
+	// RuleReferencedObjects protects referenced objects from deletion, see ProtectReferencedObjects.
+	RuleReferencedObjects = "referenced-objects"
+
+	// RuleGatewayHosts rejects overlapping gateway hosts, see StrictGateway.
+	RuleGatewayHosts = "gateway-hosts"
+
+	// RuleServiceEntryEndpoints checks service entry endpoints, see StrictServiceEntry.
+	RuleServiceEntryEndpoints = "service-entry-endpoints"
+
+	// RuleMetadataKeys checks label and annotation keys, see StrictMetadataKeys.
+	RuleMetadataKeys = "metadata-keys"
+
+	// RuleSidecarSelector rejects a second namespace-wide sidecar, see StrictSidecar.
+	RuleSidecarSelector = "sidecar-selector"
+
+	// RulePortNaming checks the protocol prefix of port names, see StrictPortNaming.
+	RulePortNaming = "port-naming"
+
+	// RuleTLSSettings checks the consistency of TLS settings, see StrictTLSSettings.
+	RuleTLSSettings = "tls-settings"
+
+	// RuleEnvoyFilterFields rejects deprecated EnvoyFilter fields, see StrictEnvoyFilter.
+	RuleEnvoyFilterFields = "envoy-filter-fields"
+
+	// RuleGatewayCredentials checks that the secrets of gateway credentialNames
+	// exist, see CheckGatewayCredentials.
+	RuleGatewayCredentials = "gateway-credentials"
+
+	// RuleNamespaceExists requires the namespace of objects to exist, see
+	// VerifyNamespaceExists.
+	RuleNamespaceExists = "namespace-exists"
+
+	// RuleGlobalNames requires the names of objects of some kinds to be unique
+	// across namespaces, see EnforceGlobalNameUniqueness.
+	RuleGlobalNames = "global-names"
+
+	// RuleRequiredLabels requires the RequiredLabels.
+	RuleRequiredLabels = "required-labels"
+
+	// RuleEmptySpec rejects objects with an empty spec, see RejectEmptySpec.
+	RuleEmptySpec = "empty-spec"
+
+	// disabledRulesConfigMapKey is the key of the disabled rules in the enforcement ConfigMap.
+	disabledRulesConfigMapKey = "disabledRules"
+)
+
+// validationRules are the optional validation rules, in the order they are logged.
+var validationRules = []string{
+	RuleUnknownFields,
+	RuleReferencedObjects,
+	RuleGatewayHosts,
+	RuleServiceEntryEndpoints,
+	RuleMetadataKeys,
+	RuleSidecarSelector,
+	RulePortNaming,
+	RuleTLSSettings,
+	RuleEnvoyFilterFields,
+	RuleGatewayCredentials,
+	RuleNamespaceExists,
+	RuleGlobalNames,
+	RuleRequiredLabels,
+	RuleEmptySpec,
+}
+
+// validateDisabledRules returns an error naming each unknown rule.
+func validateDisabledRules(rules map[string]bool) error {
+	known := make(map[string]bool, len(validationRules))
+	for _, rule := range validationRules {
+		known[rule] = true
+	}
+	var errs *multierror.Error
+	for _, rule := range sortedRules(rules) {
+		if !known[rule] {
+			errs = multierror.Append(errs, fmt.Errorf("unknown validation rule %q, want one of %s",
+				rule, strings.Join(quoteAll(validationRules), ", ")))
+		}
+	}
+	return errs.ErrorOrNil()
+}
+
+// parseDisabledRules parses a comma separated list of rule identifiers.
+func parseDisabledRules(value string) (map[string]bool, error) {
+	rules := make(map[string]bool)
+	for _, rule := range strings.Split(value, ",") {
+		if rule = strings.TrimSpace(rule); rule != "" {
+			rules[rule] = true
+		}
+	}
+	if err := validateDisabledRules(rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// sortedRules returns the rules that are set in the map, sorted.
+func sortedRules(rules map[string]bool) []string {
+	var names []string
+	for rule, set := range rules {
+		if set {
+			names = append(names, rule)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ruleConfigured returns true if the rule is enabled by the webhook parameters.
+func (wh *Webhook) ruleConfigured(rule string) bool {
+	switch rule {
+	case RuleUnknownFields:
+		return wh.rejectUnknownFields
+	case RuleReferencedObjects:
+		return wh.protectReferencedObjects
+	case RuleGatewayHosts:
+		return wh.strictGateway
+	case RuleServiceEntryEndpoints:
+		return wh.strictServiceEntry
+	case RuleMetadataKeys:
+		return wh.strictMetadataKeys
+	case RuleSidecarSelector:
+		return wh.strictSidecar
+	case RulePortNaming:
+		return wh.strictPortNaming
+	case RuleTLSSettings:
+		return wh.strictTLSSettings
+	case RuleEnvoyFilterFields:
+		return wh.strictEnvoyFilter
+	case RuleGatewayCredentials:
+		return wh.gatewayCredentials != nil
+	case RuleNamespaceExists:
+		return wh.namespaces != nil
+	case RuleGlobalNames:
+		return len(wh.globalNameKinds) > 0
+	case RuleRequiredLabels:
+		return len(wh.requiredLabels) > 0
+	case RuleEmptySpec:
+		return wh.rejectEmptySpec
+	default:
+		return false
+	}
+}
+
+// ruleActive returns true if the rule is configured and not disabled at runtime.
+func (wh *Webhook) ruleActive(rule string) bool {
+	if !wh.ruleConfigured(rule) {
+		return false
+	}
+	disabled, _ := wh.disabledRules.Load().(map[string]bool)
+	return !disabled[rule]
+}
+
+// activeRules returns the active rules, in the order of validationRules.
+func (wh *Webhook) activeRules() []string {
+	var active []string
+	for _, rule := range validationRules {
+		if wh.ruleActive(rule) {
+			active = append(active, rule)
+		}
+	}
+	return active
+}
+
+// logActiveRules logs the active and disabled rules.
+func (wh *Webhook) logActiveRules() {
+	disabled, _ := wh.disabledRules.Load().(map[string]bool)
+	scope.Infof("Active validation rules: [%s], disabled: [%s]",
+		strings.Join(wh.activeRules(), ", "), strings.Join(sortedRules(disabled), ", "))
+}
+
+// setDisabledRules updates the disabled rules from the enforcement ConfigMap,
+// which is nil if it was deleted. The DisabledRules of the webhook parameters
+// apply if the ConfigMap has no disabled rules key, or its value is invalid.
+func (wh *Webhook) setDisabledRules(cm *v1.ConfigMap) {
+	rules := wh.defaultDisabledRules
+	if cm != nil {
+		if value, ok := cm.Data[disabledRulesConfigMapKey]; ok {
+			parsed, err := parseDisabledRules(value)
+			if err != nil {
+				scope.Errorf("Invalid %q in ConfigMap %s/%s, keeping the default disabled rules: %v",
+					disabledRulesConfigMapKey, cm.Namespace, cm.Name, err)
+			} else {
+				rules = parsed
+			}
+		}
+	}
+
+	prev, _ := wh.disabledRules.Load().(map[string]bool)
+	if strings.Join(sortedRules(prev), ",") == strings.Join(sortedRules(rules), ",") {
+		return
+	}
+	wh.disabledRules.Store(rules)
+	// updates accepted while a re-enabled rule was disabled must be validated again
+	if wh.versionCache != nil {
+		wh.versionCache.clear()
+	}
+	scope.Warnf("Disabled validation rules changed from [%s] to [%s] by ConfigMap %s/%s",
+		strings.Join(sortedRules(prev), ", "), strings.Join(sortedRules(rules), ", "),
+		wh.deploymentAndServiceNamespace, wh.enforcementConfigMapName)
+	wh.logActiveRules()
+}
@@ -0,0 +1,130 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestParseDisabledRules(t *testing.T) {
+	cases := []struct {
+		value   string
+		want    map[string]bool
+		wantErr bool
+	}{
+		{value: "", want: map[string]bool{}},
+		{value: RuleGatewayHosts, want: map[string]bool{RuleGatewayHosts: true}},
+		{
+			value: " gateway-hosts, metadata-keys ,",
+			want:  map[string]bool{RuleGatewayHosts: true, RuleMetadataKeys: true},
+		},
+		{value: "gateway-hosts,typo", wantErr: true},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %q", i, c.value), func(t *testing.T) {
+			got, err := parseDisabledRules(c.value)
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("got %v want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("got unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Fatalf("got %v want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestSetDisabledRules(t *testing.T) {
+	wh := &Webhook{
+		rejectUnknownFields:  true,
+		strictGateway:        true,
+		strictMetadataKeys:   true,
+		defaultDisabledRules: map[string]bool{RuleMetadataKeys: true},
+	}
+	wh.disabledRules.Store(wh.defaultDisabledRules)
+
+	cases := []struct {
+		name string
+		cm   *v1.ConfigMap
+		want []string
+	}{
+		{
+			name: "defaults",
+			cm:   &v1.ConfigMap{Data: map[string]string{defaultEnforcementConfigMapKey: "on"}},
+			want: []string{RuleUnknownFields, RuleGatewayHosts},
+		},
+		{
+			name: "disabled by ConfigMap",
+			cm:   &v1.ConfigMap{Data: map[string]string{disabledRulesConfigMapKey: "gateway-hosts"}},
+			want: []string{RuleUnknownFields, RuleMetadataKeys},
+		},
+		{
+			name: "nothing disabled by ConfigMap",
+			cm:   &v1.ConfigMap{Data: map[string]string{disabledRulesConfigMapKey: ""}},
+			want: []string{RuleUnknownFields, RuleGatewayHosts, RuleMetadataKeys},
+		},
+		{
+			name: "invalid ConfigMap rules",
+			cm:   &v1.ConfigMap{Data: map[string]string{disabledRulesConfigMapKey: "typo"}},
+			want: []string{RuleUnknownFields, RuleGatewayHosts},
+		},
+		{
+			name: "ConfigMap deleted",
+			want: []string{RuleUnknownFields, RuleGatewayHosts},
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			wh.setDisabledRules(c.cm)
+			if got := wh.activeRules(); !reflect.DeepEqual(got, c.want) {
+				t.Fatalf("got active rules %v want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestAdmitPilotDisabledUnknownFields(t *testing.T) {
+	wh, cancel := createTestWebhook(t, dummyClient, createFakeEndpointsSource(), dummyConfig)
+	defer cancel()
+
+	request := &admissionv1beta1.AdmissionRequest{
+		Kind:      metav1.GroupVersionKind{Kind: "mock"},
+		Object:    runtime.RawExtension{Raw: makePilotConfig(t, 0, true, true)},
+		Operation: admissionv1beta1.Create,
+	}
+	if resp := wh.admitPilot(context.Background(), request); resp.Allowed {
+		t.Fatal("unknown field admitted with the rule active")
+	}
+
+	wh.disabledRules.Store(map[string]bool{RuleUnknownFields: true})
+	if resp := wh.admitPilot(context.Background(), request); !resp.Allowed {
+		t.Fatalf("unknown field rejected with the rule disabled: %v", resp.Result)
+	}
+}
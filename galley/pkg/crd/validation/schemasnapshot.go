@@ -0,0 +1,123 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/ghodss/yaml"
+
+	"istio.io/istio/pkg/config/schema"
+)
+
+// schemaVersionLength is the number of hex digits of the schema digest in
+// schema version identifiers.
+const schemaVersionLength = 16
+
+// schemaSnapshotEntry is a pilot schema in a schema snapshot. The validation
+// function is not serializable; it is that of the built-in schema of the type.
+type schemaSnapshotEntry struct {
+	Type          string `json:"type"`
+	Plural        string `json:"plural"`
+	Group         string `json:"group"`
+	Version       string `json:"version"`
+	MessageName   string `json:"messageName"`
+	Collection    string `json:"collection,omitempty"`
+	ClusterScoped bool   `json:"clusterScoped,omitempty"`
+}
+
+// schemaSnapshot is the file format of SchemaSnapshotFile, in YAML or JSON.
+type schemaSnapshot struct {
+	Schemas []schemaSnapshotEntry `json:"schemas"`
+}
+
+// snapshotOf returns the snapshot of the schemas.
+func snapshotOf(descriptor schema.Set) schemaSnapshot {
+	snapshot := schemaSnapshot{Schemas: make([]schemaSnapshotEntry, 0, len(descriptor))}
+	for _, s := range descriptor {
+		snapshot.Schemas = append(snapshot.Schemas, schemaSnapshotEntry{
+			Type:          s.Type,
+			Plural:        s.Plural,
+			Group:         s.Group,
+			Version:       s.Version,
+			MessageName:   s.MessageName,
+			Collection:    s.Collection,
+			ClusterScoped: s.ClusterScoped,
+		})
+	}
+	return snapshot
+}
+
+// schemaVersion returns the identifier of the schemas recorded with decisions,
+// a digest of their snapshot. A snapshot file of the built-in schemas has the
+// same identifier as the built-in schemas.
+func schemaVersion(descriptor schema.Set) string {
+	b, err := json.Marshal(snapshotOf(descriptor))
+	if err != nil {
+		// the snapshot only holds strings and booleans
+		panic(err)
+	}
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:])[:schemaVersionLength]
+}
+
+// loadSchemaSnapshot reads the pilot schemas from the snapshot file. Each schema
+// must have the type and message of a built-in schema, whose validation function
+// it uses.
+func loadSchemaSnapshot(path string, builtin schema.Set) (schema.Set, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read schema snapshot: %v", err)
+	}
+	var snapshot schemaSnapshot
+	if err := yaml.Unmarshal(b, &snapshot); err != nil {
+		return nil, fmt.Errorf("cannot parse schema snapshot %s: %v", path, err)
+	}
+	if len(snapshot.Schemas) == 0 {
+		return nil, fmt.Errorf("schema snapshot %s has no schemas", path)
+	}
+
+	descriptor := make(schema.Set, 0, len(snapshot.Schemas))
+	for i, entry := range snapshot.Schemas {
+		known, ok := builtin.GetByType(entry.Type)
+		if !ok {
+			return nil, fmt.Errorf("schema snapshot %s: schemas[%d] has type %q unknown to this build", path, i, entry.Type)
+		}
+		// the validation function only validates the message of the built-in schema
+		if entry.MessageName != known.MessageName {
+			return nil, fmt.Errorf("schema snapshot %s: schemas[%d] of type %q has message %q, this build validates %q",
+				path, i, entry.Type, entry.MessageName, known.MessageName)
+		}
+		descriptor = append(descriptor, schema.Instance{
+			ClusterScoped: entry.ClusterScoped,
+			VariableName:  known.VariableName,
+			Type:          entry.Type,
+			Plural:        entry.Plural,
+			Group:         entry.Group,
+			Version:       entry.Version,
+			MessageName:   entry.MessageName,
+			Validate:      known.Validate,
+			Collection:    entry.Collection,
+		})
+	}
+	if err := descriptor.Validate(); err != nil {
+		return nil, fmt.Errorf("schema snapshot %s is invalid: %v", path, err)
+	}
+	return descriptor, nil
+}
@@ -0,0 +1,166 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ghodss/yaml"
+
+	"istio.io/istio/pkg/config/schema"
+	"istio.io/istio/pkg/config/schemas"
+)
+
+func writeSchemaSnapshot(t *testing.T, dir, name string, content []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, content, 0644); err != nil {
+		t.Fatalf("WriteFile(%v) failed: %v", path, err)
+	}
+	return path
+}
+
+func TestLoadSchemaSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "galley_validation_schemas")
+	if err != nil {
+		t.Fatalf("TempDir() failed: %v", err)
+	}
+	defer os.RemoveAll(dir) // nolint: errcheck
+
+	builtin, err := yaml.Marshal(snapshotOf(schemas.Istio))
+	if err != nil {
+		t.Fatalf("Marshal() failed: %v", err)
+	}
+	vs := snapshotOf(schema.Set{schemas.VirtualService}).Schemas[0]
+
+	cases := []struct {
+		name     string
+		content  string
+		wantErr  string
+		wantSize int
+	}{
+		{name: "built-in schemas", content: string(builtin), wantSize: len(schemas.Istio)},
+		{
+			name: "virtual services only",
+			content: fmt.Sprintf("schemas:\n- type: %s\n  plural: %s\n  group: %s\n  version: %s\n  messageName: %s\n",
+				vs.Type, vs.Plural, vs.Group, vs.Version, vs.MessageName),
+			wantSize: 1,
+		},
+		{name: "not yaml", content: "schemas: [", wantErr: "cannot parse schema snapshot"},
+		{name: "no schemas", content: "schemas: []", wantErr: "has no schemas"},
+		{
+			name:    "unknown type",
+			content: "schemas:\n- type: virtual-machine\n  plural: virtual-machines\n  messageName: istio.networking.v1alpha3.VirtualService\n",
+			wantErr: `schemas[0] has type "virtual-machine" unknown to this build`,
+		},
+		{
+			name:    "other message",
+			content: fmt.Sprintf("schemas:\n- type: %s\n  plural: %s\n  messageName: istio.networking.v1alpha3.Gateway\n", vs.Type, vs.Plural),
+			wantErr: `this build validates "istio.networking.v1alpha3.VirtualService"`,
+		},
+		{
+			name: "duplicate type",
+			content: fmt.Sprintf("schemas:\n- {type: %[1]s, plural: %[2]s, messageName: %[3]s}\n- {type: %[1]s, plural: %[2]s, messageName: %[3]s}\n",
+				vs.Type, vs.Plural, vs.MessageName),
+			wantErr: "duplicate type",
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			path := writeSchemaSnapshot(t, dir, fmt.Sprintf("schemas%d.yaml", i), []byte(c.content))
+			got, err := loadSchemaSnapshot(path, schemas.Istio)
+			if c.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+					t.Fatalf("got error %v want %q", err, c.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadSchemaSnapshot() failed: %v", err)
+			}
+			if len(got) != c.wantSize {
+				t.Fatalf("got %d schemas want %d", len(got), c.wantSize)
+			}
+			for _, s := range got {
+				if s.Validate == nil {
+					t.Fatalf("schema %v has no validation function", s.Type)
+				}
+			}
+		})
+	}
+
+	if _, err := loadSchemaSnapshot(filepath.Join(dir, "missing.yaml"), schemas.Istio); err == nil {
+		t.Fatal("loadSchemaSnapshot() of a missing file succeeded, want an error")
+	}
+}
+
+func TestSchemaVersion(t *testing.T) {
+	dir, err := ioutil.TempDir("", "galley_validation_schemas")
+	if err != nil {
+		t.Fatalf("TempDir() failed: %v", err)
+	}
+	defer os.RemoveAll(dir) // nolint: errcheck
+
+	builtin := schemaVersion(schemas.Istio)
+	if !strings.HasPrefix(builtin, "sha256:") || len(builtin) != len("sha256:")+schemaVersionLength {
+		t.Fatalf("got schema version %q want sha256: and %d hex digits", builtin, schemaVersionLength)
+	}
+	if other := schemaVersion(schema.Set{schemas.VirtualService}); other == builtin {
+		t.Fatalf("got the same schema version %q for different schemas", other)
+	}
+
+	// a snapshot of the built-in schemas reproduces their version
+	content, err := yaml.Marshal(snapshotOf(schemas.Istio))
+	if err != nil {
+		t.Fatalf("Marshal() failed: %v", err)
+	}
+	args := DefaultArgs()
+	args.EnableMixerValidation = false
+	args.SchemaSnapshotFile = writeSchemaSnapshot(t, dir, "schemas.yaml", content)
+	set, err := InitValidators(args)
+	if err != nil {
+		t.Fatalf("InitValidators() failed: %v", err)
+	}
+	if got := schemaVersion(set.PilotDescriptor); got != builtin {
+		t.Fatalf("got schema version %q of the snapshot want %q", got, builtin)
+	}
+
+	args.SchemaSnapshotFile = writeSchemaSnapshot(t, dir, "empty.yaml", []byte("schemas: []"))
+	if set, err = InitValidators(args); err == nil || !strings.Contains(err.Error(), "has no schemas") {
+		t.Fatalf("got error %v want the snapshot rejected", err)
+	}
+	if got := schemaVersion(set.PilotDescriptor); got != builtin {
+		t.Fatalf("got schema version %q of a rejected snapshot want the built-in %q", got, builtin)
+	}
+}
+
+func TestReloadValidatorsSchemaVersion(t *testing.T) {
+	wh, cancel := createTestWebhook(t, dummyClient, createFakeEndpointsSource(), dummyConfig)
+	defer cancel()
+
+	descriptor := schema.Set{schemas.VirtualService}
+	if err := wh.ReloadValidators(descriptor, wh.activeValidators().mixer); err != nil {
+		t.Fatalf("ReloadValidators() failed: %v", err)
+	}
+	if got, want := wh.activeValidators().schemaVersion, schemaVersion(descriptor); got != want {
+		t.Fatalf("got schema version %q want %q", got, want)
+	}
+}
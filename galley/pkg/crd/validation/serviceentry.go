@@ -0,0 +1,47 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/hashicorp/go-multierror"
+
+	networking "istio.io/api/networking/v1alpha3"
+)
+
+// validateServiceEntryResolution rejects ServiceEntries whose endpoints are
+// inconsistent with their resolution: STATIC without endpoints, which routes
+// nowhere, and DNS with IP endpoints, which are never resolved. Endpoints of
+// resolution NONE are already rejected by the schema validation.
+func validateServiceEntryResolution(serviceEntry *networking.ServiceEntry) error {
+	var errs *multierror.Error
+	switch serviceEntry.Resolution {
+	case networking.ServiceEntry_STATIC:
+		if len(serviceEntry.Endpoints) == 0 {
+			errs = multierror.Append(errs, fmt.Errorf("resolution %v requires endpoints, or the hosts %v have no destination",
+				serviceEntry.Resolution, serviceEntry.Hosts))
+		}
+	case networking.ServiceEntry_DNS:
+		for i, endpoint := range serviceEntry.Endpoints {
+			if endpoint != nil && net.ParseIP(endpoint.Address) != nil {
+				errs = multierror.Append(errs, fmt.Errorf("endpoint %d address %q is an IP address, want a hostname for resolution %v or use resolution %v",
+					i, endpoint.Address, serviceEntry.Resolution, networking.ServiceEntry_STATIC))
+			}
+		}
+	}
+	return errs.ErrorOrNil()
+}
@@ -0,0 +1,132 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	networking "istio.io/api/networking/v1alpha3"
+
+	"istio.io/istio/pilot/test/mock"
+	"istio.io/istio/pkg/config/schema"
+	"istio.io/istio/pkg/config/schemas"
+)
+
+func makeServiceEntry(resolution networking.ServiceEntry_Resolution, addresses ...string) *networking.ServiceEntry {
+	serviceEntry := &networking.ServiceEntry{
+		Hosts:      []string{"foo.example.com"},
+		Ports:      []*networking.Port{{Number: 80, Protocol: "HTTP", Name: "http"}},
+		Location:   networking.ServiceEntry_MESH_EXTERNAL,
+		Resolution: resolution,
+	}
+	for _, address := range addresses {
+		serviceEntry.Endpoints = append(serviceEntry.Endpoints, &networking.ServiceEntry_Endpoint{Address: address})
+	}
+	return serviceEntry
+}
+
+func TestValidateServiceEntryResolution(t *testing.T) {
+	cases := []struct {
+		name         string
+		serviceEntry *networking.ServiceEntry
+		wantErr      string
+	}{
+		{
+			name:         "none without endpoints",
+			serviceEntry: makeServiceEntry(networking.ServiceEntry_NONE),
+		},
+		{
+			name:         "static with endpoints",
+			serviceEntry: makeServiceEntry(networking.ServiceEntry_STATIC, "10.0.0.1", "10.0.0.2"),
+		},
+		{
+			name:         "static without endpoints",
+			serviceEntry: makeServiceEntry(networking.ServiceEntry_STATIC),
+			wantErr:      "resolution STATIC requires endpoints",
+		},
+		{
+			name:         "dns without endpoints",
+			serviceEntry: makeServiceEntry(networking.ServiceEntry_DNS),
+		},
+		{
+			name:         "dns with hostname endpoints",
+			serviceEntry: makeServiceEntry(networking.ServiceEntry_DNS, "a.example.com", "b.example.com"),
+		},
+		{
+			name:         "dns with ip endpoint",
+			serviceEntry: makeServiceEntry(networking.ServiceEntry_DNS, "a.example.com", "10.0.0.1"),
+			wantErr:      `endpoint 1 address "10.0.0.1" is an IP address`,
+		},
+		{
+			name:         "dns with ipv6 endpoint",
+			serviceEntry: makeServiceEntry(networking.ServiceEntry_DNS, "2001:db8::1"),
+			wantErr:      `endpoint 0 address "2001:db8::1" is an IP address`,
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			err := validateServiceEntryResolution(c.serviceEntry)
+			if c.wantErr == "" {
+				if err != nil {
+					t.Fatalf("got unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+				t.Fatalf("got error %v want %q", err, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestAdmitPilotStrictServiceEntry(t *testing.T) {
+	serviceEntry := makeIstioKind(t, schemas.ServiceEntry, "default", "foo",
+		makeServiceEntry(networking.ServiceEntry_DNS, "10.0.0.1"))
+	raw, err := json.Marshal(&serviceEntry)
+	if err != nil {
+		t.Fatalf("Marshal(%v) failed: %v", serviceEntry.Name, err)
+	}
+
+	wh, cancel := createTestWebhook(t, dummyClient, createFakeEndpointsSource(), dummyConfig)
+	defer cancel()
+	descriptor := append(append(schema.Set{}, schemas.Istio...), mock.Types...)
+	if err := wh.ReloadValidators(descriptor, wh.activeValidators().mixer); err != nil {
+		t.Fatalf("ReloadValidators() failed: %v", err)
+	}
+
+	for _, strict := range []bool{false, true} {
+		t.Run(fmt.Sprintf("strict=%v", strict), func(t *testing.T) {
+			wh.strictServiceEntry = strict
+			got := wh.admitPilot(context.Background(), &admissionv1beta1.AdmissionRequest{
+				Kind:      metav1.GroupVersionKind{Kind: "ServiceEntry"},
+				Namespace: "default",
+				Object:    runtime.RawExtension{Raw: raw},
+				Operation: admissionv1beta1.Create,
+			})
+			if got.Allowed == strict {
+				t.Fatalf("got %v want %v: %v", got.Allowed, !strict, got.Result)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+
+	networking "istio.io/api/networking/v1alpha3"
+
+	"istio.io/istio/pilot/pkg/config/kube/crd"
+	"istio.io/istio/pkg/config/schemas"
+)
+
+// sidecarLister lists the Sidecars in a namespace.
+type sidecarLister func(namespace string) ([]crd.IstioKind, error)
+
+// listSidecars lists the Sidecars in the namespace from the API server.
+func (wh *Webhook) listSidecars(namespace string) ([]crd.IstioKind, error) {
+	if wh.clientset == nil {
+		return nil, errors.New("no kubernetes client available")
+	}
+	restClient := wh.clientset.Discovery().RESTClient()
+	if restClient == nil {
+		return nil, errors.New("no kubernetes REST client available")
+	}
+
+	s := schemas.Sidecar
+	raw, err := restClient.Get().
+		AbsPath("/apis", crd.ResourceGroup(&s), s.Version, "namespaces", namespace, crd.ResourceName(s.Plural)).
+		DoRaw()
+	if err != nil {
+		return nil, err
+	}
+
+	var list crd.IstioKindList
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// namespaceWideSidecars returns the sorted names of the Sidecars without a
+// workloadSelector in the namespace, other than the named one. Pilot applies
+// only one of them to the namespace, so a second one is ignored.
+func (wh *Webhook) namespaceWideSidecars(namespace, name string) ([]string, error) {
+	items, err := wh.sidecarLister(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for i := range items {
+		cfg, err := crd.ConvertObject(schemas.Sidecar, &items[i], wh.domainSuffix)
+		if err != nil {
+			scope.Warnf("skipping undecodable sidecar %s/%s: %v", items[i].Namespace, items[i].Name, err)
+			continue
+		}
+		sidecar, ok := cfg.Spec.(*networking.Sidecar)
+		if !ok || cfg.Name == name || !isNamespaceWideSidecar(sidecar) {
+			continue
+		}
+		names = append(names, cfg.Name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// isNamespaceWideSidecar returns true if the Sidecar applies to all workloads of its namespace.
+func isNamespaceWideSidecar(sidecar *networking.Sidecar) bool {
+	return sidecar.WorkloadSelector == nil || len(sidecar.WorkloadSelector.Labels) == 0
+}
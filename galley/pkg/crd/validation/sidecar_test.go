@@ -0,0 +1,151 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	networking "istio.io/api/networking/v1alpha3"
+
+	"istio.io/istio/pilot/pkg/config/kube/crd"
+	"istio.io/istio/pilot/test/mock"
+	"istio.io/istio/pkg/config/schema"
+	"istio.io/istio/pkg/config/schemas"
+)
+
+func TestAdmitPilotStrictSidecar(t *testing.T) {
+	egress := []*networking.IstioEgressListener{{Hosts: []string{"./*"}}}
+	namespaceWide := func(name string) crd.IstioKind {
+		return makeIstioKind(t, schemas.Sidecar, "default", name, &networking.Sidecar{Egress: egress})
+	}
+	selected := makeIstioKind(t, schemas.Sidecar, "default", "reviews", &networking.Sidecar{
+		WorkloadSelector: &networking.WorkloadSelector{Labels: map[string]string{"app": "reviews"}},
+		Egress:           egress,
+	})
+
+	wh, cancel := createTestWebhook(t, dummyClient, createFakeEndpointsSource(), dummyConfig)
+	defer cancel()
+	descriptor := append(append(schema.Set{}, schemas.Istio...), mock.Types...)
+	if err := wh.ReloadValidators(descriptor, wh.activeValidators().mixer); err != nil {
+		t.Fatalf("ReloadValidators() failed: %v", err)
+	}
+
+	cases := []struct {
+		name          string
+		strict        bool
+		object        crd.IstioKind
+		items         []crd.IstioKind
+		listErr       error
+		degrade       bool
+		allowed       bool
+		internalError bool
+		conflicts     []string
+	}{
+		{
+			name:    "check disabled",
+			object:  namespaceWide("second"),
+			items:   []crd.IstioKind{namespaceWide("first")},
+			allowed: true,
+		},
+		{
+			name:    "first namespace-wide sidecar",
+			strict:  true,
+			object:  namespaceWide("first"),
+			items:   []crd.IstioKind{selected},
+			allowed: true,
+		},
+		{
+			name:      "second namespace-wide sidecar",
+			strict:    true,
+			object:    namespaceWide("second"),
+			items:     []crd.IstioKind{selected, namespaceWide("first")},
+			conflicts: []string{"first"},
+		},
+		{
+			name:    "update of the namespace-wide sidecar",
+			strict:  true,
+			object:  namespaceWide("first"),
+			items:   []crd.IstioKind{namespaceWide("first")},
+			allowed: true,
+		},
+		{
+			name:    "sidecar with workloadSelector",
+			strict:  true,
+			object:  selected,
+			items:   []crd.IstioKind{namespaceWide("first")},
+			allowed: true,
+		},
+		{
+			name:          "list error",
+			strict:        true,
+			object:        namespaceWide("second"),
+			listErr:       errors.New("forbidden"),
+			internalError: true,
+		},
+		{
+			name:    "degraded list error",
+			strict:  true,
+			object:  namespaceWide("second"),
+			listErr: errors.New("connection refused"),
+			degrade: true,
+			allowed: true,
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			wh.strictSidecar = c.strict
+			wh.degradeReferenceChecks = c.degrade
+			wh.sidecarLister = func(namespace string) ([]crd.IstioKind, error) {
+				if namespace != "default" {
+					t.Fatalf("listed sidecars in namespace %q, want %q", namespace, "default")
+				}
+				return c.items, c.listErr
+			}
+			raw, err := json.Marshal(&c.object)
+			if err != nil {
+				t.Fatalf("Marshal(%v) failed: %v", c.object.Name, err)
+			}
+
+			got := wh.admitPilot(context.Background(), &admissionv1beta1.AdmissionRequest{
+				Kind:      metav1.GroupVersionKind{Kind: "Sidecar"},
+				Namespace: "default",
+				Name:      c.object.Name,
+				Object:    runtime.RawExtension{Raw: raw},
+				Operation: admissionv1beta1.Create,
+			})
+			if got.Allowed != c.allowed {
+				t.Fatalf("got %v want %v: %v", got.Allowed, c.allowed, got.Result)
+			}
+			if isInternalError(got) != c.internalError {
+				t.Fatalf("got internal error %v want %v: %v", isInternalError(got), c.internalError, got.Result)
+			}
+			for _, name := range c.conflicts {
+				if !strings.Contains(got.Result.Message, name) {
+					t.Fatalf("response %q does not name conflicting sidecar %v", got.Result.Message, name)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"istio.io/pkg/log"
+)
+
+// exit is overridden in tests.
+var exit = os.Exit
+
+// WaitSignal awaits SIGINT or SIGTERM and calls stop so that validation begins
+// draining within the TerminationGracePeriod. A second signal exits immediately.
+func WaitSignal(stop func()) {
+	sigs := make(chan os.Signal, 2)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+	waitSignal(sigs, stop)
+}
+
+func waitSignal(sigs <-chan os.Signal, stop func()) {
+	sig := <-sigs
+	scope.Infof("Received %v, stopping validation", sig)
+	stop()
+
+	sig = <-sigs
+	scope.Warnf("Received %v while stopping validation, exiting immediately", sig)
+	_ = log.Sync()
+	exit(1)
+}
@@ -0,0 +1,54 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestWaitSignal(t *testing.T) {
+	exited := make(chan int, 1)
+	exit = func(code int) { exited <- code }
+	defer func() { exit = os.Exit }()
+
+	sigs := make(chan os.Signal, 2)
+	stopped := make(chan struct{})
+	go waitSignal(sigs, func() { close(stopped) })
+
+	sigs <- syscall.SIGTERM
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("first signal did not stop validation")
+	}
+	select {
+	case code := <-exited:
+		t.Fatalf("first signal exited with %v", code)
+	default:
+	}
+
+	sigs <- syscall.SIGTERM
+	select {
+	case code := <-exited:
+		if code != 1 {
+			t.Fatalf("got exit code %v want 1", code)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("second signal did not exit")
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"strconv"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// standbyConfigMapKey is the key of the standby mode in the enforcement ConfigMap.
+const standbyConfigMapKey = "standby"
+
+// standbyMode returns the standby mode set by the enforcement ConfigMap, which
+// is nil if it was deleted. The StandbyMode of the webhook parameters applies
+// if the ConfigMap has no standby key, or its value is invalid.
+func (whc *WebhookConfigController) standbyMode(cm *v1.ConfigMap) bool {
+	standby := whc.webhookParameters.StandbyMode
+	if cm == nil {
+		return standby
+	}
+	value, ok := cm.Data[standbyConfigMapKey]
+	if !ok {
+		return standby
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		scope.Errorf("Invalid %q in ConfigMap %s/%s, keeping standby mode %v: %v",
+			standbyConfigMapKey, cm.Namespace, cm.Name, standby, err)
+		return standby
+	}
+	return parsed
+}
+
+// watchStandby watches the enforcement ConfigMap until stopped, and returns a
+// channel of the standby modes it sets. The channel is nil if there is no
+// enforcement ConfigMap, so that the standby mode never changes.
+func (whc *WebhookConfigController) watchStandby(stopCh <-chan struct{}) chan bool {
+	p := whc.webhookParameters
+	if p.EnforcementConfigMapName == "" {
+		return nil
+	}
+	standbyCh := make(chan bool, 1000)
+	_, controller := cache.NewInformer(
+		whc.createInformerConfigMapSource(p.Clientset, p.DeploymentAndServiceNamespace, p.EnforcementConfigMapName),
+		&v1.ConfigMap{},
+		0,
+		cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+				standbyCh <- whc.standbyMode(obj.(*v1.ConfigMap))
+			},
+			UpdateFunc: func(prev, curr interface{}) {
+				standbyCh <- whc.standbyMode(curr.(*v1.ConfigMap))
+			},
+			DeleteFunc: func(obj interface{}) {
+				standbyCh <- whc.standbyMode(nil)
+			},
+		},
+	)
+	go controller.Run(stopCh)
+	return standbyCh
+}
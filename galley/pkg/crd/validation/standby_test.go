@@ -0,0 +1,95 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestStandbyMode(t *testing.T) {
+	cases := []struct {
+		name    string
+		standby bool
+		cm      *corev1.ConfigMap
+		want    bool
+	}{
+		{name: "deleted ConfigMap", standby: true, want: true},
+		{name: "no standby key", standby: true, cm: &corev1.ConfigMap{Data: map[string]string{}}, want: true},
+		{name: "activated", standby: true, cm: &corev1.ConfigMap{Data: map[string]string{standbyConfigMapKey: "false"}}},
+		{name: "standby", cm: &corev1.ConfigMap{Data: map[string]string{standbyConfigMapKey: "true"}}, want: true},
+		{name: "invalid", standby: true, cm: &corev1.ConfigMap{Data: map[string]string{standbyConfigMapKey: "maybe"}}, want: true},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			whc := &WebhookConfigController{webhookParameters: &WebhookParameters{StandbyMode: c.standby}}
+			if got := whc.standbyMode(c.cm); got != c.want {
+				t.Fatalf("got %v want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestReconcileStandby(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	whc, cleanup := createTestWebhookConfigController(t,
+		client,
+		createFakeWebhookSource(),
+		dummyConfig)
+	defer cleanup()
+	whc.webhookParameters.EnableValidation = true
+	whc.webhookParameters.EnforcementConfigMapName = "galley-enforcement"
+	whc.standby = true
+	whc.createInformerConfigMapSource = func(cl clientset.Interface, namespace, name string) cache.ListerWatcher {
+		return &cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				return client.CoreV1().ConfigMaps(namespace).List(options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				return client.CoreV1().ConfigMaps(namespace).Watch(options)
+			},
+		}
+	}
+	stop := make(chan struct{})
+	defer func() { close(stop) }()
+	go whc.reconcile(stop)
+
+	g := gomega.NewGomegaWithT(t)
+	configs := client.AdmissionregistrationV1beta1().ValidatingWebhookConfigurations()
+	exists := func() bool {
+		_, err := configs.Get(dummyConfig.Name, metav1.GetOptions{})
+		return err == nil
+	}
+	g.Consistently(exists, "500ms", "50ms").Should(gomega.BeFalse())
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "galley-enforcement", Namespace: whc.webhookParameters.DeploymentAndServiceNamespace},
+		Data:       map[string]string{standbyConfigMapKey: "false"},
+	}
+	if _, err := client.CoreV1().ConfigMaps(cm.Namespace).Create(cm); err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	g.Eventually(exists, "10s", "100ms").Should(gomega.BeTrue())
+}
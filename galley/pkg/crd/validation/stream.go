@@ -0,0 +1,123 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// maxStreamedCausesThreshold is the number of causes from which the
+	// admission response is always streamed rather than encoded in memory.
+	maxStreamedCausesThreshold = 100
+
+	// streamedCausesMarker is the message of the placeholder cause that marks
+	// where the causes are written in the encoded review.
+	streamedCausesMarker = "galley-validation-streamed-causes"
+)
+
+// streamedCausesThreshold returns the number of causes from which the admission
+// response is streamed, so that the reports that reach the maxReportedErrors
+// bound are streamed, up to maxStreamedCausesThreshold.
+func streamedCausesThreshold(maxReportedErrors int) int {
+	if maxReportedErrors <= 0 || maxReportedErrors > maxStreamedCausesThreshold {
+		return maxStreamedCausesThreshold
+	}
+	return maxReportedErrors
+}
+
+// responseCauses returns the causes of the response, if any.
+func responseCauses(response *admissionv1beta1.AdmissionResponse) []v1.StatusCause {
+	if response == nil || response.Result == nil || response.Result.Details == nil {
+		return nil
+	}
+	return response.Result.Details.Causes
+}
+
+// encodeReviewStream writes the AdmissionReview of the response to w like
+// encodeReview, but encodes the causes one at a time, so that only the review
+// without its causes is held in memory.
+func encodeReviewStream(w io.Writer, response *admissionv1beta1.AdmissionResponse, apiVersion string) error {
+	causes := responseCauses(response)
+
+	// the review is encoded with a placeholder cause, which is replaced by the causes
+	placeholder := v1.StatusCause{Message: streamedCausesMarker}
+	details := *response.Result.Details
+	details.Causes = []v1.StatusCause{placeholder}
+	result := *response.Result
+	result.Details = &details
+	stub := *response
+	stub.Result = &result
+
+	envelope, err := encodeReview(&stub, apiVersion)
+	if err != nil {
+		return err
+	}
+	marker, err := json.Marshal(&placeholder)
+	if err != nil {
+		return err
+	}
+	i := bytes.Index(envelope, marker)
+	if i < 0 {
+		return errors.New("cannot find the causes in the encoded review")
+	}
+
+	if _, err := w.Write(envelope[:i]); err != nil {
+		return err
+	}
+	for j := range causes {
+		if j > 0 {
+			if _, err := w.Write([]byte(",")); err != nil {
+				return err
+			}
+		}
+		cause, err := json.Marshal(&causes[j])
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(cause); err != nil {
+			return err
+		}
+	}
+	_, err = w.Write(envelope[i+len(marker):])
+	return err
+}
+
+// writeStreamedBody writes the review of the response like writeBody, without a
+// Content-Length, so that it is sent with chunked transfer encoding. It is
+// compressed if compression is enabled and accepted, whatever its size.
+func writeStreamedBody(w http.ResponseWriter, r *http.Request, response *admissionv1beta1.AdmissionResponse,
+	apiVersion string, compressAbove int) error {
+
+	w.Header().Add("Vary", "Accept-Encoding")
+	if compressAbove == 0 || !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+		return encodeReviewStream(w, response, apiVersion)
+	}
+
+	w.Header().Set("Content-Encoding", "gzip")
+	gz := gzip.NewWriter(w)
+	if err := encodeReviewStream(gz, response, apiVersion); err != nil {
+		return err
+	}
+	return gz.Close()
+}
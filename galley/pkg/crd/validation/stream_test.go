@@ -0,0 +1,172 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func makeCausesResponse(n int) *admissionv1beta1.AdmissionResponse {
+	report := validationReport{}
+	for i := 0; i < n; i++ {
+		report.add(CheckSchema, fmt.Sprintf("spec.hosts[%d]", i), fmt.Errorf("invalid host <%d> & more", i))
+	}
+	return report.response(&admissionv1beta1.AdmissionRequest{
+		Kind: metav1.GroupVersionKind{Group: "networking.istio.io", Version: "v1alpha3", Kind: "VirtualService"},
+	}, "reviews")
+}
+
+func TestEncodeReviewStream(t *testing.T) {
+	for _, apiVersion := range []string{admissionV1beta1Version, admissionV1Version} {
+		for _, n := range []int{1, maxStreamedCausesThreshold + 1} {
+			t.Run(fmt.Sprintf("%s %d causes", apiVersion, n), func(t *testing.T) {
+				response := makeCausesResponse(n)
+				want, err := encodeReview(response, apiVersion)
+				if err != nil {
+					t.Fatalf("encodeReview() failed: %v", err)
+				}
+
+				var got bytes.Buffer
+				if err := encodeReviewStream(&got, response, apiVersion); err != nil {
+					t.Fatalf("encodeReviewStream() failed: %v", err)
+				}
+				if !bytes.Equal(got.Bytes(), want) {
+					t.Fatalf("got review\n%s\nwant\n%s", got.Bytes(), want)
+				}
+				if len(responseCauses(response)) != n {
+					t.Fatalf("got %d causes in the response after streaming, want %d", len(responseCauses(response)), n)
+				}
+			})
+		}
+	}
+}
+
+func TestStreamedCausesThreshold(t *testing.T) {
+	cases := []struct {
+		maxReportedErrors int
+		want              int
+	}{
+		{maxReportedErrors: 0, want: maxStreamedCausesThreshold},
+		{maxReportedErrors: defaultMaxReportedErrors, want: defaultMaxReportedErrors},
+		{maxReportedErrors: maxStreamedCausesThreshold * 10, want: maxStreamedCausesThreshold},
+	}
+	for _, c := range cases {
+		if got := streamedCausesThreshold(c.maxReportedErrors); got != c.want {
+			t.Errorf("streamedCausesThreshold(%d) = %d want %d", c.maxReportedErrors, got, c.want)
+		}
+	}
+}
+
+func TestServeStreamsLargeReports(t *testing.T) {
+	testServeStreamsReport(t, makeCausesResponse(maxStreamedCausesThreshold+1), maxStreamedCausesThreshold)
+}
+
+func TestServeStreamsBoundedReports(t *testing.T) {
+	// with the default parameters, a report that reaches its bound is streamed
+	p := DefaultArgs()
+	report := validationReport{maxCauses: p.MaxReportedErrors}
+	for i := 0; i < 2*p.MaxReportedErrors; i++ {
+		report.add(CheckSchema, fmt.Sprintf("spec.hosts[%d]", i), fmt.Errorf("invalid host <%d>", i))
+	}
+	response := report.response(&admissionv1beta1.AdmissionRequest{
+		Kind: metav1.GroupVersionKind{Group: "networking.istio.io", Version: "v1alpha3", Kind: "VirtualService"},
+	}, "reviews")
+	testServeStreamsReport(t, response, streamedCausesThreshold(p.MaxReportedErrors))
+}
+
+// testServeStreamsReport checks that serve streams the response with
+// streamAbove, whether it is compressed or not.
+func testServeStreamsReport(t *testing.T, response *admissionv1beta1.AdmissionResponse, streamAbove int) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serve(w, r, func(context.Context, *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+			return response
+		}, 1024, streamAbove)
+	}))
+	defer server.Close()
+
+	body, err := json.Marshal(admissionv1beta1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: admissionV1beta1Version, Kind: "AdmissionReview"},
+		Request:  &admissionv1beta1.AdmissionRequest{UID: "uid", Operation: admissionv1beta1.Create},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create AdmissionReview: %v", err)
+	}
+
+	for _, compressed := range []bool{false, true} {
+		t.Run(fmt.Sprintf("compressed %v", compressed), func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, server.URL, bytes.NewReader(body))
+			if err != nil {
+				t.Fatalf("NewRequest() failed: %v", err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+			// an explicit Accept-Encoding disables the transparent decompression of the client
+			req.Header.Set("Accept-Encoding", "identity")
+			if compressed {
+				req.Header.Set("Accept-Encoding", "gzip")
+			}
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Do() failed: %v", err)
+			}
+			defer res.Body.Close() // nolint: errcheck
+
+			// net/http sets the Content-Length of bodies that fit its write buffer, as
+			// the compressed causes do
+			if !compressed && !reflect.DeepEqual(res.TransferEncoding, []string{"chunked"}) {
+				t.Fatalf("got transfer encoding %v want chunked", res.TransferEncoding)
+			}
+			var reader io.Reader = res.Body
+			if compressed {
+				if res.Header.Get("Content-Encoding") != "gzip" {
+					t.Fatalf("got Content-Encoding %q want gzip", res.Header.Get("Content-Encoding"))
+				}
+				gz, err := gzip.NewReader(res.Body)
+				if err != nil {
+					t.Fatalf("gzip.NewReader() failed: %v", err)
+				}
+				reader = gz
+			}
+			raw, err := ioutil.ReadAll(reader)
+			if err != nil {
+				t.Fatalf("could not read body: %v", err)
+			}
+
+			var got admissionv1beta1.AdmissionReview
+			if err := json.Unmarshal(raw, &got); err != nil {
+				t.Fatalf("could not decode response body: %v", err)
+			}
+			if got.Response == nil || got.Response.UID != "uid" {
+				t.Fatalf("got response %v want uid %q", got.Response, "uid")
+			}
+			if !reflect.DeepEqual(responseCauses(got.Response), responseCauses(response)) {
+				t.Fatalf("got causes %v want %v", responseCauses(got.Response), responseCauses(response))
+			}
+		})
+	}
+}
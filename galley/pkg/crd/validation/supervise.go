@@ -0,0 +1,150 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+
+	"istio.io/pkg/probe"
+)
+
+const (
+	// ComponentWebhook is the component that serves the validation webhook.
+	ComponentWebhook = "webhook"
+
+	// ComponentRegistration is the component that reconciles the validatingwebhookconfiguration.
+	ComponentRegistration = "registration"
+
+	componentErrorBufferSize = 100
+)
+
+// ComponentError is an error reported by a validation component started by StartValidation.
+type ComponentError struct {
+	// Component is ComponentWebhook or ComponentRegistration.
+	Component string
+	Err       error
+}
+
+func (e *ComponentError) Error() string {
+	return fmt.Sprintf("validation %s: %v", e.Component, e.Err)
+}
+
+// StartValidation serves the validation webhook and reconciles its configuration
+// in separate goroutines, as enabled by vc, until stopCh is closed. Unlike
+// MustRunValidation and ReconcileWebhookConfiguration, failures do not exit the
+// process. Each is sent as a *ComponentError on the returned channel, which is
+// closed once both components have stopped, and a failing component does not
+// stop the other:
+//
+//   - the webhook reports the error that stopped it from serving;
+//   - the registration retries the creation of its controller until it succeeds,
+//     and reports each failure to create, update or delete the configuration.
+//     It stops with an error if the webhook fails before it is ready, since the
+//     configuration would then never be registered.
+//
+// Errors are dropped if the channel is full, so that a slow consumer cannot
+// stall either component.
+func StartValidation(stopCh chan struct{}, vc *WebhookParameters, kubeInterface kubernetes.Interface,
+	kubeConfig string, livenessProbeController, readinessProbeController probe.Controller) <-chan error {
+
+	errCh := make(chan error, componentErrorBufferSize)
+	report := func(component string, err error) {
+		select {
+		case errCh <- &ComponentError{Component: component, Err: err}:
+		default:
+			scope.Warnf("Dropped validation %s error: %v", component, err)
+		}
+	}
+
+	webhookServerReady, webhookServerFailed := make(chan struct{}), make(chan struct{})
+	if !vc.EnableValidation {
+		close(webhookServerReady)
+	}
+
+	// the components set their own clientsets, so each gets a copy of the parameters
+	webhookParams, registrationParams := *vc, *vc
+
+	var wg sync.WaitGroup
+	if vc.EnableValidation {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := RunValidation(webhookServerReady, stopCh, &webhookParams, kubeInterface, kubeConfig,
+				livenessProbeController, readinessProbeController)
+			if err != nil {
+				report(ComponentWebhook, err)
+				close(webhookServerFailed)
+			}
+		}()
+	}
+	if vc.EnableReconcileWebhookConfiguration {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			superviseRegistration(webhookServerReady, webhookServerFailed, stopCh, &registrationParams, kubeConfig,
+				func(err error) { report(ComponentRegistration, err) })
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(errCh)
+	}()
+	return errCh
+}
+
+// superviseRegistration reconciles the webhook configuration once the webhook
+// server is ready, retrying the creation of the controller until it succeeds or
+// stopCh is closed. It reports an error and returns if the webhook server fails
+// before it is ready.
+func superviseRegistration(webhookServerReady, webhookServerFailed <-chan struct{}, stopCh <-chan struct{},
+	vc *WebhookParameters, kubeConfig string, report func(error)) {
+
+	var whc *WebhookConfigController
+	for {
+		var err error
+		if whc, err = createWebhookConfigController(vc, kubeConfig); err == nil {
+			break
+		}
+		scope.Errorf("%v - retrying in %v", err, retryUpdateAfterFailureTimeout)
+		report(err)
+		select {
+		case <-time.After(retryUpdateAfterFailureTimeout):
+		case <-stopCh:
+			return
+		}
+	}
+
+	whc.onError = report
+
+	// wait for the galley endpoint to be available before registering
+	select {
+	case <-webhookServerReady:
+	case <-webhookServerFailed:
+		whc.configWatcher.Close() // nolint: errcheck
+		scope.Error("Validation webhook server failed before it was ready, not registering its configuration")
+		report(errors.New("webhook server failed before it was ready"))
+		return
+	case <-stopCh:
+		whc.configWatcher.Close() // nolint: errcheck
+		return
+	}
+	whc.reconcile(stopCh)
+}
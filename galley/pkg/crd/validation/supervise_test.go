@@ -0,0 +1,164 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestComponentError(t *testing.T) {
+	err := &ComponentError{Component: ComponentRegistration, Err: errors.New("boom")}
+	if got, want := err.Error(), "validation registration: boom"; got != want {
+		t.Fatalf("got %q want %q", got, want)
+	}
+}
+
+func TestStartValidation_Disabled(t *testing.T) {
+	stop := make(chan struct{})
+	defer close(stop)
+
+	errCh := StartValidation(stop, &WebhookParameters{}, (*kubernetes.Clientset)(nil), "", nil, nil)
+	select {
+	case err, ok := <-errCh:
+		if ok {
+			t.Fatalf("got unexpected error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("error channel not closed")
+	}
+}
+
+func TestStartValidation_RegistrationErrors(t *testing.T) {
+	// the configuration files are missing, so the controller cannot be created
+	vc := &WebhookParameters{EnableReconcileWebhookConfiguration: true}
+
+	stop := make(chan struct{})
+	errCh := StartValidation(stop, vc, (*kubernetes.Clientset)(nil), "", nil, nil)
+
+	// the registration keeps retrying, so the error is reported until stopped
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errCh:
+			componentErr, ok := err.(*ComponentError)
+			if !ok || componentErr.Component != ComponentRegistration {
+				t.Fatalf("got error %v want a registration error", err)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatal("registration error not reported")
+		}
+	}
+
+	close(stop)
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case _, ok := <-errCh:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("error channel not closed after stop")
+		}
+	}
+}
+
+func TestStartValidation_WebhookFailsBeforeReady(t *testing.T) {
+	whc, cleanup := createTestWebhookConfigController(t,
+		fake.NewSimpleClientset(),
+		createFakeWebhookSource(),
+		dummyConfig)
+	defer cleanup()
+
+	// the apiserver is never reached, as the webhook fails before it is ready
+	kubeConfig := filepath.Join(filepath.Dir(whc.webhookParameters.WebhookConfigFile), "kubeconfig")
+	if err := ioutil.WriteFile(kubeConfig, []byte(`apiVersion: v1
+kind: Config
+clusters:
+- name: unreachable
+  cluster:
+    server: https://127.0.0.1:1
+contexts:
+- name: unreachable
+  context:
+    cluster: unreachable
+    user: unreachable
+current-context: unreachable
+users:
+- name: unreachable
+  user:
+    token: token
+`), 0644); err != nil {
+		t.Fatalf("WriteFile(%v) failed: %v", kubeConfig, err)
+	}
+
+	// the client CA file is missing, so the webhook cannot be created
+	vc := *whc.webhookParameters
+	vc.Clientset = nil
+	vc.ClientCAFile = filepath.Join(filepath.Dir(vc.WebhookConfigFile), "missing-ca-file.yaml")
+	vc.EnableValidation = true
+	vc.EnableReconcileWebhookConfiguration = true
+
+	stop := make(chan struct{})
+	defer close(stop)
+	errCh := StartValidation(stop, &vc, (*kubernetes.Clientset)(nil), kubeConfig, nil, nil)
+
+	got := map[string]bool{}
+	timeout := time.After(30 * time.Second)
+	for {
+		select {
+		case err, ok := <-errCh:
+			if !ok {
+				if !got[ComponentWebhook] || !got[ComponentRegistration] {
+					t.Fatalf("got errors of %v want errors of both components", got)
+				}
+				return
+			}
+			componentErr, ok := err.(*ComponentError)
+			if !ok {
+				t.Fatalf("got error %v want a *ComponentError", err)
+			}
+			got[componentErr.Component] = true
+		case <-timeout:
+			t.Fatalf("error channel not closed after the webhook failed, got errors of %v", got)
+		}
+	}
+}
+
+func TestWebhookConfigControllerReportsErrors(t *testing.T) {
+	whc, cleanup := createTestWebhookConfigController(t,
+		fake.NewSimpleClientset(),
+		createFakeWebhookSource(),
+		dummyConfig)
+	defer cleanup()
+
+	var got []error
+	whc.onError = func(err error) { got = append(got, err) }
+
+	whc.webhookParameters.WebhookConfigFile = ""
+	if err := whc.rebuildWebhookConfig(); err == nil {
+		t.Fatal("unexpected success: rebuildWebhookConfig() should have failed given invalid config files")
+	}
+	if len(got) != 1 || !strings.Contains(got[0].Error(), "(re)load failed") {
+		t.Fatalf("got errors %v want a single reload error", got)
+	}
+}
@@ -0,0 +1,223 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// readinessHistorySize is the number of readiness transitions kept for the support bundle.
+	readinessHistorySize = 20
+
+	// recentRejectionsSize is the number of rejections kept for the support bundle.
+	recentRejectionsSize = 10
+)
+
+// readinessTransition is a change of the readiness of the https handler.
+type readinessTransition struct {
+	Time   time.Time `json:"time"`
+	Ready  bool      `json:"ready"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+// readinessHistory keeps the latest readiness transitions.
+type readinessHistory struct {
+	mu          sync.Mutex
+	transitions []readinessTransition
+}
+
+func (h *readinessHistory) record(ready bool, err error, now time.Time) {
+	transition := readinessTransition{Time: now, Ready: ready}
+	if err != nil {
+		transition.Reason = err.Error()
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.transitions = append(h.transitions, transition)
+	if len(h.transitions) > readinessHistorySize {
+		h.transitions = h.transitions[len(h.transitions)-readinessHistorySize:]
+	}
+}
+
+func (h *readinessHistory) snapshot() []readinessTransition {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]readinessTransition{}, h.transitions...)
+}
+
+// kindDecisions counts the decisions for a kind.
+type kindDecisions struct {
+	Allowed  int64 `json:"allowed"`
+	Rejected int64 `json:"rejected"`
+}
+
+// rejection is a recent rejection. The error is redacted like the one of the
+// DecisionRecord.
+type rejection struct {
+	Time      time.Time `json:"time"`
+	Kind      string    `json:"kind"`
+	Namespace string    `json:"namespace,omitempty"`
+	Name      string    `json:"name,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// decisionStatsSnapshot is the JSON form of the decision stats.
+type decisionStatsSnapshot struct {
+	Since            time.Time                `json:"since"`
+	Kinds            map[string]kindDecisions `json:"kinds"`
+	RecentRejections []rejection              `json:"recentRejections"`
+}
+
+// decisionStats counts the decisions per kind since the webhook was created, and
+// keeps the latest rejections.
+type decisionStats struct {
+	mu         sync.Mutex
+	since      time.Time
+	kinds      map[string]kindDecisions
+	rejections []rejection
+}
+
+func newDecisionStats(now time.Time) *decisionStats {
+	return &decisionStats{since: now, kinds: make(map[string]kindDecisions)}
+}
+
+func (s *decisionStats) add(record DecisionRecord, now time.Time) {
+	kind := record.Kind.Kind
+	if record.Kind.Group != "" {
+		kind = fmt.Sprintf("%s.%s", record.Kind.Kind, record.Kind.Group)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	counts := s.kinds[kind]
+	if record.Allowed {
+		counts.Allowed++
+	} else {
+		counts.Rejected++
+		s.rejections = append(s.rejections, rejection{
+			Time:      now,
+			Kind:      kind,
+			Namespace: record.Namespace,
+			Name:      record.Name,
+			Error:     record.Error,
+		})
+		if len(s.rejections) > recentRejectionsSize {
+			s.rejections = s.rejections[len(s.rejections)-recentRejectionsSize:]
+		}
+	}
+	s.kinds[kind] = counts
+}
+
+func (s *decisionStats) snapshot() decisionStatsSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kinds := make(map[string]kindDecisions, len(s.kinds))
+	for kind, counts := range s.kinds {
+		kinds[kind] = counts
+	}
+	return decisionStatsSnapshot{
+		Since:            s.since,
+		Kinds:            kinds,
+		RecentRejections: append([]rejection{}, s.rejections...),
+	}
+}
+
+// redactedParameters returns the webhook parameters as listed by String, which
+// omits their credentials.
+func redactedParameters(p *WebhookParameters) map[string]string {
+	config := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSuffix(p.String(), "\n"), "\n") {
+		parts := strings.SplitN(line, ": ", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		config[parts[0]] = parts[1]
+	}
+	return config
+}
+
+// supportBundle is the JSON body returned by the support bundle endpoint.
+type supportBundle struct {
+	Time              time.Time             `json:"time"`
+	Config            map[string]string     `json:"config"`
+	ReadinessHistory  []readinessTransition `json:"readinessHistory"`
+	Cert              *certReloadStatus     `json:"cert,omitempty"`
+	CertError         string                `json:"certError,omitempty"`
+	ActiveRules       []string              `json:"activeRules"`
+	DisabledRules     []string              `json:"disabledRules"`
+	WebhookRules      []webhookRules        `json:"webhookRules,omitempty"`
+	WebhookRulesError string                `json:"webhookRulesError,omitempty"`
+	Decisions         decisionStatsSnapshot `json:"decisions"`
+}
+
+// certStatus returns the expiry of the cert served by the webhook.
+func (wh *Webhook) certStatus(now time.Time) (*certReloadStatus, error) {
+	wh.mu.RLock()
+	cert := wh.cert
+	wh.mu.RUnlock()
+	if cert == nil || len(cert.Certificate) == 0 {
+		return nil, fmt.Errorf("no cert loaded")
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("could not parse cert: %v", err)
+	}
+	return &certReloadStatus{
+		NotAfter:  leaf.NotAfter,
+		ExpiresIn: leaf.NotAfter.Sub(now).Round(time.Second).String(),
+	}, nil
+}
+
+// serveSupportBundle reports the configuration and state of the webhook in a
+// single document to attach to support requests: the redacted parameters, the
+// readiness transitions, the expiry of the cert, the validation and admission
+// rules in effect and the decisions made since the webhook was created.
+func (wh *Webhook) serveSupportBundle(w http.ResponseWriter, _ *http.Request) {
+	now := time.Now()
+	disabled, _ := wh.disabledRules.Load().(map[string]bool)
+	bundle := supportBundle{
+		Time:             now,
+		Config:           wh.supportConfig,
+		ReadinessHistory: wh.readinessHistory.snapshot(),
+		ActiveRules:      wh.activeRules(),
+		DisabledRules:    sortedRules(disabled),
+		Decisions:        wh.decisionStats.snapshot(),
+	}
+	cert, err := wh.certStatus(now)
+	if err != nil {
+		bundle.CertError = err.Error()
+	}
+	bundle.Cert = cert
+	rules, err := wh.webhookRules()
+	if err != nil {
+		bundle.WebhookRulesError = err.Error()
+	}
+	bundle.WebhookRules = rules
+
+	resp, err := json.Marshal(bundle)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("could not encode support bundle: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(resp) // nolint: errcheck
+}
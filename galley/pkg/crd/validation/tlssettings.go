@@ -0,0 +1,194 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/hashicorp/go-multierror"
+
+	networking "istio.io/api/networking/v1alpha3"
+)
+
+// tlsField is a TLS setting, set if its value is not empty.
+type tlsField struct {
+	name string
+	set  bool
+}
+
+// setFields returns the names of the fields that are set.
+func setFields(fields []tlsField) []string {
+	var names []string
+	for _, f := range fields {
+		if f.set {
+			names = append(names, f.name)
+		}
+	}
+	return names
+}
+
+// ignoredFieldsError returns an error naming the fields that are set but
+// ignored for the reason, if any.
+func ignoredFieldsError(path, reason string, fields []tlsField) error {
+	names := setFields(fields)
+	if len(names) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s: %s set but ignored as %s", path, strings.Join(names, ", "), reason)
+}
+
+// validateServerTLS checks that the TLS options of a gateway server are
+// consistent with their mode. The schema validation already requires the
+// certificates of SIMPLE and MUTUAL servers; this rejects the settings it
+// accepts but that silently have no effect.
+func validateServerTLS(path string, tls *networking.Server_TLSOptions) error {
+	if tls == nil {
+		return nil
+	}
+	var errs *multierror.Error
+	add := func(err error) {
+		if err != nil {
+			errs = multierror.Append(errs, err)
+		}
+	}
+
+	clientVerification := []tlsField{
+		{"caCertificates", tls.CaCertificates != ""},
+		{"subjectAltNames", len(tls.SubjectAltNames) > 0},
+		{"verifyCertificateSpki", len(tls.VerifyCertificateSpki) > 0},
+		{"verifyCertificateHash", len(tls.VerifyCertificateHash) > 0},
+	}
+	switch tls.Mode {
+	case networking.Server_TLSOptions_PASSTHROUGH, networking.Server_TLSOptions_AUTO_PASSTHROUGH:
+		add(ignoredFieldsError(path, fmt.Sprintf("%v mode does not terminate TLS", tls.Mode), append([]tlsField{
+			{"serverCertificate", tls.ServerCertificate != ""},
+			{"privateKey", tls.PrivateKey != ""},
+			{"credentialName", tls.CredentialName != ""},
+		}, clientVerification...)))
+	case networking.Server_TLSOptions_SIMPLE, networking.Server_TLSOptions_MUTUAL:
+		if tls.CredentialName != "" {
+			add(ignoredFieldsError(path, "credentialName takes precedence", []tlsField{
+				{"serverCertificate", tls.ServerCertificate != ""},
+				{"privateKey", tls.PrivateKey != ""},
+				{"caCertificates", tls.CaCertificates != ""},
+			}))
+		}
+		if tls.Mode == networking.Server_TLSOptions_SIMPLE {
+			add(ignoredFieldsError(path, "SIMPLE mode does not verify client certificates", clientVerification))
+		}
+	}
+
+	if tls.MinProtocolVersion != networking.Server_TLSOptions_TLS_AUTO &&
+		tls.MaxProtocolVersion != networking.Server_TLSOptions_TLS_AUTO &&
+		tls.MinProtocolVersion > tls.MaxProtocolVersion {
+		add(fmt.Errorf("%s: minProtocolVersion %v is greater than maxProtocolVersion %v",
+			path, tls.MinProtocolVersion, tls.MaxProtocolVersion))
+	}
+	return errs.ErrorOrNil()
+}
+
+// validateClientTLS checks that the TLS settings of a destination rule are
+// consistent with their mode. MUTUAL requires the CA that verifies the server,
+// otherwise the client certificate is presented to any server.
+func validateClientTLS(path string, tls *networking.TLSSettings) error {
+	if tls == nil {
+		return nil
+	}
+	var errs *multierror.Error
+	add := func(err error) {
+		if err != nil {
+			errs = multierror.Append(errs, err)
+		}
+	}
+
+	switch tls.Mode {
+	case networking.TLSSettings_DISABLE:
+		add(ignoredFieldsError(path, "DISABLE mode does not originate TLS", []tlsField{
+			{"clientCertificate", tls.ClientCertificate != ""},
+			{"privateKey", tls.PrivateKey != ""},
+			{"caCertificates", tls.CaCertificates != ""},
+			{"subjectAltNames", len(tls.SubjectAltNames) > 0},
+			{"sni", tls.Sni != ""},
+		}))
+	case networking.TLSSettings_ISTIO_MUTUAL:
+		add(ignoredFieldsError(path, "ISTIO_MUTUAL mode uses the Istio certificates", []tlsField{
+			{"clientCertificate", tls.ClientCertificate != ""},
+			{"privateKey", tls.PrivateKey != ""},
+			{"caCertificates", tls.CaCertificates != ""},
+		}))
+	case networking.TLSSettings_SIMPLE:
+		add(ignoredFieldsError(path, "SIMPLE mode does not present a client certificate", []tlsField{
+			{"clientCertificate", tls.ClientCertificate != ""},
+			{"privateKey", tls.PrivateKey != ""},
+		}))
+		if len(tls.SubjectAltNames) > 0 && tls.CaCertificates == "" {
+			add(fmt.Errorf("%s: subjectAltNames require caCertificates to verify the server certificate", path))
+		}
+	case networking.TLSSettings_MUTUAL:
+		if tls.CaCertificates == "" {
+			add(fmt.Errorf("%s: MUTUAL mode requires caCertificates to verify the server certificate", path))
+		}
+	}
+	return errs.ErrorOrNil()
+}
+
+// validateTrafficPolicyTLS checks the TLS settings of a traffic policy and its port level settings.
+func validateTrafficPolicyTLS(path string, policy *networking.TrafficPolicy) []error {
+	if policy == nil {
+		return nil
+	}
+	var errs []error
+	if err := validateClientTLS(path+".tls", policy.Tls); err != nil {
+		errs = append(errs, err)
+	}
+	for i, port := range policy.PortLevelSettings {
+		if port == nil {
+			continue
+		}
+		if err := validateClientTLS(fmt.Sprintf("%s.portLevelSettings[%d].tls", path, i), port.Tls); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// validateTLSSettings checks the TLS settings of the servers of Gateways, and
+// of the traffic policies of DestinationRules and their subsets.
+func validateTLSSettings(spec proto.Message) error {
+	var errs *multierror.Error
+	switch spec := spec.(type) {
+	case *networking.Gateway:
+		for i, server := range spec.Servers {
+			if server == nil {
+				continue
+			}
+			if err := validateServerTLS(fmt.Sprintf("servers[%d].tls", i), server.Tls); err != nil {
+				errs = multierror.Append(errs, err)
+			}
+		}
+	case *networking.DestinationRule:
+		errs = multierror.Append(errs, validateTrafficPolicyTLS("trafficPolicy", spec.TrafficPolicy)...)
+		for i, subset := range spec.Subsets {
+			if subset == nil {
+				continue
+			}
+			errs = multierror.Append(errs, validateTrafficPolicyTLS(fmt.Sprintf("subsets[%d].trafficPolicy", i),
+				subset.TrafficPolicy)...)
+		}
+	}
+	return errs.ErrorOrNil()
+}
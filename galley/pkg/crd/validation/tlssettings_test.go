@@ -0,0 +1,263 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gogo/protobuf/proto"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	networking "istio.io/api/networking/v1alpha3"
+
+	"istio.io/istio/pilot/test/mock"
+	"istio.io/istio/pkg/config/schema"
+	"istio.io/istio/pkg/config/schemas"
+)
+
+func TestValidateServerTLS(t *testing.T) {
+	cases := []struct {
+		name    string
+		tls     *networking.Server_TLSOptions
+		wantErr string
+	}{
+		{name: "no tls"},
+		{
+			name: "simple",
+			tls:  &networking.Server_TLSOptions{Mode: networking.Server_TLSOptions_SIMPLE, ServerCertificate: "cert.pem", PrivateKey: "key.pem"},
+		},
+		{
+			name: "mutual",
+			tls: &networking.Server_TLSOptions{Mode: networking.Server_TLSOptions_MUTUAL, ServerCertificate: "cert.pem",
+				PrivateKey: "key.pem", CaCertificates: "ca.pem", SubjectAltNames: []string{"client.example.com"}},
+		},
+		{
+			name: "simple with credentialName",
+			tls:  &networking.Server_TLSOptions{Mode: networking.Server_TLSOptions_SIMPLE, CredentialName: "ingress-cert"},
+		},
+		{name: "passthrough", tls: &networking.Server_TLSOptions{Mode: networking.Server_TLSOptions_PASSTHROUGH}},
+		{
+			name:    "passthrough with certificates",
+			tls:     &networking.Server_TLSOptions{Mode: networking.Server_TLSOptions_PASSTHROUGH, ServerCertificate: "cert.pem", PrivateKey: "key.pem"},
+			wantErr: "tls: serverCertificate, privateKey set but ignored as PASSTHROUGH mode does not terminate TLS",
+		},
+		{
+			name:    "auto passthrough with credentialName",
+			tls:     &networking.Server_TLSOptions{Mode: networking.Server_TLSOptions_AUTO_PASSTHROUGH, CredentialName: "ingress-cert"},
+			wantErr: "credentialName set but ignored as AUTO_PASSTHROUGH mode does not terminate TLS",
+		},
+		{
+			name: "credentialName and certificates",
+			tls: &networking.Server_TLSOptions{Mode: networking.Server_TLSOptions_SIMPLE, CredentialName: "ingress-cert",
+				ServerCertificate: "cert.pem", PrivateKey: "key.pem"},
+			wantErr: "serverCertificate, privateKey set but ignored as credentialName takes precedence",
+		},
+		{
+			name: "simple with client verification",
+			tls: &networking.Server_TLSOptions{Mode: networking.Server_TLSOptions_SIMPLE, ServerCertificate: "cert.pem",
+				PrivateKey: "key.pem", CaCertificates: "ca.pem", SubjectAltNames: []string{"client.example.com"}},
+			wantErr: "caCertificates, subjectAltNames set but ignored as SIMPLE mode does not verify client certificates",
+		},
+		{
+			name: "protocol versions reversed",
+			tls: &networking.Server_TLSOptions{Mode: networking.Server_TLSOptions_SIMPLE, CredentialName: "ingress-cert",
+				MinProtocolVersion: networking.Server_TLSOptions_TLSV1_3, MaxProtocolVersion: networking.Server_TLSOptions_TLSV1_2},
+			wantErr: "minProtocolVersion TLSV1_3 is greater than maxProtocolVersion TLSV1_2",
+		},
+		{
+			name: "only a minimum protocol version",
+			tls: &networking.Server_TLSOptions{Mode: networking.Server_TLSOptions_SIMPLE, CredentialName: "ingress-cert",
+				MinProtocolVersion: networking.Server_TLSOptions_TLSV1_2},
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			err := validateServerTLS("tls", c.tls)
+			if c.wantErr == "" {
+				if err != nil {
+					t.Fatalf("got unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+				t.Fatalf("got error %v want %q", err, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateClientTLS(t *testing.T) {
+	cases := []struct {
+		name    string
+		tls     *networking.TLSSettings
+		wantErr string
+	}{
+		{name: "no tls"},
+		{name: "disable", tls: &networking.TLSSettings{Mode: networking.TLSSettings_DISABLE}},
+		{name: "istio mutual", tls: &networking.TLSSettings{Mode: networking.TLSSettings_ISTIO_MUTUAL, Sni: "reviews.default"}},
+		{name: "simple", tls: &networking.TLSSettings{Mode: networking.TLSSettings_SIMPLE, Sni: "api.example.com"}},
+		{
+			name: "mutual",
+			tls: &networking.TLSSettings{Mode: networking.TLSSettings_MUTUAL, ClientCertificate: "cert.pem", PrivateKey: "key.pem",
+				CaCertificates: "ca.pem"},
+		},
+		{
+			name:    "mutual without CA",
+			tls:     &networking.TLSSettings{Mode: networking.TLSSettings_MUTUAL, ClientCertificate: "cert.pem", PrivateKey: "key.pem"},
+			wantErr: "tls: MUTUAL mode requires caCertificates to verify the server certificate",
+		},
+		{
+			name:    "disable with settings",
+			tls:     &networking.TLSSettings{Mode: networking.TLSSettings_DISABLE, CaCertificates: "ca.pem", Sni: "api.example.com"},
+			wantErr: "caCertificates, sni set but ignored as DISABLE mode does not originate TLS",
+		},
+		{
+			name:    "istio mutual with certificates",
+			tls:     &networking.TLSSettings{Mode: networking.TLSSettings_ISTIO_MUTUAL, ClientCertificate: "cert.pem", PrivateKey: "key.pem"},
+			wantErr: "clientCertificate, privateKey set but ignored as ISTIO_MUTUAL mode uses the Istio certificates",
+		},
+		{
+			name:    "simple with client certificate",
+			tls:     &networking.TLSSettings{Mode: networking.TLSSettings_SIMPLE, ClientCertificate: "cert.pem", CaCertificates: "ca.pem"},
+			wantErr: "clientCertificate set but ignored as SIMPLE mode does not present a client certificate",
+		},
+		{
+			name:    "simple with subjectAltNames without CA",
+			tls:     &networking.TLSSettings{Mode: networking.TLSSettings_SIMPLE, SubjectAltNames: []string{"api.example.com"}},
+			wantErr: "subjectAltNames require caCertificates",
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			err := validateClientTLS("tls", c.tls)
+			if c.wantErr == "" {
+				if err != nil {
+					t.Fatalf("got unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+				t.Fatalf("got error %v want %q", err, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateTLSSettings(t *testing.T) {
+	passthrough := &networking.Server_TLSOptions{Mode: networking.Server_TLSOptions_PASSTHROUGH, ServerCertificate: "cert.pem"}
+	disabled := &networking.TLSSettings{Mode: networking.TLSSettings_DISABLE, Sni: "api.example.com"}
+
+	cases := []struct {
+		name      string
+		spec      proto.Message
+		wantPaths []string
+	}{
+		{
+			name: "gateway",
+			spec: &networking.Gateway{Servers: []*networking.Server{
+				{Port: &networking.Port{Number: 80, Protocol: "HTTP", Name: "http"}},
+				{Port: &networking.Port{Number: 443, Protocol: "HTTPS", Name: "https"}, Tls: passthrough},
+			}},
+			wantPaths: []string{"servers[1].tls"},
+		},
+		{
+			name: "destination rule",
+			spec: &networking.DestinationRule{
+				TrafficPolicy: &networking.TrafficPolicy{
+					Tls: disabled,
+					PortLevelSettings: []*networking.TrafficPolicy_PortTrafficPolicy{
+						{Port: &networking.PortSelector{Number: 8443}, Tls: disabled},
+					},
+				},
+				Subsets: []*networking.Subset{
+					{Name: "v1"},
+					{Name: "v2", TrafficPolicy: &networking.TrafficPolicy{Tls: disabled}},
+				},
+			},
+			wantPaths: []string{"trafficPolicy.tls", "trafficPolicy.portLevelSettings[0].tls", "subsets[1].trafficPolicy.tls"},
+		},
+		{
+			name: "other kind",
+			spec: &networking.VirtualService{Hosts: []string{"reviews"}},
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			err := validateTLSSettings(c.spec)
+			if len(c.wantPaths) == 0 {
+				if err != nil {
+					t.Fatalf("got unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || strings.Count(err.Error(), "set but ignored") != len(c.wantPaths) {
+				t.Fatalf("got error %v want %d errors", err, len(c.wantPaths))
+			}
+			for _, path := range c.wantPaths {
+				if !strings.Contains(err.Error(), path+":") {
+					t.Fatalf("got error %v want an error for %v", err, path)
+				}
+			}
+		})
+	}
+}
+
+func TestAdmitPilotStrictTLSSettings(t *testing.T) {
+	wh, cancel := createTestWebhook(t, dummyClient, createFakeEndpointsSource(), dummyConfig)
+	defer cancel()
+	descriptor := append(append(schema.Set{}, schemas.Istio...), mock.Types...)
+	if err := wh.ReloadValidators(descriptor, wh.activeValidators().mixer); err != nil {
+		t.Fatalf("ReloadValidators() failed: %v", err)
+	}
+
+	dr := makeIstioKind(t, schemas.DestinationRule, "default", "api", &networking.DestinationRule{
+		Host: "api.example.com",
+		TrafficPolicy: &networking.TrafficPolicy{Tls: &networking.TLSSettings{
+			Mode:              networking.TLSSettings_MUTUAL,
+			ClientCertificate: "cert.pem",
+			PrivateKey:        "key.pem",
+		}},
+	})
+	raw, err := json.Marshal(&dr)
+	if err != nil {
+		t.Fatalf("Marshal(%v) failed: %v", dr.Name, err)
+	}
+	request := &admissionv1beta1.AdmissionRequest{
+		Kind:      metav1.GroupVersionKind{Kind: "DestinationRule"},
+		Namespace: "default",
+		Object:    runtime.RawExtension{Raw: raw},
+		Operation: admissionv1beta1.Create,
+	}
+
+	if resp := wh.admitPilot(context.Background(), request); !resp.Allowed {
+		t.Fatalf("got %v want the destination rule allowed", resp.Result)
+	}
+
+	wh.strictTLSSettings = true
+	const want = "trafficPolicy.tls: MUTUAL mode requires caCertificates"
+	resp := wh.admitPilot(context.Background(), request)
+	if resp.Allowed || !strings.Contains(resp.Result.Message, want) {
+		t.Fatalf("got %v want a rejection containing %q", resp.Result, want)
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"context"
+	"fmt"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubeschema "k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// PreValidateTransform rewrites the raw object of the given kind before it is
+// validated, e.g. to expand custom fields. The returned object is only
+// validated: the admitted object is unchanged.
+type PreValidateTransform func(gvk kubeschema.GroupVersionKind, obj []byte) ([]byte, error)
+
+// transformObject wraps an admitFunc so that the object of created and updated
+// objects is rewritten by the PreValidateTransform. A transform error rejects
+// the request.
+func (wh *Webhook) transformObject(admit admitFunc) admitFunc {
+	if wh.preValidateTransform == nil {
+		return admit
+	}
+	return func(ctx context.Context, request *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+		if len(request.Object.Raw) == 0 {
+			return admit(ctx, request)
+		}
+
+		gvk := kubeschema.GroupVersionKind{Group: request.Kind.Group, Version: request.Kind.Version, Kind: request.Kind.Kind}
+		raw, err := wh.preValidateTransform(gvk, request.Object.Raw)
+		if err != nil {
+			requestLog(ctx).Infof("cannot transform %s %s/%s: %v", request.Kind.Kind, request.Namespace, request.Name, err)
+			reportValidationFailed(request, reasonTransformError)
+			return toAdmissionResponse(fmt.Errorf("cannot transform configuration: %v", err))
+		}
+
+		transformed := *request
+		transformed.Object = runtime.RawExtension{Raw: raw}
+		return admit(ctx, &transformed)
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubeschema "k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestTransformObject(t *testing.T) {
+	original := []byte(`{"spec":{"sugar":true}}`)
+	expanded := []byte(`{"spec":{"expanded":true}}`)
+	wantGVK := kubeschema.GroupVersionKind{Group: "networking.istio.io", Version: "v1alpha3", Kind: "Gateway"}
+
+	cases := []struct {
+		name       string
+		transform  PreValidateTransform
+		operation  admissionv1beta1.Operation
+		object     []byte
+		wantObject []byte
+		wantErr    string
+	}{
+		{
+			name:       "no transform",
+			operation:  admissionv1beta1.Create,
+			object:     original,
+			wantObject: original,
+		},
+		{
+			name: "transformed",
+			transform: func(gvk kubeschema.GroupVersionKind, obj []byte) ([]byte, error) {
+				if gvk != wantGVK {
+					return nil, fmt.Errorf("got kind %v want %v", gvk, wantGVK)
+				}
+				return expanded, nil
+			},
+			operation:  admissionv1beta1.Update,
+			object:     original,
+			wantObject: expanded,
+		},
+		{
+			name: "transform error",
+			transform: func(kubeschema.GroupVersionKind, []byte) ([]byte, error) {
+				return nil, errors.New("unknown sugar")
+			},
+			operation: admissionv1beta1.Create,
+			object:    original,
+			wantErr:   "cannot transform configuration: unknown sugar",
+		},
+		{
+			name: "delete",
+			transform: func(kubeschema.GroupVersionKind, []byte) ([]byte, error) {
+				return nil, errors.New("transformed a delete")
+			},
+			operation: admissionv1beta1.Delete,
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			wh := &Webhook{preValidateTransform: c.transform}
+			var validated []byte
+			admit := wh.transformObject(func(_ context.Context, request *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+				validated = request.Object.Raw
+				return &admissionv1beta1.AdmissionResponse{Allowed: true}
+			})
+
+			request := &admissionv1beta1.AdmissionRequest{
+				Kind:      metav1.GroupVersionKind{Group: wantGVK.Group, Version: wantGVK.Version, Kind: wantGVK.Kind},
+				Operation: c.operation,
+				Object:    runtime.RawExtension{Raw: c.object},
+			}
+			resp := admit(context.Background(), request)
+			if c.wantErr != "" {
+				if resp.Allowed || !strings.Contains(resp.Result.Message, c.wantErr) {
+					t.Fatalf("got %v want rejection %q", resp.Result, c.wantErr)
+				}
+				return
+			}
+			if !resp.Allowed {
+				t.Fatalf("got rejection %v", resp.Result)
+			}
+			if !bytes.Equal(validated, c.wantObject) {
+				t.Fatalf("validated %s want %s", validated, c.wantObject)
+			}
+			if !bytes.Equal(request.Object.Raw, c.object) {
+				t.Fatalf("request object changed to %s", request.Object.Raw)
+			}
+		})
+	}
+}
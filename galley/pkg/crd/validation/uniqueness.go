@@ -0,0 +1,89 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/go-multierror"
+
+	"istio.io/istio/pilot/pkg/config/kube/crd"
+	"istio.io/istio/pkg/config/schema"
+	"istio.io/istio/pkg/config/schemas"
+)
+
+// kindLister lists the objects of a kind in all namespaces.
+type kindLister func(s schema.Instance) ([]crd.IstioKind, error)
+
+// listKind lists the objects of the kind in all namespaces from the API server.
+func (wh *Webhook) listKind(s schema.Instance) ([]crd.IstioKind, error) {
+	if wh.clientset == nil {
+		return nil, errors.New("no kubernetes client available")
+	}
+	restClient := wh.clientset.Discovery().RESTClient()
+	if restClient == nil {
+		return nil, errors.New("no kubernetes REST client available")
+	}
+
+	raw, err := restClient.Get().
+		AbsPath("/apis", crd.ResourceGroup(&s), s.Version, crd.ResourceName(s.Plural)).
+		DoRaw()
+	if err != nil {
+		return nil, err
+	}
+
+	var list crd.IstioKindList
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// validateGlobalNameKinds returns an error naming each kind that is not an
+// Istio kind, or if no kinds are listed.
+func validateGlobalNameKinds(kinds []string) error {
+	if len(kinds) == 0 {
+		return errors.New("global name uniqueness requires at least one kind")
+	}
+	var errs *multierror.Error
+	for _, kind := range kinds {
+		if _, ok := schemas.Istio.GetByType(crd.CamelCaseToKebabCase(kind)); !ok {
+			errs = multierror.Append(errs, fmt.Errorf("invalid global name uniqueness kind %q", kind))
+		}
+	}
+	return errs.ErrorOrNil()
+}
+
+// nameCollisions returns the sorted namespace/name of the objects of the kind
+// with the name in other namespaces. The check is best-effort: an object with
+// the same name created concurrently in another namespace is not observed.
+func (wh *Webhook) nameCollisions(s schema.Instance, namespace, name string) ([]string, error) {
+	items, err := wh.kindLister(s)
+	if err != nil {
+		return nil, err
+	}
+
+	var collisions []string
+	for i := range items {
+		if items[i].Name == name && items[i].Namespace != namespace {
+			collisions = append(collisions, items[i].Namespace+"/"+items[i].Name)
+		}
+	}
+	sort.Strings(collisions)
+	return collisions, nil
+}
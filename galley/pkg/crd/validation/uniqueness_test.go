@@ -0,0 +1,170 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	networking "istio.io/api/networking/v1alpha3"
+
+	"istio.io/istio/pilot/pkg/config/kube/crd"
+	"istio.io/istio/pilot/test/mock"
+	"istio.io/istio/pkg/config/schema"
+	"istio.io/istio/pkg/config/schemas"
+)
+
+func TestValidateGlobalNameKinds(t *testing.T) {
+	cases := []struct {
+		name    string
+		kinds   []string
+		wantErr string
+	}{
+		{name: "istio kinds", kinds: []string{"Gateway", "VirtualService"}},
+		{name: "no kinds", wantErr: "requires at least one kind"},
+		{name: "unknown kind", kinds: []string{"Gateway", "Deployment"}, wantErr: `invalid global name uniqueness kind "Deployment"`},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			err := validateGlobalNameKinds(c.kinds)
+			if c.wantErr == "" {
+				if err != nil {
+					t.Fatalf("got unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+				t.Fatalf("got error %v want %q", err, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestAdmitPilotGlobalNameUniqueness(t *testing.T) {
+	gateway := func(namespace string) crd.IstioKind {
+		return makeIstioKind(t, schemas.Gateway, namespace, "ingress", &networking.Gateway{
+			Servers: []*networking.Server{{
+				Port:  &networking.Port{Number: 80, Protocol: "HTTP", Name: "http"},
+				Hosts: []string{"*"},
+			}},
+		})
+	}
+
+	wh, cancel := createTestWebhook(t, dummyClient, createFakeEndpointsSource(), dummyConfig)
+	defer cancel()
+	descriptor := append(append(schema.Set{}, schemas.Istio...), mock.Types...)
+	if err := wh.ReloadValidators(descriptor, wh.activeValidators().mixer); err != nil {
+		t.Fatalf("ReloadValidators() failed: %v", err)
+	}
+
+	cases := []struct {
+		name          string
+		kinds         map[string]bool
+		operation     admissionv1beta1.Operation
+		items         []crd.IstioKind
+		listErr       error
+		allowed       bool
+		internalError bool
+		collisions    []string
+	}{
+		{
+			name:      "check disabled",
+			operation: admissionv1beta1.Create,
+			items:     []crd.IstioKind{gateway("istio-system")},
+			allowed:   true,
+		},
+		{
+			name:      "other kind",
+			kinds:     map[string]bool{"VirtualService": true},
+			operation: admissionv1beta1.Create,
+			items:     []crd.IstioKind{gateway("istio-system")},
+			allowed:   true,
+		},
+		{
+			name:      "unique name",
+			kinds:     map[string]bool{"Gateway": true},
+			operation: admissionv1beta1.Create,
+			items:     []crd.IstioKind{gateway("default")},
+			allowed:   true,
+		},
+		{
+			name:       "name used in other namespaces",
+			kinds:      map[string]bool{"Gateway": true},
+			operation:  admissionv1beta1.Create,
+			items:      []crd.IstioKind{gateway("istio-system"), gateway("default"), gateway("bookinfo")},
+			collisions: []string{"bookinfo/ingress", "istio-system/ingress"},
+		},
+		{
+			name:      "update",
+			kinds:     map[string]bool{"Gateway": true},
+			operation: admissionv1beta1.Update,
+			items:     []crd.IstioKind{gateway("istio-system")},
+			allowed:   true,
+		},
+		{
+			name:          "list error",
+			kinds:         map[string]bool{"Gateway": true},
+			operation:     admissionv1beta1.Create,
+			listErr:       errors.New("forbidden"),
+			internalError: true,
+		},
+	}
+
+	object := gateway("default")
+	raw, err := json.Marshal(&object)
+	if err != nil {
+		t.Fatalf("Marshal(%v) failed: %v", object.Name, err)
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			wh.globalNameKinds = c.kinds
+			wh.kindLister = func(s schema.Instance) ([]crd.IstioKind, error) {
+				if s.Type != schemas.Gateway.Type {
+					t.Fatalf("listed %v want %v", s.Type, schemas.Gateway.Type)
+				}
+				return c.items, c.listErr
+			}
+
+			got := wh.admitPilot(context.Background(), &admissionv1beta1.AdmissionRequest{
+				Kind:      metav1.GroupVersionKind{Kind: "Gateway"},
+				Namespace: "default",
+				Name:      object.Name,
+				Object:    runtime.RawExtension{Raw: raw},
+				Operation: c.operation,
+			})
+			if got.Allowed != c.allowed {
+				t.Fatalf("got %v want %v: %v", got.Allowed, c.allowed, got.Result)
+			}
+			if isInternalError(got) != c.internalError {
+				t.Fatalf("got internal error %v want %v: %v", isInternalError(got), c.internalError, got.Result)
+			}
+			for _, name := range c.collisions {
+				if !strings.Contains(got.Result.Message, name) {
+					t.Fatalf("response %q does not name colliding object %v", got.Result.Message, name)
+				}
+			}
+		})
+	}
+}
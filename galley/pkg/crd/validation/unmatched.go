@@ -0,0 +1,70 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"context"
+	"fmt"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+)
+
+// UnmatchedDecision is the response to admission requests for objects of a
+// kind the webhook has no validation for, e.g. a kind added to the webhook
+// rules but not to the pilot schemas. If it is not set, pilot objects of
+// unmatched kinds are rejected as unrecognized, and mixer objects of kinds the
+// mixer validator does not support are admitted once their fields are checked,
+// so accepting pilot objects of unmatched kinds is an explicit opt-in.
+type UnmatchedDecision string
+
+const (
+	// UnmatchedAccept admits objects of unmatched kinds.
+	UnmatchedAccept UnmatchedDecision = "accept"
+
+	// UnmatchedReject rejects objects of unmatched kinds.
+	UnmatchedReject UnmatchedDecision = "reject"
+)
+
+// validate returns an error if the decision is unknown. An empty decision is the
+// default of each admission path.
+func (d UnmatchedDecision) validate() error {
+	switch d {
+	case "", UnmatchedAccept, UnmatchedReject:
+		return nil
+	default:
+		return fmt.Errorf("invalid default decision for unmatched objects %q, want %q or %q",
+			d, UnmatchedAccept, UnmatchedReject)
+	}
+}
+
+// admitUnmatched responds to a request for an object of a kind the webhook has
+// no validation for according to the DefaultDecisionForUnmatched, or to the
+// default decision of the admission path if it is not set.
+func (wh *Webhook) admitUnmatched(ctx context.Context, request *admissionv1beta1.AdmissionRequest,
+	kind string, defaultDecision UnmatchedDecision) *admissionv1beta1.AdmissionResponse {
+
+	decision := wh.defaultDecisionForUnmatched
+	if decision == "" {
+		decision = defaultDecision
+	}
+	if decision != UnmatchedAccept {
+		requestLog(ctx).Infof("Rejecting %s %s/%s: no validation matches its kind", kind, request.Namespace, request.Name)
+		reportValidationFailed(request, reasonUnknownType)
+		return toAdmissionResponse(fmt.Errorf("unrecognized type %v", kind))
+	}
+	requestLog(ctx).Infof("Accepting %s %s/%s: no validation matches its kind", kind, request.Namespace, request.Name)
+	reportValidationPass(request)
+	return wh.acceptResponse()
+}
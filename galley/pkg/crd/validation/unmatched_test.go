@@ -0,0 +1,120 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// kindValidator is a mixer validator supporting only some kinds.
+type kindValidator struct {
+	fakeValidator
+	kinds map[string]bool
+}
+
+func (v *kindValidator) SupportsKind(kind string) bool {
+	return v.kinds[kind]
+}
+
+func TestAdmitUnmatched(t *testing.T) {
+	wh, cancel := createTestWebhook(t, dummyClient, createFakeEndpointsSource(), dummyConfig)
+	defer cancel()
+	setMixerValidator(t, wh, &kindValidator{kinds: map[string]bool{"mock": true}})
+	gadget := []byte(`{"apiVersion": "example.com/v1", "kind": "Gadget", "metadata": {"name": "g"}, "spec": {}}`)
+
+	cases := []struct {
+		name        string
+		decision    UnmatchedDecision
+		admit       admitFunc
+		kind        string
+		raw         []byte
+		wantAllowed bool
+		wantMessage string
+	}{
+		{name: "pilot default", admit: wh.admitPilot, kind: "Gadget", raw: gadget},
+		{name: "pilot accept", decision: UnmatchedAccept, admit: wh.admitPilot, kind: "Gadget", raw: gadget, wantAllowed: true},
+		{name: "pilot reject", decision: UnmatchedReject, admit: wh.admitPilot, kind: "Gadget", raw: gadget},
+		{
+			name:        "pilot matched",
+			decision:    UnmatchedReject,
+			admit:       wh.admitPilot,
+			kind:        "mock",
+			raw:         makePilotConfig(t, 0, true, false),
+			wantAllowed: true,
+		},
+		{name: "mixer default", admit: wh.admitMixer, kind: "Gadget", raw: gadget, wantAllowed: true},
+		{name: "mixer reject", decision: UnmatchedReject, admit: wh.admitMixer, kind: "Gadget", raw: gadget},
+		{
+			name:        "mixer unknown fields",
+			admit:       wh.admitMixer,
+			kind:        "Gadget",
+			raw:         []byte(`{"apiVersion": "example.com/v1", "kind": "Gadget", "metadata": {"name": "g"}, "spec": {}, "extra": 1}`),
+			wantMessage: `unknown field "extra"`,
+		},
+		{
+			name:        "mixer matched",
+			decision:    UnmatchedReject,
+			admit:       wh.admitMixer,
+			kind:        "mock",
+			raw:         makeMixerConfig(t, 0, false),
+			wantAllowed: true,
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			wh.defaultDecisionForUnmatched = c.decision
+			resp := c.admit(context.Background(), &admissionv1beta1.AdmissionRequest{
+				Kind:      metav1.GroupVersionKind{Kind: c.kind},
+				Name:      "unmatched",
+				Object:    runtime.RawExtension{Raw: c.raw},
+				Operation: admissionv1beta1.Create,
+			})
+			if resp.Allowed != c.wantAllowed {
+				t.Fatalf("got allowed %v want %v: %v", resp.Allowed, c.wantAllowed, resp.Result)
+			}
+			wantMessage := c.wantMessage
+			if wantMessage == "" {
+				wantMessage = "unrecognized type " + c.kind
+			}
+			if !c.wantAllowed && !strings.Contains(resp.Result.Message, wantMessage) {
+				t.Fatalf("got message %q want %q", resp.Result.Message, wantMessage)
+			}
+		})
+	}
+}
+
+func TestAdmitUnmatchedDefaultArgs(t *testing.T) {
+	wh, cancel := createTestWebhook(t, dummyClient, createFakeEndpointsSource(), dummyConfig)
+	defer cancel()
+
+	// unknown pilot kinds are rejected unless accepting them is opted in
+	wh.defaultDecisionForUnmatched = DefaultArgs().DefaultDecisionForUnmatched
+	resp := wh.admitPilot(context.Background(), &admissionv1beta1.AdmissionRequest{
+		Kind:      metav1.GroupVersionKind{Kind: "Gadget"},
+		Object:    runtime.RawExtension{Raw: []byte(`{"apiVersion": "example.com/v1", "kind": "Gadget", "metadata": {"name": "g"}}`)},
+		Operation: admissionv1beta1.Create,
+	})
+	if resp.Allowed || !strings.Contains(resp.Result.Message, "unrecognized type Gadget") {
+		t.Fatalf("got %v want the unknown kind rejected as unrecognized", resp.Result)
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/ghodss/yaml"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubeyaml "k8s.io/apimachinery/pkg/util/yaml"
+
+	"istio.io/istio/pilot/pkg/config/kube/crd"
+	"istio.io/istio/pkg/config/schema"
+)
+
+// ObjectValidationResult is the result of the validation of a document by ValidateYAML.
+type ObjectValidationResult struct {
+	// Index of the document in the YAML, from 0.
+	Index int
+
+	// APIVersion, Kind, Namespace and Name of the object, as set in the document.
+	APIVersion string
+	Kind       string
+	Namespace  string
+	Name       string
+
+	// Valid is true if the object would be admitted by the webhook. Skipped
+	// documents are valid.
+	Valid bool
+
+	// Errors are the reasons the object would be rejected, one per offending
+	// field if ReportAllErrors is set.
+	Errors []string
+
+	// ViolationCodes are the codes of the failed checks, see ViolationCodes.
+	ViolationCodes []string
+
+	// Skipped is the reason the document was not validated, e.g. because it is
+	// empty or not of a kind validated by the webhook.
+	Skipped string
+}
+
+// ValidateYAML validates each document of the multi-document YAML with the
+// validators and checks of the webhook, as if the objects were created, without
+// going through the admission handlers. It returns an error only if the YAML
+// cannot be split into documents. The rejections are reported in the metrics
+// like those of admission requests.
+func (wh *Webhook) ValidateYAML(data []byte) ([]ObjectValidationResult, error) {
+	reader := kubeyaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(data)))
+	var results []ObjectValidationResult
+	for index := 0; ; index++ {
+		doc, err := reader.Read()
+		if err == io.EOF {
+			return results, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot read document %d: %v", index, err)
+		}
+		results = append(results, wh.validateDocument(index, doc))
+	}
+}
+
+// validateDocument validates a document of ValidateYAML.
+func (wh *Webhook) validateDocument(index int, doc []byte) ObjectValidationResult {
+	result := ObjectValidationResult{Index: index}
+
+	raw, err := yaml.YAMLToJSON(doc)
+	if err != nil {
+		result.Errors = []string{fmt.Sprintf("cannot decode document: %v", err)}
+		return result
+	}
+	if len(bytes.TrimSpace(raw)) == 0 || bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+		result.Valid, result.Skipped = true, "empty document"
+		return result
+	}
+	var obj metav1.PartialObjectMetadata
+	if err := yaml.Unmarshal(raw, &obj); err != nil {
+		result.Errors = []string{fmt.Sprintf("cannot decode document: %v", err)}
+		return result
+	}
+	result.APIVersion, result.Kind = obj.APIVersion, obj.Kind
+	result.Namespace, result.Name = obj.Namespace, obj.Name
+	if obj.Kind == "" {
+		result.Valid, result.Skipped = true, "no kind"
+		return result
+	}
+
+	gvk := obj.GroupVersionKind()
+	request := &admissionv1beta1.AdmissionRequest{
+		Kind:      metav1.GroupVersionKind{Group: gvk.Group, Version: gvk.Version, Kind: gvk.Kind},
+		Name:      obj.Name,
+		Namespace: obj.Namespace,
+		Operation: admissionv1beta1.Create,
+		Object:    runtime.RawExtension{Raw: raw},
+	}
+
+	admit, ok := wh.routeObject(request)
+	if !ok {
+		result.Valid, result.Skipped = true, fmt.Sprintf("%s is not validated by the webhook", obj.Kind)
+		return result
+	}
+
+	response := wh.validateWith(admit)(context.Background(), request)
+	if response == nil {
+		result.Errors = []string{"no admission response"}
+		return result
+	}
+	result.Valid = response.Allowed
+	if response.Allowed || response.Result == nil {
+		return result
+	}
+	if response.Result.Details != nil {
+		for _, cause := range response.Result.Details.Causes {
+			if cause.Type == CauseTypeViolationCode {
+				result.ViolationCodes = append(result.ViolationCodes, cause.Message)
+			} else {
+				result.Errors = append(result.Errors, fmt.Sprintf("%s: %s", cause.Field, cause.Message))
+			}
+		}
+	}
+	if len(result.Errors) == 0 {
+		result.Errors = []string{response.Result.Message}
+	}
+	return result
+}
+
+// routeObject returns the admit function validating the object of the request
+// outside of the admission handlers, i.e. admitPilot for the pilot schemas in
+// their API group and the kinds with a JSON schema, and admitMixer for the kinds
+// of the mixer validator. It sets the Resource of the request to that of the pilot
+// schema. It returns false if the webhook does not validate the kind.
+func (wh *Webhook) routeObject(request *admissionv1beta1.AdmissionRequest) (admitFunc, bool) {
+	apiVersion := request.Kind.Version
+	if request.Kind.Group != "" {
+		apiVersion = request.Kind.Group + "/" + apiVersion
+	}
+	if s, ok := wh.lookupSchema(apiVersion, request.Kind.Kind); ok && wh.inSchemaGroup(s, request.Kind.Group) {
+		resource := resourceOf(s)
+		request.Resource = metav1.GroupVersionResource{Group: resource.Group, Version: resource.Version, Resource: resource.Resource}
+		return wh.admitPilot, true
+	}
+	if wh.hasJSONSchema(request) {
+		return wh.admitPilot, true
+	}
+	if mixer := wh.activeValidators().mixer; mixer != nil && mixer.SupportsKind(request.Kind.Kind) {
+		return wh.admitMixer, true
+	}
+	return nil, false
+}
+
+// inSchemaGroup returns true if the API group is that of the schema, or an alias of it.
+func (wh *Webhook) inSchemaGroup(s schema.Instance, group string) bool {
+	canonical, ok := wh.groupAliases[group]
+	if !ok {
+		canonical = group
+	}
+	return canonical == crd.ResourceGroup(&s)
+}
@@ -0,0 +1,130 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"istio.io/istio/pkg/config/schemas"
+)
+
+func TestValidateYAML(t *testing.T) {
+	wh, cancel := createTestWebhook(t, dummyClient, createFakeEndpointsSource(), dummyConfig)
+	defer cancel()
+	if err := wh.ReloadValidators(schemas.Istio, &kindValidator{
+		fakeValidator: fakeValidator{err: errors.New("invalid rule")},
+		kinds:         map[string]bool{"rule": true},
+	}); err != nil {
+		t.Fatalf("ReloadValidators() failed: %v", err)
+	}
+
+	data := []byte(`apiVersion: networking.istio.io/v1alpha3
+kind: Gateway
+metadata:
+  name: valid
+  namespace: default
+spec:
+  selector:
+    istio: ingressgateway
+  servers:
+  - port: {number: 80, protocol: HTTP, name: http}
+    hosts: ["example.com"]
+---
+apiVersion: networking.istio.io/v1alpha3
+kind: Gateway
+metadata:
+  name: invalid
+  namespace: default
+spec:
+  servers:
+  - hosts: ["example.com"]
+---
+# only a comment
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+---
+apiVersion: gateway.example.com/v1
+kind: Gateway
+metadata:
+  name: other
+---
+apiVersion: config.istio.io/v1alpha2
+kind: rule
+metadata:
+  name: rule
+  namespace: istio-system
+spec: {}
+---
+kind: [
+`)
+	results, err := wh.ValidateYAML(data)
+	if err != nil {
+		t.Fatalf("ValidateYAML() failed: %v", err)
+	}
+
+	cases := []struct {
+		name        string
+		wantKind    string
+		wantName    string
+		wantValid   bool
+		wantError   string
+		wantSkipped string
+	}{
+		{name: "valid gateway", wantKind: "Gateway", wantName: "valid", wantValid: true},
+		{name: "invalid gateway", wantKind: "Gateway", wantName: "invalid", wantError: "port is required"},
+		{name: "empty document", wantValid: true, wantSkipped: "empty document"},
+		{name: "kubernetes kind", wantKind: "Deployment", wantName: "app", wantValid: true, wantSkipped: "not validated"},
+		{name: "kind of another group", wantKind: "Gateway", wantName: "other", wantValid: true, wantSkipped: "not validated"},
+		{name: "mixer kind", wantKind: "rule", wantName: "rule", wantError: "invalid rule"},
+		{name: "undecodable document", wantError: "cannot decode document"},
+	}
+	if len(results) != len(cases) {
+		t.Fatalf("got %d results want %d: %+v", len(results), len(cases), results)
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			got := results[i]
+			if got.Index != i || got.Kind != c.wantKind || got.Name != c.wantName {
+				t.Fatalf("got document %d %s %q want %d %s %q", got.Index, got.Kind, got.Name, i, c.wantKind, c.wantName)
+			}
+			if got.Valid != c.wantValid {
+				t.Fatalf("got valid %v want %v: %v", got.Valid, c.wantValid, got.Errors)
+			}
+			if !strings.Contains(got.Skipped, c.wantSkipped) || (c.wantSkipped == "") != (got.Skipped == "") {
+				t.Fatalf("got skipped %q want %q", got.Skipped, c.wantSkipped)
+			}
+			if c.wantError == "" {
+				if len(got.Errors) != 0 {
+					t.Fatalf("got unexpected errors %v", got.Errors)
+				}
+				return
+			}
+			if len(got.Errors) == 0 || !strings.Contains(strings.Join(got.Errors, "; "), c.wantError) {
+				t.Fatalf("got errors %v want %q", got.Errors, c.wantError)
+			}
+		})
+	}
+
+	if results[1].ViolationCodes == nil || results[1].ViolationCodes[0] != "IST0101" {
+		t.Fatalf("got violation codes %v want the schema violation", results[1].ViolationCodes)
+	}
+}
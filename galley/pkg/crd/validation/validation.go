@@ -15,22 +15,33 @@
 package validation
 
 import (
+	"context"
 	"crypto/tls"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"net/url"
 	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8svalidation "k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 
 	"github.com/hashicorp/go-multierror"
 
 	"istio.io/pkg/log"
 	"istio.io/pkg/probe"
+	buildversion "istio.io/pkg/version"
 
+	"istio.io/istio/mixer/pkg/config/store"
 	mixervalidate "istio.io/istio/mixer/pkg/validate"
+	"istio.io/istio/pkg/config/schema"
 	"istio.io/istio/pkg/config/schemas"
 	"istio.io/istio/pkg/kube"
 )
@@ -38,26 +49,67 @@ import (
 const (
 	dns1123LabelMaxLength int    = 63
 	dns1123LabelFmt       string = "[a-zA-Z0-9]([-a-z-A-Z0-9]*[a-zA-Z0-9])?"
+)
+
+var (
+	dns1123LabelRegexp = regexp.MustCompile("^" + dns1123LabelFmt + "$")
 
+	// httpsHandlerReadinessFreq is the interval between readiness checks. Overridden by tests.
 	httpsHandlerReadinessFreq = time.Second
 )
 
-var dns1123LabelRegexp = regexp.MustCompile("^" + dns1123LabelFmt + "$")
-
 // This is for lint fix
 type httpClient interface {
 	Do(req *http.Request) (*http.Response, error)
 }
 
+// readinessTLSConfig returns the TLS config of the readiness client. The client
+// certificate, if any, is reloaded for each connection to pick up rotated certs.
+func readinessTLSConfig(vc *WebhookParameters) *tls.Config {
+	config := &tls.Config{
+		InsecureSkipVerify: true,
+	}
+	if vc.ReadinessClientCertFile != "" {
+		certFile, keyFile := vc.ReadinessClientCertFile, vc.ReadinessClientKeyFile
+		config.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+			if err != nil {
+				return nil, fmt.Errorf("could not load readiness client cert: %v", err)
+			}
+			return &pair, nil
+		}
+	}
+	return config
+}
+
 func webhookHTTPSHandlerReady(client httpClient, vc *WebhookParameters) error {
+	return httpsHandlerReady(client, readinessHost(vc), vc.ReadinessHTTPMethod)
+}
+
+// readinessHost returns the host the readiness check connects to: the bind
+// address if the admission server listens on a single address, otherwise localhost.
+func readinessHost(vc *WebhookParameters) string {
+	host := "localhost"
+	if ip := net.ParseIP(vc.BindAddress); ip != nil && !ip.IsUnspecified() {
+		host = vc.BindAddress
+	}
+	return net.JoinHostPort(host, strconv.Itoa(int(vc.Port)))
+}
+
+// httpsHandlerReady checks the readiness endpoint of the webhook https handler at
+// the host with the HTTP method, GET if it is empty.
+func httpsHandlerReady(client httpClient, host, method string) error {
+	if method == "" {
+		method = http.MethodGet
+	}
 	readinessURL := &url.URL{
 		Scheme: "https",
-		Host:   fmt.Sprintf("localhost:%v", vc.Port),
+		Host:   host,
 		Path:   httpsHandlerReadyPath,
 	}
 
 	req := &http.Request{
-		Method: http.MethodGet,
+		Method: method,
 		URL:    readinessURL,
 	}
 
@@ -67,17 +119,141 @@ func webhookHTTPSHandlerReady(client httpClient, vc *WebhookParameters) error {
 	}
 	defer response.Body.Close()
 	if response.StatusCode != http.StatusOK {
-		return fmt.Errorf("GET %v returned non-200 status=%v",
-			readinessURL, response.StatusCode)
+		var status readinessStatus
+		if err := json.NewDecoder(response.Body).Decode(&status); err == nil && status.Reason != "" {
+			return fmt.Errorf("%s %v returned non-200 status=%v: %v",
+				method, readinessURL, response.StatusCode, status.Reason)
+		}
+		return fmt.Errorf("%s %v returned non-200 status=%v",
+			method, readinessURL, response.StatusCode)
 	}
 	return nil
 }
 
-//RunValidation start running Galley validation mode
-func RunValidation(ready chan<- struct{}, stopCh chan struct{}, vc *WebhookParameters,
+// ValidatorSet is the pilot descriptor and mixer validator the webhook admits
+// requests with, see PilotDescriptor and MixerValidator of WebhookParameters.
+type ValidatorSet struct {
+	PilotDescriptor schema.Set
+
+	// MixerValidator is nil if mixer validation is disabled.
+	MixerValidator store.BackendValidator
+}
+
+// InitValidators builds the validators configured by vc. Errors are returned
+// rather than being fatal so they can be reported through readiness. The set is
+// usable even then: the built-in pilot schemas replace a snapshot that cannot
+// be loaded, and the mixer validator is nil if it cannot be created.
+func InitValidators(vc *WebhookParameters) (ValidatorSet, error) {
+	var (
+		set  = ValidatorSet{PilotDescriptor: schemas.Istio}
+		errs *multierror.Error
+	)
+
+	if vc.SchemaSnapshotFile != "" {
+		descriptor, err := loadSchemaSnapshot(vc.SchemaSnapshotFile, schemas.Istio)
+		if err != nil {
+			errs = multierror.Append(errs, err)
+		} else {
+			set.PilotDescriptor = descriptor
+		}
+	}
+	if err := set.PilotDescriptor.Validate(); err != nil {
+		errs = multierror.Append(errs, fmt.Errorf("invalid pilot schema: %v", err))
+	}
+
+	if vc.EnableMixerValidation {
+		mixerValidator, err := newMixerValidator()
+		if err != nil {
+			errs = multierror.Append(errs, err)
+		} else {
+			set.MixerValidator = mixerValidator
+		}
+	}
+
+	return set, errs.ErrorOrNil()
+}
+
+func newMixerValidator() (validator store.BackendValidator, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("could not create mixer validator: %v", r)
+		}
+	}()
+	return mixervalidate.NewDefaultValidator(false), nil
+}
+
+// WatchReadiness checks the readiness of the webhook https handler every
+// httpsHandlerReadinessFreq until the context is done. onChange is called with the
+// result of the first check, and then whenever the handler becomes ready, becomes
+// not ready, or the reason it is not ready changes. Transitions are logged.
+func WatchReadiness(ctx context.Context, client httpClient, vc *WebhookParameters, onChange func(bool, error)) {
+	var (
+		checked       bool
+		ready         bool
+		reason        string
+		lastHeartbeat time.Time
+	)
+	for {
+		now := time.Now()
+		err := webhookHTTPSHandlerReady(client, vc)
+		if err == nil && vc.CheckServiceReachability {
+			err = serviceReachable(client, vc)
+		}
+		if err != nil {
+			if !checked || ready || err.Error() != reason {
+				scope.Infof("https handler for validation webhook is not ready: %v\n", err)
+				onChange(false, err)
+			}
+			ready, reason = false, err.Error()
+		} else {
+			if !checked || !ready {
+				scope.Info("https handler for validation webhook is ready\n")
+				onChange(true, nil)
+				lastHeartbeat = now
+			} else if heartbeatDue(vc.ReadinessHeartbeatInterval, lastHeartbeat, now) {
+				scope.Infof("https handler for validation webhook is ready (heartbeat every %v)\n",
+					vc.ReadinessHeartbeatInterval)
+				lastHeartbeat = now
+			}
+			ready, reason = true, ""
+		}
+		checked = true
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(httpsHandlerReadinessFreq):
+			// check again
+		}
+	}
+}
+
+// heartbeatDue returns true if a ready heartbeat should be logged. A zero interval disables heartbeats.
+func heartbeatDue(interval time.Duration, last, now time.Time) bool {
+	return interval > 0 && now.Sub(last) >= interval
+}
+
+// MustRunValidation runs Galley validation mode like RunValidation, exiting the
+// process if it fails. It is used by the standalone Galley binary.
+func MustRunValidation(ready chan<- struct{}, stopCh chan struct{}, vc *WebhookParameters,
 	kubeInterface kubernetes.Interface, kubeConfig string, livenessProbeController, readinessProbeController probe.Controller) {
+	if err := RunValidation(ready, stopCh, vc, kubeInterface, kubeConfig, livenessProbeController, readinessProbeController); err != nil {
+		log.Fatal(err.Error())
+	}
+}
+
+// RunValidation runs Galley validation mode until stopCh is closed and in-flight requests are drained.
+// It returns an error if the webhook cannot be created or fails to serve, leaving the caller to decide
+// whether to exit.
+func RunValidation(ready chan<- struct{}, stopCh chan struct{}, vc *WebhookParameters,
+	kubeInterface kubernetes.Interface, kubeConfig string, livenessProbeController, readinessProbeController probe.Controller) error {
 	log.Infof("Galley validation started with \n%s", vc)
-	mixerValidator := mixervalidate.NewDefaultValidator(false)
+	log.Infof("Galley validation version: %s", buildversion.Info)
+	validators, initErr := InitValidators(vc)
+	vc.PilotDescriptor, vc.MixerValidator = validators.PilotDescriptor, validators.MixerValidator
+	if initErr != nil {
+		log.Errorf("validator initialization failed: %v", initErr)
+	}
 
 	var clientset kubernetes.Interface
 	var err error
@@ -86,70 +262,42 @@ func RunValidation(ready chan<- struct{}, stopCh chan struct{}, vc *WebhookParam
 	if kubeInterface.(*kubernetes.Clientset) == nil {
 		clientset, err = kube.CreateClientset(kubeConfig, "")
 		if err != nil {
-			log.Fatalf("could not create k8s clientset: %v", err)
+			return fmt.Errorf("could not create k8s clientset: %v", err)
 		}
 	} else {
 		clientset = kubeInterface
 	}
-	vc.MixerValidator = mixerValidator
-	vc.PilotDescriptor = schemas.Istio
+	if vc.PreflightAPICheck {
+		if err := checkAPIServer(clientset.Discovery(), stopCh); err != nil {
+			log.Errorf("Galley validation cannot reach the API server: %v", err)
+			return fmt.Errorf("preflight check failed: %v", err)
+		}
+	}
+	if err := checkPermissions(clientset, vc); err != nil {
+		log.Errorf("Galley validation lacks a permission: %v", err)
+		return fmt.Errorf("permission check failed: %v", err)
+	}
 	vc.Clientset = clientset
+	if vc.ObjectCache && vc.DynamicClient == nil {
+		config, err := kube.BuildClientConfig(kubeConfig, "")
+		if err != nil {
+			return fmt.Errorf("could not create k8s client config: %v", err)
+		}
+		if vc.DynamicClient, err = dynamic.NewForConfig(config); err != nil {
+			return fmt.Errorf("could not create k8s dynamic client: %v", err)
+		}
+	}
 	wh, err := NewWebhook(*vc)
 	if err != nil || vc.Clientset == nil {
-		log.Fatalf("cannot create validation webhook service: %v", err)
-	}
-	validationLivenessProbe := probe.NewProbe()
-	if livenessProbeController != nil {
-		validationLivenessProbe.SetAvailable(nil)
-		validationLivenessProbe.RegisterProbe(livenessProbeController, "validationLiveness")
-	}
-
-	validationReadinessProbe := probe.NewProbe()
-	if readinessProbeController != nil {
-		validationReadinessProbe.SetAvailable(errors.New("init"))
-		validationReadinessProbe.RegisterProbe(readinessProbeController, "validationReadiness")
-
-		go func() {
-			ready := false
-			client := &http.Client{
-				Timeout: time.Second,
-				Transport: &http.Transport{
-					TLSClientConfig: &tls.Config{
-						InsecureSkipVerify: true,
-					},
-				},
-			}
-
-			for {
-				if err := webhookHTTPSHandlerReady(client, vc); err != nil {
-					validationReadinessProbe.SetAvailable(errors.New("not ready"))
-					scope.Infof("https handler for validation webhook is not ready: %v\n", err)
-					ready = false
-				} else {
-					validationReadinessProbe.SetAvailable(nil)
-					if !ready {
-						scope.Info("https handler for validation webhook is ready\n")
-						ready = true
-					}
-				}
-				<-time.After(httpsHandlerReadinessFreq)
-				// check again
-			}
-		}()
+		return fmt.Errorf("cannot create validation webhook service: %v", err)
 	}
-
-	go func() {
-		for range stopCh {
-			if livenessProbeController != nil {
-				validationLivenessProbe.SetAvailable(errors.New("stopped"))
-			}
-			if readinessProbeController != nil {
-				validationReadinessProbe.SetAvailable(errors.New("stopped"))
-			}
-			break
-		}
-	}()
-	go wh.Run(ready, stopCh)
+	wh.initErr = initErr
+	if wh.objectCache != nil {
+		wh.objectCache.start(stopCh)
+	}
+	stopProbes := wh.registerProbes(vc, stopCh, livenessProbeController, readinessProbeController)
+	defer stopProbes()
+	return wh.Serve(ready, stopCh)
 }
 
 // isDNS1123Label tests for a string that conforms to the definition of a label in
@@ -202,6 +350,176 @@ func (p *WebhookParameters) Validate() error {
 		if err := validatePort(int(p.Port)); err != nil {
 			errs = multierror.Append(errs, err)
 		}
+		if p.StatusPort != 0 {
+			if err := validatePort(int(p.StatusPort)); err != nil {
+				errs = multierror.Append(errs, fmt.Errorf("invalid status port: %v", err))
+			} else if p.StatusPort == p.Port {
+				errs = multierror.Append(errs, fmt.Errorf("status port %d must differ from the validation port", p.StatusPort))
+			}
+		}
+		if p.RequireClientCert && p.ClientCAFile == "" {
+			errs = multierror.Append(errs, errors.New("client CA file is required to require client certs"))
+		}
+		if (p.ReadinessClientCertFile == "") != (p.ReadinessClientKeyFile == "") {
+			errs = multierror.Append(errs, errors.New("readiness client cert and key files must be specified together"))
+		} else if p.RequireClientCert && p.ReadinessClientCertFile == "" {
+			errs = multierror.Append(errs, errors.New("readiness client cert is required to require client certs"))
+		}
+		if p.CertClockSkew < 0 {
+			errs = multierror.Append(errs, fmt.Errorf("invalid cert clock skew: %v", p.CertClockSkew))
+		}
+		pilotPath, mixerPath := p.admissionPaths()
+		for _, path := range []string{pilotPath, mixerPath} {
+			if !strings.HasPrefix(path, "/") {
+				errs = multierror.Append(errs, fmt.Errorf("admission path %q must begin with /", path))
+			} else if path == httpsHandlerReadyPath || strings.HasPrefix(path, "/debug/") {
+				errs = multierror.Append(errs, fmt.Errorf("admission path %q is reserved", path))
+			}
+		}
+		if pilotPath == mixerPath {
+			errs = multierror.Append(errs, fmt.Errorf("pilot and mixer admission paths must differ: %q", pilotPath))
+		}
+		if p.TerminationGracePeriod < 0 {
+			errs = multierror.Append(errs, fmt.Errorf("invalid termination grace period: %v", p.TerminationGracePeriod))
+		}
+		if p.SchemaRegistryURL != "" {
+			if u, err := url.Parse(p.SchemaRegistryURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				errs = multierror.Append(errs, fmt.Errorf("invalid schema registry URL: %q", redactURL(p.SchemaRegistryURL)))
+			}
+		}
+		if err := p.StrictnessProfile.validate(); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+		for _, field := range p.RedactedFields {
+			if _, err := parseRedactPath(field); err != nil {
+				errs = multierror.Append(errs, err)
+			}
+		}
+		for _, label := range p.RequiredLabels {
+			if reasons := k8svalidation.IsQualifiedName(label); len(reasons) > 0 {
+				errs = multierror.Append(errs, fmt.Errorf("invalid required label %q: %s", label, strings.Join(reasons, "; ")))
+			}
+		}
+		for _, ref := range p.CABundleSecrets {
+			if _, _, err := parseCABundleSecret(ref); err != nil {
+				errs = multierror.Append(errs, err)
+			}
+		}
+		if err := validateDisabledRules(p.DisabledRules); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+		if err := validateViolationCodes(p.ViolationCodes); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+		if err := p.UnhandledOperationPolicy.validate(); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+		if err := p.DefaultDecisionForUnmatched.validate(); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+		if p.EnforceGlobalNameUniqueness {
+			if err := validateGlobalNameKinds(p.GlobalNameUniquenessKinds); err != nil {
+				errs = multierror.Append(errs, err)
+			}
+		}
+		switch p.ReadinessHTTPMethod {
+		case "", http.MethodGet, http.MethodHead:
+		default:
+			errs = multierror.Append(errs, fmt.Errorf("invalid readiness HTTP method %q, want %s or %s",
+				p.ReadinessHTTPMethod, http.MethodGet, http.MethodHead))
+		}
+		if p.GRPCAddress != "" {
+			if _, _, err := net.SplitHostPort(p.GRPCAddress); err != nil {
+				errs = multierror.Append(errs, fmt.Errorf("invalid gRPC address: %q", p.GRPCAddress))
+			}
+		}
+		if p.DebugSampleRate < 0 || p.DebugSampleRate > 1 {
+			errs = multierror.Append(errs, fmt.Errorf("invalid debug sample rate: %v", p.DebugSampleRate))
+		}
+		if p.ValidatorErrorRestartThreshold < 0 {
+			errs = multierror.Append(errs, fmt.Errorf("invalid validator error restart threshold: %d", p.ValidatorErrorRestartThreshold))
+		}
+		if p.ValidatorErrorRestartThreshold > 0 && p.ValidatorErrorRestartWindow <= 0 {
+			errs = multierror.Append(errs, fmt.Errorf("invalid validator error restart window: %v", p.ValidatorErrorRestartWindow))
+		}
+		if _, err := compileJSONSchemas(p.JSONSchemas); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+		if p.BindAddress != "" && net.ParseIP(p.BindAddress) == nil {
+			errs = multierror.Append(errs, fmt.Errorf("invalid bind address: %q", p.BindAddress))
+		}
+		if p.PanicResponseCode != 0 && (p.PanicResponseCode < 400 || p.PanicResponseCode > 599) {
+			errs = multierror.Append(errs, fmt.Errorf("invalid panic response code: %d", p.PanicResponseCode))
+		}
+		if p.ObjectCacheResyncPeriod < 0 {
+			errs = multierror.Append(errs, fmt.Errorf("invalid object cache resync period: %v", p.ObjectCacheResyncPeriod))
+		}
+		if p.ResourceVersionCacheSize < 0 {
+			errs = multierror.Append(errs, fmt.Errorf("invalid resource version cache size: %v", p.ResourceVersionCacheSize))
+		}
+		if p.MaxReportedErrors < 0 {
+			errs = multierror.Append(errs, fmt.Errorf("invalid max reported errors: %v", p.MaxReportedErrors))
+		}
+		if p.ResponseCompressionThreshold < 0 {
+			errs = multierror.Append(errs, fmt.Errorf("invalid response compression threshold: %v", p.ResponseCompressionThreshold))
+		}
+		if p.CertExpiryWarningThreshold < 0 {
+			errs = multierror.Append(errs, fmt.Errorf("invalid cert expiry warning threshold: %v", p.CertExpiryWarningThreshold))
+		}
+		if err := validateValidationPipeline(p.ValidationPipeline); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+		if p.RejectMissingGatewayCredentials && !p.CheckGatewayCredentials {
+			errs = multierror.Append(errs, errors.New("rejecting missing gateway credentials requires CheckGatewayCredentials"))
+		}
+		if p.EnableDebugEndpoints && p.DebugToken == "" {
+			errs = multierror.Append(errs, errors.New("the debug endpoints require a debug token"))
+		}
+		if p.RequestDeadlineMargin < 0 {
+			errs = multierror.Append(errs, fmt.Errorf("invalid request deadline margin: %v", p.RequestDeadlineMargin))
+		}
+		if p.WatchIdleRestart < 0 {
+			errs = multierror.Append(errs, fmt.Errorf("invalid watch idle restart: %v", p.WatchIdleRestart))
+		}
+		if p.WebhookConfigResyncInterval < 0 {
+			errs = multierror.Append(errs, fmt.Errorf("invalid webhook config resync interval: %v", p.WebhookConfigResyncInterval))
+		}
+		if p.ObjectSelector != nil {
+			if _, err := metav1.LabelSelectorAsSelector(p.ObjectSelector); err != nil {
+				errs = multierror.Append(errs, fmt.Errorf("invalid object selector: %v", err))
+			}
+		}
+		for resource, operations := range p.RuleOperations {
+			if len(operations) == 0 {
+				errs = multierror.Append(errs, fmt.Errorf("no rule operations for resource %q", resource))
+			}
+			for _, operation := range operations {
+				if !knownOperations[operation] {
+					errs = multierror.Append(errs, fmt.Errorf("unknown rule operation %q for resource %q", operation, resource))
+				}
+			}
+		}
+		if p.PerNamespaceConcurrency < 0 {
+			errs = multierror.Append(errs, fmt.Errorf("invalid per-namespace concurrency: %d", p.PerNamespaceConcurrency))
+		}
+		if p.SchemaRegistryRefreshInterval < 0 {
+			errs = multierror.Append(errs, fmt.Errorf("invalid schema registry refresh interval: %v", p.SchemaRegistryRefreshInterval))
+		}
+		if p.EnforcementConfigMapName != "" && p.EnforcementConfigMapKey == "" {
+			errs = multierror.Append(errs, errors.New("enforcement ConfigMap key not specified"))
+		}
+		if p.ReadinessHeartbeatInterval < 0 {
+			errs = multierror.Append(errs, fmt.Errorf("invalid readiness heartbeat interval: %v", p.ReadinessHeartbeatInterval))
+		}
+		if p.ReadyTimeout < 0 {
+			errs = multierror.Append(errs, fmt.Errorf("invalid ready timeout: %v", p.ReadyTimeout))
+		}
+		if p.ListenBacklog < 0 {
+			errs = multierror.Append(errs, fmt.Errorf("invalid listen backlog: %d", p.ListenBacklog))
+		}
+		if p.AllowSkipAnnotation && p.SkipAnnotation == "" {
+			errs = multierror.Append(errs, errors.New("skip annotation not specified"))
+		}
 	}
 
 	return errs.ErrorOrNil()
@@ -15,8 +15,25 @@
 package validation
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
+
+	admissionregistrationv1beta1 "k8s.io/api/admissionregistration/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeschema "k8s.io/apimachinery/pkg/runtime/schema"
+
+	"istio.io/istio/pkg/mcp/testing/testcerts"
 )
 
 // scenario is a common struct used by many tests in this context.
@@ -75,6 +92,248 @@ func TestValidate(t *testing.T) {
 			wrapFunc:      func(args *WebhookParameters) { args.Port = 100000 },
 			expectedError: "port number 100000 must be in the range 1..65535",
 		},
+		"invalid status port": {
+			wrapFunc:      func(args *WebhookParameters) { args.StatusPort = 100000 },
+			expectedError: "invalid status port: port number 100000 must be in the range 1..65535",
+		},
+		"status port same as port": {
+			wrapFunc:      func(args *WebhookParameters) { args.StatusPort = args.Port },
+			expectedError: "status port 9443 must differ from the validation port",
+		},
+		"require client cert without client CA": {
+			wrapFunc: func(args *WebhookParameters) {
+				args.RequireClientCert = true
+				args.ReadinessClientCertFile = "client-cert.pem"
+				args.ReadinessClientKeyFile = "client-key.pem"
+			},
+			expectedError: "client CA file is required to require client certs",
+		},
+		"require client cert without readiness client cert": {
+			wrapFunc: func(args *WebhookParameters) {
+				args.RequireClientCert = true
+				args.ClientCAFile = "client-ca.pem"
+			},
+			expectedError: "readiness client cert is required to require client certs",
+		},
+		"readiness client cert without key": {
+			wrapFunc:      func(args *WebhookParameters) { args.ReadinessClientCertFile = "client-cert.pem" },
+			expectedError: "readiness client cert and key files must be specified together",
+		},
+		"require client cert": {
+			wrapFunc: func(args *WebhookParameters) {
+				args.RequireClientCert = true
+				args.ClientCAFile = "client-ca.pem"
+				args.ReadinessClientCertFile = "client-cert.pem"
+				args.ReadinessClientKeyFile = "client-key.pem"
+			},
+			expectedError: "",
+		},
+		"enforcement ConfigMap without key": {
+			wrapFunc: func(args *WebhookParameters) {
+				args.EnforcementConfigMapName = "galley-enforcement"
+				args.EnforcementConfigMapKey = ""
+			},
+			expectedError: "enforcement ConfigMap key not specified",
+		},
+		"invalid schema registry URL": {
+			wrapFunc:      func(args *WebhookParameters) { args.SchemaRegistryURL = "registry:8080/schemas" },
+			expectedError: `invalid schema registry URL: "registry:8080/schemas"`,
+		},
+		"invalid schema registry refresh interval": {
+			wrapFunc: func(args *WebhookParameters) {
+				args.SchemaRegistryURL = "http://registry:8080/schemas"
+				args.SchemaRegistryRefreshInterval = -time.Second
+			},
+			expectedError: "invalid schema registry refresh interval: -1s",
+		},
+		"invalid per-namespace concurrency": {
+			wrapFunc:      func(args *WebhookParameters) { args.PerNamespaceConcurrency = -1 },
+			expectedError: "invalid per-namespace concurrency: -1",
+		},
+		"invalid strictness profile": {
+			wrapFunc:      func(args *WebhookParameters) { args.StrictnessProfile = "paranoid" },
+			expectedError: `invalid strictness profile "paranoid", want one of "permissive", "standard" or "strict"`,
+		},
+		"valid object selector": {
+			wrapFunc: func(args *WebhookParameters) {
+				args.ObjectSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"istio-validation": "enabled"}}
+			},
+			expectedError: "",
+		},
+		"invalid object selector": {
+			wrapFunc: func(args *WebhookParameters) {
+				args.ObjectSelector = &metav1.LabelSelector{
+					MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "env", Operator: "Bogus"}},
+				}
+			},
+			expectedError: `invalid object selector: "Bogus" is not a valid pod selector operator`,
+		},
+		"invalid webhook config resync interval": {
+			wrapFunc:      func(args *WebhookParameters) { args.WebhookConfigResyncInterval = -time.Second },
+			expectedError: "invalid webhook config resync interval: -1s",
+		},
+		"invalid cert expiry warning threshold": {
+			wrapFunc:      func(args *WebhookParameters) { args.CertExpiryWarningThreshold = -time.Second },
+			expectedError: "invalid cert expiry warning threshold: -1s",
+		},
+		"invalid redacted field": {
+			wrapFunc:      func(args *WebhookParameters) { args.RedactedFields = []string{"spec..tls"} },
+			expectedError: `invalid redacted field "spec..tls"`,
+		},
+		"invalid response compression threshold": {
+			wrapFunc:      func(args *WebhookParameters) { args.ResponseCompressionThreshold = -1 },
+			expectedError: "invalid response compression threshold: -1",
+		},
+		"invalid max reported errors": {
+			wrapFunc:      func(args *WebhookParameters) { args.MaxReportedErrors = -1 },
+			expectedError: "invalid max reported errors: -1",
+		},
+		"invalid resource version cache size": {
+			wrapFunc:      func(args *WebhookParameters) { args.ResourceVersionCacheSize = -1 },
+			expectedError: "invalid resource version cache size: -1",
+		},
+		"invalid bind address": {
+			wrapFunc:      func(args *WebhookParameters) { args.BindAddress = "eth0" },
+			expectedError: `invalid bind address: "eth0"`,
+		},
+		"invalid required label": {
+			wrapFunc:      func(args *WebhookParameters) { args.RequiredLabels = []string{"owner", "bad label"} },
+			expectedError: `invalid required label "bad label"`,
+		},
+		"invalid unhandled operation policy": {
+			wrapFunc:      func(args *WebhookParameters) { args.UnhandledOperationPolicy = "ignore" },
+			expectedError: `invalid unhandled operation policy "ignore"`,
+		},
+		"invalid object cache resync period": {
+			wrapFunc:      func(args *WebhookParameters) { args.ObjectCacheResyncPeriod = -time.Second },
+			expectedError: "invalid object cache resync period: -1s",
+		},
+		"unknown rule operation": {
+			wrapFunc: func(args *WebhookParameters) {
+				args.RuleOperations = map[string][]admissionregistrationv1beta1.OperationType{"gateways": {"PATCH"}}
+			},
+			expectedError: `unknown rule operation "PATCH" for resource "gateways"`,
+		},
+		"no rule operations": {
+			wrapFunc: func(args *WebhookParameters) {
+				args.RuleOperations = map[string][]admissionregistrationv1beta1.OperationType{"gateways": nil}
+			},
+			expectedError: `no rule operations for resource "gateways"`,
+		},
+		"violation code for unknown check": {
+			wrapFunc:      func(args *WebhookParameters) { args.ViolationCodes = map[string]string{"hosts": "ACME1"} },
+			expectedError: `violation code for unknown check "hosts"`,
+		},
+		"invalid violation code": {
+			wrapFunc:      func(args *WebhookParameters) { args.ViolationCodes = map[string]string{CheckSchema: "ACME 1"} },
+			expectedError: `invalid violation code "ACME 1" for check "schema"`,
+		},
+		"invalid watch idle restart": {
+			wrapFunc:      func(args *WebhookParameters) { args.WatchIdleRestart = -time.Second },
+			expectedError: "invalid watch idle restart: -1s",
+		},
+		"reject missing gateway credentials without the check": {
+			wrapFunc:      func(args *WebhookParameters) { args.RejectMissingGatewayCredentials = true },
+			expectedError: "rejecting missing gateway credentials requires CheckGatewayCredentials",
+		},
+		"debug endpoints without token": {
+			wrapFunc:      func(args *WebhookParameters) { args.EnableDebugEndpoints = true },
+			expectedError: "the debug endpoints require a debug token",
+		},
+		"invalid request deadline margin": {
+			wrapFunc:      func(args *WebhookParameters) { args.RequestDeadlineMargin = -time.Second },
+			expectedError: "invalid request deadline margin: -1s",
+		},
+		"invalid panic response code": {
+			wrapFunc:      func(args *WebhookParameters) { args.PanicResponseCode = 200 },
+			expectedError: "invalid panic response code: 200",
+		},
+		"invalid default decision for unmatched objects": {
+			wrapFunc:      func(args *WebhookParameters) { args.DefaultDecisionForUnmatched = "deny" },
+			expectedError: `invalid default decision for unmatched objects "deny"`,
+		},
+		"global name uniqueness without kinds": {
+			wrapFunc:      func(args *WebhookParameters) { args.EnforceGlobalNameUniqueness = true },
+			expectedError: "global name uniqueness requires at least one kind",
+		},
+		"invalid CA bundle secret": {
+			wrapFunc:      func(args *WebhookParameters) { args.CABundleSecrets = []string{"remote-ca"} },
+			expectedError: `invalid CA bundle secret "remote-ca"`,
+		},
+		"unknown disabled rule": {
+			wrapFunc:      func(args *WebhookParameters) { args.DisabledRules = map[string]bool{"typo": true} },
+			expectedError: `unknown validation rule "typo"`,
+		},
+		"invalid readiness HTTP method": {
+			wrapFunc:      func(args *WebhookParameters) { args.ReadinessHTTPMethod = http.MethodPost },
+			expectedError: `invalid readiness HTTP method "POST"`,
+		},
+		"invalid gRPC address": {
+			wrapFunc:      func(args *WebhookParameters) { args.GRPCAddress = "9444" },
+			expectedError: `invalid gRPC address: "9444"`,
+		},
+		"invalid debug sample rate": {
+			wrapFunc:      func(args *WebhookParameters) { args.DebugSampleRate = 1.5 },
+			expectedError: "invalid debug sample rate: 1.5",
+		},
+		"invalid validator error restart threshold": {
+			wrapFunc:      func(args *WebhookParameters) { args.ValidatorErrorRestartThreshold = -1 },
+			expectedError: "invalid validator error restart threshold: -1",
+		},
+		"invalid validator error restart window": {
+			wrapFunc: func(args *WebhookParameters) {
+				args.ValidatorErrorRestartThreshold = 5
+				args.ValidatorErrorRestartWindow = 0
+			},
+			expectedError: "invalid validator error restart window: 0s",
+		},
+		"invalid JSON schema": {
+			wrapFunc: func(args *WebhookParameters) {
+				args.JSONSchemas = map[kubeschema.GroupVersionKind]json.RawMessage{
+					{Group: "example.com", Version: "v1", Kind: "Widget"}: json.RawMessage(`{"type": "map"}`),
+				}
+			},
+			expectedError: "invalid JSON schema of example.com/v1, Kind=Widget",
+		},
+		"invalid cert clock skew": {
+			wrapFunc:      func(args *WebhookParameters) { args.CertClockSkew = -time.Second },
+			expectedError: "invalid cert clock skew: -1s",
+		},
+		"admission path without leading slash": {
+			wrapFunc:      func(args *WebhookParameters) { args.PilotAdmissionPath = "admitpilot" },
+			expectedError: `admission path "admitpilot" must begin with /`,
+		},
+		"reserved admission path": {
+			wrapFunc:      func(args *WebhookParameters) { args.MixerAdmissionPath = "/ready" },
+			expectedError: `admission path "/ready" is reserved`,
+		},
+		"duplicate admission paths": {
+			wrapFunc:      func(args *WebhookParameters) { args.MixerAdmissionPath = args.PilotAdmissionPath },
+			expectedError: `pilot and mixer admission paths must differ: "/admitpilot"`,
+		},
+		"invalid termination grace period": {
+			wrapFunc:      func(args *WebhookParameters) { args.TerminationGracePeriod = -time.Second },
+			expectedError: "invalid termination grace period: -1s",
+		},
+		"invalid readiness heartbeat interval": {
+			wrapFunc:      func(args *WebhookParameters) { args.ReadinessHeartbeatInterval = -time.Second },
+			expectedError: "invalid readiness heartbeat interval: -1s",
+		},
+		"invalid ready timeout": {
+			wrapFunc:      func(args *WebhookParameters) { args.ReadyTimeout = -time.Second },
+			expectedError: "invalid ready timeout: -1s",
+		},
+		"invalid listen backlog": {
+			wrapFunc:      func(args *WebhookParameters) { args.ListenBacklog = -1 },
+			expectedError: "invalid listen backlog: -1",
+		},
+		"skip annotation unset": {
+			wrapFunc: func(args *WebhookParameters) {
+				args.AllowSkipAnnotation = true
+				args.SkipAnnotation = ""
+			},
+			expectedError: "skip annotation not specified",
+		},
 	}
 
 	for name, scenario := range scenarios {
@@ -109,3 +368,306 @@ func runTestCode(name string, t *testing.T, test scenario) {
 		t.Errorf("Test %q failed with validation disabled, expected nil error, but got: %v", name, err)
 	}
 }
+
+func TestInitValidators(t *testing.T) {
+	args := DefaultArgs()
+	set, err := InitValidators(args)
+	if err != nil {
+		t.Fatalf("InitValidators() failed: %v", err)
+	}
+	if set.MixerValidator == nil || set.PilotDescriptor == nil {
+		t.Fatal("validators not initialized")
+	}
+	if args.MixerValidator != nil || args.PilotDescriptor != nil {
+		t.Fatal("InitValidators() modified the parameters")
+	}
+
+	args.EnableMixerValidation = false
+	if set, err = InitValidators(args); err != nil {
+		t.Fatalf("InitValidators() failed: %v", err)
+	}
+	if set.MixerValidator != nil {
+		t.Fatal("mixer validator should not be initialized when mixer validation is disabled")
+	}
+}
+
+type fakeHTTPClient struct {
+	handler http.HandlerFunc
+}
+
+func (c *fakeHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	w := httptest.NewRecorder()
+	c.handler(w, req)
+	return w.Result(), nil
+}
+
+func TestWebhookHTTPSHandlerReady(t *testing.T) {
+	wh := &Webhook{}
+	client := &fakeHTTPClient{handler: wh.serveReady}
+
+	if err := webhookHTTPSHandlerReady(client, DefaultArgs()); err != nil {
+		t.Fatalf("webhookHTTPSHandlerReady() failed: %v", err)
+	}
+
+	wh.initErr = errors.New("bad schema")
+	err := webhookHTTPSHandlerReady(client, DefaultArgs())
+	if err == nil || !strings.Contains(err.Error(), "validator initialization failed: bad schema") {
+		t.Fatalf("got %v want error with initialization reason", err)
+	}
+}
+
+func TestWebhookHTTPSHandlerReady_Method(t *testing.T) {
+	var got []string
+	wh := &Webhook{}
+	client := &fakeHTTPClient{handler: func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Method)
+		wh.serveReady(w, r)
+	}}
+
+	for _, method := range []string{"", http.MethodGet, http.MethodHead} {
+		vc := DefaultArgs()
+		vc.ReadinessHTTPMethod = method
+		if err := webhookHTTPSHandlerReady(client, vc); err != nil {
+			t.Fatalf("webhookHTTPSHandlerReady() with method %q failed: %v", method, err)
+		}
+	}
+	if want := []string{http.MethodGet, http.MethodGet, http.MethodHead}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got methods %v want %v", got, want)
+	}
+
+	wh.initErr = errors.New("bad schema")
+	vc := DefaultArgs()
+	vc.ReadinessHTTPMethod = http.MethodHead
+	if err := webhookHTTPSHandlerReady(client, vc); err == nil || !strings.Contains(err.Error(), "HEAD") {
+		t.Fatalf("got %v want HEAD request error", err)
+	}
+}
+
+func TestServeReady_Method(t *testing.T) {
+	wh := &Webhook{}
+	cases := []struct {
+		method     string
+		wantStatus int
+	}{
+		{method: http.MethodGet, wantStatus: http.StatusOK},
+		{method: http.MethodHead, wantStatus: http.StatusOK},
+		{method: http.MethodPost, wantStatus: http.StatusMethodNotAllowed},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.method), func(t *testing.T) {
+			w := httptest.NewRecorder()
+			wh.serveReady(w, httptest.NewRequest(c.method, httpsHandlerReadyPath, nil))
+			if w.Code != c.wantStatus {
+				t.Fatalf("got status %v want %v", w.Code, c.wantStatus)
+			}
+		})
+	}
+}
+
+func TestReadinessHost(t *testing.T) {
+	cases := []struct {
+		bindAddress string
+		want        string
+	}{
+		{bindAddress: "", want: "localhost:9443"},
+		{bindAddress: "0.0.0.0", want: "localhost:9443"},
+		{bindAddress: "::", want: "localhost:9443"},
+		{bindAddress: "10.0.0.1", want: "10.0.0.1:9443"},
+		{bindAddress: "fd00::1", want: "[fd00::1]:9443"},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %q", i, c.bindAddress), func(t *testing.T) {
+			vc := &WebhookParameters{Port: 9443, BindAddress: c.bindAddress}
+			if got := readinessHost(vc); got != c.want {
+				t.Fatalf("got %q want %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestServeReady_InitError(t *testing.T) {
+	wh := &Webhook{initErr: errors.New("bad schema")}
+	w := httptest.NewRecorder()
+	wh.serveReady(w, httptest.NewRequest("GET", httpsHandlerReadyPath, nil))
+
+	res := w.Result()
+	if res.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("got status %v want %v", res.StatusCode, http.StatusServiceUnavailable)
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("could not read body: %v", err)
+	}
+	if want := `{"reason":"validator initialization failed: bad schema"}`; string(body) != want {
+		t.Fatalf("got body %s want %s", body, want)
+	}
+}
+
+func TestReadinessHandler_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	hanging := func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}
+
+	w := httptest.NewRecorder()
+	readinessHandler(hanging, 10*time.Millisecond).ServeHTTP(w, httptest.NewRequest("GET", httpsHandlerReadyPath, nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("got status %v want %v", w.Code, http.StatusServiceUnavailable)
+	}
+	if got := w.Header().Get("Cache-Control"); !strings.Contains(got, "no-store") {
+		t.Fatalf("got Cache-Control %q want no-store", got)
+	}
+}
+
+func TestReadinessHandler_NoCache(t *testing.T) {
+	ready := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	for _, timeout := range []time.Duration{0, time.Minute} {
+		w := httptest.NewRecorder()
+		readinessHandler(ready, timeout).ServeHTTP(w, httptest.NewRequest("GET", httpsHandlerReadyPath, nil))
+		if got := w.Header().Get("Cache-Control"); got != "no-cache, no-store, must-revalidate" {
+			t.Fatalf("timeout %v: got Cache-Control %q want no-cache, no-store, must-revalidate", timeout, got)
+		}
+		if got := w.Header().Get("Pragma"); got != "no-cache" {
+			t.Fatalf("timeout %v: got Pragma %q want no-cache", timeout, got)
+		}
+	}
+}
+
+func TestHeartbeatDue(t *testing.T) {
+	last := time.Unix(0, 0)
+	cases := []struct {
+		name     string
+		interval time.Duration
+		elapsed  time.Duration
+		want     bool
+	}{
+		{name: "disabled", interval: 0, elapsed: time.Hour, want: false},
+		{name: "not due", interval: time.Minute, elapsed: 30 * time.Second, want: false},
+		{name: "due", interval: time.Minute, elapsed: time.Minute, want: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := heartbeatDue(c.interval, last, last.Add(c.elapsed)); got != c.want {
+				t.Fatalf("got %v want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestReadinessTLSConfig(t *testing.T) {
+	if config := readinessTLSConfig(&WebhookParameters{}); config.GetClientCertificate != nil {
+		t.Fatal("readiness client presents a cert without a configured cert")
+	}
+
+	dir, err := ioutil.TempDir("", "galley_validation_readiness")
+	if err != nil {
+		t.Fatalf("TempDir() failed: %v", err)
+	}
+	defer os.RemoveAll(dir) // nolint: errcheck
+
+	vc := &WebhookParameters{
+		ReadinessClientCertFile: filepath.Join(dir, "cert.pem"),
+		ReadinessClientKeyFile:  filepath.Join(dir, "key.pem"),
+	}
+	config := readinessTLSConfig(vc)
+	if _, err := config.GetClientCertificate(nil); err == nil {
+		t.Fatal("GetClientCertificate() succeeded without cert files")
+	}
+
+	if err := ioutil.WriteFile(vc.ReadinessClientCertFile, testcerts.ServerCert, 0644); err != nil {
+		t.Fatalf("WriteFile(%v) failed: %v", vc.ReadinessClientCertFile, err)
+	}
+	if err := ioutil.WriteFile(vc.ReadinessClientKeyFile, testcerts.ServerKey, 0644); err != nil {
+		t.Fatalf("WriteFile(%v) failed: %v", vc.ReadinessClientKeyFile, err)
+	}
+	if cert, err := config.GetClientCertificate(nil); err != nil || cert == nil {
+		t.Fatalf("GetClientCertificate() got %v %v want cert", cert, err)
+	}
+}
+
+// scriptedReadiness serves the scripted readiness reasons in order, an empty
+// reason meaning ready, and cancels the watch after the last one.
+func scriptedReadiness(reasons []string, cancel func()) *fakeHTTPClient {
+	var i int
+	return &fakeHTTPClient{handler: func(w http.ResponseWriter, r *http.Request) {
+		reason := reasons[i]
+		if i++; i == len(reasons) {
+			cancel()
+		}
+		if reason == "" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"reason": "` + reason + `"}`)) // nolint: errcheck
+	}}
+}
+
+func TestWatchReadiness(t *testing.T) {
+	defer func(freq time.Duration) { httpsHandlerReadinessFreq = freq }(httpsHandlerReadinessFreq)
+	httpsHandlerReadinessFreq = time.Millisecond
+
+	type change struct {
+		ready  bool
+		reason string
+	}
+	cases := []struct {
+		name    string
+		reasons []string
+		want    []change
+	}{
+		{
+			name:    "steady ready",
+			reasons: []string{"", "", ""},
+			want:    []change{{ready: true}},
+		},
+		{
+			name:    "steady not ready",
+			reasons: []string{"init", "init", "init"},
+			want:    []change{{reason: "init"}},
+		},
+		{
+			name:    "becomes ready",
+			reasons: []string{"init", "init", "", ""},
+			want:    []change{{reason: "init"}, {ready: true}},
+		},
+		{
+			name:    "flapping",
+			reasons: []string{"", "down", "", "down", "down", ""},
+			want:    []change{{ready: true}, {reason: "down"}, {ready: true}, {reason: "down"}, {ready: true}},
+		},
+		{
+			name:    "reason changes",
+			reasons: []string{"init", "sync", "sync"},
+			want:    []change{{reason: "init"}, {reason: "sync"}},
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			client := scriptedReadiness(c.reasons, cancel)
+
+			var got []change
+			WatchReadiness(ctx, client, DefaultArgs(), func(ready bool, err error) {
+				ch := change{ready: ready}
+				if err != nil {
+					ch.reason = err.Error()[strings.LastIndex(err.Error(), " ")+1:]
+				}
+				got = append(got, ch)
+			})
+
+			if !reflect.DeepEqual(got, c.want) {
+				t.Fatalf("got changes %v want %v", got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,19 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// nolint
+//go:generate $REPO_ROOT/bin/protoc.sh -I$REPO_ROOT --gogo_out=plugins=grpc:$REPO_ROOT $REPO_ROOT/galley/pkg/crd/validation/validationpb/validation.proto
+
+// Package validationpb defines the gRPC service that exposes the galley validators.
+package validationpb
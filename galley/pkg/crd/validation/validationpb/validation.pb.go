@@ -0,0 +1,262 @@
+// Code generated by protoc-gen-gogo. DO NOT EDIT.
+// source: galley/pkg/crd/validation/validationpb/validation.proto
+
+package validationpb
+
+import (
+	context "context"
+	fmt "fmt"
+	proto "github.com/gogo/protobuf/proto"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.GoGoProtoPackageIsVersion3 // please upgrade the proto package
+
+// ValidateRequest is an object to validate.
+type ValidateRequest struct {
+	// The API group of the object, e.g. networking.istio.io.
+	Group string `protobuf:"bytes,1,opt,name=group,proto3" json:"group,omitempty"`
+	// The API version of the object, e.g. v1alpha3.
+	Version string `protobuf:"bytes,2,opt,name=version,proto3" json:"version,omitempty"`
+	// The kind of the object, e.g. Gateway.
+	Kind string `protobuf:"bytes,3,opt,name=kind,proto3" json:"kind,omitempty"`
+	// The namespace of the object, if it is namespaced.
+	Namespace string `protobuf:"bytes,4,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	// The object, encoded as JSON or YAML.
+	Object               []byte   `protobuf:"bytes,5,opt,name=object,proto3" json:"object,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ValidateRequest) Reset()         { *m = ValidateRequest{} }
+func (m *ValidateRequest) String() string { return proto.CompactTextString(m) }
+func (*ValidateRequest) ProtoMessage()    {}
+func (*ValidateRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_1a3e7e96ca95e58a, []int{0}
+}
+func (m *ValidateRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ValidateRequest.Unmarshal(m, b)
+}
+func (m *ValidateRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ValidateRequest.Marshal(b, m, deterministic)
+}
+func (m *ValidateRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ValidateRequest.Merge(m, src)
+}
+func (m *ValidateRequest) XXX_Size() int {
+	return xxx_messageInfo_ValidateRequest.Size(m)
+}
+func (m *ValidateRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ValidateRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ValidateRequest proto.InternalMessageInfo
+
+func (m *ValidateRequest) GetGroup() string {
+	if m != nil {
+		return m.Group
+	}
+	return ""
+}
+
+func (m *ValidateRequest) GetVersion() string {
+	if m != nil {
+		return m.Version
+	}
+	return ""
+}
+
+func (m *ValidateRequest) GetKind() string {
+	if m != nil {
+		return m.Kind
+	}
+	return ""
+}
+
+func (m *ValidateRequest) GetNamespace() string {
+	if m != nil {
+		return m.Namespace
+	}
+	return ""
+}
+
+func (m *ValidateRequest) GetObject() []byte {
+	if m != nil {
+		return m.Object
+	}
+	return nil
+}
+
+// ValidateResponse is the decision of the validators.
+type ValidateResponse struct {
+	// Whether the object is valid.
+	Allowed bool `protobuf:"varint,1,opt,name=allowed,proto3" json:"allowed,omitempty"`
+	// The reasons the object was rejected, if it is not allowed.
+	Errors               []string `protobuf:"bytes,2,rep,name=errors,proto3" json:"errors,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ValidateResponse) Reset()         { *m = ValidateResponse{} }
+func (m *ValidateResponse) String() string { return proto.CompactTextString(m) }
+func (*ValidateResponse) ProtoMessage()    {}
+func (*ValidateResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_1a3e7e96ca95e58a, []int{1}
+}
+func (m *ValidateResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ValidateResponse.Unmarshal(m, b)
+}
+func (m *ValidateResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ValidateResponse.Marshal(b, m, deterministic)
+}
+func (m *ValidateResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ValidateResponse.Merge(m, src)
+}
+func (m *ValidateResponse) XXX_Size() int {
+	return xxx_messageInfo_ValidateResponse.Size(m)
+}
+func (m *ValidateResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ValidateResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ValidateResponse proto.InternalMessageInfo
+
+func (m *ValidateResponse) GetAllowed() bool {
+	if m != nil {
+		return m.Allowed
+	}
+	return false
+}
+
+func (m *ValidateResponse) GetErrors() []string {
+	if m != nil {
+		return m.Errors
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*ValidateRequest)(nil), "istio.galley.validation.v1alpha1.ValidateRequest")
+	proto.RegisterType((*ValidateResponse)(nil), "istio.galley.validation.v1alpha1.ValidateResponse")
+}
+
+func init() {
+	proto.RegisterFile("galley/pkg/crd/validation/validationpb/validation.proto", fileDescriptor_1a3e7e96ca95e58a)
+}
+
+var fileDescriptor_1a3e7e96ca95e58a = []byte{
+	// 262 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x91, 0x3f, 0x4f, 0xf3, 0x30,
+	0x10, 0xc6, 0x95, 0xfe, 0x7b, 0x9b, 0x53, 0xf5, 0x82, 0x2c, 0x84, 0x2c, 0xc4, 0x10, 0x75, 0xca,
+	0x94, 0x28, 0x65, 0x60, 0x47, 0x7c, 0x82, 0x0c, 0x1d, 0xd8, 0x9c, 0xe4, 0x14, 0x4c, 0x8d, 0xcf,
+	0xb5, 0xdd, 0x20, 0x26, 0x3e, 0x00, 0x5f, 0x1a, 0xd5, 0x69, 0x68, 0xc4, 0x82, 0xd8, 0xee, 0xf7,
+	0x9c, 0xee, 0xfc, 0xf8, 0x1e, 0xb8, 0x6f, 0x85, 0x52, 0xf8, 0x9e, 0x9b, 0x5d, 0x9b, 0xd7, 0xb6,
+	0xc9, 0x3b, 0xa1, 0x64, 0x23, 0xbc, 0x24, 0x3d, 0x2a, 0x4d, 0x35, 0x82, 0xcc, 0x58, 0xf2, 0xc4,
+	0x12, 0xe9, 0xbc, 0xa4, 0xac, 0x1f, 0xcf, 0x46, 0xed, 0xae, 0x10, 0xca, 0x3c, 0x8b, 0x62, 0xfd,
+	0x19, 0xc1, 0xc5, 0xb6, 0xd7, 0xb1, 0xc4, 0xfd, 0x01, 0x9d, 0x67, 0x57, 0x30, 0x6f, 0x2d, 0x1d,
+	0x0c, 0x8f, 0x92, 0x28, 0x8d, 0xcb, 0x1e, 0x18, 0x87, 0x7f, 0x1d, 0x5a, 0x27, 0x49, 0xf3, 0x49,
+	0xd0, 0x07, 0x64, 0x0c, 0x66, 0x3b, 0xa9, 0x1b, 0x3e, 0x0d, 0x72, 0xa8, 0xd9, 0x2d, 0xc4, 0x5a,
+	0xbc, 0xa2, 0x33, 0xa2, 0x46, 0x3e, 0x0b, 0x8d, 0xb3, 0xc0, 0xae, 0x61, 0x41, 0xd5, 0x0b, 0xd6,
+	0x9e, 0xcf, 0x93, 0x28, 0x5d, 0x95, 0x27, 0x5a, 0x3f, 0xc2, 0xe5, 0xd9, 0x8c, 0x33, 0xa4, 0x1d,
+	0x1e, 0xdf, 0x15, 0x4a, 0xd1, 0x1b, 0x36, 0xc1, 0xcf, 0xb2, 0x1c, 0xf0, 0xb8, 0x05, 0xad, 0x25,
+	0xeb, 0xf8, 0x24, 0x99, 0xa6, 0x71, 0x79, 0xa2, 0xcd, 0x07, 0xc0, 0xf6, 0xfb, 0xab, 0x6c, 0x0f,
+	0xcb, 0x61, 0x27, 0x2b, 0xb2, 0xdf, 0x0e, 0x92, 0xfd, 0x38, 0xc6, 0xcd, 0xe6, 0x2f, 0x23, 0xbd,
+	0xe5, 0x87, 0xff, 0x4f, 0xab, 0x71, 0x2e, 0xd5, 0x22, 0xa4, 0x71, 0xf7, 0x35, 0x00, 0xb2, 0x7e,
+	0x45, 0x5d, 0xc8, 0x01, 0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// ValidationClient is the client API for Validation service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type ValidationClient interface {
+	// Validate validates a single object as if it were created.
+	Validate(ctx context.Context, in *ValidateRequest, opts ...grpc.CallOption) (*ValidateResponse, error)
+}
+
+type validationClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewValidationClient(cc *grpc.ClientConn) ValidationClient {
+	return &validationClient{cc}
+}
+
+func (c *validationClient) Validate(ctx context.Context, in *ValidateRequest, opts ...grpc.CallOption) (*ValidateResponse, error) {
+	out := new(ValidateResponse)
+	err := c.cc.Invoke(ctx, "/istio.galley.validation.v1alpha1.Validation/Validate", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ValidationServer is the server API for Validation service.
+type ValidationServer interface {
+	// Validate validates a single object as if it were created.
+	Validate(context.Context, *ValidateRequest) (*ValidateResponse, error)
+}
+
+// UnimplementedValidationServer can be embedded to have forward compatible implementations.
+type UnimplementedValidationServer struct {
+}
+
+func (*UnimplementedValidationServer) Validate(ctx context.Context, req *ValidateRequest) (*ValidateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Validate not implemented")
+}
+
+func RegisterValidationServer(s *grpc.Server, srv ValidationServer) {
+	s.RegisterService(&_Validation_serviceDesc, srv)
+}
+
+func _Validation_Validate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ValidateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ValidationServer).Validate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/istio.galley.validation.v1alpha1.Validation/Validate",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ValidationServer).Validate(ctx, req.(*ValidateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Validation_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "istio.galley.validation.v1alpha1.Validation",
+	HandlerType: (*ValidationServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Validate",
+			Handler:    _Validation_Validate_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "galley/pkg/crd/validation/validationpb/validation.proto",
+}
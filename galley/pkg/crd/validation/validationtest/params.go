@@ -0,0 +1,199 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package validationtest provides helpers for testing the Galley validation webhook.
+package validationtest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ghodss/yaml"
+	admissionregistrationv1beta1 "k8s.io/api/admissionregistration/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"istio.io/istio/galley/pkg/crd/validation"
+	"istio.io/istio/pkg/config/schemas"
+)
+
+// NewTestParameters returns valid WebhookParameters for tests. The certificate,
+// key, CA certificate and webhook configuration files are generated in a temporary
+// directory that is removed by the returned cleanup function. The parameters use
+// a fake Clientset and a free local port.
+func NewTestParameters(t testing.TB) (*validation.WebhookParameters, func()) {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "galley_validationtest")
+	if err != nil {
+		t.Fatalf("TempDir() failed: %v", err)
+	}
+	cleanup := func() {
+		os.RemoveAll(dir) // nolint: errcheck
+	}
+
+	p := validation.DefaultArgs()
+	p.CertFile = filepath.Join(dir, "cert-chain.pem")
+	p.KeyFile = filepath.Join(dir, "key.pem")
+	p.CACertFile = filepath.Join(dir, "root-cert.pem")
+	p.WebhookConfigFile = filepath.Join(dir, "webhook-config.yaml")
+	p.PilotDescriptor = schemas.Istio
+	p.Clientset = fake.NewSimpleClientset()
+
+	port, err := freePort()
+	if err != nil {
+		cleanup()
+		t.Fatalf("could not find a free port: %v", err)
+	}
+	p.Port = port
+
+	caCert, serverCert, serverKey, err := generateCerts(fmt.Sprintf("%s.%s.svc", p.ServiceName, p.DeploymentAndServiceNamespace))
+	if err != nil {
+		cleanup()
+		t.Fatalf("could not generate certificates: %v", err)
+	}
+	config, err := webhookConfig(p)
+	if err != nil {
+		cleanup()
+		t.Fatalf("could not create webhook configuration: %v", err)
+	}
+	for file, data := range map[string][]byte{
+		p.CACertFile:        caCert,
+		p.CertFile:          serverCert,
+		p.KeyFile:           serverKey,
+		p.WebhookConfigFile: config,
+	} {
+		if err := ioutil.WriteFile(file, data, 0644); err != nil {
+			cleanup()
+			t.Fatalf("WriteFile(%v) failed: %v", file, err)
+		}
+	}
+
+	return p, cleanup
+}
+
+// freePort returns a local port that is not in use.
+func freePort() (uint, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close() // nolint: errcheck
+	return uint(l.Addr().(*net.TCPAddr).Port), nil
+}
+
+// generateCerts returns a self-signed CA certificate, and a server certificate
+// for the host signed by the CA with its private key, in PEM encoding.
+func generateCerts(host string) (caCert, serverCert, serverKey []byte, err error) {
+	notBefore := time.Now().Add(-time.Hour)
+	notAfter := notBefore.Add(24 * time.Hour)
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "validationtest-ca"},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: host},
+		DNSNames:     []string{host},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, caTemplate, &key.PublicKey, caKey)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	caCert = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER})
+	serverCert = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	serverKey = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return caCert, serverCert, serverKey, nil
+}
+
+// webhookConfig returns the validatingwebhookconfiguration for the pilot and mixer
+// admission paths of the parameters.
+func webhookConfig(p *validation.WebhookParameters) ([]byte, error) {
+	webhook := func(name, path string) admissionregistrationv1beta1.ValidatingWebhook {
+		return admissionregistrationv1beta1.ValidatingWebhook{
+			Name: name,
+			ClientConfig: admissionregistrationv1beta1.WebhookClientConfig{
+				Service: &admissionregistrationv1beta1.ServiceReference{
+					Name:      p.ServiceName,
+					Namespace: p.DeploymentAndServiceNamespace,
+					Path:      &path,
+				},
+			},
+			Rules: []admissionregistrationv1beta1.RuleWithOperations{{
+				Operations: []admissionregistrationv1beta1.OperationType{
+					admissionregistrationv1beta1.Create,
+					admissionregistrationv1beta1.Update,
+				},
+				Rule: admissionregistrationv1beta1.Rule{
+					APIGroups:   []string{"*"},
+					APIVersions: []string{"*"},
+					Resources:   []string{"*"},
+				},
+			}},
+		}
+	}
+
+	return yaml.Marshal(&admissionregistrationv1beta1.ValidatingWebhookConfiguration{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "admissionregistration.k8s.io/v1beta1",
+			Kind:       "ValidatingWebhookConfiguration",
+		},
+		ObjectMeta: metav1.ObjectMeta{Name: p.WebhookName},
+		Webhooks: []admissionregistrationv1beta1.ValidatingWebhook{
+			webhook("pilot.validation.istio.io", p.PilotAdmissionPath),
+			webhook("mixer.validation.istio.io", p.MixerAdmissionPath),
+		},
+	})
+}
@@ -0,0 +1,46 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validationtest
+
+import (
+	"os"
+	"testing"
+
+	"istio.io/istio/galley/pkg/crd/validation"
+)
+
+func TestNewTestParameters(t *testing.T) {
+	p, cleanup := NewTestParameters(t)
+
+	if err := p.Validate(); err != nil {
+		cleanup()
+		t.Fatalf("Validate() failed: %v", err)
+	}
+	wh, err := validation.NewWebhook(*p)
+	if err != nil {
+		cleanup()
+		t.Fatalf("NewWebhook() failed: %v", err)
+	}
+	wh.Stop()
+	if _, err := validation.NewWebhookConfigController(*p); err != nil {
+		cleanup()
+		t.Fatalf("NewWebhookConfigController() failed: %v", err)
+	}
+
+	cleanup()
+	if _, err := os.Stat(p.CertFile); !os.IsNotExist(err) {
+		t.Fatalf("cleanup did not remove %v: %v", p.CertFile, err)
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const defaultValidatorErrorRestartWindow = time.Minute
+
+// toInternalErrorResponse is like toAdmissionResponse, but marks the rejection as
+// an internal error of a validator, e.g. a policy that cannot be evaluated, rather
+// than a rejection of an invalid object.
+func toInternalErrorResponse(err error) *admissionv1beta1.AdmissionResponse {
+	return &admissionv1beta1.AdmissionResponse{Result: &v1.Status{
+		Message: err.Error(),
+		Reason:  v1.StatusReasonInternalError,
+		Code:    http.StatusInternalServerError,
+	}}
+}
+
+// isInternalError returns true if the response is a rejection due to an internal validator error.
+func isInternalError(response *admissionv1beta1.AdmissionResponse) bool {
+	return response != nil && !response.Allowed && response.Result != nil &&
+		response.Result.Code == http.StatusInternalServerError
+}
+
+// validatorErrors counts consecutive internal validator errors. Once threshold
+// errors occur within the window, without an admission or a legitimate rejection
+// in between, the validator is considered stuck and onUnhealthy is called once,
+// e.g. to fail the liveness probe so that the pod is restarted.
+type validatorErrors struct {
+	threshold int
+	window    time.Duration
+	now       func() time.Time
+
+	mu          sync.Mutex
+	count       int
+	since       time.Time
+	unhealthy   bool
+	onUnhealthy func(error)
+}
+
+func newValidatorErrors(threshold int, window time.Duration) *validatorErrors {
+	return &validatorErrors{
+		threshold: threshold,
+		window:    window,
+		now:       time.Now,
+	}
+}
+
+// observe records the outcome of an admission request.
+func (e *validatorErrors) observe(response *admissionv1beta1.AdmissionResponse) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !isInternalError(response) {
+		e.count = 0
+		return
+	}
+
+	now := e.now()
+	if e.count == 0 || now.Sub(e.since) > e.window {
+		e.count, e.since = 0, now
+	}
+	e.count++
+	if e.count < e.threshold || e.unhealthy {
+		return
+	}
+
+	e.unhealthy = true
+	err := fmt.Errorf("%d consecutive validator errors within %v, last: %s",
+		e.count, now.Sub(e.since).Round(time.Millisecond), response.Result.Message)
+	scope.Errorf("Validator is unhealthy: %v", err)
+	if e.onUnhealthy != nil {
+		e.onUnhealthy(err)
+	}
+}
+
+// trackValidatorErrors wraps an admitFunc so that its internal errors are counted
+// towards the restart threshold.
+func (wh *Webhook) trackValidatorErrors(admit admitFunc) admitFunc {
+	if wh.validatorErrors == nil {
+		return admit
+	}
+	return func(ctx context.Context, request *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+		response := admit(ctx, request)
+		wh.validatorErrors.observe(response)
+		return response
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+)
+
+func TestValidatorErrorsObserve(t *testing.T) {
+	internal := toInternalErrorResponse(errors.New("cannot evaluate rego policy"))
+	rejected := toAdmissionResponse(errors.New("configuration is invalid"))
+	allowed := &admissionv1beta1.AdmissionResponse{Allowed: true}
+
+	type event struct {
+		after    time.Duration
+		response *admissionv1beta1.AdmissionResponse
+	}
+	cases := []struct {
+		name          string
+		events        []event
+		wantUnhealthy bool
+	}{
+		{
+			name:          "threshold reached",
+			events:        []event{{0, internal}, {time.Second, internal}, {time.Second, internal}},
+			wantUnhealthy: true,
+		},
+		{
+			name:   "below threshold",
+			events: []event{{0, internal}, {time.Second, internal}},
+		},
+		{
+			name:   "reset by admission",
+			events: []event{{0, internal}, {time.Second, internal}, {0, allowed}, {time.Second, internal}},
+		},
+		{
+			name:   "reset by rejection",
+			events: []event{{0, internal}, {time.Second, internal}, {0, rejected}, {time.Second, internal}},
+		},
+		{
+			name:   "rejections are not errors",
+			events: []event{{0, rejected}, {0, rejected}, {0, rejected}, {0, rejected}},
+		},
+		{
+			name:   "outside window",
+			events: []event{{0, internal}, {time.Second, internal}, {time.Minute, internal}},
+		},
+		{
+			name: "streak restarted after window",
+			events: []event{{0, internal}, {time.Minute, internal}, {time.Second, internal},
+				{time.Second, internal}},
+			wantUnhealthy: true,
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			now := time.Unix(0, 0)
+			var unhealthy []error
+			e := newValidatorErrors(3, 30*time.Second)
+			e.now = func() time.Time { return now }
+			e.onUnhealthy = func(err error) { unhealthy = append(unhealthy, err) }
+
+			for _, ev := range c.events {
+				now = now.Add(ev.after)
+				e.observe(ev.response)
+			}
+			if got := len(unhealthy) > 0; got != c.wantUnhealthy {
+				t.Fatalf("got unhealthy %v want %v", got, c.wantUnhealthy)
+			}
+			if c.wantUnhealthy && !strings.Contains(unhealthy[0].Error(), "cannot evaluate rego policy") {
+				t.Fatalf("got error %v want it to contain the last validator error", unhealthy[0])
+			}
+
+			// the validator is reported unhealthy only once
+			e.observe(internal)
+			e.observe(internal)
+			e.observe(internal)
+			if len(unhealthy) != 1 {
+				t.Fatalf("got %d unhealthy reports want 1", len(unhealthy))
+			}
+		})
+	}
+}
+
+func TestTrackValidatorErrors(t *testing.T) {
+	wh := &Webhook{}
+	admit := func(context.Context, *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+		return toInternalErrorResponse(errors.New("cannot list references"))
+	}
+	if resp := wh.trackValidatorErrors(admit)(context.Background(), &admissionv1beta1.AdmissionRequest{}); !isInternalError(resp) {
+		t.Fatalf("got %v want an internal error", resp)
+	}
+
+	var unhealthy bool
+	wh.validatorErrors = newValidatorErrors(2, time.Minute)
+	wh.validatorErrors.onUnhealthy = func(error) { unhealthy = true }
+	tracked := wh.trackValidatorErrors(admit)
+	for i := 0; i < 2; i++ {
+		tracked(context.Background(), &admissionv1beta1.AdmissionRequest{})
+	}
+	if !unhealthy {
+		t.Fatal("validator not reported unhealthy after reaching the threshold")
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"sync"
+
+	"github.com/ghodss/yaml"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// versionCache is a bounded LRU cache of the last accepted resourceVersion of
+// each object, by UID. Entries also record the namespace and name of the object,
+// and a digest of its body, so that different content submitted with the same
+// resourceVersion, e.g. after another admission plugin rejected an update, is
+// validated again.
+type versionCache struct {
+	size int
+
+	mu      sync.Mutex
+	entries map[types.UID]*list.Element
+	lru     *list.List
+}
+
+type versionEntry struct {
+	uid             types.UID
+	namespace       string
+	name            string
+	resourceVersion string
+	digest          [sha256.Size]byte
+}
+
+func newVersionCache(size int) *versionCache {
+	return &versionCache{
+		size:    size,
+		entries: make(map[types.UID]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// accepted returns true if the entry matches the last accepted version of its
+// object. The cached version is evicted if the object namespace or name changed.
+func (c *versionCache) accepted(entry versionEntry) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[entry.uid]
+	if !ok {
+		return false
+	}
+	cached := elem.Value.(versionEntry)
+	if cached.namespace != entry.namespace || cached.name != entry.name {
+		c.lru.Remove(elem)
+		delete(c.entries, entry.uid)
+		return false
+	}
+	if cached != entry {
+		return false
+	}
+	c.lru.MoveToFront(elem)
+	return true
+}
+
+// add records the entry as the last accepted version of its object, evicting
+// the least recently used object if the cache is full.
+func (c *versionCache) add(entry versionEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[entry.uid]; ok {
+		elem.Value = entry
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.entries[entry.uid] = c.lru.PushFront(entry)
+	if c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(versionEntry).uid)
+	}
+}
+
+// clear removes all entries, e.g. when the validators or the disabled rules change.
+func (c *versionCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[types.UID]*list.Element)
+	c.lru.Init()
+}
+
+// updateVersion returns the version entry of the object of an update request,
+// or false if the object has no UID or resourceVersion.
+func updateVersion(request *admissionv1beta1.AdmissionRequest) (versionEntry, bool) {
+	if request.Operation != admissionv1beta1.Update {
+		return versionEntry{}, false
+	}
+	var obj struct {
+		Metadata v1.ObjectMeta `json:"metadata"`
+	}
+	if err := yaml.Unmarshal(request.Object.Raw, &obj); err != nil {
+		return versionEntry{}, false
+	}
+	if obj.Metadata.UID == "" || obj.Metadata.ResourceVersion == "" {
+		return versionEntry{}, false
+	}
+	// the digest must resist collisions, as a body colliding with an accepted one is not validated
+	return versionEntry{
+		uid:             obj.Metadata.UID,
+		namespace:       obj.Metadata.Namespace,
+		name:            obj.Metadata.Name,
+		resourceVersion: obj.Metadata.ResourceVersion,
+		digest:          sha256.Sum256(request.Object.Raw),
+	}, true
+}
+
+// cacheVersions wraps an admitFunc so that a repeated update of an object with
+// the last accepted resourceVersion is accepted without validation.
+func (wh *Webhook) cacheVersions(admit admitFunc) admitFunc {
+	if wh.versionCache == nil {
+		return admit
+	}
+	return func(ctx context.Context, request *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+		entry, ok := updateVersion(request)
+		if !ok {
+			return admit(ctx, request)
+		}
+		if wh.versionCache.accepted(entry) {
+			requestLog(ctx).Debugf("Accepting update of %s %s/%s: resourceVersion %s was already accepted",
+				request.Kind.Kind, entry.namespace, entry.name, entry.resourceVersion)
+			reportValidationPass(request)
+			return wh.acceptResponse()
+		}
+
+		response := admit(ctx, request)
+		if response != nil && response.Allowed {
+			wh.versionCache.add(entry)
+		}
+		return response
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestVersionCache(t *testing.T) {
+	entry := func(uid, name, resourceVersion string) versionEntry {
+		return versionEntry{uid: types.UID("uid-" + uid), namespace: "default", name: name, resourceVersion: resourceVersion}
+	}
+
+	c := newVersionCache(2)
+	c.add(entry("a", "a", "1"))
+	if !c.accepted(entry("a", "a", "1")) {
+		t.Fatal("cached version not accepted")
+	}
+	if c.accepted(entry("a", "a", "2")) {
+		t.Fatal("new version accepted")
+	}
+	changed := entry("a", "a", "1")
+	changed.digest[0] = 1
+	if c.accepted(changed) {
+		t.Fatal("changed content accepted")
+	}
+
+	// a renamed object is evicted
+	if c.accepted(entry("a", "renamed", "1")) {
+		t.Fatal("renamed object accepted")
+	}
+	if c.accepted(entry("a", "a", "1")) {
+		t.Fatal("renamed object not evicted")
+	}
+
+	// the least recently used object is evicted
+	c.add(entry("a", "a", "1"))
+	c.add(entry("b", "b", "1"))
+	c.accepted(entry("a", "a", "1"))
+	c.add(entry("c", "c", "1"))
+	if c.accepted(entry("b", "b", "1")) {
+		t.Fatal("least recently used object not evicted")
+	}
+	if !c.accepted(entry("a", "a", "1")) || !c.accepted(entry("c", "c", "1")) {
+		t.Fatal("recently used objects evicted")
+	}
+
+	c.clear()
+	if c.accepted(entry("a", "a", "1")) {
+		t.Fatal("cleared cache accepted object")
+	}
+}
+
+func TestCacheVersions(t *testing.T) {
+	object := func(resourceVersion, spec string) []byte {
+		return []byte(fmt.Sprintf(`{"metadata": {"name": "foo", "namespace": "default", "uid": "uid", "resourceVersion": %q}, "spec": %s}`,
+			resourceVersion, spec))
+	}
+
+	wh := &Webhook{versionCache: newVersionCache(10)}
+	var validated int
+	admit := wh.cacheVersions(func(_ context.Context, request *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+		validated++
+		if string(request.Object.Raw) == string(object("1", `"bad"`)) {
+			return toAdmissionResponse(errors.New("invalid"))
+		}
+		return &admissionv1beta1.AdmissionResponse{Allowed: true}
+	})
+
+	cases := []struct {
+		name          string
+		operation     admissionv1beta1.Operation
+		raw           []byte
+		wantAllowed   bool
+		wantValidated bool
+	}{
+		{name: "first update", operation: admissionv1beta1.Update, raw: object("1", `"good"`), wantAllowed: true, wantValidated: true},
+		{name: "repeated update", operation: admissionv1beta1.Update, raw: object("1", `"good"`), wantAllowed: true},
+		{name: "same version, other content", operation: admissionv1beta1.Update, raw: object("1", `"bad"`), wantValidated: true},
+		{name: "new version", operation: admissionv1beta1.Update, raw: object("2", `"good"`), wantAllowed: true, wantValidated: true},
+		{name: "create", operation: admissionv1beta1.Create, raw: object("2", `"good"`), wantAllowed: true, wantValidated: true},
+		{name: "repeated new version", operation: admissionv1beta1.Update, raw: object("2", `"good"`), wantAllowed: true},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			before := validated
+			resp := admit(context.Background(), &admissionv1beta1.AdmissionRequest{
+				Operation: c.operation,
+				Object:    runtime.RawExtension{Raw: c.raw},
+			})
+			if resp.Allowed != c.wantAllowed {
+				t.Fatalf("got allowed %v want %v", resp.Allowed, c.wantAllowed)
+			}
+			if gotValidated := validated > before; gotValidated != c.wantValidated {
+				t.Fatalf("got validated %v want %v", gotValidated, c.wantValidated)
+			}
+		})
+	}
+
+	// a change of the disabled rules invalidates the accepted versions
+	wh.setDisabledRules(&corev1.ConfigMap{Data: map[string]string{disabledRulesConfigMapKey: RuleTLSSettings}})
+	before := validated
+	admit(context.Background(), &admissionv1beta1.AdmissionRequest{
+		Operation: admissionv1beta1.Update,
+		Object:    runtime.RawExtension{Raw: object("2", `"good"`)},
+	})
+	if validated == before {
+		t.Fatal("got the update accepted without validation after the disabled rules changed")
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Identifiers of the checks that are not optional rules, for WebhookParameters.ViolationCodes.
+const (
+	// CheckSchema validates objects against their schema, including the
+	// JSONSchemas and the schemas of the SchemaRegistryURL.
+	CheckSchema = "schema"
+
+	// CheckPolicy evaluates the Rego policies of the RegoPolicyDir.
+	CheckPolicy = "policy"
+)
+
+// CauseTypeViolationCode is the type of the causes of a rejection that carry the
+// violation code of a failed check as their message, and the offending field.
+const CauseTypeViolationCode v1.CauseType = "ViolationCode"
+
+// defaultViolationCodes are the stable codes of the checks, e.g. to link a
+// rejection to the documentation of its remediation.
+var defaultViolationCodes = map[string]string{
+	CheckSchema:               "IST0101",
+	RuleUnknownFields:         "IST0102",
+	RuleReferencedObjects:     "IST0103",
+	RuleGatewayHosts:          "IST0104",
+	RuleServiceEntryEndpoints: "IST0105",
+	RuleMetadataKeys:          "IST0106",
+	RuleSidecarSelector:       "IST0107",
+	RulePortNaming:            "IST0108",
+	RuleTLSSettings:           "IST0109",
+	RuleNamespaceExists:       "IST0110",
+	RuleGlobalNames:           "IST0111",
+	RuleRequiredLabels:        "IST0112",
+	RuleEmptySpec:             "IST0113",
+	CheckPolicy:               "IST0114",
+	RuleGatewayCredentials:    "IST0115",
+	RuleEnvoyFilterFields:     "IST0116",
+}
+
+// violationCodeRegexp matches the violation codes, which are also metric labels.
+var violationCodeRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// validateViolationCodes returns an error naming each unknown check and invalid code.
+func validateViolationCodes(codes map[string]string) error {
+	checks := make([]string, 0, len(codes))
+	for check := range codes {
+		checks = append(checks, check)
+	}
+	sort.Strings(checks)
+
+	var errs *multierror.Error
+	for _, check := range checks {
+		if _, ok := defaultViolationCodes[check]; !ok {
+			errs = multierror.Append(errs, fmt.Errorf("violation code for unknown check %q, want one of %s",
+				check, strings.Join(quoteAll(violationChecks()), ", ")))
+		}
+		if code := codes[check]; !violationCodeRegexp.MatchString(code) {
+			errs = multierror.Append(errs, fmt.Errorf("invalid violation code %q for check %q", code, check))
+		}
+	}
+	return errs.ErrorOrNil()
+}
+
+// violationChecks returns the sorted identifiers of the checks with a violation code.
+func violationChecks() []string {
+	checks := make([]string, 0, len(defaultViolationCodes))
+	for check := range defaultViolationCodes {
+		checks = append(checks, check)
+	}
+	sort.Strings(checks)
+	return checks
+}
+
+// mergeViolationCodes returns the default violation codes, replaced by those configured.
+func mergeViolationCodes(configured map[string]string) map[string]string {
+	codes := make(map[string]string, len(defaultViolationCodes))
+	for check, code := range defaultViolationCodes {
+		codes[check] = code
+	}
+	for check, code := range configured {
+		codes[check] = code
+	}
+	return codes
+}
+
+// violationCause returns the cause recording the violation code of a check that
+// failed on the field.
+func violationCause(code, field string) v1.StatusCause {
+	return v1.StatusCause{Type: CauseTypeViolationCode, Message: code, Field: field}
+}
+
+// withViolation records the violation code of the check, if any, in the causes
+// of the rejection and reports it. The field is the offending field, if known.
+func (wh *Webhook) withViolation(request *admissionv1beta1.AdmissionRequest, check, field string,
+	response *admissionv1beta1.AdmissionResponse) *admissionv1beta1.AdmissionResponse {
+	code := wh.violationCodes[check]
+	if code == "" || response == nil || response.Result == nil {
+		return response
+	}
+	if response.Result.Details == nil {
+		response.Result.Details = &v1.StatusDetails{
+			Name:  request.Name,
+			Group: request.Kind.Group,
+			Kind:  request.Kind.Kind,
+		}
+	}
+	response.Result.Details.Causes = append(response.Result.Details.Causes, violationCause(code, field))
+	reportViolation(request, check, code)
+	return response
+}
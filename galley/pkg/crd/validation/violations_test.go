@@ -0,0 +1,155 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestValidateViolationCodes(t *testing.T) {
+	cases := []struct {
+		name    string
+		codes   map[string]string
+		wantErr string
+	}{
+		{name: "none"},
+		{name: "overrides", codes: map[string]string{CheckSchema: "ACME-0001", RuleGatewayHosts: "ACME.2"}},
+		{name: "unknown check", codes: map[string]string{"hosts": "ACME1"}, wantErr: `violation code for unknown check "hosts"`},
+		{name: "empty code", codes: map[string]string{CheckPolicy: ""}, wantErr: `invalid violation code "" for check "policy"`},
+		{name: "invalid code", codes: map[string]string{CheckPolicy: "ACME 1"}, wantErr: `invalid violation code "ACME 1"`},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			err := validateViolationCodes(c.codes)
+			if c.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+				t.Fatalf("got %v want error containing %q", err, c.wantErr)
+			}
+		})
+	}
+
+	// every check has a distinct default code
+	owners := make(map[string]string)
+	for _, check := range append(append([]string(nil), validationRules...), CheckSchema, CheckPolicy) {
+		code, ok := defaultViolationCodes[check]
+		if !ok {
+			t.Fatalf("check %q has no default violation code", check)
+		}
+		if owner, ok := owners[code]; ok {
+			t.Fatalf("checks %q and %q share the violation code %q", owner, check, code)
+		}
+		owners[code] = check
+	}
+}
+
+func TestAdmitPilotViolationCodes(t *testing.T) {
+	wh, cancel := createTestWebhook(t, dummyClient, createFakeEndpointsSource(), dummyConfig)
+	defer cancel()
+	wh.rejectUnknownFields = true
+
+	cases := []struct {
+		name            string
+		reportAllErrors bool
+		codes           map[string]string
+		raw             []byte
+		want            []metav1.StatusCause
+	}{
+		{name: "valid", raw: makePilotConfig(t, 0, true, false)},
+		{
+			name: "invalid spec",
+			raw:  makePilotConfig(t, 0, false, false),
+			want: []metav1.StatusCause{violationCause("IST0101", "spec")},
+		},
+		{
+			name: "unknown field",
+			raw:  makePilotConfig(t, 0, true, true),
+			want: []metav1.StatusCause{violationCause("IST0102", "unexpected_key")},
+		},
+		{
+			name:  "configured code",
+			codes: map[string]string{CheckSchema: "ACME-0001"},
+			raw:   makePilotConfig(t, 0, false, false),
+			want:  []metav1.StatusCause{violationCause("ACME-0001", "spec")},
+		},
+		{
+			name:            "all errors",
+			reportAllErrors: true,
+			raw:             makePilotConfig(t, 0, false, true),
+			want:            []metav1.StatusCause{violationCause("IST0101", "spec"), violationCause("IST0102", "unexpected_key")},
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			wh.reportAllErrors = c.reportAllErrors
+			wh.violationCodes = mergeViolationCodes(c.codes)
+			resp := wh.admitPilot(context.Background(), &admissionv1beta1.AdmissionRequest{
+				Kind:      metav1.GroupVersionKind{Kind: "mock"},
+				Object:    runtime.RawExtension{Raw: c.raw},
+				Operation: admissionv1beta1.Create,
+			})
+			if len(c.want) == 0 {
+				if !resp.Allowed {
+					t.Fatalf("got rejected, want allowed: %v", resp.Result)
+				}
+				return
+			}
+			if resp.Allowed || resp.Result.Details == nil {
+				t.Fatalf("got %v want a rejection with details", resp.Result)
+			}
+			var got []metav1.StatusCause
+			for _, cause := range resp.Result.Details.Causes {
+				if cause.Type == CauseTypeViolationCode {
+					got = append(got, cause)
+				}
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Fatalf("got violation causes %v want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestValidationReportViolations(t *testing.T) {
+	request := &admissionv1beta1.AdmissionRequest{Kind: metav1.GroupVersionKind{Kind: "mock"}}
+	report := validationReport{maxCauses: 1, codes: defaultViolationCodes}
+	report.add(CheckSchema, "spec", fmt.Errorf("first"))
+	report.add(CheckSchema, "spec", fmt.Errorf("second"))
+	report.add(RuleGatewayHosts, "spec.servers", fmt.Errorf("third"))
+
+	resp := report.response(request, "name")
+	want := []metav1.StatusCause{
+		{Type: metav1.CauseTypeFieldValueInvalid, Message: "first", Field: "spec"},
+		violationCause("IST0101", "spec"),
+		violationCause("IST0104", "spec.servers"),
+	}
+	if got := resp.Result.Details.Causes; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got causes %v want %v", got, want)
+	}
+}
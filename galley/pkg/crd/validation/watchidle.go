@@ -0,0 +1,96 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/howeyc/fsnotify"
+)
+
+// newDirWatcher watches the parent directories of the files, so that symlink
+// updates of mounted secrets and ConfigMaps are caught.
+func newDirWatcher(files []string) (*fsnotify.Watcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		watchDir, _ := filepath.Split(file)
+		if err := watcher.Watch(watchDir); err != nil {
+			watcher.Close() // nolint: errcheck
+			return nil, fmt.Errorf("could not watch %v: %v", file, err)
+		}
+	}
+	return watcher, nil
+}
+
+// rewatch replaces the watcher of the files with a new one, after it produced no
+// events for the idle period. The watcher is kept if it cannot be replaced.
+func rewatch(name string, watcher *fsnotify.Watcher, files []string, idle time.Duration) *fsnotify.Watcher {
+	scope.Warnf("No events from the %s watch for %v, restarting it", name, idle)
+	replacement, err := newDirWatcher(files)
+	if err != nil {
+		scope.Errorf("Cannot restart the %s watch: %v", name, err)
+		return watcher
+	}
+	watcher.Close() // nolint: errcheck
+	return replacement
+}
+
+// idleTimer fires once a watch has produced no events for the period, see
+// WatchIdleRestart. It never fires if the period is not positive.
+type idleTimer struct {
+	period time.Duration
+	timer  *time.Timer
+}
+
+func newIdleTimer(period time.Duration) *idleTimer {
+	t := &idleTimer{period: period}
+	if period > 0 {
+		t.timer = time.NewTimer(period)
+	}
+	return t
+}
+
+// C returns the channel the timer fires on, which is nil if it is disabled.
+func (t *idleTimer) C() <-chan time.Time {
+	if t.timer == nil {
+		return nil
+	}
+	return t.timer.C
+}
+
+// reset starts a new idle period, e.g. on an event of the watch or after it fired.
+func (t *idleTimer) reset() {
+	if t.timer == nil {
+		return
+	}
+	if !t.timer.Stop() {
+		select {
+		case <-t.timer.C:
+		default:
+		}
+	}
+	t.timer.Reset(t.period)
+}
+
+func (t *idleTimer) stop() {
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validation
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestIdleTimer(t *testing.T) {
+	disabled := newIdleTimer(0)
+	disabled.reset()
+	disabled.stop()
+	if disabled.C() != nil {
+		t.Fatal("disabled idle timer has a channel")
+	}
+
+	idle := newIdleTimer(50 * time.Millisecond)
+	defer idle.stop()
+
+	// events postpone the timer
+	deadline := time.Now().Add(150 * time.Millisecond)
+	for time.Now().Before(deadline) {
+		select {
+		case <-idle.C():
+			t.Fatal("idle timer fired despite events")
+		case <-time.After(10 * time.Millisecond):
+			idle.reset()
+		}
+	}
+
+	select {
+	case <-idle.C():
+	case <-time.After(5 * time.Second):
+		t.Fatal("idle timer did not fire without events")
+	}
+
+	// it fires again after a reset
+	idle.reset()
+	select {
+	case <-idle.C():
+	case <-time.After(5 * time.Second):
+		t.Fatal("idle timer did not fire after a reset")
+	}
+}
+
+func TestRewatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "galley_validation_rewatch")
+	if err != nil {
+		t.Fatalf("TempDir() failed: %v", err)
+	}
+	defer os.RemoveAll(dir) // nolint: errcheck
+	file := filepath.Join(dir, "cert-file.yaml")
+
+	watcher, err := newDirWatcher([]string{file})
+	if err != nil {
+		t.Fatalf("newDirWatcher() failed: %v", err)
+	}
+	watcher = rewatch("test", watcher, []string{file}, time.Minute)
+	defer watcher.Close() // nolint: errcheck
+
+	if err := ioutil.WriteFile(file, []byte("cert"), 0644); err != nil {
+		t.Fatalf("WriteFile(%v) failed: %v", file, err)
+	}
+	select {
+	case <-watcher.Event:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event from the restarted watch")
+	}
+
+	// the watcher is kept if it cannot be replaced
+	missing := filepath.Join(dir, "missing", "key-file.yaml")
+	if got := rewatch("test", watcher, []string{missing}, time.Minute); got != watcher {
+		t.Fatal("watcher replaced although the new watch failed")
+	}
+}
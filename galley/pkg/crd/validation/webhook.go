@@ -16,28 +16,45 @@ package validation
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
-	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ghodss/yaml"
+	"github.com/gogo/protobuf/proto"
+	"github.com/hashicorp/go-multierror"
 	"github.com/howeyc/fsnotify"
+	"golang.org/x/sync/singleflight"
+	"google.golang.org/grpc"
 	admissionv1beta1 "k8s.io/api/admission/v1beta1"
 	"k8s.io/api/admissionregistration/v1beta1"
+	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/runtime"
+	kubeschema "k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/client-go/dynamic"
 	clientset "k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
 
+	networking "istio.io/api/networking/v1alpha3"
+
 	mixerCrd "istio.io/istio/mixer/pkg/config/crd"
 	"istio.io/istio/mixer/pkg/config/store"
 	"istio.io/istio/pilot/pkg/config/kube/crd"
@@ -69,6 +86,31 @@ const (
 	retryUpdateAfterFailureTimeout = time.Second
 
 	httpsHandlerReadyPath = "/ready"
+
+	defaultReadyTimeout = time.Second
+
+	defaultPilotAdmissionPath = "/admitpilot"
+	defaultMixerAdmissionPath = "/admitmixer"
+
+	// defaultRequestDeadlineMargin leaves time to send the response before the API server times out.
+	defaultRequestDeadlineMargin = time.Second
+
+	// defaultTerminationGracePeriod is shorter than the default pod terminationGracePeriodSeconds.
+	defaultTerminationGracePeriod = 20 * time.Second
+
+	defaultSkipAnnotation = "validation.istio.io/skip"
+
+	defaultWebhookConfigResyncInterval = time.Minute
+
+	defaultCertExpiryWarningThreshold = 7 * 24 * time.Hour
+
+	// maxRequestBytes is the maximum size of an admission request body, after
+	// decompression.
+	maxRequestBytes = 10 << 20
+
+	defaultResponseCompressionThreshold = 32 << 10
+
+	defaultMaxReportedErrors = 20
 )
 
 // WebhookParameters contains the configuration for the Istio Pilot validation
@@ -124,8 +166,460 @@ type WebhookParameters struct {
 
 	// Enable reconcile validatingwebhookconfiguration
 	EnableReconcileWebhookConfiguration bool
+
+	// EnableMixerValidation enables validation of mixer configuration. When disabled
+	// the mixer validator is not constructed and mixer resources are admitted as-is.
+	EnableMixerValidation bool
+
+	// DisableKeepAlives disables HTTP keep-alives on the webhook server. Every admission
+	// request then pays for a new TCP and TLS handshake, but the apiserver no longer
+	// holds idle connections open and new connections are spread across replicas.
+	DisableKeepAlives bool
+
+	// ListenConfig, if set, is used to create the webhook listener, e.g. to set
+	// socket options via Control or to tune TCP keep-alive probes.
+	ListenConfig *net.ListenConfig
+
+	// ListenBacklog, if greater than zero, sets the accept queue length of the webhook
+	// listener. A larger backlog absorbs connection bursts from the apiserver instead of
+	// resetting them, at the cost of kernel memory and added latency for queued
+	// connections. The kernel caps the value at net.core.somaxconn. Linux only.
+	ListenBacklog int
+
+	// AllowSkipAnnotation allows individual objects to opt out of validation by
+	// setting SkipAnnotation to a true value. Disabled by default so validation
+	// cannot be bypassed unless explicitly permitted by the operator.
+	AllowSkipAnnotation bool
+
+	// SkipAnnotation is the annotation used to opt an object out of validation
+	// when AllowSkipAnnotation is set.
+	SkipAnnotation string
+
+	// AcceptMessage, if set, is returned as the status message of admission
+	// responses that allow the object.
+	AcceptMessage string
+
+	// GroupAliases maps the API group of incoming objects to the canonical group
+	// of the pilot schema used to validate them, e.g. after an API group rename.
+	// Every canonical group must be present in PilotDescriptor.
+	GroupAliases map[string]string
+
+	// StatusPort, if set, serves the readiness and other status endpoints over plain
+	// HTTP on a port separate from the admission port, e.g. so network policies and
+	// probes can target it independently. Readiness is still served on the admission
+	// port so the TLS handler itself can be verified.
+	StatusPort uint
+
+	// DecisionSink, if set, is called with every admission decision, e.g. to feed an
+	// external audit system. See DecisionSink for delivery guarantees.
+	DecisionSink DecisionSink
+
+	// ApplyDefaultsBeforeValidate applies Defaulter to pilot configuration before it is
+	// validated, so rules that depend on defaulted fields see the effective object.
+	ApplyDefaultsBeforeValidate bool
+
+	// Defaulter fills in defaults for the spec of the given schema. It is only applied
+	// to the copy used for validation; the admitted object is never modified. Defaults
+	// to the built-in Istio defaults, see defaultPilotSpec.
+	Defaulter Defaulter
+
+	// CertClockSkew is the clock skew tolerated when checking that the serving
+	// certificate is within its validity period. A certificate that is not yet
+	// valid or has expired is reported when it is loaded, instead of surfacing
+	// later as an opaque TLS handshake failure.
+	CertClockSkew time.Duration
+
+	// EnableDebugEndpoints serves the /debug endpoints, e.g. to change the log level
+	// at runtime or to export a support bundle, alongside the readiness endpoint.
+	EnableDebugEndpoints bool
+
+	// DebugToken must be presented as a bearer token to access the debug
+	// endpoints. It is required if EnableDebugEndpoints is set.
+	DebugToken string
+
+	// SkipUnchangedSpecOnUpdate admits updates that only change object metadata,
+	// e.g. labels set by controllers, without validating the unchanged spec again.
+	SkipUnchangedSpecOnUpdate bool
+
+	// Decoder, if set, decodes admitted objects instead of the default JSON/YAML
+	// decoding, e.g. to support custom or forked types registered in a runtime.Scheme.
+	Decoder runtime.Decoder
+
+	// ProtectReferencedObjects rejects the deletion of Gateways that are still
+	// referenced by VirtualServices. The webhook configuration must include the
+	// DELETE operation for gateways. The check is best-effort: the VirtualServices
+	// are listed from the API server when the delete is admitted, so a referencing
+	// VirtualService created concurrently with the delete is not observed.
+	ProtectReferencedObjects bool
+
+	// RegoPolicyDir, if set, is the directory of Rego policies, e.g. a mounted
+	// ConfigMap, that are evaluated against admitted objects after the schema
+	// validation. A request is rejected if any policy denies it.
+	RegoPolicyDir string
+
+	// ReadyTimeout bounds the time the readiness handler takes to respond. The
+	// handler responds with 503 Service Unavailable when the bound is exceeded.
+	// Zero disables the bound.
+	ReadyTimeout time.Duration
+
+	// PilotAdmissionPath is the path pilot configuration is admitted on. Webhooks
+	// of the webhook configuration served on /admitpilot are registered with this
+	// path instead. Empty uses /admitpilot.
+	PilotAdmissionPath string
+
+	// MixerAdmissionPath is the path mixer configuration is admitted on. Webhooks
+	// of the webhook configuration served on /admitmixer are registered with this
+	// path instead. Empty uses /admitmixer.
+	MixerAdmissionPath string
+
+	// TerminationGracePeriod bounds the time the webhook drains in-flight admission
+	// requests when it is stopped, e.g. on SIGTERM. It should be shorter than the
+	// terminationGracePeriodSeconds of the pod. Zero closes connections immediately.
+	TerminationGracePeriod time.Duration
+
+	// StrictGateway rejects Gateways that declare duplicate or overlapping hosts
+	// on the same port in different servers, in addition to the schema validation.
+	StrictGateway bool
+
+	// StrictServiceEntry rejects ServiceEntries whose endpoints are inconsistent
+	// with their resolution, i.e. STATIC without endpoints or DNS with IP endpoints.
+	StrictServiceEntry bool
+
+	// StrictMetadataKeys rejects objects whose label or annotation keys are not
+	// Kubernetes qualified names, naming the offending keys.
+	StrictMetadataKeys bool
+
+	// StrictSidecar rejects a Sidecar without workloadSelector in a namespace that
+	// already has one, naming the existing Sidecar. Pilot applies only one of them.
+	StrictSidecar bool
+
+	// StrictPortNaming rejects Gateway and ServiceEntry ports whose name is not
+	// prefixed by their protocol, e.g. http-web, suggesting the correct name.
+	StrictPortNaming bool
+
+	// StrictTLSSettings rejects Gateway and DestinationRule TLS settings that are
+	// inconsistent with their mode, e.g. certificates of a PASSTHROUGH server.
+	StrictTLSSettings bool
+
+	// StrictEnvoyFilter rejects EnvoyFilters that use the deprecated workloadLabels
+	// or filters rather than workloadSelector and configPatches.
+	StrictEnvoyFilter bool
+
+	// EmitRejectionEvents records a Warning event for rejected objects, at most
+	// once a minute for each object, so that rejections show up in `kubectl describe`.
+	EmitRejectionEvents bool
+
+	// ReadinessHeartbeatInterval, if set, is the interval at which the readiness
+	// check logs that the webhook is still ready. Transitions are always logged.
+	ReadinessHeartbeatInterval time.Duration
+
+	// VerifyCertDNSNames fails startup if the server certificate is not valid for
+	// the service DNS name, i.e. <ServiceName>.<DeploymentAndServiceNamespace>.svc,
+	// which the API server verifies when calling the webhook.
+	VerifyCertDNSNames bool
+
+	// ReportAllErrors rejects invalid objects with all of their validation errors,
+	// listed in Status.Details.Causes, rather than only the first.
+	ReportAllErrors bool
+
+	// ClientCAFile is the path to the x509 CA bundle used to verify client
+	// certificates. Client certificates are verified if presented.
+	ClientCAFile string
+
+	// RequireClientCert rejects connections without a client certificate signed
+	// by a CA in ClientCAFile, e.g. to only allow the API server to call the webhook.
+	RequireClientCert bool
+
+	// ReadinessClientCertFile and ReadinessClientKeyFile are the x509 certificate
+	// and private key presented by the readiness check. They are required if
+	// RequireClientCert is set, and are reloaded on each connection.
+	ReadinessClientCertFile string
+	ReadinessClientKeyFile  string
+
+	// EnforcementConfigMapName, if set, is the name of a ConfigMap in the
+	// DeploymentAndServiceNamespace that sets the enforcement mode at runtime, e.g.
+	// to pause enforcement in an emergency. The EnforcementConfigMapKey value is
+	// "on" to reject invalid objects, "warn" to admit them with a warning log, or
+	// "off" to admit all objects. Validation is enforced if the ConfigMap is missing.
+	EnforcementConfigMapName string
+
+	// EnforcementConfigMapKey is the key of the enforcement mode in the ConfigMap.
+	EnforcementConfigMapKey string
+
+	// SchemaRegistryURL, if set, is the HTTP endpoint of a schema registry serving
+	// OpenAPI v3 schemas that objects are validated against in addition to the
+	// built-in validation. Objects are rejected until the schemas have been fetched
+	// once; afterwards the cached schemas are used if a refresh fails.
+	SchemaRegistryURL string
+
+	// SchemaRegistryRefreshInterval is the interval at which the schemas are
+	// refetched from the schema registry, or 0 to only fetch them at startup.
+	SchemaRegistryRefreshInterval time.Duration
+
+	// PerNamespaceConcurrency, if set, is the maximum number of admission requests
+	// handled concurrently for each namespace. Further requests wait for a slot and
+	// are rejected if none becomes available in time.
+	PerNamespaceConcurrency int
+
+	// StrictnessProfile is a preset of the optional checks. See StrictnessProfile
+	// for the checks enabled by each profile.
+	StrictnessProfile StrictnessProfile
+
+	// AuditLogDecisions logs each admission decision as a JSON line on the
+	// validation-audit log scope, e.g. for SIEM ingestion.
+	AuditLogDecisions bool
+
+	// ObjectSelector, if set, is written into each webhook of the
+	// validatingwebhookconfiguration, so that only objects with matching labels
+	// are sent for validation.
+	ObjectSelector *v1.LabelSelector
+
+	// RuleOperations overrides, by resource, the operations the rules of the
+	// validatingwebhookconfiguration apply to, e.g. to validate gateways only on
+	// CREATE. Resources that are not listed keep the operations of their rule.
+	RuleOperations map[string][]v1beta1.OperationType
+
+	// DisableWebhookConfigEnforcement stops restoring the validatingwebhookconfiguration
+	// from the desired configuration when it is deleted or modified by someone else.
+	DisableWebhookConfigEnforcement bool
+
+	// WebhookConfigResyncInterval, if set, is the interval at which the
+	// validatingwebhookconfiguration is reconciled unless DisableWebhookConfigEnforcement
+	// is set, in case a change was not observed by the watch.
+	WebhookConfigResyncInterval time.Duration
+
+	// WatchIdleRestart, if set, restarts the file watches of the certificates and
+	// the webhook configuration, and the watch of the validatingwebhookconfiguration
+	// if it is enforced, once they produce no events for this long, in case they
+	// stalled silently. The watched objects are reloaded after a restart.
+	WatchIdleRestart time.Duration
+
+	// EnforceRequestDeadline rejects admission requests that take longer than the
+	// timeoutSeconds of their webhook in the WebhookConfigFile, less the
+	// RequestDeadlineMargin, with a Timeout status, since the API server no longer
+	// waits for them. The timeouts are read when the webhook is created.
+	EnforceRequestDeadline bool
+	RequestDeadlineMargin  time.Duration
+
+	// ValidationPipeline are the ordered stages objects are validated by. It
+	// defaults to the schema, registry and policy stages, stopping at the first
+	// failure.
+	ValidationPipeline []ValidationStage
+
+	// CertExpiryWarningThreshold, if set, logs a warning when the serving
+	// certificate is loaded and expires within the threshold.
+	CertExpiryWarningThreshold time.Duration
+
+	// CheckServiceReachability additionally checks readiness through the
+	// validation service, as the API server reaches the webhook, instead of only
+	// on the loopback address. The service must have ready endpoints.
+	CheckServiceReachability bool
+
+	// RedactedFields are the paths of object fields, e.g.
+	// spec.servers[*].tls.privateKey, whose values are removed from rejected
+	// objects and rejection reasons before they are logged or recorded in events.
+	RedactedFields []string
+
+	// DisableLivenessProbe skips registering the validation liveness probe in
+	// RunValidation, even if a liveness probe controller is passed.
+	DisableLivenessProbe bool
+
+	// ResponseCompressionThreshold is the size in bytes above which admission
+	// responses are gzip encoded, if the API server accepts gzip. Zero disables
+	// compression.
+	ResponseCompressionThreshold int
+
+	// MaxReportedErrors bounds the validation errors reported by ReportAllErrors.
+	// Objects with more errors are still rejected, with a note of the number of
+	// errors left out. Zero reports all errors.
+	MaxReportedErrors int
+
+	// LifecycleObserver, if set, is notified when the webhook server starts, the
+	// webhook is registered and ready, and when the webhook stops.
+	LifecycleObserver LifecycleObserver
+
+	// ResourceVersionCacheSize is the number of objects whose last accepted
+	// resourceVersion is cached, so that controllers re-submitting an unchanged
+	// update of the same version are accepted without validation. Zero disables
+	// the cache.
+	ResourceVersionCacheSize int
+
+	// DeduplicateConcurrentRequests validates concurrent identical admission
+	// requests, e.g. retries of a CREATE, once and shares the response between
+	// them. Requests are identical if they differ only by their UID.
+	DeduplicateConcurrentRequests bool
+
+	// BindAddress is the IP address the admission server listens on, e.g. to
+	// restrict it to one interface of a multi-homed node. Empty listens on all
+	// interfaces. The readiness check connects to the bind address.
+	BindAddress string
+
+	// RequiredLabels are the labels that created and updated objects must carry,
+	// e.g. owner and team. Objects lacking any of them are rejected.
+	RequiredLabels []string
+
+	// RequiredLabelsExemptNamespaces are the namespaces, e.g. istio-system, whose
+	// objects are not required to carry RequiredLabels.
+	RequiredLabelsExemptNamespaces []string
+
+	// JSONSchemas are JSON Schema documents that objects of each kind are validated
+	// against, in addition to the built-in validation. Objects of a kind without a
+	// built-in validator, e.g. a custom resource, are validated only against its
+	// schema. The schemas are compiled on startup.
+	JSONSchemas map[kubeschema.GroupVersionKind]json.RawMessage
+
+	// ValidatorErrorRestartThreshold is the number of consecutive internal validator
+	// errors, e.g. policies that cannot be evaluated, after which the liveness probe
+	// fails so that the pod is restarted. Rejections of invalid objects are not
+	// errors. Zero disables the check.
+	ValidatorErrorRestartThreshold int
+
+	// ValidatorErrorRestartWindow is the interval within which the consecutive
+	// errors must occur to count towards ValidatorErrorRestartThreshold.
+	ValidatorErrorRestartWindow time.Duration
+
+	// DebugSampleRate is the fraction, from 0 to 1, of admission requests whose
+	// debug logs, e.g. the rejected object, are logged when the debug level is
+	// enabled. Requests are sampled by UID.
+	DebugSampleRate float64
+
+	// GRPCAddress, if set, is the address, e.g. :9444, of a gRPC server exposing
+	// the validators to tools outside Kubernetes, see validationpb. It serves with
+	// the same cert as the admission server.
+	GRPCAddress string
+
+	// ReadinessHTTPMethod is the HTTP method, GET or HEAD, of the readiness check
+	// of the admission server. The readiness endpoint answers both.
+	ReadinessHTTPMethod string
+
+	// DecisionOutput, if set, receives each admission decision as an NDJSON line
+	// with the fields of the audit log entry, independently of the logging
+	// configuration. It is written by the decision sink, see DecisionSink.
+	DecisionOutput io.Writer
+
+	// DisabledRules turns off the optional validation rules by identifier, e.g.
+	// RuleGatewayHosts, without changing the fields that enable them. If the
+	// enforcement ConfigMap has a "disabledRules" key, its comma separated rules
+	// replace these at runtime.
+	DisabledRules map[string]bool
+
+	// ViolationCodes replace the stable codes of the checks, by rule identifier
+	// or CheckSchema and CheckPolicy, e.g. to link to in-house documentation.
+	// Each rejection by a check lists its code in a cause of CauseTypeViolationCode.
+	ViolationCodes map[string]string
+
+	// PreflightAPICheck confirms that the API server is reachable before the
+	// webhook is created, retrying a few times, so that RunValidation fails early
+	// with a clear error rather than later during registration.
+	PreflightAPICheck bool
+
+	// RejectEmptySpec rejects objects whose spec has no fields set, for the kinds
+	// where that has no effect, e.g. a VirtualService without hosts or routes.
+	RejectEmptySpec bool
+
+	// StandbyMode serves the webhook but leaves the validatingwebhookconfiguration
+	// to another Galley, i.e. it is neither registered nor enforced, e.g. for
+	// blue/green control plane deploys. The "standby" key of the enforcement
+	// ConfigMap, "true" or "false", switches the mode at runtime.
+	StandbyMode bool
+
+	// VerifyNamespaceExists rejects created and updated objects whose namespace
+	// does not exist, e.g. because of a typo. The existence of namespaces is
+	// looked up with the Clientset and cached briefly.
+	VerifyNamespaceExists bool
+
+	// CheckGatewayCredentials looks up the Secrets referenced by the credentialName
+	// of Gateway servers in the namespace of the Gateway with the Clientset, and
+	// logs a warning naming those that do not exist, as the listeners using them
+	// are broken. RejectMissingGatewayCredentials rejects the Gateway instead.
+	CheckGatewayCredentials         bool
+	RejectMissingGatewayCredentials bool
+
+	// AllowNamespaceMaintenanceMode validates the objects of namespaces annotated
+	// with validation.istio.io/mode=warn warn-only, as with the "warn" enforcement
+	// mode, so that teams can relax validation during maintenance of their
+	// namespace. The namespaces are watched with the Clientset.
+	AllowNamespaceMaintenanceMode bool
+
+	// DegradeReferenceChecks admits objects, logging a warning, when a check that
+	// lists other objects on the API server, i.e. ProtectReferencedObjects,
+	// StrictSidecar, VerifyNamespaceExists, CheckGatewayCredentials and
+	// EnforceGlobalNameUniqueness,
+	// cannot reach it, rather than rejecting them. The object is still validated
+	// otherwise.
+	DegradeReferenceChecks bool
+
+	// EnforceGlobalNameUniqueness rejects the creation of an object whose name is
+	// already used by an object of the same kind in another namespace, for the
+	// GlobalNameUniquenessKinds, e.g. Gateway. The objects are listed with the
+	// Clientset, so two objects created concurrently may still share a name.
+	EnforceGlobalNameUniqueness bool
+	GlobalNameUniquenessKinds   []string
+
+	// UnhandledOperationPolicy is the response to operations other than CREATE,
+	// UPDATE and DELETE. See UnhandledOperationPolicy.
+	UnhandledOperationPolicy UnhandledOperationPolicy
+
+	// DefaultDecisionForUnmatched, if set, is the response to created and updated
+	// objects of a kind without validation. See UnmatchedDecision for the default.
+	DefaultDecisionForUnmatched UnmatchedDecision
+
+	// PanicResponseCode and PanicResponseReason are the status code and reason
+	// of the rejection of a request whose validation panicked, so that clients
+	// can tell a validator bug from a rejection of the object. They default to
+	// 500 and InternalError. Only rejections of code 500 count towards the
+	// ValidatorErrorRestartThreshold.
+	PanicResponseCode   int32
+	PanicResponseReason v1.StatusReason
+
+	// AdditionalCACertFiles and CABundleSecrets are CA bundles appended to the
+	// CACertFile in the caBundle of the validatingwebhookconfiguration, e.g. so
+	// that the configuration is shared by clusters with different CAs. The
+	// secrets are namespace/name references to Secrets with a "ca.crt" key, read
+	// whenever the configuration is rebuilt. Duplicate certificates are omitted.
+	AdditionalCACertFiles []string
+	CABundleSecrets       []string
+
+	// PreValidateTransform, if set, rewrites created and updated objects before
+	// they are validated. See PreValidateTransform.
+	PreValidateTransform PreValidateTransform
+
+	// SchemaSnapshotFile is a file of pilot schemas to validate against instead
+	// of the built-in schemas, e.g. to reproduce the decisions of a past release.
+	// Each schema must have the type and message of a built-in schema. The
+	// identifier of the schemas in use is recorded with the decisions.
+	SchemaSnapshotFile string
+
+	// ObjectCache serves the lookups of the checks that list other objects, i.e.
+	// ProtectReferencedObjects, StrictSidecar and EnforceGlobalNameUniqueness,
+	// from shared informers rather than the API server. The webhook is not ready
+	// until the informers are synced. It requires the DynamicClient.
+	ObjectCache bool
+
+	// ObjectCacheResyncPeriod is the resync period of the informers of the
+	// ObjectCache. Zero disables the resync.
+	ObjectCacheResyncPeriod time.Duration
+
+	// DynamicClient is the client of the informers of the ObjectCache. If nil,
+	// RunValidation creates it from the kubeconfig.
+	DynamicClient dynamic.Interface
 }
 
+// admissionPaths returns the paths pilot and mixer configuration are admitted on.
+func (p *WebhookParameters) admissionPaths() (pilot, mixer string) {
+	pilot, mixer = p.PilotAdmissionPath, p.MixerAdmissionPath
+	if pilot == "" {
+		pilot = defaultPilotAdmissionPath
+	}
+	if mixer == "" {
+		mixer = defaultMixerAdmissionPath
+	}
+	return pilot, mixer
+}
+
+// Defaulter applies defaults in-place to the spec of a pilot configuration resource.
+type Defaulter func(s schema.Instance, spec proto.Message)
+
 type createInformerEndpointSource func(cl clientset.Interface, namespace, name string) cache.ListerWatcher
 
 var (
@@ -154,6 +648,92 @@ func (p *WebhookParameters) String() string {
 	fmt.Fprintf(buf, "ServiceName: %s\n", p.ServiceName)
 	fmt.Fprintf(buf, "EnableValidation: %v\n", p.EnableValidation)
 	fmt.Fprintf(buf, "EnableReconcileWebhookConfiguration: %v\n", p.EnableReconcileWebhookConfiguration)
+	fmt.Fprintf(buf, "EnableMixerValidation: %v\n", p.EnableMixerValidation)
+	fmt.Fprintf(buf, "DisableKeepAlives: %v\n", p.DisableKeepAlives)
+	fmt.Fprintf(buf, "ListenBacklog: %d\n", p.ListenBacklog)
+	fmt.Fprintf(buf, "AllowSkipAnnotation: %v\n", p.AllowSkipAnnotation)
+	fmt.Fprintf(buf, "SkipAnnotation: %s\n", p.SkipAnnotation)
+	fmt.Fprintf(buf, "AcceptMessage: %s\n", p.AcceptMessage)
+	fmt.Fprintf(buf, "GroupAliases: %v\n", p.GroupAliases)
+	fmt.Fprintf(buf, "StatusPort: %d\n", p.StatusPort)
+	fmt.Fprintf(buf, "ApplyDefaultsBeforeValidate: %v\n", p.ApplyDefaultsBeforeValidate)
+	fmt.Fprintf(buf, "CertClockSkew: %v\n", p.CertClockSkew)
+	fmt.Fprintf(buf, "EnableDebugEndpoints: %v\n", p.EnableDebugEndpoints)
+	fmt.Fprintf(buf, "SkipUnchangedSpecOnUpdate: %v\n", p.SkipUnchangedSpecOnUpdate)
+	fmt.Fprintf(buf, "ProtectReferencedObjects: %v\n", p.ProtectReferencedObjects)
+	fmt.Fprintf(buf, "RegoPolicyDir: %s\n", p.RegoPolicyDir)
+	fmt.Fprintf(buf, "ReadyTimeout: %v\n", p.ReadyTimeout)
+	fmt.Fprintf(buf, "PilotAdmissionPath: %s\n", p.PilotAdmissionPath)
+	fmt.Fprintf(buf, "MixerAdmissionPath: %s\n", p.MixerAdmissionPath)
+	fmt.Fprintf(buf, "TerminationGracePeriod: %v\n", p.TerminationGracePeriod)
+	fmt.Fprintf(buf, "StrictGateway: %v\n", p.StrictGateway)
+	fmt.Fprintf(buf, "StrictServiceEntry: %v\n", p.StrictServiceEntry)
+	fmt.Fprintf(buf, "StrictMetadataKeys: %v\n", p.StrictMetadataKeys)
+	fmt.Fprintf(buf, "StrictSidecar: %v\n", p.StrictSidecar)
+	fmt.Fprintf(buf, "StrictPortNaming: %v\n", p.StrictPortNaming)
+	fmt.Fprintf(buf, "StrictTLSSettings: %v\n", p.StrictTLSSettings)
+	fmt.Fprintf(buf, "StrictEnvoyFilter: %v\n", p.StrictEnvoyFilter)
+	fmt.Fprintf(buf, "EmitRejectionEvents: %v\n", p.EmitRejectionEvents)
+	fmt.Fprintf(buf, "ReadinessHeartbeatInterval: %v\n", p.ReadinessHeartbeatInterval)
+	fmt.Fprintf(buf, "VerifyCertDNSNames: %v\n", p.VerifyCertDNSNames)
+	fmt.Fprintf(buf, "ReportAllErrors: %v\n", p.ReportAllErrors)
+	fmt.Fprintf(buf, "ClientCAFile: %s\n", p.ClientCAFile)
+	fmt.Fprintf(buf, "RequireClientCert: %v\n", p.RequireClientCert)
+	fmt.Fprintf(buf, "ReadinessClientCertFile: %s\n", p.ReadinessClientCertFile)
+	fmt.Fprintf(buf, "ReadinessClientKeyFile: %s\n", p.ReadinessClientKeyFile)
+	fmt.Fprintf(buf, "EnforcementConfigMapName: %s\n", p.EnforcementConfigMapName)
+	fmt.Fprintf(buf, "EnforcementConfigMapKey: %s\n", p.EnforcementConfigMapKey)
+	fmt.Fprintf(buf, "SchemaRegistryURL: %s\n", redactURL(p.SchemaRegistryURL))
+	fmt.Fprintf(buf, "SchemaRegistryRefreshInterval: %v\n", p.SchemaRegistryRefreshInterval)
+	fmt.Fprintf(buf, "PerNamespaceConcurrency: %d\n", p.PerNamespaceConcurrency)
+	fmt.Fprintf(buf, "StrictnessProfile: %s\n", p.StrictnessProfile)
+	fmt.Fprintf(buf, "AuditLogDecisions: %v\n", p.AuditLogDecisions)
+	fmt.Fprintf(buf, "ObjectSelector: %v\n", v1.FormatLabelSelector(p.ObjectSelector))
+	fmt.Fprintf(buf, "RuleOperations: %v\n", p.RuleOperations)
+	fmt.Fprintf(buf, "DisableWebhookConfigEnforcement: %v\n", p.DisableWebhookConfigEnforcement)
+	fmt.Fprintf(buf, "WebhookConfigResyncInterval: %v\n", p.WebhookConfigResyncInterval)
+	fmt.Fprintf(buf, "WatchIdleRestart: %v\n", p.WatchIdleRestart)
+	fmt.Fprintf(buf, "EnforceRequestDeadline: %v\n", p.EnforceRequestDeadline)
+	fmt.Fprintf(buf, "RequestDeadlineMargin: %v\n", p.RequestDeadlineMargin)
+	fmt.Fprintf(buf, "ValidationPipeline: %v\n", p.ValidationPipeline)
+	fmt.Fprintf(buf, "CertExpiryWarningThreshold: %v\n", p.CertExpiryWarningThreshold)
+	fmt.Fprintf(buf, "CheckServiceReachability: %v\n", p.CheckServiceReachability)
+	fmt.Fprintf(buf, "RedactedFields: %v\n", p.RedactedFields)
+	fmt.Fprintf(buf, "DisableLivenessProbe: %v\n", p.DisableLivenessProbe)
+	fmt.Fprintf(buf, "ResponseCompressionThreshold: %v\n", p.ResponseCompressionThreshold)
+	fmt.Fprintf(buf, "MaxReportedErrors: %v\n", p.MaxReportedErrors)
+	fmt.Fprintf(buf, "ResourceVersionCacheSize: %v\n", p.ResourceVersionCacheSize)
+	fmt.Fprintf(buf, "DeduplicateConcurrentRequests: %v\n", p.DeduplicateConcurrentRequests)
+	fmt.Fprintf(buf, "BindAddress: %v\n", p.BindAddress)
+	fmt.Fprintf(buf, "RequiredLabels: %v\n", p.RequiredLabels)
+	fmt.Fprintf(buf, "RequiredLabelsExemptNamespaces: %v\n", p.RequiredLabelsExemptNamespaces)
+	fmt.Fprintf(buf, "JSONSchemas: %d\n", len(p.JSONSchemas))
+	fmt.Fprintf(buf, "ValidatorErrorRestartThreshold: %v\n", p.ValidatorErrorRestartThreshold)
+	fmt.Fprintf(buf, "ValidatorErrorRestartWindow: %v\n", p.ValidatorErrorRestartWindow)
+	fmt.Fprintf(buf, "DebugSampleRate: %v\n", p.DebugSampleRate)
+	fmt.Fprintf(buf, "GRPCAddress: %v\n", p.GRPCAddress)
+	fmt.Fprintf(buf, "ReadinessHTTPMethod: %v\n", p.ReadinessHTTPMethod)
+	fmt.Fprintf(buf, "DisabledRules: %v\n", sortedRules(p.DisabledRules))
+	fmt.Fprintf(buf, "ViolationCodes: %v\n", p.ViolationCodes)
+	fmt.Fprintf(buf, "PreflightAPICheck: %v\n", p.PreflightAPICheck)
+	fmt.Fprintf(buf, "RejectEmptySpec: %v\n", p.RejectEmptySpec)
+	fmt.Fprintf(buf, "StandbyMode: %v\n", p.StandbyMode)
+	fmt.Fprintf(buf, "VerifyNamespaceExists: %v\n", p.VerifyNamespaceExists)
+	fmt.Fprintf(buf, "CheckGatewayCredentials: %v\n", p.CheckGatewayCredentials)
+	fmt.Fprintf(buf, "RejectMissingGatewayCredentials: %v\n", p.RejectMissingGatewayCredentials)
+	fmt.Fprintf(buf, "AllowNamespaceMaintenanceMode: %v\n", p.AllowNamespaceMaintenanceMode)
+	fmt.Fprintf(buf, "DegradeReferenceChecks: %v\n", p.DegradeReferenceChecks)
+	fmt.Fprintf(buf, "UnhandledOperationPolicy: %s\n", p.UnhandledOperationPolicy)
+	fmt.Fprintf(buf, "DefaultDecisionForUnmatched: %s\n", p.DefaultDecisionForUnmatched)
+	fmt.Fprintf(buf, "PanicResponseCode: %d\n", p.PanicResponseCode)
+	fmt.Fprintf(buf, "PanicResponseReason: %s\n", p.PanicResponseReason)
+	fmt.Fprintf(buf, "EnforceGlobalNameUniqueness: %v\n", p.EnforceGlobalNameUniqueness)
+	fmt.Fprintf(buf, "GlobalNameUniquenessKinds: %v\n", p.GlobalNameUniquenessKinds)
+	fmt.Fprintf(buf, "AdditionalCACertFiles: %v\n", p.AdditionalCACertFiles)
+	fmt.Fprintf(buf, "CABundleSecrets: %v\n", p.CABundleSecrets)
+	fmt.Fprintf(buf, "SchemaSnapshotFile: %s\n", p.SchemaSnapshotFile)
+	fmt.Fprintf(buf, "ObjectCache: %v\n", p.ObjectCache)
+	fmt.Fprintf(buf, "ObjectCacheResyncPeriod: %v\n", p.ObjectCacheResyncPeriod)
 
 	return buf.String()
 }
@@ -171,6 +751,28 @@ func DefaultArgs() *WebhookParameters {
 		WebhookName:                         "istio-galley",
 		EnableValidation:                    true,
 		EnableReconcileWebhookConfiguration: true,
+		EnableMixerValidation:               true,
+		SkipAnnotation:                      defaultSkipAnnotation,
+		ReadyTimeout:                        defaultReadyTimeout,
+		PilotAdmissionPath:                  defaultPilotAdmissionPath,
+		MixerAdmissionPath:                  defaultMixerAdmissionPath,
+		TerminationGracePeriod:              defaultTerminationGracePeriod,
+		EnforcementConfigMapKey:             defaultEnforcementConfigMapKey,
+		SchemaRegistryRefreshInterval:       defaultSchemaRegistryRefreshInterval,
+		StrictnessProfile:                   StrictnessStandard,
+		WebhookConfigResyncInterval:         defaultWebhookConfigResyncInterval,
+		CertExpiryWarningThreshold:          defaultCertExpiryWarningThreshold,
+		RedactedFields:                      append([]string(nil), defaultRedactedFields...),
+		ResponseCompressionThreshold:        defaultResponseCompressionThreshold,
+		MaxReportedErrors:                   defaultMaxReportedErrors,
+		ValidatorErrorRestartWindow:         defaultValidatorErrorRestartWindow,
+		DebugSampleRate:                     defaultDebugSampleRate,
+		ReadinessHTTPMethod:                 http.MethodGet,
+		PreflightAPICheck:                   true,
+		UnhandledOperationPolicy:            UnhandledOperationAccept,
+		PanicResponseCode:                   http.StatusInternalServerError,
+		PanicResponseReason:                 v1.StatusReasonInternalError,
+		RequestDeadlineMargin:               defaultRequestDeadlineMargin,
 	}
 }
 
@@ -178,17 +780,27 @@ func DefaultArgs() *WebhookParameters {
 type Webhook struct {
 	keyCertWatcher *fsnotify.Watcher
 
+	// watchIdleRestart is the WatchIdleRestart of the keyCertWatcher.
+	watchIdleRestart time.Duration
+
+	// pilotDeadline and mixerDeadline bound the admission requests, see EnforceRequestDeadline.
+	pilotDeadline time.Duration
+	mixerDeadline time.Duration
+
 	mu   sync.RWMutex
 	cert *tls.Certificate
 
+	// validators holds the active *validatorSet. It is swapped as a whole on
+	// reload so that a request is never admitted with a partial set.
+	validators atomic.Value
+
 	// pilot
-	descriptor   schema.Set
 	domainSuffix string
-
-	// mixer
-	validator store.BackendValidator
+	groupAliases map[string]string
+	defaulter    Defaulter
 
 	server                        *http.Server
+	statusServer                  *http.Server
 	clientset                     clientset.Interface
 	deploymentAndServiceNamespace string
 	deploymentName                string
@@ -196,25 +808,120 @@ type Webhook struct {
 	webhookName                   string
 	keyFile                       string
 	certFile                      string
+	certClockSkew                 time.Duration
+	certExpiryWarning             time.Duration
+	listenConfig                  *net.ListenConfig
+	listenBacklog                 int
+	allowSkipAnnotation           bool
+	skipAnnotation                string
+	acceptMessage                 string
+	decisionSink                  DecisionSink
+	decisions                     chan DecisionRecord
+	auditDecisions                bool
+	pipeline                      []ValidationStage
+	redactor                      *redactor
+	compressResponseAbove         int
+	streamCausesAbove             int
+	maxReportedErrors             int
+	violationCodes                map[string]string
+	lifecycle                     LifecycleObserver
+	versionCache                  *versionCache
+	inflight                      *singleflight.Group
+	requiredLabels                []string
+	requiredLabelsExempt          map[string]bool
+	jsonSchemas                   map[kubeschema.GroupVersionKind]*apiextensionsv1beta1.JSONSchemaProps
+	validatorErrors               *validatorErrors
+	debugSampleRate               float64
+	grpcAddress                   string
+	grpcServer                    *grpc.Server
+	debugToken                    string
+	webhookRules                  func() ([]webhookRules, error)
+	skipUnchangedSpecOnUpdate     bool
+	decoder                       runtime.Decoder
+	preValidateTransform          PreValidateTransform
+	protectReferencedObjects      bool
+	rejectUnknownFields           bool
+	terminationGracePeriod        time.Duration
+	strictGateway                 bool
+	strictServiceEntry            bool
+	strictMetadataKeys            bool
+	strictSidecar                 bool
+	strictPortNaming              bool
+	strictTLSSettings             bool
+	strictEnvoyFilter             bool
+	rejectEmptySpec               bool
+	reportAllErrors               bool
+	policies                      *regoPolicies
+	schemaRegistry                *schemaRegistry
+	namespaceLimiter              *namespaceLimiter
+	virtualServiceLister          virtualServiceLister
+	sidecarLister                 sidecarLister
+	kindLister                    kindLister
+	objectCache                   *objectCache
+	globalNameKinds               map[string]bool
+	namespaces                    *namespaceCache
+	gatewayCredentials            secretLookup
+	rejectMissingCredentials      bool
+	degradeReferenceChecks        bool
+	unhandledOperationPolicy      UnhandledOperationPolicy
+	defaultDecisionForUnmatched   UnmatchedDecision
+	panicResponseCode             int32
+	panicResponseReason           v1.StatusReason
+	enforcementConfigMapName      string
+	enforcementConfigMapKey       string
+
+	// enforcement holds the enforcement mode set by the enforcement ConfigMap.
+	enforcement atomic.Value
+
+	// disabledRules holds the map of rules disabled by the enforcement ConfigMap,
+	// or defaultDisabledRules.
+	disabledRules        atomic.Value
+	defaultDisabledRules map[string]bool
+
+	// statusMux serves the status endpoints. It is the admission server's mux
+	// unless a separate status port is configured.
+	statusMux *http.ServeMux
+
+	// initErr holds validator initialization errors reported by the readiness endpoint.
+	initErr error
+
+	// allowMaintenanceMode is the AllowNamespaceMaintenanceMode. The
+	// maintenanceNamespaces holds the cache.Store of the namespaces once they are watched.
+	allowMaintenanceMode  bool
+	maintenanceNamespaces atomic.Value
+
+	// supportConfig, readinessHistory and decisionStats are reported by the
+	// support bundle endpoint. They are only kept if the debug endpoints are enabled.
+	supportConfig    map[string]string
+	readinessHistory *readinessHistory
+	decisionStats    *decisionStats
 
 	// test hook for informers
-	createInformerEndpointSource createInformerEndpointSource
+	createInformerEndpointSource  createInformerEndpointSource
+	createInformerConfigMapSource createInformerConfigMapSource
+	createInformerNamespaceSource createInformerNamespaceSource
+}
+
+// readinessStatus is the JSON body returned by the readiness endpoint when not ready.
+type readinessStatus struct {
+	Reason string `json:"reason,omitempty"`
 }
 
 // Reload the server's cert/key for TLS from file and save it for later use by the https server.
-func (wh *Webhook) reloadKeyCert() {
-	pair, err := reloadKeyCert(wh.certFile, wh.keyFile)
+func (wh *Webhook) reloadKeyCert() (*tls.Certificate, error) {
+	pair, err := reloadKeyCert(wh.certFile, wh.keyFile, wh.certClockSkew, wh.certExpiryWarning)
 	if err != nil {
-		return
+		return nil, err
 	}
 
 	wh.mu.Lock()
 	wh.cert = pair
 	wh.mu.Unlock()
+	return pair, nil
 }
 
 // Reload the server's cert/key for TLS from file.
-func reloadKeyCert(certFile, keyFile string) (*tls.Certificate, error) {
+func reloadKeyCert(certFile, keyFile string, clockSkew, expiryWarning time.Duration) (*tls.Certificate, error) {
 	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
 	if err != nil {
 		reportValidationCertKeyUpdateError(err)
@@ -235,74 +942,432 @@ func reloadKeyCert(certFile, keyFile string) (*tls.Certificate, error) {
 				scope.Infof("x509 cert [%v] - Issuer: %q, Subject: %q, SN: %x, NotBefore: %q, NotAfter: %q\n",
 					row, c.Issuer, c.Subject, c.SerialNumber,
 					c.NotBefore.Format(time.RFC3339), c.NotAfter.Format(time.RFC3339))
+				if err := checkCertValidity(c, time.Now(), clockSkew); err != nil {
+					scope.Warnf("x509 cert [%v] - %v", row, err)
+				}
 				row++
 			}
 		}
 	}
+
+	if leaf, err := x509.ParseCertificate(pair.Certificate[0]); err == nil {
+		now := time.Now()
+		reportCertExpiry(leaf.NotAfter.Sub(now))
+		if err := checkCertExpiry(leaf, now, expiryWarning); err != nil {
+			scope.Warnf("x509 cert [0] - %v", err)
+		}
+	}
 	return &pair, nil
 }
 
+// checkCertExpiry returns an error if the certificate expires within the threshold.
+func checkCertExpiry(cert *x509.Certificate, now time.Time, threshold time.Duration) error {
+	if threshold == 0 || now.After(cert.NotAfter) {
+		return nil
+	}
+	if remaining := cert.NotAfter.Sub(now); remaining < threshold {
+		return fmt.Errorf("certificate expires at %v, in %v", cert.NotAfter.Format(time.RFC3339), remaining.Round(time.Second))
+	}
+	return nil
+}
+
+// checkCertValidity verifies that now is within the certificate's validity period,
+// allowing for the specified clock skew.
+func checkCertValidity(cert *x509.Certificate, now time.Time, clockSkew time.Duration) error {
+	if now.Add(clockSkew).Before(cert.NotBefore) {
+		return fmt.Errorf("certificate is not valid until %v (current time %v, allowed clock skew %v)",
+			cert.NotBefore.Format(time.RFC3339), now.Format(time.RFC3339), clockSkew)
+	}
+	if now.Add(-clockSkew).After(cert.NotAfter) {
+		return fmt.Errorf("certificate expired at %v (current time %v, allowed clock skew %v)",
+			cert.NotAfter.Format(time.RFC3339), now.Format(time.RFC3339), clockSkew)
+	}
+	return nil
+}
+
+// loadCertPool returns a pool of the PEM encoded certificates in the file.
+func loadCertPool(file string) (*x509.CertPool, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("could not read client CA file %v: %v", file, err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in client CA file %v", file)
+	}
+	return pool, nil
+}
+
+// serviceDNSNames returns the DNS names the API server may use to reach the webhook service.
+func serviceDNSNames(service, namespace string) []string {
+	return []string{fmt.Sprintf("%s.%s.svc", service, namespace)}
+}
+
+// checkCertDNSNames verifies that the certificate is valid for each of the DNS names.
+func checkCertDNSNames(cert *x509.Certificate, names []string) error {
+	for _, name := range names {
+		if err := cert.VerifyHostname(name); err != nil {
+			return fmt.Errorf("certificate is not valid for %q (subject %q, DNS SANs %v)",
+				name, cert.Subject, cert.DNSNames)
+		}
+	}
+	return nil
+}
+
 // NewWebhook creates a new instance of the admission webhook controller.
 func NewWebhook(p WebhookParameters) (*Webhook, error) {
-	pair, err := reloadKeyCert(p.CertFile, p.KeyFile)
+	if err := validateGroupAliases(p.GroupAliases, p.PilotDescriptor); err != nil {
+		return nil, err
+	}
+
+	pair, err := reloadKeyCert(p.CertFile, p.KeyFile, p.CertClockSkew, p.CertExpiryWarningThreshold)
 	if err != nil {
 		return nil, err
 	}
+	if p.VerifyCertDNSNames {
+		leaf, err := x509.ParseCertificate(pair.Certificate[0])
+		if err != nil {
+			return nil, fmt.Errorf("could not parse server certificate %v: %v", p.CertFile, err)
+		}
+		if err := checkCertDNSNames(leaf, serviceDNSNames(p.ServiceName, p.DeploymentAndServiceNamespace)); err != nil {
+			return nil, fmt.Errorf("server certificate %v: %v", p.CertFile, err)
+		}
+	}
 
-	// Configuration must be updated whenever the caBundle changes. Watch the parent directory of
-	// the target files so we can catch symlink updates of k8s secrets.
-	keyCertWatcher, err := fsnotify.NewWatcher()
+	var eventSink, outputSink DecisionSink
+	if p.EmitRejectionEvents {
+		if p.Clientset == nil {
+			return nil, errors.New("rejection events require a k8s client")
+		}
+		eventSink = newRejectionEvents(p.Clientset).sink
+	}
+	if p.DecisionOutput != nil {
+		outputSink = (&decisionOutput{w: p.DecisionOutput}).sink
+	}
+	decisionSink := combineDecisionSinks(p.DecisionSink, eventSink, outputSink)
+
+	var namespaces *namespaceCache
+	if p.VerifyNamespaceExists {
+		if p.Clientset == nil {
+			return nil, errors.New("verifying namespaces requires a k8s client")
+		}
+		namespaces = newNamespaceCache(kubeNamespaceLookup(p.Clientset), namespaceCacheTTL)
+	}
+
+	var gatewayCredentials secretLookup
+	if p.CheckGatewayCredentials {
+		if p.Clientset == nil {
+			return nil, errors.New("checking gateway credentials requires a k8s client")
+		}
+		gatewayCredentials = kubeSecretLookup(p.Clientset)
+	}
+
+	if p.AllowNamespaceMaintenanceMode && p.Clientset == nil {
+		return nil, errors.New("namespace maintenance mode requires a k8s client")
+	}
+
+	var pilotDeadline, mixerDeadline time.Duration
+	if p.EnforceRequestDeadline {
+		if pilotDeadline, mixerDeadline, err = requestDeadlines(&p); err != nil {
+			return nil, err
+		}
+		pilotPath, mixerPath := p.admissionPaths()
+		scope.Infof("Admission requests are bounded to %v on %s and %v on %s",
+			pilotDeadline, pilotPath, mixerDeadline, mixerPath)
+	}
+
+	var policies *regoPolicies
+	if p.RegoPolicyDir != "" {
+		if policies, err = loadRegoPolicies(p.RegoPolicyDir); err != nil {
+			return nil, err
+		}
+	}
+
+	strictness := p.strictness()
+
+	redactor, err := newRedactor(p.RedactedFields)
 	if err != nil {
 		return nil, err
 	}
-	for _, file := range []string{p.CertFile, p.KeyFile} {
-		watchDir, _ := filepath.Split(file)
-		if err := keyCertWatcher.Watch(watchDir); err != nil {
-			return nil, fmt.Errorf("could not watch %v: %v", file, err)
+
+	pipeline := p.ValidationPipeline
+	if len(pipeline) == 0 {
+		pipeline = defaultValidationPipeline()
+	} else if err := validateValidationPipeline(pipeline); err != nil {
+		return nil, err
+	}
+
+	jsonSchemas, err := compileJSONSchemas(p.JSONSchemas)
+	if err != nil {
+		return nil, err
+	}
+
+	var registry *schemaRegistry
+	if p.SchemaRegistryURL != "" {
+		registry = newSchemaRegistry(p.SchemaRegistryURL, p.SchemaRegistryRefreshInterval)
+		if err := registry.refresh(); err != nil {
+			// fail closed: objects are rejected until a refresh succeeds
+			scope.Errorf("Schema registry unavailable, rejecting all objects until it is reachable: %v", err)
 		}
 	}
 
+	// Configuration must be updated whenever the caBundle changes. Watch the parent directory of
+	// the target files so we can catch symlink updates of k8s secrets.
+	keyCertWatcher, err := newDirWatcher([]string{p.CertFile, p.KeyFile})
+	if err != nil {
+		return nil, err
+	}
+
 	wh := &Webhook{
 		server: &http.Server{
-			Addr: fmt.Sprintf(":%v", p.Port),
+			Addr: net.JoinHostPort(p.BindAddress, strconv.Itoa(int(p.Port))),
 		},
 		keyFile:                       p.KeyFile,
 		certFile:                      p.CertFile,
+		certClockSkew:                 p.CertClockSkew,
+		certExpiryWarning:             p.CertExpiryWarningThreshold,
 		keyCertWatcher:                keyCertWatcher,
+		watchIdleRestart:              p.WatchIdleRestart,
+		pilotDeadline:                 pilotDeadline,
+		mixerDeadline:                 mixerDeadline,
 		cert:                          pair,
-		descriptor:                    p.PilotDescriptor,
-		validator:                     p.MixerValidator,
+		groupAliases:                  p.GroupAliases,
 		clientset:                     p.Clientset,
 		deploymentName:                p.DeploymentName,
 		serviceName:                   p.ServiceName,
 		webhookName:                   p.WebhookName,
 		deploymentAndServiceNamespace: p.DeploymentAndServiceNamespace,
+		listenConfig:                  p.ListenConfig,
+		listenBacklog:                 p.ListenBacklog,
+		allowSkipAnnotation:           p.AllowSkipAnnotation,
+		skipAnnotation:                p.SkipAnnotation,
+		acceptMessage:                 p.AcceptMessage,
+		decisionSink:                  decisionSink,
+		decisions:                     make(chan DecisionRecord, decisionBufferSize),
+		auditDecisions:                p.AuditLogDecisions,
+		pipeline:                      pipeline,
+		redactor:                      redactor,
+		compressResponseAbove:         p.ResponseCompressionThreshold,
+		streamCausesAbove:             streamedCausesThreshold(p.MaxReportedErrors),
+		maxReportedErrors:             p.MaxReportedErrors,
+		violationCodes:                mergeViolationCodes(p.ViolationCodes),
+		lifecycle:                     p.LifecycleObserver,
+		debugToken:                    p.DebugToken,
+		skipUnchangedSpecOnUpdate:     p.SkipUnchangedSpecOnUpdate,
+		decoder:                       p.Decoder,
+		protectReferencedObjects:      strictness.referencedObjects,
+		rejectUnknownFields:           strictness.unknownFields,
+		terminationGracePeriod:        p.TerminationGracePeriod,
+		strictGateway:                 strictness.gatewayHosts,
+		strictServiceEntry:            strictness.serviceEntryEndpoints,
+		strictMetadataKeys:            strictness.metadataKeys,
+		strictSidecar:                 strictness.sidecarSelectors,
+		strictPortNaming:              strictness.portNames,
+		strictTLSSettings:             strictness.tlsSettings,
+		strictEnvoyFilter:             strictness.envoyFilters,
+		rejectEmptySpec:               p.RejectEmptySpec,
+		reportAllErrors:               p.ReportAllErrors,
+		policies:                      policies,
+		schemaRegistry:                registry,
+		jsonSchemas:                   jsonSchemas,
+		debugSampleRate:               p.DebugSampleRate,
+		grpcAddress:                   p.GRPCAddress,
 		createInformerEndpointSource:  defaultCreateInformerEndpointSource,
+		createInformerConfigMapSource: defaultCreateInformerConfigMapSource,
+		createInformerNamespaceSource: defaultCreateInformerNamespaceSource,
+		allowMaintenanceMode:          p.AllowNamespaceMaintenanceMode,
+		enforcementConfigMapName:      p.EnforcementConfigMapName,
+		enforcementConfigMapKey:       p.EnforcementConfigMapKey,
+		preValidateTransform:          p.PreValidateTransform,
+		namespaces:                    namespaces,
+		gatewayCredentials:            gatewayCredentials,
+		rejectMissingCredentials:      p.RejectMissingGatewayCredentials,
+		degradeReferenceChecks:        p.DegradeReferenceChecks,
+		unhandledOperationPolicy:      p.UnhandledOperationPolicy,
+		defaultDecisionForUnmatched:   p.DefaultDecisionForUnmatched,
+		panicResponseCode:             p.PanicResponseCode,
+		panicResponseReason:           p.PanicResponseReason,
+	}
+	wh.storeValidators(p.PilotDescriptor, p.MixerValidator)
+	wh.virtualServiceLister = wh.listVirtualServices
+	for rule, disabled := range p.DisabledRules {
+		if disabled {
+			if wh.defaultDisabledRules == nil {
+				wh.defaultDisabledRules = make(map[string]bool)
+			}
+			wh.defaultDisabledRules[rule] = true
+		}
+	}
+	wh.disabledRules.Store(wh.defaultDisabledRules)
+	wh.sidecarLister = wh.listSidecars
+	wh.kindLister = wh.listKind
+	if p.EnforceGlobalNameUniqueness {
+		wh.globalNameKinds = make(map[string]bool, len(p.GlobalNameUniquenessKinds))
+		for _, kind := range p.GlobalNameUniquenessKinds {
+			wh.globalNameKinds[kind] = true
+		}
+	}
+	if p.ObjectCache {
+		if p.DynamicClient == nil {
+			return nil, errors.New("the object cache requires a dynamic client")
+		}
+		wh.useObjectCache(p.DynamicClient, p.ObjectCacheResyncPeriod)
+	}
+	if p.PerNamespaceConcurrency > 0 {
+		wh.namespaceLimiter = newNamespaceLimiter(p.PerNamespaceConcurrency)
+	}
+	if len(p.RequiredLabels) > 0 {
+		wh.requiredLabels = append([]string(nil), p.RequiredLabels...)
+		wh.requiredLabelsExempt = make(map[string]bool, len(p.RequiredLabelsExemptNamespaces))
+		for _, namespace := range p.RequiredLabelsExemptNamespaces {
+			wh.requiredLabelsExempt[namespace] = true
+		}
+	}
+	if p.ResourceVersionCacheSize > 0 {
+		wh.versionCache = newVersionCache(p.ResourceVersionCacheSize)
+	}
+	if p.DeduplicateConcurrentRequests {
+		wh.inflight = &singleflight.Group{}
+	}
+	if p.ValidatorErrorRestartThreshold > 0 {
+		wh.validatorErrors = newValidatorErrors(p.ValidatorErrorRestartThreshold, p.ValidatorErrorRestartWindow)
+	}
+	if p.DisableKeepAlives {
+		wh.server.SetKeepAlivesEnabled(false)
+	}
+	if p.ApplyDefaultsBeforeValidate {
+		wh.defaulter = p.Defaulter
+		if wh.defaulter == nil {
+			wh.defaulter = defaultPilotSpec
+		}
 	}
 
-	// mtls disabled because apiserver webhook cert usage is still TBD.
 	wh.server.TLSConfig = &tls.Config{GetCertificate: wh.getCert}
+	if p.ClientCAFile != "" {
+		pool, err := loadCertPool(p.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		wh.server.TLSConfig.ClientCAs = pool
+		wh.server.TLSConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		if p.RequireClientCert {
+			wh.server.TLSConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+	if p.GRPCAddress != "" {
+		wh.grpcServer = wh.newGRPCServer()
+	}
 	h := http.NewServeMux()
-	h.HandleFunc("/admitpilot", wh.serveAdmitPilot)
-	h.HandleFunc("/admitmixer", wh.serveAdmitMixer)
-	h.HandleFunc(httpsHandlerReadyPath, wh.serveReady)
+	pilotPath, mixerPath := p.admissionPaths()
+	h.HandleFunc(pilotPath, wh.serveAdmitPilot)
+	h.HandleFunc(mixerPath, wh.serveAdmitMixer)
+	readyHandler := readinessHandler(wh.serveReady, p.ReadyTimeout)
+	h.Handle(httpsHandlerReadyPath, readyHandler)
 	wh.server.Handler = h
 
+	wh.statusMux = h
+	if p.StatusPort != 0 {
+		wh.statusMux = http.NewServeMux()
+		wh.statusMux.Handle(httpsHandlerReadyPath, readyHandler)
+		wh.statusServer = &http.Server{
+			Addr:    fmt.Sprintf(":%v", p.StatusPort),
+			Handler: wh.statusMux,
+		}
+	}
+	wh.statusMux.Handle(debugVersionPath, noCache(http.HandlerFunc(serveVersion)))
+	wh.webhookRules = func() ([]webhookRules, error) { return effectiveWebhookRules(&p) }
+	if p.EnableDebugEndpoints {
+		wh.supportConfig = redactedParameters(&p)
+		wh.readinessHistory = &readinessHistory{}
+		wh.decisionStats = newDecisionStats(time.Now())
+		wh.registerDebugHandlers()
+	}
+
 	return wh, nil
 }
 
 //Stop the server
 func (wh *Webhook) Stop() {
+	lifecycleObserver(wh.lifecycle).ShutdownStarted()
+	if wh.terminationGracePeriod > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), wh.terminationGracePeriod)
+		defer cancel()
+		scope.Infof("Draining admission requests for up to %v", wh.terminationGracePeriod)
+		if err := wh.server.Shutdown(ctx); err != nil {
+			scope.Warnf("Admission requests not drained within %v: %v", wh.terminationGracePeriod, err)
+		}
+	}
 	wh.server.Close() // nolint: errcheck
+	if wh.statusServer != nil {
+		wh.statusServer.Close() // nolint: errcheck
+	}
+	if wh.grpcServer != nil {
+		wh.grpcServer.Stop()
+	}
+	lifecycleObserver(wh.lifecycle).ShutdownComplete()
 }
 
-// Run implements the webhook server
+// Run implements the webhook server. It exits the process if the webhook fails to serve.
 func (wh *Webhook) Run(ready chan<- struct{}, stopCh <-chan struct{}) {
+	if err := wh.Serve(ready, stopCh); err != nil {
+		scope.Fatal(err.Error())
+	}
+}
+
+// Serve runs the webhook server until stopCh is closed or the webhook fails to
+// serve, in which case the error is returned.
+func (wh *Webhook) Serve(ready chan<- struct{}, stopCh <-chan struct{}) error {
+	serveErrCh := make(chan error, 3)
 	go func() {
-		if err := wh.server.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
-			scope.Fatalf("admission webhook ListenAndServeTLS failed: %v", err)
+		listener, err := wh.listen()
+		if err != nil {
+			serveErrCh <- fmt.Errorf("admission webhook listen failed: %v", err)
+			return
+		}
+		lifecycleObserver(wh.lifecycle).ServerStarted()
+		if err := wh.server.ServeTLS(listener, "", ""); err != nil && err != http.ErrServerClosed {
+			serveErrCh <- fmt.Errorf("admission webhook ServeTLS failed: %v", err)
+		}
+	}()
+	if wh.statusServer != nil {
+		go func() {
+			if err := wh.statusServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				serveErrCh <- fmt.Errorf("validation status server ListenAndServe failed: %v", err)
+			}
+		}()
+	}
+	if wh.grpcServer != nil {
+		go func() {
+			if err := wh.serveGRPC(); err != nil {
+				serveErrCh <- err
+			}
+		}()
+	}
+
+	// stop is closed when stopCh is closed or serving fails, after serveErr is set.
+	stop := make(chan struct{})
+	var serveErr error
+	go func() {
+		select {
+		case <-stopCh:
+		case serveErr = <-serveErrCh:
 		}
+		close(stop)
 	}()
+
+	wh.logActiveRules()
+	if wh.decisionSink != nil {
+		go wh.runDecisionSink(stop)
+	}
+	if wh.enforcementConfigMapName != "" {
+		go wh.watchEnforcement(stop)
+	}
+	if wh.allowMaintenanceMode {
+		go wh.watchMaintenanceNamespaces(stop)
+	}
+	if wh.schemaRegistry != nil {
+		go wh.schemaRegistry.run(stop)
+	}
 	defer func() {
 		wh.Stop()
 	}()
@@ -315,32 +1380,67 @@ func (wh *Webhook) Run(ready chan<- struct{}, stopCh <-chan struct{}) {
 	// galley endpoint to be available at least once before
 	// self-registering. Subsequent Istio upgrades rely on deployment
 	// rolling updates to set maxUnavailable to zero.
-	if shutdown := wh.waitForEndpointReady(stopCh); shutdown {
-		return
+	if shutdown := wh.waitForEndpointReady(stop); shutdown {
+		return serveErr
 	}
 
-	ready <- struct{}{}
+	select {
+	case ready <- struct{}{}:
+		lifecycleObserver(wh.lifecycle).Ready()
+	case <-stop:
+		return serveErr
+	}
 
 	// use a timer to debounce key/cert updates
 	var keyCertTimerC <-chan time.Time
 
+	idle := newIdleTimer(wh.watchIdleRestart)
+	defer idle.stop()
+
 	for {
 		select {
 		case <-keyCertTimerC:
 			keyCertTimerC = nil
-			wh.reloadKeyCert()
+			wh.reloadKeyCert() // nolint: errcheck
 		case event, more := <-wh.keyCertWatcher.Event:
+			idle.reset()
 			if more && (event.IsModify() || event.IsCreate()) && keyCertTimerC == nil {
 				keyCertTimerC = time.After(watchDebounceDelay)
 			}
+		case <-idle.C():
+			wh.keyCertWatcher = rewatch("key/cert", wh.keyCertWatcher, []string{wh.certFile, wh.keyFile}, wh.watchIdleRestart)
+			idle.reset()
+			// reload in case the stalled watch missed an update
+			if keyCertTimerC == nil {
+				keyCertTimerC = time.After(watchDebounceDelay)
+			}
 		case err := <-wh.keyCertWatcher.Error:
 			scope.Errorf("configWatcher error: %v", err)
-		case <-stopCh:
-			return
+		case <-stop:
+			return serveErr
 		}
 	}
 }
 
+// listen creates the TCP listener for the webhook server.
+func (wh *Webhook) listen() (net.Listener, error) {
+	lc := wh.listenConfig
+	if lc == nil {
+		lc = &net.ListenConfig{}
+	}
+	listener, err := lc.Listen(context.Background(), "tcp", wh.server.Addr)
+	if err != nil {
+		return nil, err
+	}
+	if wh.listenBacklog > 0 {
+		if err := setListenBacklog(listener, wh.listenBacklog); err != nil {
+			listener.Close() // nolint: errcheck
+			return nil, fmt.Errorf("could not set listen backlog to %d: %v", wh.listenBacklog, err)
+		}
+	}
+	return listener, nil
+}
+
 func (wh *Webhook) getCert(*tls.ClientHelloInfo) (*tls.Certificate, error) {
 	wh.mu.Lock()
 	defer wh.mu.Unlock()
@@ -351,14 +1451,186 @@ func toAdmissionResponse(err error) *admissionv1beta1.AdmissionResponse {
 	return &admissionv1beta1.AdmissionResponse{Result: &v1.Status{Message: err.Error()}}
 }
 
-type admitFunc func(*admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse
+// validateGroupAliases verifies that every alias maps to a group in the descriptor.
+func validateGroupAliases(aliases map[string]string, descriptor schema.Set) error {
+	groups := make(map[string]bool, len(descriptor))
+	for i := range descriptor {
+		groups[crd.ResourceGroup(&descriptor[i])] = true
+	}
+
+	var errs *multierror.Error
+	for alias, canonical := range aliases {
+		if !groups[canonical] {
+			errs = multierror.Append(errs, fmt.Errorf("group alias %q maps to unknown group %q", alias, canonical))
+		}
+	}
+	return errs.ErrorOrNil()
+}
+
+// validatorSet is the set of validators used to admit a request.
+type validatorSet struct {
+	// pilot
+	descriptor schema.Set
+
+	// mixer, nil if mixer validation is disabled
+	mixer store.BackendValidator
+
+	// schemaVersion identifies the pilot descriptor, see schemaVersion.
+	schemaVersion string
+}
+
+// activeValidators returns the validators to admit the next request with.
+func (wh *Webhook) activeValidators() *validatorSet {
+	return wh.validators.Load().(*validatorSet)
+}
+
+// ReloadValidators replaces the validators used to admit requests. The new
+// validators must be fully initialized: they are swapped in atomically, and
+// requests in flight complete with the validators they started with. The
+// active validators are kept if the new descriptor cannot be used.
+func (wh *Webhook) ReloadValidators(descriptor schema.Set, mixer store.BackendValidator) error {
+	if err := validateGroupAliases(wh.groupAliases, descriptor); err != nil {
+		return err
+	}
+	wh.storeValidators(descriptor, mixer)
+	// versions accepted by the previous validators may be rejected by the new ones
+	if wh.versionCache != nil {
+		wh.versionCache.clear()
+	}
+	return nil
+}
+
+// storeValidators makes the validators active, reporting the identifier of
+// the pilot descriptor if it changed.
+func (wh *Webhook) storeValidators(descriptor schema.Set, mixer store.BackendValidator) {
+	validators := &validatorSet{descriptor: descriptor, mixer: mixer, schemaVersion: schemaVersion(descriptor)}
+	previous, _ := wh.validators.Load().(*validatorSet)
+	wh.validators.Store(validators)
+	if previous != nil && previous.schemaVersion != validators.schemaVersion {
+		reportSchemaVersion(previous.schemaVersion, false)
+	}
+	if previous == nil || previous.schemaVersion != validators.schemaVersion {
+		scope.Infof("Validating pilot configuration with schemas %s", validators.schemaVersion)
+		reportSchemaVersion(validators.schemaVersion, true)
+	}
+}
+
+// lookupSchema finds the pilot schema for the object kind. Objects in an aliased
+// API group only match schemas in the canonical group.
+func (wh *Webhook) lookupSchema(apiVersion, kind string) (schema.Instance, bool) {
+	s, exists := wh.activeValidators().descriptor.GetByType(crd.CamelCaseToKebabCase(kind))
+	if !exists {
+		return schema.Instance{}, false
+	}
+
+	group := apiVersion
+	if i := strings.Index(apiVersion, "/"); i >= 0 {
+		group = apiVersion[:i]
+	}
+	if canonical, ok := wh.groupAliases[group]; ok && crd.ResourceGroup(&s) != canonical {
+		return schema.Instance{}, false
+	}
+	return s, true
+}
+
+// acceptResponse returns an admission response allowing the object.
+func (wh *Webhook) acceptResponse() *admissionv1beta1.AdmissionResponse {
+	response := &admissionv1beta1.AdmissionResponse{Allowed: true}
+	if wh.acceptMessage != "" {
+		response.Result = &v1.Status{Message: wh.acceptMessage}
+	}
+	return response
+}
+
+type admitFunc func(context.Context, *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse
+
+var errRequestTooLarge = fmt.Errorf("request body exceeds %d bytes", maxRequestBytes)
+
+// readBody reads the request body, decompressing it if it is gzip encoded. At
+// most maxRequestBytes are read, so that a small compressed body cannot
+// expand without bound.
+func readBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil {
+		return nil, nil
+	}
+	var in io.Reader = r.Body
+	if strings.EqualFold(r.Header.Get("Content-Encoding"), "gzip") {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return nil, fmt.Errorf("could not decompress body: %v", err)
+		}
+		defer gz.Close() // nolint: errcheck
+		in = gz
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(in, maxRequestBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("could not read body: %v", err)
+	}
+	if len(body) > maxRequestBytes {
+		return nil, errRequestTooLarge
+	}
+	return body, nil
+}
+
+// writeBody writes the response body, gzip encoded if it is larger than
+// compressAbove and the request accepts gzip.
+func writeBody(w http.ResponseWriter, r *http.Request, body []byte, compressAbove int) error {
+	w.Header().Add("Vary", "Accept-Encoding")
+	if compressAbove == 0 || len(body) <= compressAbove || !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+		_, err := w.Write(body)
+		return err
+	}
+
+	w.Header().Set("Content-Encoding", "gzip")
+	gz := gzip.NewWriter(w)
+	if _, err := gz.Write(body); err != nil {
+		return err
+	}
+	return gz.Close()
+}
 
-func serve(w http.ResponseWriter, r *http.Request, admit admitFunc) {
-	var body []byte
-	if r.Body != nil {
-		if data, err := ioutil.ReadAll(r.Body); err == nil {
-			body = data
+// acceptsGzip returns true if the Accept-Encoding header accepts gzip, i.e. it
+// lists gzip or * without a zero quality value.
+func acceptsGzip(header string) bool {
+	for _, value := range strings.Split(header, ",") {
+		parts := strings.Split(value, ";")
+		coding := strings.TrimSpace(parts[0])
+		if !strings.EqualFold(coding, "gzip") && coding != "*" {
+			continue
 		}
+		accepted := true
+		for _, param := range parts[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); err == nil && q == 0 {
+					accepted = false
+				}
+			}
+		}
+		return accepted
+	}
+	return false
+}
+
+// serve admits the AdmissionReview of the request and writes the review of the
+// response, compressed above compressAbove bytes and streamed from streamAbove
+// causes, if they are positive.
+func serve(w http.ResponseWriter, r *http.Request, admit admitFunc, compressAbove, streamAbove int) {
+	// the request ID is echoed even if the request is rejected, so that clients
+	// can correlate failures with the webhook logs
+	id := requestID(r)
+	w.Header().Set(requestIDHeader, id)
+	ctx := withRequestID(r.Context(), id)
+
+	body, err := readBody(r)
+	if err == errRequestTooLarge {
+		reportValidationHTTPError(http.StatusRequestEntityTooLarge)
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	} else if err != nil {
+		reportValidationHTTPError(http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 	if len(body) == 0 {
 		reportValidationHTTPError(http.StatusBadRequest)
@@ -374,92 +1646,356 @@ func serve(w http.ResponseWriter, r *http.Request, admit admitFunc) {
 		return
 	}
 
+	// respond with the same AdmissionReview version that was sent by the apiserver
+	apiVersion := reviewVersion(body)
+
 	var reviewResponse *admissionv1beta1.AdmissionResponse
-	ar := admissionv1beta1.AdmissionReview{}
-	if _, _, err := deserializer.Decode(body, nil, &ar); err != nil {
+	request, err := decodeReview(body, apiVersion)
+	if err != nil {
 		reviewResponse = toAdmissionResponse(fmt.Errorf("could not decode body: %v", err))
+	} else if request == nil {
+		reviewResponse = toAdmissionResponse(errors.New("could not decode body: no request found"))
 	} else {
-		reviewResponse = admit(ar.Request)
+		reportRequestBytes(request)
+		reviewResponse = admit(ctx, request)
+	}
+	if reviewResponse == nil {
+		reviewResponse = toInternalErrorResponse(errors.New("no admission response"))
 	}
 
-	response := admissionv1beta1.AdmissionReview{}
-	if reviewResponse != nil {
-		response.Response = reviewResponse
-		if ar.Request != nil {
-			response.Response.UID = ar.Request.UID
+	// the API server ignores a response whose uid differs from the request,
+	// so the uid is always echoed, whatever admit set
+	if request != nil {
+		if request.UID == "" {
+			requestLog(ctx).Warnf("Admission request for %s %s/%s has no uid, its response may be ignored",
+				request.Kind.Kind, request.Namespace, request.Name)
 		}
+		reviewResponse.UID = request.UID
 	}
 
-	resp, err := json.Marshal(response)
+	if streamAbove > 0 && len(responseCauses(reviewResponse)) >= streamAbove {
+		// the status is already sent once the body is written, so errors are only logged
+		if err := writeStreamedBody(w, r, reviewResponse, apiVersion, compressAbove); err != nil {
+			reportValidationHTTPError(http.StatusInternalServerError)
+			requestLog(ctx).Errorf("could not stream response: %v", err)
+		}
+		return
+	}
+
+	resp, err := encodeReview(reviewResponse, apiVersion)
 	if err != nil {
 		reportValidationHTTPError(http.StatusInternalServerError)
 		http.Error(w, fmt.Sprintf("could encode response: %v", err), http.StatusInternalServerError)
 		return
 	}
-	if _, err := w.Write(resp); err != nil {
+	if err := writeBody(w, r, resp, compressAbove); err != nil {
 		reportValidationHTTPError(http.StatusInternalServerError)
 		http.Error(w, fmt.Sprintf("could write response: %v", err), http.StatusInternalServerError)
 	}
 }
 
+// readinessHandler bounds the time the readiness handler takes to respond, so
+// that probes are answered promptly even if the handler hangs.
+func readinessHandler(handler http.HandlerFunc, timeout time.Duration) http.Handler {
+	if timeout == 0 {
+		return noCache(handler)
+	}
+	return noCache(http.TimeoutHandler(handler, timeout, "readiness check timed out"))
+}
+
+// noCache marks the responses of the handler as not cacheable, so that caching
+// proxies in front of the probes never serve a stale status.
+func noCache(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+		w.Header().Set("Pragma", "no-cache")
+		w.Header().Set("Expires", "0")
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// serveReady answers GET and HEAD requests, since some health-check proxies only
+// issue HEAD requests.
 func (wh *Webhook) serveReady(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", http.MethodGet+", "+http.MethodHead)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var reason string
+	if wh.initErr != nil {
+		reason = fmt.Sprintf("validator initialization failed: %v", wh.initErr)
+	} else if wh.objectCache != nil && !wh.objectCache.synced() {
+		reason = "object cache is not synced"
+	}
+	if reason != "" {
+		resp, err := json.Marshal(readinessStatus{Reason: reason})
+		if err != nil {
+			http.Error(w, fmt.Sprintf("could encode response: %v", err), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write(resp) // nolint: errcheck
+		return
+	}
 	w.WriteHeader(http.StatusOK)
 }
 
 func (wh *Webhook) serveAdmitPilot(w http.ResponseWriter, r *http.Request) {
-	serve(w, r, wh.admitPilot)
+	serve(w, r, wh.sampleDebugLogs(wh.recordDecisions(wh.enforced(wh.limitDeadline(wh.pilotDeadline, wh.trackValidatorErrors(wh.limitNamespace(
+		wh.cacheVersions(wh.deduplicate(wh.validateWith(wh.admitPilot))))))))), wh.compressResponseAbove, wh.streamCausesAbove)
 }
 
 func (wh *Webhook) serveAdmitMixer(w http.ResponseWriter, r *http.Request) {
-	serve(w, r, wh.admitMixer)
+	serve(w, r, wh.sampleDebugLogs(wh.recordDecisions(wh.enforced(wh.limitDeadline(wh.mixerDeadline, wh.trackValidatorErrors(wh.limitNamespace(
+		wh.cacheVersions(wh.deduplicate(wh.validateWith(wh.admitMixer))))))))), wh.compressResponseAbove, wh.streamCausesAbove)
 }
 
-func (wh *Webhook) admitPilot(request *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+// validateWith wraps admit with the checks that apply to the objects of all
+// kinds. Unlike the wrappers of the admission handlers, these decide whether an
+// object is valid, and are shared with ValidateYAML.
+func (wh *Webhook) validateWith(admit admitFunc) admitFunc {
+	return wh.recoverPanics(wh.transformObject(wh.requireNamespace(wh.requireLabels(wh.checkMetadataKeys(admit)))))
+}
+
+func (wh *Webhook) admitPilot(ctx context.Context, request *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
 	switch request.Operation {
 	case admissionv1beta1.Create, admissionv1beta1.Update:
+	case admissionv1beta1.Delete:
+		if wh.ruleActive(RuleReferencedObjects) {
+			return wh.admitPilotDelete(ctx, request)
+		}
+		return wh.acceptResponse()
 	default:
-		scope.Warnf("Unsupported webhook operation %v", request.Operation)
-		reportValidationFailed(request, reasonUnsupportedOperation)
-		return &admissionv1beta1.AdmissionResponse{Allowed: true}
+		return wh.admitUnhandledOperation(ctx, request)
+	}
+
+	if wh.skipUnchangedSpecOnUpdate && onlyMetadataUpdated(request) {
+		return wh.acceptResponse()
 	}
 
 	var obj crd.IstioKind
-	if err := yaml.Unmarshal(request.Object.Raw, &obj); err != nil {
-		scope.Infof("cannot decode configuration: %v", err)
+	if err := wh.decodeObject(request.Object.Raw, &obj); err != nil {
+		requestLog(ctx).Infof("cannot decode configuration: %v", wh.redactedError(request, err))
 		reportValidationFailed(request, reasonYamlDecodeError)
 		return toAdmissionResponse(fmt.Errorf("cannot decode configuration: %v", err))
 	}
 
-	s, exists := wh.descriptor.GetByType(crd.CamelCaseToKebabCase(obj.Kind))
+	if wh.skipValidation(ctx, request, obj.Name, obj.Annotations) {
+		return wh.acceptResponse()
+	}
+
+	s, exists := wh.lookupSchema(obj.APIVersion, obj.Kind)
+	if !exists && wh.hasJSONSchema(request) {
+		// custom kinds are validated only by their JSON schema
+		return wh.runPipeline(ctx, request, func(context.Context) *admissionv1beta1.AdmissionResponse { return nil })
+	}
 	if !exists {
-		scope.Infof("unrecognized type %v", obj.Kind)
+		if _, known := wh.activeValidators().descriptor.GetByType(crd.CamelCaseToKebabCase(obj.Kind)); !known {
+			return wh.admitUnmatched(ctx, request, obj.Kind, UnmatchedReject)
+		}
+		// the kind is validated in another API group than that of the object
+		requestLog(ctx).Infof("unrecognized type %v", obj.Kind)
 		reportValidationFailed(request, reasonUnknownType)
 		return toAdmissionResponse(fmt.Errorf("unrecognized type %v", obj.Kind))
 	}
 
 	out, err := crd.ConvertObject(s, &obj, wh.domainSuffix)
 	if err != nil {
-		scope.Infof("error decoding configuration: %v", err)
+		requestLog(ctx).Infof("error decoding configuration: %v", wh.redactedError(request, err))
 		reportValidationFailed(request, reasonCRDConversionError)
 		return toAdmissionResponse(fmt.Errorf("error decoding configuration: %v", err))
 	}
 
-	if err := s.Validate(out.Name, out.Namespace, out.Spec); err != nil {
-		scope.Infof("configuration is invalid: %v", err)
-		reportValidationFailed(request, reasonInvalidConfig)
-		return toAdmissionResponse(fmt.Errorf("configuration is invalid: %v", err))
+	// out is decoded from the request, so defaulting it does not affect the admitted object.
+	if wh.defaulter != nil {
+		wh.defaulter(s, out.Spec)
 	}
 
-	if reason, err := checkFields(request.Object.Raw, request.Kind.Kind, request.Namespace, obj.Name); err != nil {
-		reportValidationFailed(request, reason)
-		return toAdmissionResponse(err)
-	}
+	return wh.runPipeline(ctx, request, func(ctx context.Context) *admissionv1beta1.AdmissionResponse {
+		report := validationReport{maxCauses: wh.maxReportedErrors, codes: wh.violationCodes}
+		if wh.ruleActive(RuleEmptySpec) {
+			if err := validateNonEmptySpec(s, obj.Kind, out.Spec); err != nil {
+				requestLog(ctx).Infof("configuration is invalid: %v", wh.redactedError(request, err))
+				if !wh.reportAllErrors {
+					reportStageFailed(ctx, request, reasonEmptySpec)
+					return wh.withViolation(request, RuleEmptySpec, "spec",
+						toAdmissionResponse(fmt.Errorf("configuration is invalid: %v", err)))
+				}
+				report.add(RuleEmptySpec, "spec", err)
+			}
+		}
+		if err := s.Validate(out.Name, out.Namespace, out.Spec); err != nil {
+			requestLog(ctx).Infof("configuration is invalid: %v", wh.redactedError(request, err))
+			if !wh.reportAllErrors {
+				reportStageFailed(ctx, request, reasonInvalidConfig)
+				return wh.withViolation(request, CheckSchema, "spec",
+					toAdmissionResponse(fmt.Errorf("configuration is invalid: %v", err)))
+			}
+			report.add(CheckSchema, "spec", err)
+		}
+
+		if gateway, ok := out.Spec.(*networking.Gateway); ok && wh.ruleActive(RuleGatewayHosts) {
+			if err := validateGatewayServers(gateway); err != nil {
+				requestLog(ctx).Infof("gateway is invalid: %v", wh.redactedError(request, err))
+				if !wh.reportAllErrors {
+					reportStageFailed(ctx, request, reasonInvalidConfig)
+					return wh.withViolation(request, RuleGatewayHosts, "spec.servers",
+						toAdmissionResponse(fmt.Errorf("configuration is invalid: %v", err)))
+				}
+				report.add(RuleGatewayHosts, "spec.servers", err)
+			}
+		}
+
+		if serviceEntry, ok := out.Spec.(*networking.ServiceEntry); ok && wh.ruleActive(RuleServiceEntryEndpoints) {
+			if err := validateServiceEntryResolution(serviceEntry); err != nil {
+				requestLog(ctx).Infof("service entry is invalid: %v", wh.redactedError(request, err))
+				if !wh.reportAllErrors {
+					reportStageFailed(ctx, request, reasonInvalidConfig)
+					return wh.withViolation(request, RuleServiceEntryEndpoints, "spec.endpoints",
+						toAdmissionResponse(fmt.Errorf("configuration is invalid: %v", err)))
+				}
+				report.add(RuleServiceEntryEndpoints, "spec.endpoints", err)
+			}
+		}
+
+		if wh.ruleActive(RulePortNaming) {
+			if err := validatePortNames(out.Spec); err != nil {
+				requestLog(ctx).Infof("port names are invalid: %v", wh.redactedError(request, err))
+				if !wh.reportAllErrors {
+					reportStageFailed(ctx, request, reasonInvalidPortName)
+					return wh.withViolation(request, RulePortNaming, "spec",
+						toAdmissionResponse(fmt.Errorf("configuration is invalid: %v", err)))
+				}
+				report.add(RulePortNaming, "spec", err)
+			}
+		}
+
+		if wh.ruleActive(RuleTLSSettings) {
+			if err := validateTLSSettings(out.Spec); err != nil {
+				requestLog(ctx).Infof("TLS settings are inconsistent: %v", wh.redactedError(request, err))
+				if !wh.reportAllErrors {
+					reportStageFailed(ctx, request, reasonInconsistentTLS)
+					return wh.withViolation(request, RuleTLSSettings, "spec",
+						toAdmissionResponse(fmt.Errorf("configuration is invalid: %v", err)))
+				}
+				report.add(RuleTLSSettings, "spec", err)
+			}
+		}
+
+		if filter, ok := out.Spec.(*networking.EnvoyFilter); ok && wh.ruleActive(RuleEnvoyFilterFields) {
+			if err := validateEnvoyFilterFields(filter); err != nil {
+				requestLog(ctx).Infof("envoy filter is invalid: %v", wh.redactedError(request, err))
+				if !wh.reportAllErrors {
+					reportStageFailed(ctx, request, reasonDeprecatedField)
+					return wh.withViolation(request, RuleEnvoyFilterFields, "spec",
+						toAdmissionResponse(fmt.Errorf("configuration is invalid: %v", err)))
+				}
+				report.add(RuleEnvoyFilterFields, "spec", err)
+			}
+		}
+
+		if gateway, ok := out.Spec.(*networking.Gateway); ok && wh.ruleActive(RuleGatewayCredentials) {
+			namespace := objectNamespace(out, request)
+			missing, err := wh.missingGatewayCredentials(namespace, gateway)
+			if err != nil {
+				err = fmt.Errorf("cannot look up secrets in namespace %s: %v", namespace, err)
+				if response := wh.failedReferenceLookup(ctx, request, RuleGatewayCredentials, err); response != nil {
+					return response
+				}
+			}
+			if len(missing) > 0 {
+				err := fmt.Errorf("credentialName references secrets that do not exist in namespace %s: %s",
+					namespace, strings.Join(missing, ", "))
+				if !wh.rejectMissingCredentials {
+					requestLog(ctx).Warnf("Gateway %s/%s: %v", namespace, out.Name, wh.redactedError(request, err))
+					reportGatewayCredentialsMissing(request)
+				} else {
+					requestLog(ctx).Infof("gateway is invalid: %v", wh.redactedError(request, err))
+					if !wh.reportAllErrors {
+						reportStageFailed(ctx, request, reasonMissingCredential)
+						return wh.withViolation(request, RuleGatewayCredentials, "spec.servers",
+							toAdmissionResponse(fmt.Errorf("configuration is invalid: %v", err)))
+					}
+					report.add(RuleGatewayCredentials, "spec.servers", err)
+				}
+			}
+		}
+
+		if sidecar, ok := out.Spec.(*networking.Sidecar); ok && wh.ruleActive(RuleSidecarSelector) && isNamespaceWideSidecar(sidecar) {
+			namespace := objectNamespace(out, request)
+			others, err := wh.namespaceWideSidecars(namespace, out.Name)
+			if err != nil {
+				err = fmt.Errorf("cannot list sidecars in namespace %s: %v", namespace, err)
+				if response := wh.failedReferenceLookup(ctx, request, RuleSidecarSelector, err); response != nil {
+					return response
+				}
+			}
+			if len(others) > 0 {
+				err := fmt.Errorf("namespace %s already has a sidecar without workloadSelector: %s",
+					namespace, strings.Join(others, ", "))
+				requestLog(ctx).Infof("sidecar is invalid: %v", wh.redactedError(request, err))
+				if !wh.reportAllErrors {
+					reportStageFailed(ctx, request, reasonConflictingSidecar)
+					return wh.withViolation(request, RuleSidecarSelector, "spec.workloadSelector",
+						toAdmissionResponse(fmt.Errorf("configuration is invalid: %v", err)))
+				}
+				report.add(RuleSidecarSelector, "spec.workloadSelector", err)
+			}
+		}
+
+		if request.Operation == admissionv1beta1.Create && wh.globalNameKinds[obj.Kind] && wh.ruleActive(RuleGlobalNames) {
+			collisions, err := wh.nameCollisions(s, objectNamespace(out, request), out.Name)
+			if err != nil {
+				err = fmt.Errorf("cannot list %s objects: %v", obj.Kind, err)
+				if response := wh.failedReferenceLookup(ctx, request, RuleGlobalNames, err); response != nil {
+					return response
+				}
+			}
+			if len(collisions) > 0 {
+				err := fmt.Errorf("%s name %q is already used in other namespaces: %s",
+					obj.Kind, out.Name, strings.Join(collisions, ", "))
+				requestLog(ctx).Infof("configuration is invalid: %v", wh.redactedError(request, err))
+				if !wh.reportAllErrors {
+					reportStageFailed(ctx, request, reasonNameCollision)
+					return wh.withViolation(request, RuleGlobalNames, "metadata.name",
+						toAdmissionResponse(fmt.Errorf("configuration is invalid: %v", err)))
+				}
+				report.add(RuleGlobalNames, "metadata.name", err)
+			}
+		}
 
-	reportValidationPass(request)
-	return &admissionv1beta1.AdmissionResponse{Allowed: true}
+		if wh.reportAllErrors {
+			if wh.ruleActive(RuleUnknownFields) {
+				if err := report.addUnknownFields(request.Object.Raw); err != nil {
+					reportStageFailed(ctx, request, reasonYamlDecodeError)
+					return toAdmissionResponse(err)
+				}
+			}
+			if !report.empty() {
+				reportStageFailed(ctx, request, reasonInvalidConfig)
+				return report.response(request, obj.Name)
+			}
+		} else if wh.ruleActive(RuleUnknownFields) {
+			if reason, field, err := checkFields(request.Object.Raw, request.Kind.Kind, request.Namespace, obj.Name); err != nil {
+				reportStageFailed(ctx, request, reason)
+				if reason == reasonYamlDecodeError {
+					return toAdmissionResponse(err)
+				}
+				return wh.withViolation(request, RuleUnknownFields, field, toAdmissionResponse(err))
+			}
+		}
+		return nil
+	})
 }
 
-func (wh *Webhook) admitMixer(request *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+func (wh *Webhook) admitMixer(ctx context.Context, request *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+	validator := wh.activeValidators().mixer
+	if validator == nil {
+		return wh.acceptResponse()
+	}
+
 	ev := &store.BackendEvent{
 		Key: store.Key{
 			Namespace: request.Namespace,
@@ -468,19 +2004,34 @@ func (wh *Webhook) admitMixer(request *admissionv1beta1.AdmissionRequest) *admis
 	}
 	switch request.Operation {
 	case admissionv1beta1.Create, admissionv1beta1.Update:
+		if wh.skipUnchangedSpecOnUpdate && onlyMetadataUpdated(request) {
+			return wh.acceptResponse()
+		}
 		ev.Type = store.Update
 		var obj unstructured.Unstructured
-		if err := yaml.Unmarshal(request.Object.Raw, &obj); err != nil {
+		if err := wh.decodeObject(request.Object.Raw, &obj); err != nil {
 			reportValidationFailed(request, reasonYamlDecodeError)
 			return toAdmissionResponse(fmt.Errorf("cannot decode configuration: %v", err))
 		}
 
+		if wh.skipValidation(ctx, request, obj.GetName(), obj.GetAnnotations()) {
+			return wh.acceptResponse()
+		}
+
 		ev.Value = mixerCrd.ToBackEndResource(&obj)
 		ev.Key.Name = ev.Value.Metadata.Name
 
-		if reason, err := checkFields(request.Object.Raw, request.Kind.Kind, request.Namespace, ev.Key.Name); err != nil {
-			reportValidationFailed(request, reason)
-			return toAdmissionResponse(err)
+		if !wh.reportAllErrors && wh.ruleActive(RuleUnknownFields) {
+			if reason, field, err := checkFields(request.Object.Raw, request.Kind.Kind, request.Namespace, ev.Key.Name); err != nil {
+				reportValidationFailed(request, reason)
+				if reason == reasonYamlDecodeError {
+					return toAdmissionResponse(err)
+				}
+				return wh.withViolation(request, RuleUnknownFields, field, toAdmissionResponse(err))
+			}
+		}
+		if !validator.SupportsKind(request.Kind.Kind) {
+			return wh.admitUnmatched(ctx, request, request.Kind.Kind, UnmatchedAccept)
 		}
 
 	case admissionv1beta1.Delete:
@@ -491,38 +2042,99 @@ func (wh *Webhook) admitMixer(request *admissionv1beta1.AdmissionRequest) *admis
 		ev.Type = store.Delete
 		ev.Key.Name = request.Name
 	default:
-		scope.Warnf("Unsupported webhook operation %v", request.Operation)
-		reportValidationFailed(request, reasonUnsupportedOperation)
-		return &admissionv1beta1.AdmissionResponse{Allowed: true}
+		return wh.admitUnhandledOperation(ctx, request)
 	}
 
 	// webhook skips deletions
-	if ev.Type == store.Update {
-		if err := wh.validator.Validate(ev); err != nil {
-			reportValidationFailed(request, reasonInvalidConfig)
-			return toAdmissionResponse(err)
+	if ev.Type != store.Update {
+		reportValidationPass(request)
+		return wh.acceptResponse()
+	}
+
+	return wh.runPipeline(ctx, request, func(ctx context.Context) *admissionv1beta1.AdmissionResponse {
+		if wh.reportAllErrors {
+			report := validationReport{maxCauses: wh.maxReportedErrors, codes: wh.violationCodes}
+			if err := validator.Validate(ev); err != nil {
+				report.add(CheckSchema, "spec", err)
+			}
+			if wh.ruleActive(RuleUnknownFields) {
+				if err := report.addUnknownFields(request.Object.Raw); err != nil {
+					reportStageFailed(ctx, request, reasonYamlDecodeError)
+					return toAdmissionResponse(err)
+				}
+			}
+			if !report.empty() {
+				reportStageFailed(ctx, request, reasonInvalidConfig)
+				return report.response(request, ev.Key.Name)
+			}
+		} else if err := validator.Validate(ev); err != nil {
+			reportStageFailed(ctx, request, reasonInvalidConfig)
+			return wh.withViolation(request, CheckSchema, "spec", toAdmissionResponse(err))
 		}
+		return nil
+	})
+}
+
+// skipValidation returns true if skipping validation via annotation is allowed and
+// the object carries the skip annotation with a true value.
+func (wh *Webhook) skipValidation(ctx context.Context, request *admissionv1beta1.AdmissionRequest, name string, annotations map[string]string) bool {
+	if !wh.allowSkipAnnotation || wh.skipAnnotation == "" {
+		return false
+	}
+	value, ok := annotations[wh.skipAnnotation]
+	if !ok {
+		return false
+	}
+	if skip, err := strconv.ParseBool(value); err != nil || !skip {
+		return false
+	}
+	requestLog(ctx).Warnf("Skipping validation of %s resource %s/%s: annotated with %s=%q",
+		request.Kind.Kind, request.Namespace, name, wh.skipAnnotation, value)
+	return true
+}
+
+// decodeObject decodes the raw admitted object into obj with the configured decoder.
+func (wh *Webhook) decodeObject(raw []byte, obj runtime.Object) error {
+	if wh.decoder == nil {
+		return yaml.Unmarshal(raw, obj)
 	}
+	_, _, err := wh.decoder.Decode(raw, nil, obj)
+	return err
+}
 
-	reportValidationPass(request)
-	return &admissionv1beta1.AdmissionResponse{Allowed: true}
+// onlyMetadataUpdated returns true if the request is an update that leaves everything
+// but the object metadata unchanged.
+func onlyMetadataUpdated(request *admissionv1beta1.AdmissionRequest) bool {
+	if request.Operation != admissionv1beta1.Update || len(request.OldObject.Raw) == 0 {
+		return false
+	}
+
+	var obj, oldObj map[string]interface{}
+	if err := yaml.Unmarshal(request.Object.Raw, &obj); err != nil {
+		return false
+	}
+	if err := yaml.Unmarshal(request.OldObject.Raw, &oldObj); err != nil {
+		return false
+	}
+	delete(obj, "metadata")
+	delete(oldObj, "metadata")
+	return reflect.DeepEqual(obj, oldObj)
 }
 
-func checkFields(raw []byte, kind string, namespace string, name string) (string, error) {
-	trial := make(map[string]json.RawMessage)
-	if err := yaml.Unmarshal(raw, &trial); err != nil {
-		scope.Infof("cannot decode configuration fields: %v", err)
-		return reasonYamlDecodeError, fmt.Errorf("cannot decode configuration fields: %v", err)
+// checkFields returns the reason and the field of the first unknown field of the raw object.
+func checkFields(raw []byte, kind string, namespace string, name string) (string, string, error) {
+	unknown, err := unknownFields(raw)
+	if err != nil {
+		scope.Infof("%v", err)
+		return reasonYamlDecodeError, "", err
 	}
 
-	for key := range trial {
-		if _, ok := validFields[key]; !ok {
-			scope.Infof("unknown field %q on %s resource %s/%s",
-				key, kind, namespace, name)
-			return reasonInvalidConfig, fmt.Errorf("unknown field %q on %s resource %s/%s",
-				key, kind, namespace, name)
-		}
+	if len(unknown) > 0 {
+		scope.Infof("unknown field %q on %s resource %s/%s",
+			unknown[0], kind, namespace, name)
+		return reasonInvalidConfig, unknown[0], fmt.Errorf("unknown field %q on %s resource %s/%s",
+			unknown[0], kind, namespace, name)
 	}
 
-	return "", nil
+	return "", "", nil
 }
@@ -16,27 +16,38 @@ package validation
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"sync"
+	"syscall"
 	"testing"
 	"time"
 
 	"github.com/ghodss/yaml"
+	"github.com/gogo/protobuf/proto"
 	"github.com/onsi/gomega"
+	admissionv1 "k8s.io/api/admission/v1"
 	admissionv1beta1 "k8s.io/api/admission/v1beta1"
 	admissionregistrationv1beta1 "k8s.io/api/admissionregistration/v1beta1"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	kubeschema "k8s.io/apimachinery/pkg/runtime/schema"
 	clientset "k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/fake"
 	"k8s.io/client-go/tools/cache"
@@ -46,6 +57,7 @@ import (
 	"istio.io/istio/pilot/pkg/config/kube/crd"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/test/mock"
+	"istio.io/istio/pkg/config/schema"
 	"istio.io/istio/pkg/config/schemas"
 	"istio.io/istio/pkg/mcp/testing/testcerts"
 	testConfig "istio.io/istio/pkg/test/config"
@@ -67,6 +79,14 @@ func (fv *fakeValidator) SupportsKind(string) bool {
 	return true
 }
 
+// setMixerValidator replaces the mixer validator and keeps the pilot descriptor of the webhook.
+func setMixerValidator(t *testing.T, wh *Webhook, validator store.BackendValidator) {
+	t.Helper()
+	if err := wh.ReloadValidators(wh.activeValidators().descriptor, validator); err != nil {
+		t.Fatalf("ReloadValidators() failed: %v", err)
+	}
+}
+
 var (
 	dummyConfig = &admissionregistrationv1beta1.ValidatingWebhookConfiguration{
 		ObjectMeta: metav1.ObjectMeta{
@@ -341,7 +361,7 @@ func TestAdmitPilot(t *testing.T) {
 
 	for i, c := range cases {
 		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
-			got := wh.admitPilot(c.in)
+			got := wh.admitPilot(context.Background(), c.in)
 			if got.Allowed != c.allowed {
 				t.Fatalf("got %v want %v", got.Allowed, c.allowed)
 			}
@@ -491,12 +511,23 @@ func TestAdmitMixer(t *testing.T) {
 			validator: &fakeValidator{},
 			allowed:   false,
 		},
+		{
+			name: "mixer validation disabled",
+			in: &admissionv1beta1.AdmissionRequest{
+				Kind:      metav1.GroupVersionKind{Kind: "mock"},
+				Name:      "mixer validation disabled",
+				Object:    runtime.RawExtension{Raw: extraKeyConfig},
+				Operation: admissionv1beta1.Create,
+			},
+			validator: nil,
+			allowed:   true,
+		},
 	}
 
 	for i, c := range cases {
 		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
-			wh.validator = c.validator // override mixer backend validator
-			got := wh.admitMixer(c.in)
+			setMixerValidator(t, wh, c.validator) // override mixer backend validator
+			got := wh.admitMixer(context.Background(), c.in)
 			if c.allowed != got.Allowed {
 				t.Fatalf("got %v want %v", got, c.allowed)
 			}
@@ -504,6 +535,459 @@ func TestAdmitMixer(t *testing.T) {
 	}
 }
 
+func annotate(t *testing.T, raw []byte, key, value string) []byte {
+	t.Helper()
+	uns := &unstructured.Unstructured{}
+	if err := json.Unmarshal(raw, &uns.Object); err != nil {
+		t.Fatalf("Unmarshal() failed: %v", err)
+	}
+	annotations := uns.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[key] = value
+	uns.SetAnnotations(annotations)
+	annotated, err := json.Marshal(uns)
+	if err != nil {
+		t.Fatalf("Marshal(%v) failed: %v", uns, err)
+	}
+	return annotated
+}
+
+func TestAdmitSkipAnnotation(t *testing.T) {
+	invalidPilot := makePilotConfig(t, 0, false, false)
+	mixerConfig := makeMixerConfig(t, 0, false)
+
+	wh, cancel := createTestWebhook(t, dummyClient, createFakeEndpointsSource(), dummyConfig)
+	defer cancel()
+	wh.skipAnnotation = defaultSkipAnnotation
+	setMixerValidator(t, wh, &fakeValidator{errors.New("fail")})
+
+	cases := []struct {
+		name    string
+		admit   admitFunc
+		raw     []byte
+		allow   bool
+		allowed bool
+	}{
+		{
+			name:    "pilot skip true",
+			admit:   wh.admitPilot,
+			raw:     annotate(t, invalidPilot, defaultSkipAnnotation, "true"),
+			allow:   true,
+			allowed: true,
+		},
+		{
+			name:    "pilot skip false",
+			admit:   wh.admitPilot,
+			raw:     annotate(t, invalidPilot, defaultSkipAnnotation, "false"),
+			allow:   true,
+			allowed: false,
+		},
+		{
+			name:    "pilot skip malformed",
+			admit:   wh.admitPilot,
+			raw:     annotate(t, invalidPilot, defaultSkipAnnotation, "yes please"),
+			allow:   true,
+			allowed: false,
+		},
+		{
+			name:    "pilot skip not allowed",
+			admit:   wh.admitPilot,
+			raw:     annotate(t, invalidPilot, defaultSkipAnnotation, "true"),
+			allow:   false,
+			allowed: false,
+		},
+		{
+			name:    "mixer skip true",
+			admit:   wh.admitMixer,
+			raw:     annotate(t, mixerConfig, defaultSkipAnnotation, "true"),
+			allow:   true,
+			allowed: true,
+		},
+		{
+			name:    "mixer skip not allowed",
+			admit:   wh.admitMixer,
+			raw:     annotate(t, mixerConfig, defaultSkipAnnotation, "true"),
+			allow:   false,
+			allowed: false,
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			wh.allowSkipAnnotation = c.allow
+			got := c.admit(context.Background(), &admissionv1beta1.AdmissionRequest{
+				Kind:      metav1.GroupVersionKind{Kind: "mock"},
+				Object:    runtime.RawExtension{Raw: c.raw},
+				Operation: admissionv1beta1.Create,
+			})
+			if got.Allowed != c.allowed {
+				t.Fatalf("got %v want %v", got.Allowed, c.allowed)
+			}
+		})
+	}
+}
+
+func TestAdmitAcceptMessage(t *testing.T) {
+	valid := makePilotConfig(t, 0, true, false)
+	invalid := makePilotConfig(t, 0, false, false)
+
+	wh, cancel := createTestWebhook(t, dummyClient, createFakeEndpointsSource(), dummyConfig)
+	defer cancel()
+
+	request := func(raw []byte) *admissionv1beta1.AdmissionRequest {
+		return &admissionv1beta1.AdmissionRequest{
+			Kind:      metav1.GroupVersionKind{Kind: "mock"},
+			Object:    runtime.RawExtension{Raw: raw},
+			Operation: admissionv1beta1.Create,
+		}
+	}
+
+	if got := wh.admitPilot(context.Background(), request(valid)); got.Result != nil {
+		t.Fatalf("unexpected result without accept message: %v", got.Result)
+	}
+
+	wh.acceptMessage = "validated by galley"
+	got := wh.admitPilot(context.Background(), request(valid))
+	if !got.Allowed || got.Result == nil || got.Result.Message != wh.acceptMessage {
+		t.Fatalf("got %v want allowed response with message %q", got, wh.acceptMessage)
+	}
+	got = wh.admitPilot(context.Background(), request(invalid))
+	if got.Allowed || got.Result == nil || got.Result.Message == wh.acceptMessage {
+		t.Fatalf("got %v want denied response without accept message", got)
+	}
+}
+
+func withAPIVersion(t *testing.T, raw []byte, apiVersion string) []byte {
+	t.Helper()
+	uns := &unstructured.Unstructured{}
+	if err := json.Unmarshal(raw, &uns.Object); err != nil {
+		t.Fatalf("Unmarshal() failed: %v", err)
+	}
+	uns.SetAPIVersion(apiVersion)
+	out, err := json.Marshal(uns)
+	if err != nil {
+		t.Fatalf("Marshal(%v) failed: %v", uns, err)
+	}
+	return out
+}
+
+func TestAdmitPilotGroupAliases(t *testing.T) {
+	aliased := withAPIVersion(t, makePilotConfig(t, 0, true, false), "old.istio.io/v1")
+
+	wh, cancel := createTestWebhook(t, dummyClient, createFakeEndpointsSource(), dummyConfig)
+	defer cancel()
+
+	cases := []struct {
+		name    string
+		aliases map[string]string
+		allowed bool
+	}{
+		{
+			name:    "no aliases",
+			allowed: true,
+		},
+		{
+			name:    "alias to schema group",
+			aliases: map[string]string{"old.istio.io": "test.istio.io"},
+			allowed: true,
+		},
+		{
+			name:    "alias to other group",
+			aliases: map[string]string{"old.istio.io": "networking.istio.io"},
+			allowed: false,
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			wh.groupAliases = c.aliases
+			got := wh.admitPilot(context.Background(), &admissionv1beta1.AdmissionRequest{
+				Kind:      metav1.GroupVersionKind{Kind: "mock"},
+				Object:    runtime.RawExtension{Raw: aliased},
+				Operation: admissionv1beta1.Create,
+			})
+			if got.Allowed != c.allowed {
+				t.Fatalf("got %v want %v", got.Allowed, c.allowed)
+			}
+		})
+	}
+}
+
+func TestValidateGroupAliases(t *testing.T) {
+	if err := validateGroupAliases(map[string]string{"old.istio.io": "test.istio.io"}, mock.Types); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := validateGroupAliases(map[string]string{"old.istio.io": "unknown.istio.io"}, mock.Types); err == nil {
+		t.Fatal("expected error for alias to unknown group")
+	}
+}
+
+func TestAdmitPilotDefaulter(t *testing.T) {
+	invalid := makePilotConfig(t, 0, false, false)
+
+	wh, cancel := createTestWebhook(t, dummyClient, createFakeEndpointsSource(), dummyConfig)
+	defer cancel()
+
+	request := &admissionv1beta1.AdmissionRequest{
+		Kind:      metav1.GroupVersionKind{Kind: "mock"},
+		Object:    runtime.RawExtension{Raw: invalid},
+		Operation: admissionv1beta1.Create,
+	}
+	if got := wh.admitPilot(context.Background(), request); got.Allowed {
+		t.Fatal("config without key should be rejected without defaulting")
+	}
+
+	wh.defaulter = func(s schema.Instance, spec proto.Message) {
+		if mock, ok := spec.(*testConfig.MockConfig); ok && mock.Key == "" {
+			mock.Key = "default"
+		}
+	}
+	if got := wh.admitPilot(context.Background(), request); !got.Allowed {
+		t.Fatalf("config should be allowed once defaulted: %v", got.Result)
+	}
+	if !bytes.Equal(request.Object.Raw, invalid) {
+		t.Fatal("defaulting modified the admitted object")
+	}
+}
+
+func TestAdmitSkipUnchangedSpecOnUpdate(t *testing.T) {
+	invalidPilot := makePilotConfig(t, 0, false, false)
+	relabeledPilot := annotate(t, invalidPilot, "owner", "controller")
+	mixerConfig := makeMixerConfig(t, 0, false)
+	relabeledMixer := annotate(t, mixerConfig, "owner", "controller")
+	changedMixer := makeMixerConfig(t, 0, true)
+
+	wh, cancel := createTestWebhook(t, dummyClient, createFakeEndpointsSource(), dummyConfig)
+	defer cancel()
+	setMixerValidator(t, wh, &fakeValidator{errors.New("fail")})
+
+	cases := []struct {
+		name      string
+		admit     admitFunc
+		operation admissionv1beta1.Operation
+		obj       []byte
+		oldObj    []byte
+		skip      bool
+		allowed   bool
+	}{
+		{
+			name:      "pilot metadata-only update",
+			admit:     wh.admitPilot,
+			operation: admissionv1beta1.Update,
+			obj:       relabeledPilot,
+			oldObj:    invalidPilot,
+			skip:      true,
+			allowed:   true,
+		},
+		{
+			name:      "pilot metadata-only update without skip",
+			admit:     wh.admitPilot,
+			operation: admissionv1beta1.Update,
+			obj:       relabeledPilot,
+			oldObj:    invalidPilot,
+			allowed:   false,
+		},
+		{
+			name:      "pilot create",
+			admit:     wh.admitPilot,
+			operation: admissionv1beta1.Create,
+			obj:       relabeledPilot,
+			oldObj:    invalidPilot,
+			skip:      true,
+			allowed:   false,
+		},
+		{
+			name:      "pilot spec update",
+			admit:     wh.admitPilot,
+			operation: admissionv1beta1.Update,
+			obj:       invalidPilot,
+			oldObj:    makePilotConfig(t, 0, true, false),
+			skip:      true,
+			allowed:   false,
+		},
+		{
+			name:      "mixer metadata-only update",
+			admit:     wh.admitMixer,
+			operation: admissionv1beta1.Update,
+			obj:       relabeledMixer,
+			oldObj:    mixerConfig,
+			skip:      true,
+			allowed:   true,
+		},
+		{
+			name:      "mixer top-level field added",
+			admit:     wh.admitMixer,
+			operation: admissionv1beta1.Update,
+			obj:       changedMixer,
+			oldObj:    mixerConfig,
+			skip:      true,
+			allowed:   false,
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			wh.skipUnchangedSpecOnUpdate = c.skip
+			got := c.admit(context.Background(), &admissionv1beta1.AdmissionRequest{
+				Kind:      metav1.GroupVersionKind{Kind: "mock"},
+				Name:      "mock-config0",
+				Object:    runtime.RawExtension{Raw: c.obj},
+				OldObject: runtime.RawExtension{Raw: c.oldObj},
+				Operation: c.operation,
+			})
+			if got.Allowed != c.allowed {
+				t.Fatalf("got %v want %v", got.Allowed, c.allowed)
+			}
+		})
+	}
+}
+
+// fakeDecoder decodes JSON objects and records the number of decoded objects.
+type fakeDecoder struct {
+	decoded int
+	err     error
+}
+
+func (d *fakeDecoder) Decode(data []byte, _ *kubeschema.GroupVersionKind, into runtime.Object) (
+	runtime.Object, *kubeschema.GroupVersionKind, error) {
+	if d.err != nil {
+		return nil, nil, d.err
+	}
+	if err := json.Unmarshal(data, into); err != nil {
+		return nil, nil, err
+	}
+	d.decoded++
+	return into, nil, nil
+}
+
+func TestReloadValidators(t *testing.T) {
+	pilotConfig := makePilotConfig(t, 0, true, false)
+	mixerConfig := makeMixerConfig(t, 0, false)
+
+	wh, cancel := createTestWebhook(t, dummyClient, createFakeEndpointsSource(), dummyConfig)
+	defer cancel()
+	wh.groupAliases = map[string]string{"old.istio.io": "test.istio.io"}
+
+	if err := wh.ReloadValidators(schemas.Istio, &fakeValidator{}); err == nil {
+		t.Fatal("ReloadValidators() should fail for a descriptor without the aliased group")
+	}
+	if got := wh.activeValidators().descriptor; len(got) != len(mock.Types) {
+		t.Fatalf("failed reload replaced the descriptor: got %v", got)
+	}
+
+	var reloader, admitters sync.WaitGroup
+	stop := make(chan struct{})
+	reloader.Add(1)
+	go func() {
+		defer reloader.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			descriptor := append(schema.Set{}, mock.Types...)
+			if err := wh.ReloadValidators(descriptor, &fakeValidator{}); err != nil {
+				t.Errorf("ReloadValidators() failed: %v", err)
+				return
+			}
+		}
+	}()
+
+	for i := 0; i < 4; i++ {
+		admitters.Add(1)
+		go func() {
+			defer admitters.Done()
+			for j := 0; j < 100; j++ {
+				if got := wh.admitPilot(context.Background(), &admissionv1beta1.AdmissionRequest{
+					Kind:      metav1.GroupVersionKind{Kind: "mock"},
+					Object:    runtime.RawExtension{Raw: pilotConfig},
+					Operation: admissionv1beta1.Create,
+				}); !got.Allowed {
+					t.Errorf("pilot config rejected during reload: %v", got.Result)
+					return
+				}
+				if got := wh.admitMixer(context.Background(), &admissionv1beta1.AdmissionRequest{
+					Kind:      metav1.GroupVersionKind{Kind: "mock"},
+					Object:    runtime.RawExtension{Raw: mixerConfig},
+					Operation: admissionv1beta1.Create,
+				}); !got.Allowed {
+					t.Errorf("mixer config rejected during reload: %v", got.Result)
+					return
+				}
+			}
+		}()
+	}
+
+	admitters.Wait()
+	close(stop)
+	reloader.Wait()
+}
+
+func TestAdmitCustomDecoder(t *testing.T) {
+	valid := makePilotConfig(t, 0, true, false)
+	mixerConfig := makeMixerConfig(t, 0, false)
+
+	wh, cancel := createTestWebhook(t, dummyClient, createFakeEndpointsSource(), dummyConfig)
+	defer cancel()
+
+	cases := []struct {
+		name    string
+		admit   admitFunc
+		raw     []byte
+		decoder *fakeDecoder
+		allowed bool
+	}{
+		{
+			name:    "pilot",
+			admit:   wh.admitPilot,
+			raw:     valid,
+			decoder: &fakeDecoder{},
+			allowed: true,
+		},
+		{
+			name:    "pilot decode error",
+			admit:   wh.admitPilot,
+			raw:     valid,
+			decoder: &fakeDecoder{err: errors.New("unknown type")},
+			allowed: false,
+		},
+		{
+			name:    "mixer",
+			admit:   wh.admitMixer,
+			raw:     mixerConfig,
+			decoder: &fakeDecoder{},
+			allowed: true,
+		},
+		{
+			name:    "mixer decode error",
+			admit:   wh.admitMixer,
+			raw:     mixerConfig,
+			decoder: &fakeDecoder{err: errors.New("unknown type")},
+			allowed: false,
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			wh.decoder = c.decoder
+			got := c.admit(context.Background(), &admissionv1beta1.AdmissionRequest{
+				Kind:      metav1.GroupVersionKind{Kind: "mock"},
+				Object:    runtime.RawExtension{Raw: c.raw},
+				Operation: admissionv1beta1.Create,
+			})
+			if got.Allowed != c.allowed {
+				t.Fatalf("got %v want %v", got.Allowed, c.allowed)
+			}
+			if c.decoder.err == nil && c.decoder.decoded != 1 {
+				t.Fatalf("custom decoder decoded %d objects, want 1", c.decoder.decoded)
+			}
+		})
+	}
+}
+
 func makeTestReview(t *testing.T, valid bool) []byte {
 	t.Helper()
 	review := admissionv1beta1.AdmissionReview{
@@ -546,6 +1030,38 @@ func TestServe_Basic(t *testing.T) {
 	}
 }
 
+func TestServeListenError(t *testing.T) {
+	wh, cleanup := createTestWebhook(t,
+		fake.NewSimpleClientset(),
+		createFakeEndpointsSource(),
+		dummyConfig)
+	defer cleanup()
+
+	// occupy the webhook port
+	l, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("Listen() failed: %v", err)
+	}
+	defer l.Close() // nolint: errcheck
+	wh.server.Addr = l.Addr().String()
+
+	stop := make(chan struct{})
+	defer close(stop)
+	errCh := make(chan error)
+	go func() {
+		errCh <- wh.Serve(make(chan struct{}), stop)
+	}()
+
+	select {
+	case err := <-errCh:
+		if err == nil || !strings.Contains(err.Error(), "admission webhook listen failed") {
+			t.Fatalf("got %v want listen error", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Serve() did not return the listen error within 10 seconds")
+	}
+}
+
 func TestServe(t *testing.T) {
 	wh, cleanup := createTestWebhook(t,
 		fake.NewSimpleClientset(),
@@ -563,10 +1079,23 @@ func TestServe(t *testing.T) {
 	validReview := makeTestReview(t, true)
 	invalidReview := makeTestReview(t, false)
 
+	gzipped := func(data []byte) []byte {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		if _, err := gz.Write(data); err != nil {
+			t.Fatalf("could not compress body: %v", err)
+		}
+		if err := gz.Close(); err != nil {
+			t.Fatalf("could not compress body: %v", err)
+		}
+		return buf.Bytes()
+	}
+
 	cases := []struct {
 		name            string
 		body            []byte
 		contentType     string
+		contentEncoding string
 		wantStatusCode  int
 		wantAllowed     bool
 		allowedResponse bool
@@ -607,17 +1136,45 @@ func TestServe(t *testing.T) {
 			wantAllowed:    false,
 			wantStatusCode: http.StatusBadRequest,
 		},
+		{
+			name:            "gzip encoded",
+			body:            gzipped(validReview),
+			contentType:     "application/json",
+			contentEncoding: "gzip",
+			wantAllowed:     true,
+			wantStatusCode:  http.StatusOK,
+			allowedResponse: true,
+		},
+		{
+			name:            "bad gzip content",
+			body:            []byte{0, 1, 2, 3, 4, 5}, // random data
+			contentType:     "application/json",
+			contentEncoding: "gzip",
+			wantAllowed:     false,
+			wantStatusCode:  http.StatusBadRequest,
+		},
+		{
+			name:            "gzip encoded too large",
+			body:            gzipped(make([]byte, maxRequestBytes+1)),
+			contentType:     "application/json",
+			contentEncoding: "gzip",
+			wantAllowed:     false,
+			wantStatusCode:  http.StatusRequestEntityTooLarge,
+		},
 	}
 
 	for i, c := range cases {
 		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
 			req := httptest.NewRequest("POST", "http://validator", bytes.NewReader(c.body))
 			req.Header.Add("Content-Type", c.contentType)
+			if c.contentEncoding != "" {
+				req.Header.Add("Content-Encoding", c.contentEncoding)
+			}
 			w := httptest.NewRecorder()
 
-			serve(w, req, func(*admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+			serve(w, req, func(context.Context, *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
 				return &admissionv1beta1.AdmissionResponse{Allowed: c.allowedResponse}
-			})
+			}, 0, 0)
 
 			res := w.Result()
 
@@ -645,6 +1202,368 @@ func TestServe(t *testing.T) {
 	}
 }
 
+func TestServe_AdmissionReviewVersions(t *testing.T) {
+	raw := makePilotConfig(t, 0, true, false)
+
+	v1beta1Review, err := json.Marshal(admissionv1beta1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1beta1", Kind: "AdmissionReview"},
+		Request: &admissionv1beta1.AdmissionRequest{
+			UID:       "v1beta1-uid",
+			Object:    runtime.RawExtension{Raw: raw},
+			Operation: admissionv1beta1.Create,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create v1beta1 AdmissionReview: %v", err)
+	}
+	v1Review, err := json.Marshal(admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+		Request: &admissionv1.AdmissionRequest{
+			UID:       "v1-uid",
+			Object:    runtime.RawExtension{Raw: raw},
+			Operation: admissionv1.Create,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create v1 AdmissionReview: %v", err)
+	}
+
+	cases := []struct {
+		name           string
+		body           []byte
+		wantAPIVersion string
+		wantUID        string
+	}{
+		{
+			name:           "v1beta1",
+			body:           v1beta1Review,
+			wantAPIVersion: "admission.k8s.io/v1beta1",
+			wantUID:        "v1beta1-uid",
+		},
+		{
+			name:           "v1",
+			body:           v1Review,
+			wantAPIVersion: "admission.k8s.io/v1",
+			wantUID:        "v1-uid",
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			req := httptest.NewRequest("POST", "http://validator", bytes.NewReader(c.body))
+			req.Header.Add("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			var gotOperation admissionv1beta1.Operation
+			serve(w, req, func(_ context.Context, request *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+				gotOperation = request.Operation
+				return &admissionv1beta1.AdmissionResponse{Allowed: true}
+			}, 0, 0)
+
+			res := w.Result()
+			if res.StatusCode != http.StatusOK {
+				t.Fatalf("wrong status code: got %v want %v", res.StatusCode, http.StatusOK)
+			}
+			if gotOperation != admissionv1beta1.Create {
+				t.Fatalf("wrong operation passed to admit: got %v want %v", gotOperation, admissionv1beta1.Create)
+			}
+
+			gotBody, err := ioutil.ReadAll(res.Body)
+			if err != nil {
+				t.Fatalf("could not read body: %v", err)
+			}
+			var gotReview admissionv1.AdmissionReview
+			if err := json.Unmarshal(gotBody, &gotReview); err != nil {
+				t.Fatalf("could not decode response body: %v", err)
+			}
+			if gotReview.APIVersion != c.wantAPIVersion {
+				t.Fatalf("wrong apiVersion: got %v want %v", gotReview.APIVersion, c.wantAPIVersion)
+			}
+			if gotReview.Kind != "AdmissionReview" {
+				t.Fatalf("wrong kind: got %v want AdmissionReview", gotReview.Kind)
+			}
+			if gotReview.Response == nil {
+				t.Fatal("missing response")
+			}
+			if string(gotReview.Response.UID) != c.wantUID {
+				t.Fatalf("wrong uid: got %v want %v", gotReview.Response.UID, c.wantUID)
+			}
+			if !gotReview.Response.Allowed {
+				t.Fatal("AdmissionReview.Response.Allowed is wrong: got false want true")
+			}
+		})
+	}
+}
+
+func TestServe_EchoesRequestUID(t *testing.T) {
+	review := func(request *admissionv1beta1.AdmissionRequest) []byte {
+		t.Helper()
+		body, err := json.Marshal(admissionv1beta1.AdmissionReview{
+			TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1beta1", Kind: "AdmissionReview"},
+			Request:  request,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create AdmissionReview: %v", err)
+		}
+		return body
+	}
+	request := &admissionv1beta1.AdmissionRequest{UID: "request-uid", Operation: admissionv1beta1.Create}
+
+	cases := []struct {
+		name        string
+		body        []byte
+		response    *admissionv1beta1.AdmissionResponse
+		wantUID     string
+		wantAllowed bool
+	}{
+		{
+			name:        "response without uid",
+			body:        review(request),
+			response:    &admissionv1beta1.AdmissionResponse{Allowed: true},
+			wantUID:     "request-uid",
+			wantAllowed: true,
+		},
+		{
+			name:        "response with another uid",
+			body:        review(request),
+			response:    &admissionv1beta1.AdmissionResponse{UID: "other-uid", Allowed: true},
+			wantUID:     "request-uid",
+			wantAllowed: true,
+		},
+		{
+			name:    "no response",
+			body:    review(request),
+			wantUID: "request-uid",
+		},
+		{
+			name:     "no request",
+			body:     review(nil),
+			response: &admissionv1beta1.AdmissionResponse{Allowed: true},
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			req := httptest.NewRequest("POST", "http://validator", bytes.NewReader(c.body))
+			req.Header.Add("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			serve(w, req, func(context.Context, *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+				return c.response
+			}, 0, 0)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("wrong status code: got %v want %v", w.Code, http.StatusOK)
+			}
+			var gotReview admissionv1beta1.AdmissionReview
+			if err := json.Unmarshal(w.Body.Bytes(), &gotReview); err != nil {
+				t.Fatalf("could not decode response body: %v", err)
+			}
+			if gotReview.Response == nil {
+				t.Fatal("missing response")
+			}
+			if string(gotReview.Response.UID) != c.wantUID {
+				t.Fatalf("wrong uid: got %v want %v", gotReview.Response.UID, c.wantUID)
+			}
+			if gotReview.Response.Allowed != c.wantAllowed {
+				t.Fatalf("got allowed %v want %v", gotReview.Response.Allowed, c.wantAllowed)
+			}
+		})
+	}
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	cases := []struct {
+		header string
+		want   bool
+	}{
+		{header: "", want: false},
+		{header: "gzip", want: true},
+		{header: "GZIP", want: true},
+		{header: "deflate, gzip;q=0.5", want: true},
+		{header: "gzip;q=0", want: false},
+		{header: "*", want: true},
+		{header: "deflate, br", want: false},
+		{header: "identity", want: false},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %q", i, c.header), func(t *testing.T) {
+			if got := acceptsGzip(c.header); got != c.want {
+				t.Fatalf("got %v want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestServe_ResponseCompression(t *testing.T) {
+	review, err := json.Marshal(admissionv1beta1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1beta1", Kind: "AdmissionReview"},
+		Request:  &admissionv1beta1.AdmissionRequest{UID: "uid", Operation: admissionv1beta1.Create},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create AdmissionReview: %v", err)
+	}
+	reason := strings.Repeat("configuration is invalid; ", 100)
+
+	cases := []struct {
+		name           string
+		acceptEncoding string
+		compressAbove  int
+		wantGzip       bool
+	}{
+		{
+			name:           "compressed",
+			acceptEncoding: "gzip",
+			compressAbove:  1024,
+			wantGzip:       true,
+		},
+		{
+			name:          "gzip not accepted",
+			compressAbove: 1024,
+		},
+		{
+			name:           "below threshold",
+			acceptEncoding: "gzip",
+			compressAbove:  1 << 20,
+		},
+		{
+			name:           "compression disabled",
+			acceptEncoding: "gzip",
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			req := httptest.NewRequest("POST", "http://validator", bytes.NewReader(review))
+			req.Header.Set("Content-Type", "application/json")
+			if c.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", c.acceptEncoding)
+			}
+			w := httptest.NewRecorder()
+
+			serve(w, req, func(context.Context, *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+				return toAdmissionResponse(errors.New(reason))
+			}, c.compressAbove, 0)
+
+			res := w.Result()
+			if res.StatusCode != http.StatusOK {
+				t.Fatalf("wrong status code: got %v want %v", res.StatusCode, http.StatusOK)
+			}
+			body := io.Reader(res.Body)
+			if gotGzip := res.Header.Get("Content-Encoding") == "gzip"; gotGzip != c.wantGzip {
+				t.Fatalf("got gzip encoding %v want %v", gotGzip, c.wantGzip)
+			} else if gotGzip {
+				gz, err := gzip.NewReader(res.Body)
+				if err != nil {
+					t.Fatalf("could not decompress body: %v", err)
+				}
+				body = gz
+			}
+
+			var gotReview admissionv1beta1.AdmissionReview
+			if err := json.NewDecoder(body).Decode(&gotReview); err != nil {
+				t.Fatalf("could not decode response body: %v", err)
+			}
+			if gotReview.Response == nil || gotReview.Response.Result == nil || gotReview.Response.Result.Message != reason {
+				t.Fatalf("got response %v want rejection %q", gotReview.Response, reason)
+			}
+		})
+	}
+}
+
+func TestListen(t *testing.T) {
+	wh, cleanup := createTestWebhook(t,
+		fake.NewSimpleClientset(),
+		createFakeEndpointsSource(),
+		dummyConfig)
+	defer cleanup()
+
+	var controlCalled bool
+	wh.listenConfig = &net.ListenConfig{
+		Control: func(network, address string, c syscall.RawConn) error {
+			controlCalled = true
+			return nil
+		},
+	}
+	wh.listenBacklog = 16
+
+	listener, err := wh.listen()
+	if err != nil {
+		t.Fatalf("listen() failed: %v", err)
+	}
+	defer listener.Close() // nolint: errcheck
+
+	if !controlCalled {
+		t.Fatal("custom ListenConfig was not used to create the listener")
+	}
+}
+
+func TestListen_BindAddress(t *testing.T) {
+	wh, cleanup := createTestWebhook(t,
+		fake.NewSimpleClientset(),
+		createFakeEndpointsSource(),
+		dummyConfig)
+	defer cleanup()
+
+	boundWh, err := NewWebhook(WebhookParameters{
+		CertFile:    wh.certFile,
+		KeyFile:     wh.keyFile,
+		BindAddress: "127.0.0.1",
+	})
+	if err != nil {
+		t.Fatalf("NewWebhook() failed: %v", err)
+	}
+	defer boundWh.Stop()
+
+	listener, err := boundWh.listen()
+	if err != nil {
+		t.Fatalf("listen() failed: %v", err)
+	}
+	defer listener.Close() // nolint: errcheck
+
+	if addr := listener.Addr().(*net.TCPAddr); !addr.IP.Equal(net.ParseIP("127.0.0.1")) {
+		t.Fatalf("got listener address %v want 127.0.0.1", addr)
+	}
+}
+
+func TestStatusMux(t *testing.T) {
+	wh, cleanup := createTestWebhook(t,
+		fake.NewSimpleClientset(),
+		createFakeEndpointsSource(),
+		dummyConfig)
+	defer cleanup()
+
+	if wh.statusServer != nil || wh.statusMux != wh.server.Handler {
+		t.Fatal("status endpoints should be served by the admission server when no status port is set")
+	}
+
+	options := WebhookParameters{
+		CertFile:   wh.certFile,
+		KeyFile:    wh.keyFile,
+		StatusPort: 15014,
+	}
+	statusWh, err := NewWebhook(options)
+	if err != nil {
+		t.Fatalf("NewWebhook() failed: %v", err)
+	}
+	defer statusWh.Stop()
+	if statusWh.statusServer == nil || statusWh.statusServer.Addr != ":15014" {
+		t.Fatalf("status server not configured: %v", statusWh.statusServer)
+	}
+
+	for name, handler := range map[string]http.Handler{
+		"admission": statusWh.server.Handler,
+		"status":    statusWh.statusServer.Handler,
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", httpsHandlerReadyPath, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s server readiness returned %v", name, w.Code)
+		}
+	}
+}
+
 func checkCert(t *testing.T, whc *Webhook, cert, key []byte) bool {
 	t.Helper()
 	actual := whc.cert
@@ -655,6 +1574,149 @@ func checkCert(t *testing.T, whc *Webhook, cert, key []byte) bool {
 	return bytes.Equal(actual.Certificate[0], expected.Certificate[0])
 }
 
+func TestLoadCertPool(t *testing.T) {
+	dir, err := ioutil.TempDir("", "galley_validation_webhook")
+	if err != nil {
+		t.Fatalf("TempDir() failed: %v", err)
+	}
+	defer os.RemoveAll(dir) // nolint: errcheck
+
+	caFile := filepath.Join(dir, "ca.pem")
+	if err := ioutil.WriteFile(caFile, testcerts.CACert, 0644); err != nil {
+		t.Fatalf("WriteFile(%v) failed: %v", caFile, err)
+	}
+	bogusFile := filepath.Join(dir, "bogus.pem")
+	if err := ioutil.WriteFile(bogusFile, []byte("bogus"), 0644); err != nil {
+		t.Fatalf("WriteFile(%v) failed: %v", bogusFile, err)
+	}
+
+	cases := []struct {
+		name    string
+		file    string
+		wantErr string
+	}{
+		{name: "valid", file: caFile},
+		{name: "missing", file: filepath.Join(dir, "missing.pem"), wantErr: "could not read client CA file"},
+		{name: "no certificates", file: bogusFile, wantErr: "no certificates found"},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			pool, err := loadCertPool(c.file)
+			if c.wantErr == "" {
+				if err != nil || pool == nil {
+					t.Fatalf("got pool %v error %v want pool", pool, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+				t.Fatalf("got %v want error containing %q", err, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckCertDNSNames(t *testing.T) {
+	names := serviceDNSNames("istio-galley", "istio-system")
+
+	cases := []struct {
+		name     string
+		dnsNames []string
+		wantErr  bool
+	}{
+		{name: "matching SAN", dnsNames: []string{"istio-galley", "istio-galley.istio-system.svc"}},
+		{name: "wildcard SAN", dnsNames: []string{"*.istio-system.svc"}},
+		{name: "no SANs", wantErr: true},
+		{name: "wrong namespace", dnsNames: []string{"istio-galley.default.svc"}, wantErr: true},
+		{name: "missing svc suffix", dnsNames: []string{"istio-galley.istio-system"}, wantErr: true},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			cert := &x509.Certificate{DNSNames: c.dnsNames}
+			err := checkCertDNSNames(cert, names)
+			if c.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "istio-galley.istio-system.svc") {
+					t.Fatalf("got %v want error naming the service DNS name", err)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestCheckCertValidity(t *testing.T) {
+	notBefore := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	cert := &x509.Certificate{
+		NotBefore: notBefore,
+		NotAfter:  notBefore.Add(24 * time.Hour),
+	}
+
+	cases := []struct {
+		name    string
+		now     time.Time
+		skew    time.Duration
+		wantErr string
+	}{
+		{name: "valid", now: notBefore.Add(time.Hour)},
+		{name: "not yet valid", now: notBefore.Add(-time.Minute), wantErr: "not valid until"},
+		{name: "not yet valid within skew", now: notBefore.Add(-time.Minute), skew: 2 * time.Minute},
+		{name: "expired", now: cert.NotAfter.Add(time.Minute), wantErr: "expired"},
+		{name: "expired within skew", now: cert.NotAfter.Add(time.Minute), skew: 2 * time.Minute},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			err := checkCertValidity(cert, c.now, c.skew)
+			if c.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+				t.Fatalf("got %v want error containing %q", err, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckCertExpiry(t *testing.T) {
+	notAfter := time.Date(2019, 1, 8, 0, 0, 0, 0, time.UTC)
+	cert := &x509.Certificate{
+		NotBefore: notAfter.Add(-7 * 24 * time.Hour),
+		NotAfter:  notAfter,
+	}
+
+	cases := []struct {
+		name      string
+		now       time.Time
+		threshold time.Duration
+		wantErr   string
+	}{
+		{name: "outside threshold", now: notAfter.Add(-48 * time.Hour), threshold: 24 * time.Hour},
+		{name: "within threshold", now: notAfter.Add(-time.Hour), threshold: 24 * time.Hour, wantErr: "expires at 2019-01-08T00:00:00Z, in 1h0m0s"},
+		{name: "no threshold", now: notAfter.Add(-time.Hour)},
+		{name: "expired", now: notAfter.Add(time.Hour), threshold: 24 * time.Hour},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			err := checkCertExpiry(cert, c.now, c.threshold)
+			if c.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+				t.Fatalf("got %v want error containing %q", err, c.wantErr)
+			}
+		})
+	}
+}
+
 func TestReloadCert(t *testing.T) {
 	wh, cleanup := createTestWebhook(t,
 		fake.NewSimpleClientset(),
@@ -15,11 +15,12 @@
 package components
 
 import (
+	"sync"
+
 	"k8s.io/client-go/kubernetes"
 
 	"istio.io/istio/galley/pkg/crd/validation"
 	"istio.io/istio/galley/pkg/server/process"
-	"istio.io/istio/pkg/cmd"
 	"istio.io/pkg/probe"
 )
 
@@ -27,24 +28,37 @@ import (
 func NewValidation(kubeInterface kubernetes.Interface, kubeConfig string,
 	params *validation.WebhookParameters, liveness, readiness probe.Controller) process.Component {
 
+	stopCh := make(chan struct{})
+	var stopOnce sync.Once
+	stop := func() {
+		stopOnce.Do(func() { close(stopCh) })
+	}
+	validationDone := make(chan struct{})
+
 	return process.ComponentFromFns(
 		// start
 		func() error {
 			webhookServerReady := make(chan struct{})
-			stopCh := make(chan struct{})
 			if params.EnableValidation {
-				go validation.RunValidation(webhookServerReady, stopCh, params, kubeInterface, kubeConfig, liveness, readiness)
+				go func() {
+					validation.MustRunValidation(webhookServerReady, stopCh, params, kubeInterface, kubeConfig, liveness, readiness)
+					close(validationDone)
+				}()
+			} else {
+				close(validationDone)
 			}
 			if params.EnableReconcileWebhookConfiguration {
 				go validation.ReconcileWebhookConfiguration(webhookServerReady, stopCh, params, kubeConfig)
 			}
 			if params.EnableValidation || params.EnableReconcileWebhookConfiguration {
-				go cmd.WaitSignal(stopCh)
+				go validation.WaitSignal(stop)
 			}
 			return nil
 		},
 		// stop
 		func() {
-			// validation doesn't have a stop function.
+			// wait for in-flight admission requests to drain.
+			stop()
+			<-validationDone
 		})
 }
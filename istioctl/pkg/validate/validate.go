@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sort"
 	"strings"
 
 	"github.com/hashicorp/go-multierror"
@@ -74,6 +75,43 @@ Example resource specifications include:
 
 type validator struct {
 	mixerValidator mixerstore.BackendValidator
+
+	// DependencyAwareOrdering validates the objects of all files in dependency
+	// order, so that referenced objects are known when their referrers are
+	// validated, instead of in the order they appear.
+	DependencyAwareOrdering bool
+}
+
+// dependencyRank returns the rank of the object's kind in dependency order.
+// Objects of lower rank may be referenced by objects of higher rank, e.g. a
+// mixer rule references handlers and instances, and a VirtualService references
+// Gateways.
+func dependencyRank(un *unstructured.Unstructured) int {
+	if un.GetAPIVersion() == mixerAPIVersion {
+		switch un.GetKind() {
+		case constant.AdapterKind, constant.TemplateKind, constant.AttributeManifestKind:
+			return 0
+		case constant.RulesKind:
+			return 2
+		default:
+			// handlers, instances and legacy adapter and template kinds
+			return 1
+		}
+	}
+	switch un.GetKind() {
+	case "Gateway", "DestinationRule", "ServiceEntry":
+		return 1
+	default:
+		return 2
+	}
+}
+
+// sortByDependency orders the objects so that referenced kinds precede their
+// referrers. The order of objects of the same rank is kept.
+func sortByDependency(objects []unstructured.Unstructured) {
+	sort.SliceStable(objects, func(i, j int) bool {
+		return dependencyRank(&objects[i]) < dependencyRank(&objects[j])
+	})
 }
 
 func checkFields(un *unstructured.Unstructured) error {
@@ -199,43 +237,62 @@ func (v *validator) validateDeploymentLabel(istioNamespace string, un *unstructu
 }
 
 func (v *validator) validateFile(istioNamespace *string, reader io.Reader) error {
+	objects, errs := decodeFile(reader)
+	if err := v.validateObjects(istioNamespace, objects); err != nil {
+		errs = multierror.Append(err, errs)
+	}
+	return errs
+}
+
+// decodeFile returns the objects in the file, up to the first decoding error.
+func decodeFile(reader io.Reader) ([]unstructured.Unstructured, error) {
 	decoder := yaml.NewDecoder(reader)
-	var errs error
+	var objects []unstructured.Unstructured
 	for {
 		// YAML allows non-string keys and the produces generic keys for nested fields
 		raw := make(map[interface{}]interface{})
 		err := decoder.Decode(&raw)
 		if err == io.EOF {
-			return errs
+			return objects, nil
 		}
 		if err != nil {
-			errs = multierror.Append(errs, err)
-			return errs
+			return objects, multierror.Append(nil, err)
 		}
 		if len(raw) == 0 {
 			continue
 		}
 		out := transformInterfaceMap(raw)
-		un := unstructured.Unstructured{Object: out}
-		err = v.validateResource(*istioNamespace, &un)
+		objects = append(objects, unstructured.Unstructured{Object: out})
+	}
+}
+
+func (v *validator) validateObjects(istioNamespace *string, objects []unstructured.Unstructured) error {
+	var errs error
+	for i := range objects {
+		un := &objects[i]
+		err := v.validateResource(*istioNamespace, un)
 		if err != nil {
 			errs = multierror.Append(errs, multierror.Prefix(err, fmt.Sprintf("%s/%s/%s:",
 				un.GetKind(), un.GetNamespace(), un.GetName())))
 		}
 	}
+	return errs
 }
 
-func validateFiles(istioNamespace *string, filenames []string, referential bool, writer io.Writer) error {
+func validateFiles(istioNamespace *string, filenames []string, referential, dependencyAwareOrdering bool,
+	writer io.Writer) error {
 	if len(filenames) == 0 {
 		return errMissingFilename
 	}
 
 	v := &validator{
-		mixerValidator: mixervalidate.NewDefaultValidator(referential),
+		mixerValidator:          mixervalidate.NewDefaultValidator(referential),
+		DependencyAwareOrdering: dependencyAwareOrdering,
 	}
 
 	var errs, err error
 	var reader io.Reader
+	var objects []unstructured.Unstructured
 	for _, filename := range filenames {
 		if filename == "-" {
 			reader = os.Stdin
@@ -246,11 +303,27 @@ func validateFiles(istioNamespace *string, filenames []string, referential bool,
 			errs = multierror.Append(errs, fmt.Errorf("cannot read file %q: %v", filename, err))
 			continue
 		}
+		if v.DependencyAwareOrdering {
+			// the objects of all files are ordered together, since objects often
+			// reference objects in other files
+			decoded, err := decodeFile(reader)
+			if err != nil {
+				errs = multierror.Append(errs, err)
+			}
+			objects = append(objects, decoded...)
+			continue
+		}
 		err = v.validateFile(istioNamespace, reader)
 		if err != nil {
 			errs = multierror.Append(errs, err)
 		}
 	}
+	if v.DependencyAwareOrdering {
+		sortByDependency(objects)
+		if err := v.validateObjects(istioNamespace, objects); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+	}
 	if errs != nil {
 		return errs
 	}
@@ -270,6 +343,7 @@ func validateFiles(istioNamespace *string, filenames []string, referential bool,
 func NewValidateCommand(istioNamespace *string) *cobra.Command {
 	var filenames []string
 	var referential bool
+	var dependencyAwareOrdering bool
 
 	c := &cobra.Command{
 		Use:   "validate -f FILENAME [options]",
@@ -289,13 +363,15 @@ func NewValidateCommand(istioNamespace *string) *cobra.Command {
 `,
 		Args: cobra.NoArgs,
 		RunE: func(c *cobra.Command, _ []string) error {
-			return validateFiles(istioNamespace, filenames, referential, c.OutOrStderr())
+			return validateFiles(istioNamespace, filenames, referential, dependencyAwareOrdering, c.OutOrStderr())
 		},
 	}
 
 	flags := c.PersistentFlags()
 	flags.StringSliceVarP(&filenames, "filename", "f", nil, "Names of files to validate")
 	flags.BoolVarP(&referential, "referential", "x", true, "Enable structural validation for policy and telemetry")
+	flags.BoolVar(&dependencyAwareOrdering, "dependency-aware-ordering", false,
+		"Validate referenced kinds, e.g. Gateways and Mixer handlers, before the objects that reference them")
 
 	return c
 }
@@ -233,6 +233,30 @@ spec:
   - handler: handler-for-valid-rule.denier
     instances:
     - instance-for-valid-rule.checknothing`
+	validMixerRuleDependencies = `
+apiVersion: "config.istio.io/v1alpha2"
+kind: denier
+metadata:
+  name: handler-for-valid-rule
+spec:
+  status:
+    code: 7
+    message: denied
+---
+apiVersion: "config.istio.io/v1alpha2"
+kind: checknothing
+metadata:
+  name: instance-for-valid-rule
+spec:
+---
+apiVersion: "config.istio.io/v1alpha2"
+kind: attributemanifest
+metadata:
+  name: attributes-for-valid-rule
+spec:
+  attributes:
+    request.headers:
+      valueType: STRING_MAP`
 	invalidYAML = `
 (...!)`
 	validKubernetesYAML = `
@@ -395,6 +419,7 @@ func TestValidateCommand(t *testing.T) {
 	valid := buildMultiDocYAML([]string{validVirtualService, validVirtualService1})
 	invalid := buildMultiDocYAML([]string{invalidVirtualService, validVirtualService1})
 	unsupportedMixerRule := buildMultiDocYAML([]string{validVirtualService, validMixerRule})
+	mixerRuleBeforeDependencies := buildMultiDocYAML([]string{validMixerRule, validMixerRuleDependencies})
 
 	validFilename, closeValidFile := createTestFile(t, valid)
 	defer closeValidFile.Close()
@@ -405,6 +430,15 @@ func TestValidateCommand(t *testing.T) {
 	unsupportedMixerRuleFilename, closeMixerRuleFile := createTestFile(t, unsupportedMixerRule)
 	defer closeMixerRuleFile.Close()
 
+	mixerRuleBeforeDependenciesFilename, closeMixerRuleBeforeDependenciesFile := createTestFile(t, mixerRuleBeforeDependencies)
+	defer closeMixerRuleBeforeDependenciesFile.Close()
+
+	mixerRuleFilename, closeMixerRuleOnlyFile := createTestFile(t, validMixerRule)
+	defer closeMixerRuleOnlyFile.Close()
+
+	mixerRuleDependenciesFilename, closeMixerRuleDependenciesFile := createTestFile(t, validMixerRuleDependencies)
+	defer closeMixerRuleDependenciesFile.Close()
+
 	invalidYAMLFile, closeInvalidYAMLFile := createTestFile(t, invalidYAML)
 	defer closeInvalidYAMLFile.Close()
 
@@ -476,6 +510,20 @@ func TestValidateCommand(t *testing.T) {
 			args:      []string{"--filename", unsupportedMixerRuleFilename},
 			wantError: true,
 		},
+		{
+			name:      "mixer rule before its dependencies",
+			args:      []string{"--filename", mixerRuleBeforeDependenciesFilename},
+			wantError: true,
+		},
+		{
+			name: "mixer rule before its dependencies with dependency-aware ordering",
+			args: []string{"--filename", mixerRuleBeforeDependenciesFilename, "--dependency-aware-ordering"},
+		},
+		{
+			name: "mixer rule and dependencies in separate files with dependency-aware ordering",
+			args: []string{"--filename", mixerRuleFilename, "--filename", mixerRuleDependenciesFilename,
+				"--dependency-aware-ordering"},
+		},
 		{
 			name:      "invalid filename",
 			args:      []string{"--filename", "INVALID_FILE_NAME"},